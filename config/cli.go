@@ -5,6 +5,10 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 )
 
@@ -13,7 +17,18 @@ type CLIConfig struct {
 	LogLevel    string
 	Input       string
 	OutputsJSON string
+	DryRun      bool
+	Once        bool
+	Mode        string
 	ShowHelp    bool
+	// RequireExplicitOutput fails instead of falling back to ./output if no target is configured
+	RequireExplicitOutput bool
+	// File stability overrides, 0 keeps the configured value
+	MaxRetries      int
+	CheckInterval   int // Milliseconds
+	StabilityPeriod int // Milliseconds
+	// Flags given on the command line, to tell an explicit 0 from an absent flag
+	setFlags map[string]bool
 }
 
 // ParseCLI parses command line arguments and returns a CLIConfig
@@ -24,6 +39,13 @@ func ParseCLI() *CLIConfig {
 	flag.StringVar(&cfg.LogLevel, "log-level", "", "Set log level (DEBUG, INFO, WARN, ERROR)")
 	flag.StringVar(&cfg.Input, "input", "", "Set input directory")
 	flag.StringVar(&cfg.OutputsJSON, "outputs", "", "Set output targets as JSON array")
+	flag.BoolVar(&cfg.DryRun, "dry-run", false, "Log transfers without writing to targets or removing files")
+	flag.BoolVar(&cfg.RequireExplicitOutput, "require-explicit-output", false, "Fail instead of using ./output if no output target is configured")
+	flag.BoolVar(&cfg.Once, "once", false, "Process the files present in the input directory, then exit")
+	flag.StringVar(&cfg.Mode, "mode", "", "Remove (move) or keep (copy) delivered source files")
+	flag.IntVar(&cfg.MaxRetries, "max-retries", 0, "Maximum number of file stability checks")
+	flag.IntVar(&cfg.CheckInterval, "check-interval", 0, "File stability check interval in milliseconds")
+	flag.IntVar(&cfg.StabilityPeriod, "stability-period", 0, "Period a file must remain stable in milliseconds")
 	flag.BoolVar(&cfg.ShowHelp, "help", false, "Show help message")
 
 	// Also handle short forms and alternative help flags
@@ -43,6 +65,8 @@ func ParseCLI() *CLIConfig {
 
 	// Parse flags
 	flag.Parse()
+	cfg.setFlags = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { cfg.setFlags[f.Name] = true })
 
 	return cfg
 }
@@ -68,6 +92,31 @@ func (cli *CLIConfig) ApplyToCfg(cfg *EnvConfig) error {
 		cfg.Output = targets
 	}
 
+	// Apply dry run (the flag can only enable it)
+	if cli.DryRun {
+		cfg.DryRun = true
+	}
+	if cli.RequireExplicitOutput {
+		cfg.RequireExplicitOutput = true
+	}
+	if cli.Once {
+		cfg.Once = true
+	}
+	if cli.Mode != "" {
+		cfg.Mode = strings.ToLower(cli.Mode)
+	}
+
+	// Apply file stability parameters
+	if cli.MaxRetries > 0 {
+		cfg.FileStability.MaxRetries = cli.MaxRetries
+	}
+	if cli.CheckInterval > 0 {
+		cfg.FileStability.CheckInterval = cli.CheckInterval
+	}
+	if cli.StabilityPeriod > 0 {
+		cfg.FileStability.StabilityPeriod = cli.StabilityPeriod
+	}
+
 	return nil
 }
 
@@ -87,7 +136,7 @@ OPTIONS:
     
     --outputs JSON       Set output targets as JSON array
                         Format: [{"path":"./output1","type":"filesystem"},...]
-                        Supported types: filesystem, s3, sftp, ftp
+                        Supported types: filesystem, s3, sftp, ftp, metadata
                         
                         Filesystem example:
                         [{"path":"./backup","type":"filesystem"}]
@@ -101,6 +150,24 @@ OPTIONS:
                         [{"path":"sftp://server/path","type":"sftp",
                           "host":"server.com","username":"user","password":"pass"}]
     
+    --dry-run            Log transfers without writing to targets or removing files
+                        S3 targets still check the connection and the bucket
+
+    --require-explicit-output
+                        Fail at startup if no output target is configured
+                        instead of using the filesystem target ./output
+
+    --once               Process the files present in the input directory, then exit
+                        instead of watching it
+
+    --mode MODE          move (default): remove delivered source files
+                        copy: keep them in the input directory
+
+    --max-retries N      Maximum number of file stability checks (default: 30)
+    --check-interval MS  File stability check interval in milliseconds (default: 1000)
+    --stability-period MS
+                        Period a file must remain stable in milliseconds (default: 1000)
+
     -h, --help           Show this help message
 
 EXAMPLES:
@@ -122,6 +189,9 @@ CONFIGURATION PRIORITY:
 ENVIRONMENT VARIABLES:
     LOG_LEVEL            Same as --log-level
     INPUT                Same as --input  
+    DRY_RUN              Same as --dry-run
+    REQUIRE_EXPLICIT_OUTPUT
+                         Same as --require-explicit-output
     OUTPUT_1_PATH        First output target path
     OUTPUT_1_TYPE        First output target type
     ...                  Additional OUTPUT_X_* variables
@@ -153,6 +223,19 @@ func (cli *CLIConfig) Validate() error {
 		return err
 	}
 
+	for _, option := range []struct {
+		flag  string
+		value int
+	}{
+		{"max-retries", cli.MaxRetries},
+		{"check-interval", cli.CheckInterval},
+		{"stability-period", cli.StabilityPeriod},
+	} {
+		if option.value < 0 || (option.value == 0 && cli.setFlags[option.flag]) {
+			return fmt.Errorf("invalid --%s: %d (must be positive)", option.flag, option.value)
+		}
+	}
+
 	return nil
 }
 
@@ -195,9 +278,129 @@ func validateOutputTarget(target OutputTarget, index int) error {
 	if target.Type == "" {
 		return fmt.Errorf("output target %d: 'type' is required", index+1)
 	}
-	if target.Type != "filesystem" && target.Type != "s3" && target.Type != "sftp" && target.Type != "ftp" {
-		return fmt.Errorf("output target %d: invalid type '%s' (allowed: filesystem, s3, sftp, ftp)", index+1, target.Type)
+	if target.Type != "filesystem" && target.Type != "s3" && target.Type != "sftp" && target.Type != "ftp" && target.Type != "metadata" && target.Type != "kafka" {
+		return fmt.Errorf("output target %d: invalid type '%s' (allowed: filesystem, s3, sftp, ftp, metadata, kafka)", index+1, target.Type)
+	}
+
+	return nil
+}
+
+// validateOutputTargetFields checks the type-specific required fields of a target
+func validateOutputTargetFields(target OutputTarget, index int) error {
+	var missing []string
+
+	switch target.Type {
+	case "s3":
+		s3Config := target.GetS3Config()
+		missing = missingFields(map[string]string{
+			"endpoint":   s3Config.Endpoint,
+			"access-key": s3Config.AccessKey,
+			"secret-key": s3Config.SecretKey,
+			"region":     s3Config.Region,
+		})
+		if target.ACL != "" && !slices.Contains(S3CannedACLs, target.ACL) {
+			return fmt.Errorf("output target %d (s3): invalid acl '%s' (allowed: %s)", index+1, target.ACL, strings.Join(S3CannedACLs, ", "))
+		}
+	case "ftp", "sftp":
+		ftpConfig := target.GetFTPConfig()
+		missing = missingFields(map[string]string{
+			"host":     ftpConfig.Host,
+			"username": ftpConfig.Username,
+			"password": ftpConfig.Password,
+		})
+		if ftpConfig.PathSeparator != "" && ftpConfig.PathSeparator != "/" && ftpConfig.PathSeparator != `\` {
+			return fmt.Errorf("output target %d (%s): invalid remote-path-separator '%s' (allowed: /, \\)", index+1, target.Type, ftpConfig.PathSeparator)
+		}
+		if target.Type == "sftp" && ftpConfig.PathSeparator == `\` {
+			return fmt.Errorf("output target %d (sftp): remote-path-separator is only supported for ftp targets", index+1)
+		}
+	case "kafka":
+		kafkaConfig := target.GetKafkaConfig()
+		if len(kafkaConfig.Brokers) == 0 || kafkaConfig.Topic == "" {
+			return fmt.Errorf("output target %d (kafka): path must look like kafka://broker:9092/topic, got '%s'", index+1, target.Path)
+		}
+		if target.MaxInlineBytes < 0 {
+			return fmt.Errorf("output target %d (kafka): max-inline-bytes must not be negative", index+1)
+		}
+	case "filesystem":
+		if info, err := os.Stat(target.Path); err == nil && !info.IsDir() {
+			return fmt.Errorf("output target %d (filesystem): %w: %s", index+1, ErrTargetPathIsFile, target.Path)
+		}
+	}
+
+	if err := validatePrecreateDirs(target, index); err != nil {
+		return err
+	}
+	if err := validateRename(target, index); err != nil {
+		return err
+	}
+	if err := validateBatch(target, index); err != nil {
+		return err
+	}
+	if _, err := ParseSchedule(target.Schedule); err != nil {
+		return fmt.Errorf("output target %d (%s): %w", index+1, target.Type, err)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("output target %d (%s): missing required field(s): %s", index+1, target.Type, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// validatePrecreateDirs only allows relative directories below the target on types that have directories
+func validatePrecreateDirs(target OutputTarget, index int) error {
+	if len(target.PrecreateDirs) == 0 {
+		return nil
+	}
+	if target.Type == "metadata" || target.Type == "kafka" {
+		return fmt.Errorf("output target %d (%s): precreate-dirs is only supported for filesystem, s3, sftp and ftp targets", index+1, target.Type)
+	}
+	for _, dir := range target.PrecreateDirs {
+		if !filepath.IsLocal(filepath.FromSlash(dir)) {
+			return fmt.Errorf("output target %d (%s): invalid precreate-dirs entry '%s': must be a relative path inside the target", index+1, target.Type, dir)
+		}
+	}
+	return nil
+}
+
+// validateRename requires a valid RenameRegex, RenameReplace alone would have no effect
+func validateRename(target OutputTarget, index int) error {
+	if target.RenameRegex == "" {
+		if target.RenameReplace != "" {
+			return fmt.Errorf("output target %d (%s): rename-replace requires rename-regex", index+1, target.Type)
+		}
+		return nil
 	}
+	if _, err := regexp.Compile(target.RenameRegex); err != nil {
+		return fmt.Errorf("output target %d (%s): invalid rename-regex '%s': %w", index+1, target.Type, target.RenameRegex, err)
+	}
+	return nil
+}
 
+// validateBatch only allows batches on S3 targets and requires an interval for a file limit
+func validateBatch(target OutputTarget, index int) error {
+	if target.BatchInterval == 0 && target.BatchMaxFiles == 0 {
+		return nil
+	}
+	if target.Type != "s3" {
+		return fmt.Errorf("output target %d (%s): batch-interval is only supported for s3 targets", index+1, target.Type)
+	}
+	if target.BatchInterval < 0 || target.BatchMaxFiles < 0 {
+		return fmt.Errorf("output target %d (s3): batch-interval and batch-max-files must not be negative", index+1)
+	}
+	if target.BatchInterval == 0 {
+		return fmt.Errorf("output target %d (s3): batch-max-files requires batch-interval", index+1)
+	}
 	return nil
 }
+
+func missingFields(fields map[string]string) []string {
+	var missing []string
+	for name, value := range fields {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -98,6 +98,65 @@ func TestParseCLI(t *testing.T) {
 	}
 }
 
+func TestParseCLI_FileStabilityFlags(t *testing.T) {
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"test", "--max-retries", "5", "--check-interval", "250", "--stability-period", "500"}
+
+	result := ParseCLI()
+
+	if result.MaxRetries != 5 || result.CheckInterval != 250 || result.StabilityPeriod != 500 {
+		t.Errorf("file stability flags = (%d, %d, %d), want (5, 250, 500)",
+			result.MaxRetries, result.CheckInterval, result.StabilityPeriod)
+	}
+}
+
+func TestParseCLI_FileStabilityFlagZero(t *testing.T) {
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"test", "--max-retries", "0"}
+
+	result := ParseCLI()
+
+	if err := result.Validate(); err == nil || !strings.Contains(err.Error(), "--max-retries") {
+		t.Errorf("Validate() error = %v, want an error for an explicit --max-retries 0", err)
+	}
+}
+
+func TestCLIConfig_ApplyToCfg_FileStabilityPrecedence(t *testing.T) {
+	originalEnv := backupEnvironment()
+	defer restoreEnvironment(originalEnv)
+	clearTestEnvironment()
+
+	t.Setenv("FILE_STABILITY_MAX_RETRIES", "10")
+	t.Setenv("FILE_STABILITY_CHECK_INTERVAL", "2000")
+	t.Setenv("FILE_STABILITY_PERIOD", "3000")
+
+	cfg := &EnvConfig{}
+	cfg.SetDefaults()
+	if err := cfg.LoadFromEnvironment(); err != nil {
+		t.Fatalf("LoadFromEnvironment() error = %v", err)
+	}
+
+	// Only the given flags override the environment values
+	cli := &CLIConfig{MaxRetries: 5, StabilityPeriod: 500}
+	if err := cli.ApplyToCfg(cfg); err != nil {
+		t.Fatalf("ApplyToCfg() error = %v", err)
+	}
+
+	if cfg.FileStability.MaxRetries != 5 {
+		t.Errorf("MaxRetries = %d, want 5 (CLI)", cfg.FileStability.MaxRetries)
+	}
+	if cfg.FileStability.CheckInterval != 2000 {
+		t.Errorf("CheckInterval = %d, want 2000 (env)", cfg.FileStability.CheckInterval)
+	}
+	if cfg.FileStability.StabilityPeriod != 500 {
+		t.Errorf("StabilityPeriod = %d, want 500 (CLI)", cfg.FileStability.StabilityPeriod)
+	}
+}
+
 func TestCLIConfig_ApplyToCfg(t *testing.T) { // NOSONAR - deckt viele CLI-Kombinationen ab
 	tests := []struct {
 		name     string
@@ -148,6 +207,21 @@ func TestCLIConfig_ApplyToCfg(t *testing.T) { // NOSONAR - deckt viele CLI-Kombi
 			},
 			wantErr: false,
 		},
+		{
+			name: "CLI overrides mode",
+			cli: &CLIConfig{
+				Mode: "COPY",
+			},
+			initial: &EnvConfig{
+				Input: "./input",
+				Mode:  ModeMove,
+			},
+			expected: &EnvConfig{
+				Input: "./input",
+				Mode:  ModeCopy,
+			},
+			wantErr: false,
+		},
 		{
 			name: "CLI overrides input directory",
 			cli: &CLIConfig{
@@ -468,6 +542,45 @@ func TestCLIConfig_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid file stability parameters",
+			cli: &CLIConfig{
+				MaxRetries:      5,
+				CheckInterval:   250,
+				StabilityPeriod: 500,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "negative max retries",
+			cli:     &CLIConfig{MaxRetries: -1},
+			wantErr: true,
+			errMsg:  "--max-retries",
+		},
+		{
+			name:    "negative check interval",
+			cli:     &CLIConfig{CheckInterval: -100},
+			wantErr: true,
+			errMsg:  "--check-interval",
+		},
+		{
+			name:    "negative stability period",
+			cli:     &CLIConfig{StabilityPeriod: -1},
+			wantErr: true,
+			errMsg:  "--stability-period",
+		},
+		{
+			name:    "explicit zero max retries",
+			cli:     &CLIConfig{setFlags: map[string]bool{"max-retries": true}},
+			wantErr: true,
+			errMsg:  "--max-retries",
+		},
+		{
+			name:    "explicit zero check interval",
+			cli:     &CLIConfig{setFlags: map[string]bool{"check-interval": true}},
+			wantErr: true,
+			errMsg:  "--check-interval",
+		},
 	}
 
 	for _, tt := range tests {
@@ -1,13 +1,26 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+)
+
+// Supported values of FileStability.Mode
+const (
+	FileStabilityModeStat     = "stat"
+	FileStabilityModeChecksum = "checksum"
+)
 
-	"gopkg.in/yaml.v3"
+// Supported values of Mode
+const (
+	ModeMove = "move"
+	ModeCopy = "copy"
 )
 
 type EnvConfig struct {
@@ -15,16 +28,64 @@ type EnvConfig struct {
 		Level string `yaml:"level"`
 	} `yaml:"log"`
 	Input         string       `yaml:"input"`
+	InputOptions  InputConfig  `yaml:"input-options"`
 	Output        OutputConfig `yaml:"output"`
+	OutputsFile   string       `yaml:"outputs-file"`   // Optional YAML/JSON file with additional output targets
+	OutputsStrict bool         `yaml:"outputs-strict"` // Fail instead of warning when OUTPUTS cannot be parsed
+	DryRun        bool         `yaml:"dry-run"`        // Log transfers without writing to targets or removing source files
+	// Fail at startup instead of falling back to the filesystem target ./output if no target is configured
+	RequireExplicitOutput bool `yaml:"require-explicit-output"`
+	// Process the files present in the input directory at startup, then exit instead of watching it
+	Once bool `yaml:"once"`
+	// Mode "move" (default) removes delivered source files, "copy" keeps them in the input directory.
+	Mode string `yaml:"mode"`
+	// State file of the originals copied in copy mode, so they are not copied again after a restart
+	CopyStateFile string `yaml:"copy-state-file"`
+
 	FileStability struct {
-		MaxRetries      int `yaml:"max-retries"`      // Maximum number of repetitions in case of file instability
-		CheckInterval   int `yaml:"check-interval"`   // Check interval in milliseconds
-		StabilityPeriod int `yaml:"stability-period"` // Period during which a file must remain stable in milliseconds
+		MaxRetries      int    `yaml:"max-retries"`      // Maximum number of repetitions in case of file instability
+		CheckInterval   int    `yaml:"check-interval"`   // Check interval in milliseconds
+		StabilityPeriod int    `yaml:"stability-period"` // Period during which a file must remain stable in milliseconds
+		Mode            string `yaml:"mode"`             // "stat" (size/mtime, default) or "checksum" (content hash)
+		// Process names (case-insensitive substrings) that keep a file incomplete while open, even if harmless
+		RelevantProcesses []string `yaml:"relevant-processes"`
 	} `yaml:"file-stability"`
 	WorkerPool struct {
 		Workers   int `yaml:"workers"`    // Number of parallel workers
 		QueueSize int `yaml:"queue-size"` // Size of the file queue
+
+		InitialScanWorkers int `yaml:"initial-scan-workers"` // Number of workers while draining existing files at startup (0 = Workers)
+		MaxPerSubdir       int `yaml:"max-per-subdir"`       // Files of the same top-level input subdirectory processed at once (0 = unlimited)
+		MaxQueueBytes      int `yaml:"max-queue-bytes"`      // Total size of queued and in-process files before enqueueing blocks (0 = unlimited)
 	} `yaml:"worker-pool"`
+	Health   HealthConfig `yaml:"health"`
+	Manifest struct {
+		Path        string `yaml:"path"`         // Optional append-only JSONL manifest of all deliveries
+		SessionHash bool   `yaml:"session-hash"` // Maintain a combined Merkle hash of all files delivered since the start
+	} `yaml:"manifest"`
+	Mirror struct {
+		PropagateDeletes bool `yaml:"propagate-deletes"` // Delete files removed from the input directory from all targets as well
+	} `yaml:"mirror"`
+	Shutdown struct {
+		Timeout int `yaml:"timeout"` // Maximum graceful shutdown duration in milliseconds before forcing exit
+	} `yaml:"shutdown"`
+	S3         S3Defaults     `yaml:"s3"`
+	Transfer   TransferConfig `yaml:"transfer"`
+	TLS        TLSConfig      `yaml:"tls"`
+	Tracing    TracingConfig  `yaml:"tracing"`
+	Targets    TargetsConfig  `yaml:"targets"`
+	Filesystem struct {
+		RequireMetadataPreservation bool   `yaml:"require-metadata-preservation"` // Fail transfers if permissions/timestamps cannot be preserved
+		Fsync                       bool   `yaml:"fsync"`                         // Sync file content to disk before the copy counts as complete
+		FsyncDir                    bool   `yaml:"fsync-dir"`                     // Additionally sync the parent directory of the new file
+		MinFreeInodes               int    `yaml:"min-free-inodes"`               // Refuse to write when fewer inodes are free on the target volume (0 = off)
+		ForceFileMode               string `yaml:"force-file-mode"`               // Octal mode (e.g. "0644") applied to copied files instead of the source mode
+		TempSuffix                  string `yaml:"temp-suffix"`                   // Write copies to a hidden .<name>.<pid>-<random><suffix> and rename them when complete (empty = write directly)
+		StaleTempAge                int    `yaml:"stale-temp-age"`                // Milliseconds after which leftover temp files are removed at startup (default 24 h)
+		ChecksumDuringCopy          bool   `yaml:"checksum-during-copy"`          // Calculate the initial checksum while copying to a single filesystem target
+	} `yaml:"filesystem"`
+	// Provenance records which layer set each setting (see provenance.go)
+	Provenance Provenance `yaml:"-"`
 }
 
 // LoadFromEnvironment loads the configuration from environment variables
@@ -37,94 +98,130 @@ func (c *EnvConfig) LoadFromEnvironment() error {
 		c.Input = inputDir
 	}
 
+	// Input Options
+	c.loadInputOptionsFromEnv()
+
 	// File Stability Configuration - support different formats
 	c.loadFileStabilityFromEnv()
 
 	// Worker Pool Configuration - support different formats
 	c.loadWorkerPoolFromEnv()
 
-	// Output Targets - flat structure
-	c.loadOutputTargetsFromEnv()
+	// Health Configuration
+	c.loadHealthFromEnv()
 
-	// Output Targets - YAML-structure from env
-	if len(c.Output) == 0 {
-		c.loadOutputFromYAMLEnv()
+	if manifestPath := firstNonEmptyEnv("MANIFEST_PATH", "manifest.path"); manifestPath != "" {
+		c.Manifest.Path = manifestPath
 	}
+	c.Manifest.SessionHash = readBoolEnv(c.Manifest.SessionHash, "MANIFEST_SESSION_HASH", "manifest.session_hash")
 
-	// Output Targets - JSON/YAML structure as fallback
-	if len(c.Output) == 0 {
-		if targets := parseOutputTargetsEnv("OUTPUTS"); len(targets) > 0 {
-			c.Output = targets
-		}
-	}
+	c.Mirror.PropagateDeletes = readBoolEnv(c.Mirror.PropagateDeletes, "MIRROR_PROPAGATE_DELETES", "mirror.propagate_deletes")
 
-	return nil
-}
+	// Filesystem target options
+	c.loadFilesystemFromEnv()
 
-// loadOutputTargetsFromEnv loads output targets from the new flat ENV structure
-func (c *EnvConfig) loadOutputTargetsFromEnv() {
-	targetMap := make(map[string]*OutputTarget)
-
-	for _, env := range os.Environ() {
-		key, value, ok := splitEnvVar(env)
-		if !ok {
-			continue
-		}
-		indexStr, ok := outputPathIndex(key)
-		if !ok {
-			continue
-		}
-		if targetMap[indexStr] == nil {
-			targetMap[indexStr] = &OutputTarget{}
+	if value := firstNonEmptyEnv("S3_DEFAULT_SSL", "s3.default_ssl"); value != "" {
+		if ssl, err := parseBool(value); err == nil {
+			c.S3.DefaultSSL = toBoolPtr(ssl)
 		}
-		targetMap[indexStr].Path = value
 	}
 
-	// Load additional properties for each target
-	for index, target := range targetMap {
-		c.loadTargetProperties(target, index)
+	c.S3.MaxIdleConns = readPositiveIntEnv(c.S3.MaxIdleConns, "S3_MAX_IDLE_CONNS", "s3.max_idle_conns")
+	c.S3.MaxIdleConnsPerHost = readPositiveIntEnv(c.S3.MaxIdleConnsPerHost, "S3_MAX_IDLE_CONNS_PER_HOST", "s3.max_idle_conns_per_host")
+	c.S3.HealthCheckRetries = readPositiveIntEnv(c.S3.HealthCheckRetries, "S3_HEALTH_CHECK_RETRIES", "s3.health_check_retries")
+	if version := firstNonEmptyEnv("S3_SIGNATURE_VERSION", "s3.signature_version"); version != "" {
+		c.S3.SignatureVersion = strings.ToLower(version)
 	}
 
-	if targets := outputTargetsFromMap(targetMap); len(targets) > 0 {
-		c.Output = targets
+	if caFile := firstNonEmptyEnv("TLS_CA_FILE", "tls.ca_file"); caFile != "" {
+		c.TLS.CAFile = caFile
+	}
+	c.TLS.InsecureSkipVerify = readBoolEnv(c.TLS.InsecureSkipVerify, "TLS_INSECURE_SKIP_VERIFY", "tls.insecure_skip_verify")
+	if version := firstNonEmptyEnv("TLS_MIN_VERSION", "tls.min_version"); version != "" {
+		c.TLS.MinVersion = version
 	}
-}
-
-// loadTargetProperties loads all properties for a target based on its index
-func (c *EnvConfig) loadTargetProperties(target *OutputTarget, index string) {
-	prefix := "OUTPUT_" + index + "_"
 
-	// Grundlegende Eigenschaften
-	if value := os.Getenv(prefix + "TYPE"); value != "" {
-		target.Type = value
+	c.Tracing.Enabled = readBoolEnv(c.Tracing.Enabled, "TRACING_ENABLED", "tracing.enabled")
+	if endpoint := firstNonEmptyEnv("TRACING_ENDPOINT", "tracing.endpoint"); endpoint != "" {
+		c.Tracing.Endpoint = endpoint
+	}
+	c.Tracing.Insecure = readBoolEnv(c.Tracing.Insecure, "TRACING_INSECURE", "tracing.insecure")
+	if serviceName := firstNonEmptyEnv("TRACING_SERVICE_NAME", "tracing.service_name"); serviceName != "" {
+		c.Tracing.ServiceName = serviceName
+	}
+	if hosts := readListEnv("TARGETS_ALLOWED_HOSTS", "targets.allowed_hosts"); len(hosts) > 0 {
+		c.Targets.AllowedHosts = hosts
 	}
 
-	// S3-spezifische Eigenschaften
-	if value := os.Getenv(prefix + "ENDPOINT"); value != "" {
-		target.Endpoint = value
+	c.Transfer.MaxInMemoryBytes = readPositiveIntEnv(c.Transfer.MaxInMemoryBytes, "TRANSFER_MAX_IN_MEMORY_BYTES", "transfer.max_in_memory_bytes")
+	c.DryRun = readBoolEnv(c.DryRun, "DRY_RUN", "dry_run")
+	c.RequireExplicitOutput = readBoolEnv(c.RequireExplicitOutput, "REQUIRE_EXPLICIT_OUTPUT", "require_explicit_output")
+	c.Once = readBoolEnv(c.Once, "ONCE", "once")
+	if mode := firstNonEmptyEnv("MODE", "mode"); mode != "" {
+		c.Mode = strings.ToLower(mode)
 	}
-	if value := os.Getenv(prefix + "ACCESS_KEY"); value != "" {
-		target.AccessKey = value
+	if stateFile := firstNonEmptyEnv("COPY_STATE_FILE", "copy_state_file"); stateFile != "" {
+		c.CopyStateFile = stateFile
 	}
-	if value := os.Getenv(prefix + "SECRET_KEY"); value != "" {
-		target.SecretKey = value
+
+	c.Transfer.MaxBytesPerSec = readPositiveIntEnv(c.Transfer.MaxBytesPerSec, "TRANSFER_MAX_BYTES_PER_SEC", "transfer.max_bytes_per_sec")
+	c.Transfer.MaxFilesPerSec = readPositiveIntEnv(c.Transfer.MaxFilesPerSec, "TRANSFER_MAX_FILES_PER_SEC", "transfer.max_files_per_sec")
+	c.Transfer.SourceRemoveRetries = readPositiveIntEnv(c.Transfer.SourceRemoveRetries, "TRANSFER_SOURCE_REMOVE_RETRIES", "transfer.source_remove_retries")
+	if marker := firstNonEmptyEnv("TRANSFER_SUCCESS_MARKER", "transfer.success_marker"); marker != "" {
+		c.Transfer.SuccessMarker = marker
 	}
-	if value := os.Getenv(prefix + "SSL"); value != "" {
-		target.SSL = toBoolPtr(strings.ToLower(value) == "true")
+	c.Transfer.SuccessMarkerQuiet = readPositiveIntEnv(c.Transfer.SuccessMarkerQuiet, "TRANSFER_SUCCESS_MARKER_QUIET", "transfer.success_marker_quiet")
+	c.Transfer.QuickVerify = readBoolEnv(c.Transfer.QuickVerify, "TRANSFER_QUICK_VERIFY", "transfer.quick_verify")
+	c.Transfer.FileTimeout = readPositiveIntEnv(c.Transfer.FileTimeout, "TRANSFER_FILE_TIMEOUT", "transfer.file_timeout")
+	if dir := firstNonEmptyEnv("TRANSFER_DEAD_LETTER_DIR", "transfer.dead_letter_dir"); dir != "" {
+		c.Transfer.DeadLetterDir = dir
 	}
-	if value := os.Getenv(prefix + "REGION"); value != "" {
-		target.Region = value
+
+	c.Shutdown.Timeout = readPositiveIntEnv(c.Shutdown.Timeout, "SHUTDOWN_TIMEOUT", "shutdown.timeout")
+
+	// Output Targets - flat structure
+	c.loadOutputTargetsFromEnv()
+
+	// Output Targets - YAML-structure from env
+	if len(c.Output) == 0 {
+		c.loadOutputFromYAMLEnv()
 	}
 
-	// FTP/SFTP-spezifische Eigenschaften
-	if value := os.Getenv(prefix + "HOST"); value != "" {
-		target.Host = value
+	// Output Targets - JSON/YAML structure as fallback
+	c.OutputsStrict = readBoolEnv(c.OutputsStrict, "OUTPUTS_STRICT", "outputs_strict")
+	if len(c.Output) == 0 {
+		targets, err := parseOutputTargetsEnv("OUTPUTS")
+		if err != nil {
+			if c.OutputsStrict {
+				return err
+			}
+			slog.Warn("Ignoring invalid OUTPUTS, no output targets loaded from it", "error", err)
+		}
+		if len(targets) > 0 {
+			c.Output = targets
+		}
+	}
+
+	return nil
+}
+
+// ApplyS3Defaults sets the global default SSL on all S3 targets without an explicit SSL setting.
+// It must run after all configuration sources (including CLI outputs) have been applied.
+func (c *EnvConfig) ApplyS3Defaults() {
+	if c.S3.DefaultSSL == nil {
+		return
 	}
-	if value := os.Getenv(prefix + "USERNAME"); value != "" {
-		target.Username = value
+	for i := range c.Output {
+		if c.Output[i].Type == "s3" && c.Output[i].SSL == nil {
+			c.Output[i].SSL = toBoolPtr(*c.S3.DefaultSSL)
+		}
 	}
-	if value := os.Getenv(prefix + "PASSWORD"); value != "" {
-		target.Password = value
+}
+
+// loadOutputTargetsFromEnv loads output targets from the flat OUTPUT_X_* ENV structure
+func (c *EnvConfig) loadOutputTargetsFromEnv() {
+	if targets := readOutputTargets(flatOutputFormat, flatOutputIndices()); len(targets) > 0 {
+		c.Output = targets
 	}
 }
 
@@ -133,58 +230,105 @@ func (c *EnvConfig) loadFileStabilityFromEnv() {
 	c.FileStability.MaxRetries = readPositiveIntEnv(c.FileStability.MaxRetries, "FILE_STABILITY_MAX_RETRIES", "file_stability.max_retries")
 	c.FileStability.CheckInterval = readPositiveIntEnv(c.FileStability.CheckInterval, "FILE_STABILITY_CHECK_INTERVAL", "file_stability.check_interval")
 	c.FileStability.StabilityPeriod = readPositiveIntEnv(c.FileStability.StabilityPeriod, "FILE_STABILITY_PERIOD", "file_stability.period")
+	if mode := firstNonEmptyEnv("FILE_STABILITY_MODE", "file_stability.mode"); mode != "" {
+		c.FileStability.Mode = mode
+	}
+	if processes := readListEnv("FILE_STABILITY_RELEVANT_PROCESSES", "file_stability.relevant_processes"); len(processes) > 0 {
+		c.FileStability.RelevantProcesses = processes
+	}
 }
 
 // loadWorkerPoolFromEnv lädt die Worker-Pool-Konfiguration aus Umgebungsvariablen
 func (c *EnvConfig) loadWorkerPoolFromEnv() {
 	c.WorkerPool.Workers = readPositiveIntEnv(c.WorkerPool.Workers, "WORKER_POOL_WORKERS", "worker_pool.workers")
 	c.WorkerPool.QueueSize = readPositiveIntEnv(c.WorkerPool.QueueSize, "WORKER_POOL_QUEUE_SIZE", "worker_pool.queue_size")
+	c.WorkerPool.InitialScanWorkers = readPositiveIntEnv(c.WorkerPool.InitialScanWorkers, "WORKER_POOL_INITIAL_SCAN_WORKERS", "worker_pool.initial_scan_workers")
+	c.WorkerPool.MaxPerSubdir = readPositiveIntEnv(c.WorkerPool.MaxPerSubdir, "WORKER_POOL_MAX_PER_SUBDIR", "worker_pool.max_per_subdir")
+	c.WorkerPool.MaxQueueBytes = readPositiveIntEnv(c.WorkerPool.MaxQueueBytes, "WORKER_POOL_MAX_QUEUE_BYTES", "worker_pool.max_queue_bytes")
 }
 
-// loadOutputFromYAMLEnv lädt Output-Targets aus YAML-strukturierten Umgebungsvariablen
-func (c *EnvConfig) loadOutputFromYAMLEnv() {
-	var targets []OutputTarget
-	for targetIndex := 0; ; targetIndex++ {
-		target, ok := readYAMLOutputTarget(targetIndex)
-		if !ok {
-			break
-		}
-		targets = append(targets, target)
+// loadInputOptionsFromEnv loads additional input options from environment variables
+func (c *EnvConfig) loadInputOptionsFromEnv() {
+	if patterns := readListEnv("INPUT_WATCH_PATTERNS", "input_options.watch_patterns"); len(patterns) > 0 {
+		c.InputOptions.WatchPatterns = patterns
 	}
-
-	if len(targets) > 0 {
-		c.Output = targets
+	c.InputOptions.SkipHiddenDirs = readBoolEnv(c.InputOptions.SkipHiddenDirs, "INPUT_SKIP_HIDDEN_DIRS", "input_options.skip_hidden_dirs")
+	c.InputOptions.RenameComplete = readBoolEnv(c.InputOptions.RenameComplete, "INPUT_RENAME_COMPLETE", "input_options.rename_complete")
+	if patterns := readListEnv("INPUT_RENAME_COMPLETE_PATTERNS", "input_options.rename_complete_patterns"); len(patterns) > 0 {
+		c.InputOptions.RenameCompletePatterns = patterns
+	}
+	c.InputOptions.WatchRemoveGrace = readPositiveIntEnv(c.InputOptions.WatchRemoveGrace, "INPUT_WATCH_REMOVE_GRACE", "input_options.watch_remove_grace")
+	c.InputOptions.DeleteDelay = readPositiveIntEnv(c.InputOptions.DeleteDelay, "INPUT_DELETE_DELAY", "input_options.delete_delay")
+	if order := firstNonEmptyEnv("INPUT_ORDER", "input_options.order"); order != "" {
+		c.InputOptions.Order = order
+	}
+	if suffix := firstNonEmptyEnv("INPUT_COMPLETION_MARKER_SUFFIX", "input_options.completion_marker_suffix"); suffix != "" {
+		c.InputOptions.CompletionMarkerSuffix = suffix
+	}
+	if lockFile := firstNonEmptyEnv("INPUT_PAUSE_LOCK_FILE", "input_options.pause_lock_file"); lockFile != "" {
+		c.InputOptions.PauseLockFile = lockFile
+	}
+	if sizeOrder := firstNonEmptyEnv("INPUT_SIZE_ORDER", "input_options.size_order"); sizeOrder != "" {
+		c.InputOptions.SizeOrder = sizeOrder
 	}
+	if dirMode := firstNonEmptyEnv("INPUT_DIR_MODE", "input_options.dir_mode"); dirMode != "" {
+		c.InputOptions.DirMode = dirMode
+	}
+	if typeChange := firstNonEmptyEnv("INPUT_TYPE_CHANGE", "input_options.type_change"); typeChange != "" {
+		c.InputOptions.TypeChange = typeChange
+	}
+	if contentTypes := readListEnv("INPUT_ALLOW_CONTENT_TYPES", "input_options.allow_content_types"); len(contentTypes) > 0 {
+		c.InputOptions.AllowContentTypes = contentTypes
+	}
+	c.InputOptions.Decompress = readBoolEnv(c.InputOptions.Decompress, "INPUT_DECOMPRESS", "input_options.decompress")
+	c.InputOptions.HashCache = readBoolEnv(c.InputOptions.HashCache, "INPUT_HASH_CACHE", "input_options.hash_cache")
+	c.InputOptions.RequireNonEmpty = readBoolEnv(c.InputOptions.RequireNonEmpty, "INPUT_REQUIRE_NON_EMPTY", "input_options.require_non_empty")
 }
 
-func splitEnvVar(env string) (string, string, bool) {
-	parts := strings.SplitN(env, "=", 2)
-	if len(parts) != 2 {
-		return "", "", false
+// loadFilesystemFromEnv loads the filesystem target options from environment variables
+func (c *EnvConfig) loadFilesystemFromEnv() {
+	c.Filesystem.RequireMetadataPreservation = readBoolEnv(c.Filesystem.RequireMetadataPreservation, "FILESYSTEM_REQUIRE_METADATA_PRESERVATION", "filesystem.require_metadata_preservation")
+	c.Filesystem.Fsync = readBoolEnv(c.Filesystem.Fsync, "FILESYSTEM_FSYNC", "filesystem.fsync")
+	c.Filesystem.FsyncDir = readBoolEnv(c.Filesystem.FsyncDir, "FILESYSTEM_FSYNC_DIR", "filesystem.fsync_dir")
+	c.Filesystem.ChecksumDuringCopy = readBoolEnv(c.Filesystem.ChecksumDuringCopy, "FILESYSTEM_CHECKSUM_DURING_COPY", "filesystem.checksum_during_copy")
+	c.Filesystem.MinFreeInodes = readPositiveIntEnv(c.Filesystem.MinFreeInodes, "FILESYSTEM_MIN_FREE_INODES", "filesystem.min_free_inodes")
+	if mode := firstNonEmptyEnv("FILESYSTEM_FORCE_FILE_MODE", "filesystem.force_file_mode"); mode != "" {
+		c.Filesystem.ForceFileMode = mode
 	}
-	return parts[0], parts[1], true
+	if suffix := firstNonEmptyEnv("FILESYSTEM_TEMP_SUFFIX", "filesystem.temp_suffix"); suffix != "" {
+		c.Filesystem.TempSuffix = suffix
+	}
+	c.Filesystem.StaleTempAge = readPositiveIntEnv(c.Filesystem.StaleTempAge, "FILESYSTEM_STALE_TEMP_AGE", "filesystem.stale_temp_age")
 }
 
-func outputPathIndex(key string) (string, bool) {
-	if !strings.HasPrefix(key, "OUTPUT_") || !strings.HasSuffix(key, "_PATH") {
-		return "", false
-	}
-	indexStr := strings.TrimPrefix(key, "OUTPUT_")
-	indexStr = strings.TrimSuffix(indexStr, "_PATH")
-	if indexStr == "" {
-		return "", false
+// loadHealthFromEnv loads the health server configuration from environment variables
+func (c *EnvConfig) loadHealthFromEnv() {
+	if token := firstNonEmptyEnv("HEALTH_AUTH_TOKEN", "health.auth_token"); token != "" {
+		c.Health.AuthToken = token
+	}
+	c.Health.EnablePprof = readBoolEnv(c.Health.EnablePprof, "HEALTH_ENABLE_PPROF", "health.enable_pprof")
+	c.Health.DiskWarnFreeMB = readPositiveIntEnv(c.Health.DiskWarnFreeMB, "HEALTH_DISK_WARN_FREE_MB", "health.disk_warn_free_mb")
+	c.Health.DiskCriticalFreeMB = readPositiveIntEnv(c.Health.DiskCriticalFreeMB, "HEALTH_DISK_CRITICAL_FREE_MB", "health.disk_critical_free_mb")
+	c.Health.MaxConcurrentProbes = readPositiveIntEnv(c.Health.MaxConcurrentProbes, "HEALTH_MAX_CONCURRENT_PROBES", "health.max_concurrent_probes")
+	c.Health.MaxQueueAge = readPositiveIntEnv(c.Health.MaxQueueAge, "HEALTH_MAX_QUEUE_AGE", "health.max_queue_age")
+	c.Health.QueueDegradedPercent = readPositiveIntEnv(c.Health.QueueDegradedPercent, "HEALTH_QUEUE_DEGRADED_PERCENT", "health.queue_degraded_percent")
+	c.Health.QueueUnhealthyPercent = readPositiveIntEnv(c.Health.QueueUnhealthyPercent, "HEALTH_QUEUE_UNHEALTHY_PERCENT", "health.queue_unhealthy_percent")
+	c.Health.ReadHeaderTimeout = readPositiveIntEnv(c.Health.ReadHeaderTimeout, "HEALTH_READ_HEADER_TIMEOUT", "health.read_header_timeout")
+	c.Health.ReadTimeout = readPositiveIntEnv(c.Health.ReadTimeout, "HEALTH_READ_TIMEOUT", "health.read_timeout")
+	c.Health.WriteTimeout = readPositiveIntEnv(c.Health.WriteTimeout, "HEALTH_WRITE_TIMEOUT", "health.write_timeout")
+	if onBindError := firstNonEmptyEnv("HEALTH_ON_BIND_ERROR", "health.on_bind_error"); onBindError != "" {
+		c.Health.OnBindError = onBindError
 	}
-	return indexStr, true
 }
 
-func outputTargetsFromMap(targetMap map[string]*OutputTarget) []OutputTarget {
-	var targets []OutputTarget
-	for _, target := range targetMap {
-		if target.Path != "" {
-			targets = append(targets, *target)
-		}
+// maxYAMLOutputIndex is the highest output.N index scanned, gaps in between are allowed
+const maxYAMLOutputIndex = 99
+
+// loadOutputFromYAMLEnv lädt Output-Targets aus YAML-strukturierten Umgebungsvariablen
+func (c *EnvConfig) loadOutputFromYAMLEnv() {
+	if targets := readOutputTargets(dottedOutputFormat, dottedOutputIndices()); len(targets) > 0 {
+		c.Output = targets
 	}
-	return targets
 }
 
 func firstNonEmptyEnv(keys ...string) string {
@@ -196,22 +340,6 @@ func firstNonEmptyEnv(keys ...string) string {
 	return ""
 }
 
-func parseOutputTargetsEnv(key string) []OutputTarget {
-	outputTargetsStr := os.Getenv(key)
-	if outputTargetsStr == "" {
-		return nil
-	}
-
-	var targets []OutputTarget
-	if err := json.Unmarshal([]byte(outputTargetsStr), &targets); err == nil {
-		return targets
-	}
-	if err := yaml.Unmarshal([]byte(outputTargetsStr), &targets); err == nil {
-		return targets
-	}
-	return nil
-}
-
 func readPositiveIntEnv(defaultValue int, keys ...string) int {
 	for _, key := range keys {
 		if value := os.Getenv(key); value != "" {
@@ -223,40 +351,47 @@ func readPositiveIntEnv(defaultValue int, keys ...string) int {
 	return defaultValue
 }
 
-func readYAMLOutputTarget(index int) (OutputTarget, bool) {
-	path := os.Getenv(fmt.Sprintf("output.%d.path", index))
-	targetType := os.Getenv(fmt.Sprintf("output.%d.type", index))
-	if path == "" || targetType == "" {
-		return OutputTarget{}, false
+// readBoolEnv reads a boolean from the first set environment variable, invalid values are ignored
+func readBoolEnv(defaultValue bool, keys ...string) bool {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			if parsed, err := parseBool(value); err == nil {
+				return parsed
+			}
+		}
 	}
+	return defaultValue
+}
 
-	target := OutputTarget{
-		Path: path,
-		Type: targetType,
+// parseBool accepts everything strconv.ParseBool does plus yes/no and on/off
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
 	}
+	return strconv.ParseBool(strings.TrimSpace(value))
+}
 
-	target.Endpoint = os.Getenv(fmt.Sprintf("output.%d.endpoint", index))
-	target.AccessKey = os.Getenv(fmt.Sprintf("output.%d.access_key", index))
-	target.SecretKey = os.Getenv(fmt.Sprintf("output.%d.secret_key", index))
-	target.Region = os.Getenv(fmt.Sprintf("output.%d.region", index))
-	target.Host = os.Getenv(fmt.Sprintf("output.%d.host", index))
-	target.Username = os.Getenv(fmt.Sprintf("output.%d.username", index))
-	target.Password = os.Getenv(fmt.Sprintf("output.%d.password", index))
+// readListEnv reads a comma-separated list from the first non-empty environment variable
+func readListEnv(keys ...string) []string {
+	return splitList(firstNonEmptyEnv(keys...))
+}
 
-	if sslStr := os.Getenv(fmt.Sprintf("output.%d.ssl", index)); sslStr != "" {
-		target.SSL = toBoolPtr(strings.ToLower(sslStr) == "true")
+// splitList splits a comma separated value, empty items are dropped
+func splitList(value string) []string {
+	if value == "" {
+		return nil
 	}
-	if portStr := os.Getenv(fmt.Sprintf("output.%d.port", index)); portStr != "" {
-		if port, err := strconv.Atoi(portStr); err == nil {
-			target.Port = port
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-
-	return target, true
-}
-
-func toBoolPtr(value bool) *bool {
-	return &value
+	return items
 }
 
 // SetDefaults setzt Standard-Werte für die Konfiguration
@@ -267,6 +402,9 @@ func (c *EnvConfig) SetDefaults() {
 	if c.Input == "" {
 		c.Input = "./input"
 	}
+	if c.CopyStateFile == "" {
+		c.CopyStateFile = "./copied-files.json"
+	}
 	// File Stability Defaults
 	if c.FileStability.MaxRetries == 0 {
 		c.FileStability.MaxRetries = 30 // 30 Versuche
@@ -284,17 +422,130 @@ func (c *EnvConfig) SetDefaults() {
 	if c.WorkerPool.QueueSize == 0 {
 		c.WorkerPool.QueueSize = 100 // 100 Dateien in der Warteschlange
 	}
+	// Transfer Defaults
+	if c.Transfer.MaxInMemoryBytes == 0 {
+		c.Transfer.MaxInMemoryBytes = 64 * 1024 * 1024 // 64 MiB
+	}
+	if c.Transfer.SourceRemoveRetries == 0 {
+		c.Transfer.SourceRemoveRetries = 3
+	}
+	// Shutdown Defaults
+	if c.Shutdown.Timeout == 0 {
+		c.Shutdown.Timeout = 30000 // 30 Sekunden
+	}
 }
 
+// Validation errors returned by EnvConfig.Validate
+var (
+	ErrInputRequired  = errors.New("input directory is required")
+	ErrOutputRequired = errors.New("at least one output target is required")
+	// ErrTargetPathIsFile is returned when a filesystem target path exists as a regular file
+	ErrTargetPathIsFile = errors.New("target path is a file, expected a directory")
+)
+
 // Validate checks the configuration for completeness.
 func (c *EnvConfig) Validate() error {
 	if c.Input == "" {
-		return os.ErrInvalid
+		return ErrInputRequired
 	}
 
 	// Check that at least one target is configured.
 	if len(c.Output) == 0 {
-		return os.ErrInvalid
+		return ErrOutputRequired
+	}
+
+	for i, target := range c.Output {
+		if err := validateOutputTarget(target, i); err != nil {
+			return err
+		}
+		if err := validateOutputTargetFields(target, i); err != nil {
+			return err
+		}
+	}
+
+	for _, pattern := range c.InputOptions.WatchPatterns {
+		if _, err := filepath.Match(filepath.ToSlash(pattern), ""); err != nil {
+			return fmt.Errorf("invalid watch pattern %q: %w", pattern, err)
+		}
+	}
+
+	for _, pattern := range c.InputOptions.RenameCompletePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid rename-complete pattern %q: %w", pattern, err)
+		}
+	}
+
+	// 0 is replaced by the default, a negative size cannot be a queue
+	if c.WorkerPool.QueueSize < 0 {
+		return fmt.Errorf("invalid worker-pool queue-size %d: must be positive", c.WorkerPool.QueueSize)
+	}
+
+	if _, err := c.InputOptions.InputDirMode(); err != nil {
+		return err
+	}
+	if _, err := c.FilesystemForceFileMode(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(c.Filesystem.TempSuffix, `/\`) || c.Filesystem.TempSuffix == "." {
+		return fmt.Errorf("invalid filesystem temp-suffix %q: must not contain path separators", c.Filesystem.TempSuffix)
+	}
+	if err := c.S3.validateSignatureVersion(); err != nil {
+		return err
+	}
+	if err := c.TLS.validateMinVersion(); err != nil {
+		return err
+	}
+	if err := c.Targets.validateAllowedHosts(); err != nil {
+		return err
+	}
+	if err := c.Transfer.validateSuccessMarker(); err != nil {
+		return err
+	}
+	if err := c.Transfer.validateFileTimeout(c.Input); err != nil {
+		return err
+	}
+	if err := c.InputOptions.validateOrder(); err != nil {
+		return err
+	}
+	if err := c.InputOptions.validateSizeOrder(); err != nil {
+		return err
+	}
+	if err := c.InputOptions.validateCompletionMarkerSuffix(); err != nil {
+		return err
+	}
+	if err := c.InputOptions.validateTypeChange(); err != nil {
+		return err
+	}
+	if err := c.InputOptions.validatePauseLockFile(); err != nil {
+		return err
+	}
+	if c.InputOptions.RequireNonEmpty && !c.Once {
+		return fmt.Errorf("input-options require-non-empty only applies to a single run (--once)")
+	}
+
+	switch c.Mode {
+	case "", ModeMove, ModeCopy:
+	default:
+		return fmt.Errorf("invalid mode %q (allowed: %s, %s)", c.Mode, ModeMove, ModeCopy)
+	}
+
+	switch c.FileStability.Mode {
+	case "", FileStabilityModeStat, FileStabilityModeChecksum:
+	default:
+		return fmt.Errorf("invalid file-stability mode %q (allowed: %s, %s)", c.FileStability.Mode, FileStabilityModeStat, FileStabilityModeChecksum)
+	}
+
+	if err := c.Health.validateQueueThresholds(); err != nil {
+		return err
+	}
+	if err := c.Health.validateOnBindError(); err != nil {
+		return err
+	}
+	if err := c.Health.validateMaxConcurrentProbes(); err != nil {
+		return err
+	}
+	if err := c.Tracing.validateEndpoint(); err != nil {
+		return err
 	}
 
 	return nil
@@ -310,3 +561,23 @@ func (c *EnvConfig) GetLogLevel() string {
 		return "INFO"
 	}
 }
+
+// DefaultStaleTempAge is the age in milliseconds after which leftover temp files of filesystem targets are removed
+const DefaultStaleTempAge = 24 * 60 * 60 * 1000
+
+// FilesystemStaleTempAge returns Filesystem.StaleTempAge, DefaultStaleTempAge if it is not set
+func (c *EnvConfig) FilesystemStaleTempAge() time.Duration {
+	age := c.Filesystem.StaleTempAge
+	if age <= 0 {
+		age = DefaultStaleTempAge
+	}
+	return time.Duration(age) * time.Millisecond
+}
+
+// FilesystemForceFileMode returns the parsed Filesystem.ForceFileMode, 0 if copied files keep the source mode
+func (c *EnvConfig) FilesystemForceFileMode() (os.FileMode, error) {
+	if c.Filesystem.ForceFileMode == "" {
+		return 0, nil
+	}
+	return parsePermission(c.Filesystem.ForceFileMode, "filesystem force-file-mode", "0644")
+}
@@ -1,11 +1,18 @@
 package config
 
 import (
+	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Test constants to reduce duplication
@@ -114,15 +121,16 @@ func TestEnvConfig_GetLogLevel(t *testing.T) {
 
 func TestEnvConfig_Validate(t *testing.T) {
 	tests := []struct {
-		name      string
-		config    EnvConfig
-		wantError bool
+		name          string
+		config        EnvConfig
+		wantError     bool
+		expectedError error
 	}{
 		{
 			name: "valid config",
 			config: EnvConfig{
 				Input:  testSomeInput,
-				Output: []OutputTarget{{Path: testSomeOutput, Type: "file"}},
+				Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
 			},
 			wantError: false,
 		},
@@ -130,9 +138,10 @@ func TestEnvConfig_Validate(t *testing.T) {
 			name: "empty input",
 			config: EnvConfig{
 				Input:  "",
-				Output: []OutputTarget{{Path: testSomeOutput, Type: "file"}},
+				Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
 			},
-			wantError: true,
+			wantError:     true,
+			expectedError: ErrInputRequired,
 		},
 		{
 			name: "no output targets",
@@ -140,7 +149,8 @@ func TestEnvConfig_Validate(t *testing.T) {
 				Input:  testSomeInput,
 				Output: []OutputTarget{},
 			},
-			wantError: true,
+			wantError:     true,
+			expectedError: ErrOutputRequired,
 		},
 		{
 			name: "nil output",
@@ -148,7 +158,8 @@ func TestEnvConfig_Validate(t *testing.T) {
 				Input:  testSomeInput,
 				Output: nil,
 			},
-			wantError: true,
+			wantError:     true,
+			expectedError: ErrOutputRequired,
 		},
 	}
 
@@ -158,6 +169,29 @@ func TestEnvConfig_Validate(t *testing.T) {
 			if (err != nil) != tt.wantError {
 				t.Errorf("Validate() error = %v, wantError %v", err, tt.wantError)
 			}
+			if tt.expectedError != nil && !errors.Is(err, tt.expectedError) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_Validate_ErrorMessages(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          EnvConfig
+		expectedMessage string
+	}{
+		{"empty input", EnvConfig{Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}}}, "input directory is required"},
+		{"no output", EnvConfig{Input: testSomeInput}, "at least one output target is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if err == nil || err.Error() != tt.expectedMessage {
+				t.Errorf("Validate() error = %v, want %q", err, tt.expectedMessage)
+			}
 		})
 	}
 }
@@ -375,6 +409,55 @@ func TestEnvConfig_LoadFromEnvironment_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestEnvConfig_LoadFromEnvironment_InvalidOutputs(t *testing.T) {
+	tests := []struct {
+		name        string
+		outputs     string
+		strict      string
+		wantErr     bool
+		wantWarning bool
+		wantTargets int
+	}{
+		{name: "malformed warns", outputs: `[{"path":"/out","type":"filesystem"`, wantWarning: true},
+		{name: "malformed fails in strict mode", outputs: `[{"path":"/out","type":"filesystem"`, strict: "true", wantErr: true},
+		{name: "yaml is accepted in strict mode", outputs: "- path: /out\n  type: filesystem", strict: "true", wantTargets: 1},
+		{name: "empty list is no parse failure", outputs: "[]", strict: "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalEnv := backupEnvironment()
+			defer restoreEnvironment(originalEnv)
+			clearTestEnvironment()
+
+			var logs bytes.Buffer
+			previous := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+			defer slog.SetDefault(previous)
+
+			t.Setenv("OUTPUTS", tt.outputs)
+			if tt.strict != "" {
+				t.Setenv("OUTPUTS_STRICT", tt.strict)
+			}
+
+			cfg := EnvConfig{}
+			err := cfg.LoadFromEnvironment()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadFromEnvironment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidOutputsEnv) {
+				t.Errorf("LoadFromEnvironment() error = %v, want %v", err, ErrInvalidOutputsEnv)
+			}
+			if warned := strings.Contains(logs.String(), "Ignoring invalid OUTPUTS"); warned != tt.wantWarning {
+				t.Errorf("warning logged = %v, want %v (logs: %q)", warned, tt.wantWarning, logs.String())
+			}
+			if len(cfg.Output) != tt.wantTargets {
+				t.Errorf("got %d output targets, want %d", len(cfg.Output), tt.wantTargets)
+			}
+		})
+	}
+}
+
 func TestEnvConfig_LoadOutputTargetsEdgeCases(t *testing.T) {
 	// Backup current environment
 	originalEnv := backupEnvironment()
@@ -876,9 +959,11 @@ func TestEnvConfig_SetDefaults_FileStability(t *testing.T) {
 			name: "partial config preserves existing values",
 			initial: EnvConfig{
 				FileStability: struct {
-					MaxRetries      int `yaml:"max-retries"`
-					CheckInterval   int `yaml:"check-interval"`
-					StabilityPeriod int `yaml:"stability-period"`
+					MaxRetries        int      `yaml:"max-retries"`
+					CheckInterval     int      `yaml:"check-interval"`
+					StabilityPeriod   int      `yaml:"stability-period"`
+					Mode              string   `yaml:"mode"`
+					RelevantProcesses []string `yaml:"relevant-processes"`
 				}{
 					MaxRetries:      50,
 					CheckInterval:   0, // Will be defaulted
@@ -900,9 +985,11 @@ func TestEnvConfig_SetDefaults_FileStability(t *testing.T) {
 			name: "complete config preserves all values",
 			initial: EnvConfig{
 				FileStability: struct {
-					MaxRetries      int `yaml:"max-retries"`
-					CheckInterval   int `yaml:"check-interval"`
-					StabilityPeriod int `yaml:"stability-period"`
+					MaxRetries        int      `yaml:"max-retries"`
+					CheckInterval     int      `yaml:"check-interval"`
+					StabilityPeriod   int      `yaml:"stability-period"`
+					Mode              string   `yaml:"mode"`
+					RelevantProcesses []string `yaml:"relevant-processes"`
 				}{
 					MaxRetries:      100,
 					CheckInterval:   3,
@@ -1136,7 +1223,7 @@ func TestEnvConfig_LoadOutputFromYAMLEnv(t *testing.T) { // NOSONAR - viele YAML
 			description: "Should ignore invalid port values",
 		},
 		{
-			name: "gap in indices stops loading",
+			name: "gap in indices is skipped",
 			setupEnv: func() {
 				os.Setenv("output.0.path", "s3://bucket1")
 				os.Setenv("output.0.type", "s3")
@@ -1149,8 +1236,32 @@ func TestEnvConfig_LoadOutputFromYAMLEnv(t *testing.T) { // NOSONAR - viele YAML
 					Path: "s3://bucket1",
 					Type: "s3",
 				},
+				{
+					Path: "s3://bucket2",
+					Type: "s3",
+				},
+			},
+			description: "Should load all present indices despite gaps",
+		},
+		{
+			name: "sparse indices keep index order",
+			setupEnv: func() {
+				os.Setenv("output.7.path", "/out/seven")
+				os.Setenv("output.7.type", "filesystem")
+				os.Setenv("output.3.path", "/out/three")
+				os.Setenv("output.3.type", "filesystem")
+			},
+			expected: []OutputTarget{
+				{
+					Path: "/out/three",
+					Type: "filesystem",
+				},
+				{
+					Path: "/out/seven",
+					Type: "filesystem",
+				},
 			},
-			description: "Should stop at first gap in indices",
+			description: "Should collect sparse indices in ascending order",
 		},
 		{
 			name: "missing path stops loading",
@@ -1267,3 +1378,831 @@ func clearOutputYAMLEnv() {
 		}
 	}
 }
+
+func TestEnvConfig_LoadHealthFromEnv(t *testing.T) {
+	t.Setenv("HEALTH_AUTH_TOKEN", "env-token")
+
+	cfg := EnvConfig{}
+	cfg.Health.AuthToken = "yaml-token"
+	if err := cfg.LoadFromEnvironment(); err != nil {
+		t.Fatalf("LoadFromEnvironment() error = %v", err)
+	}
+
+	if cfg.Health.AuthToken != "env-token" {
+		t.Errorf("Health.AuthToken = %q, want %q", cfg.Health.AuthToken, "env-token")
+	}
+}
+
+func TestEnvConfig_Validate_WatchPatterns(t *testing.T) {
+	cfg := EnvConfig{
+		Input:  testSomeInput,
+		Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+	}
+	cfg.InputOptions.WatchPatterns = []string{"incoming/**"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() unexpected error for valid pattern: %v", err)
+	}
+
+	cfg.InputOptions.WatchPatterns = []string{"incoming/[a"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for malformed pattern")
+	}
+}
+
+func TestEnvConfig_LoadInputOptionsFromEnv(t *testing.T) {
+	t.Setenv("INPUT_WATCH_PATTERNS", "incoming/**, archive/*")
+
+	cfg := EnvConfig{}
+	if err := cfg.LoadFromEnvironment(); err != nil {
+		t.Fatalf("LoadFromEnvironment() error = %v", err)
+	}
+
+	expected := []string{"incoming/**", "archive/*"}
+	if fmt.Sprint(cfg.InputOptions.WatchPatterns) != fmt.Sprint(expected) {
+		t.Errorf("InputOptions.WatchPatterns = %v, want %v", cfg.InputOptions.WatchPatterns, expected)
+	}
+}
+
+func TestEnvConfig_LoadFilesystemFromEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		initial  bool
+		expected bool
+	}{
+		{"enabled", "true", false, true},
+		{"disabled overrides yaml", "false", true, false},
+		{"invalid keeps previous value", "maybe", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FILESYSTEM_REQUIRE_METADATA_PRESERVATION", tt.value)
+			cfg := EnvConfig{}
+			cfg.Filesystem.RequireMetadataPreservation = tt.initial
+			cfg.loadFilesystemFromEnv()
+			if cfg.Filesystem.RequireMetadataPreservation != tt.expected {
+				t.Errorf("RequireMetadataPreservation = %v, want %v", cfg.Filesystem.RequireMetadataPreservation, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_ShutdownTimeout(t *testing.T) {
+	cfg := EnvConfig{}
+	cfg.SetDefaults()
+	if cfg.Shutdown.Timeout != 30000 {
+		t.Errorf("default Shutdown.Timeout = %d, want 30000", cfg.Shutdown.Timeout)
+	}
+
+	t.Setenv("SHUTDOWN_TIMEOUT", "5000")
+	if err := cfg.LoadFromEnvironment(); err != nil {
+		t.Fatalf("LoadFromEnvironment() error = %v", err)
+	}
+	if cfg.Shutdown.Timeout != 5000 {
+		t.Errorf("Shutdown.Timeout = %d, want 5000", cfg.Shutdown.Timeout)
+	}
+}
+
+func TestEnvConfig_Validate_OutputTargets(t *testing.T) {
+	tests := []struct {
+		name            string
+		yamlConfig      string
+		expectedMessage string
+	}{
+		{
+			name: "valid mixed targets",
+			yamlConfig: `
+input: /in
+output:
+  - path: /out
+    type: filesystem
+  - path: s3://bucket/prefix
+    type: s3
+    endpoint: s3.example.com
+    access-key: key
+    secret-key: secret
+    region: eu-central-1
+  - path: sftp://server.example.com/upload
+    type: sftp
+    username: user
+    password: pass
+`,
+		},
+		{
+			name: "s3 invalid acl",
+			yamlConfig: `
+input: /in
+output:
+  - path: s3://bucket
+    type: s3
+    endpoint: minio:9000
+    access-key: key
+    secret-key: secret
+    region: eu-central-1
+    acl: public
+`,
+			expectedMessage: "output target 1 (s3): invalid acl 'public' (allowed: private, public-read, public-read-write, " +
+				"authenticated-read, aws-exec-read, bucket-owner-read, bucket-owner-full-control)",
+		},
+		{
+			name: "kafka without topic",
+			yamlConfig: `
+input: /in
+output:
+  - path: kafka://broker:9092
+    type: kafka
+`,
+			expectedMessage: "output target 1 (kafka): path must look like kafka://broker:9092/topic, got 'kafka://broker:9092'",
+		},
+		{
+			name: "ftp invalid remote path separator",
+			yamlConfig: `
+input: /in
+output:
+  - path: ftp://server.example.com/upload
+    type: ftp
+    username: user
+    password: pass
+    remote-path-separator: ":"
+`,
+			expectedMessage: "output target 1 (ftp): invalid remote-path-separator ':' (allowed: /, \\)",
+		},
+		{
+			name: "invalid type",
+			yamlConfig: `
+input: /in
+output:
+  - path: /out
+    type: filesytem
+`,
+			expectedMessage: "output target 1: invalid type 'filesytem' (allowed: filesystem, s3, sftp, ftp, metadata, kafka)",
+		},
+		{
+			name: "missing type",
+			yamlConfig: `
+input: /in
+output:
+  - path: /out
+`,
+			expectedMessage: "output target 1: 'type' is required",
+		},
+		{
+			name: "s3 missing credentials",
+			yamlConfig: `
+input: /in
+output:
+  - path: /out
+    type: filesystem
+  - path: s3://bucket
+    type: s3
+    endpoint: s3.example.com
+    region: eu-central-1
+`,
+			expectedMessage: "output target 2 (s3): missing required field(s): access-key, secret-key",
+		},
+		{
+			name: "ftp missing host and password",
+			yamlConfig: `
+input: /in
+output:
+  - path: /upload
+    type: ftp
+    username: user
+`,
+			expectedMessage: "output target 1 (ftp): missing required field(s): host, password",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg EnvConfig
+			if err := yaml.Unmarshal([]byte(tt.yamlConfig), &cfg); err != nil {
+				t.Fatalf("failed to parse YAML: %v", err)
+			}
+
+			err := cfg.Validate()
+			if tt.expectedMessage == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectedMessage {
+				t.Errorf("Validate() error = %v, want %q", err, tt.expectedMessage)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_LoadOutputFromYAMLEnv_HighestIndex(t *testing.T) {
+	t.Setenv(fmt.Sprintf("output.%d.path", maxYAMLOutputIndex), "/out/last")
+	t.Setenv(fmt.Sprintf("output.%d.type", maxYAMLOutputIndex), "filesystem")
+	t.Setenv(fmt.Sprintf("output.%d.path", maxYAMLOutputIndex+1), "/out/ignored")
+	t.Setenv(fmt.Sprintf("output.%d.type", maxYAMLOutputIndex+1), "filesystem")
+
+	cfg := EnvConfig{}
+	cfg.loadOutputFromYAMLEnv()
+
+	if len(cfg.Output) != 1 || cfg.Output[0].Path != "/out/last" {
+		t.Fatalf("expected only the target at the highest scanned index, got %+v", cfg.Output)
+	}
+}
+
+func TestEnvConfig_ApplyS3Defaults(t *testing.T) {
+	cfg := EnvConfig{
+		Output: OutputConfig{
+			{Path: "s3://bucket/unset", Type: "s3"},
+			{Path: "s3://bucket/explicit", Type: "s3", SSL: toBoolPtr(true)},
+			{Path: "/out", Type: "filesystem"},
+		},
+	}
+	cfg.S3.DefaultSSL = toBoolPtr(false)
+
+	cfg.ApplyS3Defaults()
+
+	if cfg.Output[0].GetS3Config().SSL {
+		t.Error("target without ssl should use the global default (false)")
+	}
+	if !cfg.Output[1].GetS3Config().SSL {
+		t.Error("explicit per-target ssl must not be overridden by the global default")
+	}
+	if cfg.Output[2].SSL != nil {
+		t.Error("non-S3 targets must not be changed")
+	}
+}
+
+func TestEnvConfig_ApplyS3Defaults_Unset(t *testing.T) {
+	cfg := EnvConfig{Output: OutputConfig{{Path: "s3://bucket", Type: "s3"}}}
+
+	cfg.ApplyS3Defaults()
+
+	if cfg.Output[0].SSL != nil || !cfg.Output[0].GetS3Config().SSL {
+		t.Error("without a global default, S3 targets should keep the built-in default (true)")
+	}
+}
+
+func TestEnvConfig_LoadS3DefaultSSLFromEnv(t *testing.T) {
+	t.Setenv("S3_DEFAULT_SSL", "false")
+
+	cfg := EnvConfig{}
+	if err := cfg.LoadFromEnvironment(); err != nil {
+		t.Fatalf("LoadFromEnvironment() error = %v", err)
+	}
+
+	if cfg.S3.DefaultSSL == nil || *cfg.S3.DefaultSSL {
+		t.Errorf("S3.DefaultSSL = %v, want false", formatBoolPtr(cfg.S3.DefaultSSL))
+	}
+}
+
+func TestEnvConfig_Validate_FilesystemTargetIsFile(t *testing.T) {
+	targetFile := filepath.Join(t.TempDir(), "output")
+	if err := os.WriteFile(targetFile, []byte("not a directory"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := EnvConfig{
+		Input:  testSomeInput,
+		Output: []OutputTarget{{Path: targetFile, Type: "filesystem"}},
+	}
+	err := cfg.Validate()
+	if !errors.Is(err, ErrTargetPathIsFile) {
+		t.Fatalf("Validate() error = %v, want %v", err, ErrTargetPathIsFile)
+	}
+	if !strings.Contains(err.Error(), "output target 1 (filesystem)") {
+		t.Errorf("error should name the target, got: %v", err)
+	}
+}
+
+func TestEnvConfig_Validate_QueueThresholds(t *testing.T) {
+	tests := []struct {
+		name      string
+		degraded  int
+		unhealthy int
+		wantErr   bool
+	}{
+		{name: "defaults", degraded: 0, unhealthy: 0, wantErr: false},
+		{name: "custom", degraded: 50, unhealthy: 70, wantErr: false},
+		{name: "unhealthy at 100", degraded: 95, unhealthy: 100, wantErr: false},
+		{name: "degraded equals unhealthy", degraded: 70, unhealthy: 70, wantErr: true},
+		{name: "degraded above default unhealthy", degraded: 95, unhealthy: 0, wantErr: true},
+		{name: "unhealthy above 100", degraded: 50, unhealthy: 101, wantErr: true},
+		{name: "negative degraded", degraded: -1, unhealthy: 90, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := EnvConfig{
+				Input:  testSomeInput,
+				Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+			}
+			cfg.Health.QueueDegradedPercent = tt.degraded
+			cfg.Health.QueueUnhealthyPercent = tt.unhealthy
+
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_DryRun(t *testing.T) {
+	t.Setenv("DRY_RUN", "true")
+	cfg := EnvConfig{}
+	if err := cfg.LoadFromEnvironment(); err != nil {
+		t.Fatalf("LoadFromEnvironment() error = %v", err)
+	}
+	if !cfg.DryRun {
+		t.Error("DRY_RUN=true should enable dry-run mode")
+	}
+
+	cfg = EnvConfig{}
+	if err := (&CLIConfig{DryRun: true}).ApplyToCfg(&cfg); err != nil {
+		t.Fatalf("ApplyToCfg() error = %v", err)
+	}
+	if !cfg.DryRun {
+		t.Error("--dry-run should enable dry-run mode")
+	}
+}
+
+func TestInputConfig_InputDirMode(t *testing.T) {
+	tests := []struct {
+		dirMode string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{dirMode: "", want: DefaultInputDirMode},
+		{dirMode: "0700", want: 0700},
+		{dirMode: "750", want: 0750},
+		{dirMode: "0o700", wantErr: true},
+		{dirMode: "0800", wantErr: true},
+		{dirMode: "1777", wantErr: true},
+		{dirMode: "rwx------", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dirMode, func(t *testing.T) {
+			mode, err := InputConfig{DirMode: tt.dirMode}.InputDirMode()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InputDirMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && mode != tt.want {
+				t.Errorf("InputDirMode() = %o, want %o", mode, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_FilesystemForceFileMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "0644", want: 0644},
+		{value: "440", want: 0440},
+		{value: "0999", wantErr: true},
+		{value: "rw-r--r--", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			cfg := EnvConfig{Input: testSomeInput, Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}}}
+			cfg.Filesystem.ForceFileMode = tt.value
+
+			mode, err := cfg.FilesystemForceFileMode()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FilesystemForceFileMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && mode != tt.want {
+				t.Errorf("FilesystemForceFileMode() = %o, want %o", mode, tt.want)
+			}
+			if validateErr := cfg.Validate(); (validateErr != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", validateErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_Validate_AllowedHosts(t *testing.T) {
+	for _, hosts := range [][]string{nil, {"sftp.partner.example", "10.0.0.0/8", "192.0.2.10"}, {"10.0.0.0/33"}} {
+		cfg := EnvConfig{Input: testSomeInput, Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}}}
+		cfg.Targets.AllowedHosts = hosts
+
+		err := cfg.Validate()
+		if wantErr := len(hosts) == 1; (err != nil) != wantErr {
+			t.Errorf("Validate() with allowed-hosts %v error = %v, wantErr %v", hosts, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_SuccessMarker(t *testing.T) {
+	for _, marker := range []string{"", "_SUCCESS", ".done", "..", "batch/_SUCCESS"} {
+		cfg := EnvConfig{Input: testSomeInput, Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}}}
+		cfg.Transfer.SuccessMarker = marker
+
+		err := cfg.Validate()
+		if wantErr := marker == ".." || marker == "batch/_SUCCESS"; (err != nil) != wantErr {
+			t.Errorf("Validate() with success-marker %q error = %v, wantErr %v", marker, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_FilesystemTempSuffix(t *testing.T) {
+	for _, suffix := range []string{"", ".partial", "~tmp", "../x", `\x`} {
+		cfg := EnvConfig{Input: testSomeInput, Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}}}
+		cfg.Filesystem.TempSuffix = suffix
+
+		err := cfg.Validate()
+		if wantErr := suffix == "../x" || suffix == `\x`; (err != nil) != wantErr {
+			t.Errorf("Validate() with temp-suffix %q error = %v, wantErr %v", suffix, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_FileStabilityMode(t *testing.T) {
+	for _, mode := range []string{"", FileStabilityModeStat, FileStabilityModeChecksum, "inotify"} {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.FileStability.Mode = mode
+
+		err := cfg.Validate()
+		if wantErr := mode == "inotify"; (err != nil) != wantErr {
+			t.Errorf("Validate() with file-stability mode %q error = %v, wantErr %v", mode, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_InputTypeChange(t *testing.T) {
+	for _, typeChange := range []string{"", InputTypeChangeWatch, InputTypeChangeSkip, "follow"} {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.InputOptions.TypeChange = typeChange
+
+		err := cfg.Validate()
+		if wantErr := typeChange == "follow"; (err != nil) != wantErr {
+			t.Errorf("Validate() with type-change %q error = %v, wantErr %v", typeChange, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_HealthOnBindError(t *testing.T) {
+	for _, onBindError := range []string{"", HealthOnBindErrorExit, HealthOnBindErrorUnhealthy, "ignore"} {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.Health.OnBindError = onBindError
+
+		err := cfg.Validate()
+		if wantErr := onBindError == "ignore"; (err != nil) != wantErr {
+			t.Errorf("Validate() with on-bind-error %q error = %v, wantErr %v", onBindError, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_HealthMaxConcurrentProbes(t *testing.T) {
+	for _, probes := range []int{0, 1, 16, -1} {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.Health.MaxConcurrentProbes = probes
+
+		err := cfg.Validate()
+		if wantErr := probes < 0; (err != nil) != wantErr {
+			t.Errorf("Validate() with max-concurrent-probes %d error = %v, wantErr %v", probes, err, wantErr)
+		}
+	}
+	if got := (HealthConfig{}).ProbeConcurrency(); got != DefaultMaxConcurrentProbes {
+		t.Errorf("ProbeConcurrency() default = %d, want %d", got, DefaultMaxConcurrentProbes)
+	}
+}
+
+func TestEnvConfig_Validate_RequireNonEmpty(t *testing.T) {
+	tests := []struct {
+		once    bool
+		wantErr bool
+	}{
+		{once: true},
+		{once: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+			Once:   tt.once,
+		}
+		cfg.InputOptions.RequireNonEmpty = true
+
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with require-non-empty and once %v error = %v, wantErr %v", tt.once, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_Tracing(t *testing.T) {
+	tests := []struct {
+		enabled  bool
+		endpoint string
+		wantErr  bool
+	}{
+		{},
+		{endpoint: "otel-collector:4318"},
+		{enabled: true, endpoint: "otel-collector:4318"},
+		{enabled: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.Tracing.Enabled = tt.enabled
+		cfg.Tracing.Endpoint = tt.endpoint
+
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with tracing enabled %v and endpoint %q error = %v, wantErr %v", tt.enabled, tt.endpoint, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_TLSMinVersion(t *testing.T) {
+	tests := []struct {
+		minVersion string
+		want       uint16
+		wantErr    bool
+	}{
+		{minVersion: "", want: tls.VersionTLS12},
+		{minVersion: TLSVersion12, want: tls.VersionTLS12},
+		{minVersion: TLSVersion13, want: tls.VersionTLS13},
+		{minVersion: "1.1", wantErr: true},
+		{minVersion: "tls1.3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.TLS.MinVersion = tt.minVersion
+
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with tls min-version %q error = %v, wantErr %v", tt.minVersion, err, tt.wantErr)
+		}
+		if got, _ := cfg.TLS.MinTLSVersion(); !tt.wantErr && got != tt.want {
+			t.Errorf("MinTLSVersion() for %q = %#x, want %#x", tt.minVersion, got, tt.want)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_InputSizeOrder(t *testing.T) {
+	tests := []struct {
+		sizeOrder string
+		order     string
+		wantErr   bool
+	}{
+		{sizeOrder: ""},
+		{sizeOrder: InputSizeOrderNone},
+		{sizeOrder: InputSizeOrderSmallest},
+		{sizeOrder: InputSizeOrderLargest},
+		{sizeOrder: InputSizeOrderNone, order: InputOrderName},
+		{sizeOrder: InputSizeOrderLargest, order: InputOrderMtime, wantErr: true},
+		{sizeOrder: "biggest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.InputOptions.SizeOrder = tt.sizeOrder
+		cfg.InputOptions.Order = tt.order
+
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with size-order %q and order %q error = %v, wantErr %v", tt.sizeOrder, tt.order, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_InputCompletionMarkerSuffix(t *testing.T) {
+	for _, suffix := range []string{"", ".done", "_READY", "/done", `\done`, "."} {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.InputOptions.CompletionMarkerSuffix = suffix
+
+		err := cfg.Validate()
+		if wantErr := suffix == "/done" || suffix == `\done` || suffix == "."; (err != nil) != wantErr {
+			t.Errorf("Validate() with completion-marker-suffix %q error = %v, wantErr %v", suffix, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_InputPauseLockFile(t *testing.T) {
+	for _, lockFile := range []string{"", ".shifter-pause", "PAUSE", "maintenance/pause", `..\pause`, ".."} {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.InputOptions.PauseLockFile = lockFile
+
+		err := cfg.Validate()
+		if wantErr := strings.ContainsAny(lockFile, `/\`) || lockFile == ".."; (err != nil) != wantErr {
+			t.Errorf("Validate() with pause-lock-file %q error = %v, wantErr %v", lockFile, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_PrecreateDirs(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  OutputTarget
+		wantErr bool
+	}{
+		{"relative dirs", OutputTarget{Path: testSomeOutput, Type: "filesystem", PrecreateDirs: []string{"in/a", "out"}}, false},
+		{"absolute dir", OutputTarget{Path: testSomeOutput, Type: "filesystem", PrecreateDirs: []string{"/etc"}}, true},
+		{"escaping dir", OutputTarget{Path: testSomeOutput, Type: "filesystem", PrecreateDirs: []string{"../up"}}, true},
+		{"kafka target", OutputTarget{Path: "kafka://broker:9092/topic", Type: "kafka", PrecreateDirs: []string{"in"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := EnvConfig{Input: testSomeInput, Output: []OutputTarget{tt.target}}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_Validate_Rename(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  OutputTarget
+		wantErr bool
+	}{
+		{"no rename", OutputTarget{Path: testSomeOutput, Type: "filesystem"}, false},
+		{"regex and replacement", OutputTarget{Path: testSomeOutput, Type: "filesystem", RenameRegex: `-\d{8}(\.csv)$`, RenameReplace: "$1"}, false},
+		{"regex without replacement", OutputTarget{Path: testSomeOutput, Type: "filesystem", RenameRegex: `\.tmp$`}, false},
+		{"invalid regex", OutputTarget{Path: testSomeOutput, Type: "filesystem", RenameRegex: `data-(\d+`}, true},
+		{"replacement without regex", OutputTarget{Path: testSomeOutput, Type: "filesystem", RenameReplace: "$1"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := EnvConfig{Input: testSomeInput, Output: []OutputTarget{tt.target}}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_Validate_QueueSize(t *testing.T) {
+	tests := []struct {
+		queueSize int
+		defaults  bool
+		wantErr   bool
+	}{
+		{queueSize: 100},
+		{queueSize: 0, defaults: true},
+		{queueSize: -1, wantErr: true},
+		{queueSize: -1, defaults: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		cfg := EnvConfig{Input: testSomeInput, Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}}}
+		cfg.WorkerPool.QueueSize = tt.queueSize
+		if tt.defaults {
+			cfg.SetDefaults()
+		}
+
+		err := cfg.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with queue-size %d (defaults %v) error = %v, wantErr %v", tt.queueSize, tt.defaults, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_FileTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		fileTimeout   int
+		deadLetterDir string
+		wantErr       bool
+	}{
+		{name: "off"},
+		{name: "with dead-letter dir", fileTimeout: 60000, deadLetterDir: "/var/lib/file-shifter/dead-letter"},
+		{name: "without dead-letter dir", fileTimeout: 60000, wantErr: true},
+		{name: "dead-letter dir inside input", fileTimeout: 60000, deadLetterDir: testSomeInput + "/dead-letter", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := EnvConfig{Input: testSomeInput, Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}}}
+			cfg.Transfer.FileTimeout = tt.fileTimeout
+			cfg.Transfer.DeadLetterDir = tt.deadLetterDir
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_Validate_Batch(t *testing.T) {
+	s3Target := func(interval, maxFiles int) OutputTarget {
+		return OutputTarget{
+			Path: "s3://bucket/batches", Type: "s3", Endpoint: "minio:9000", AccessKey: "access", SecretKey: "secret", Region: "eu-central-1",
+			BatchInterval: interval, BatchMaxFiles: maxFiles,
+		}
+	}
+	tests := []struct {
+		name    string
+		target  OutputTarget
+		wantErr bool
+	}{
+		{"no batch", s3Target(0, 0), false},
+		{"interval", s3Target(60000, 0), false},
+		{"interval and max files", s3Target(60000, 100), false},
+		{"max files without interval", s3Target(0, 100), true},
+		{"negative interval", s3Target(-1, 0), true},
+		{"filesystem target", OutputTarget{Path: testSomeOutput, Type: "filesystem", BatchInterval: 60000}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := EnvConfig{Input: testSomeInput, Output: []OutputTarget{tt.target}}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_Validate_S3SignatureVersion(t *testing.T) {
+	for _, version := range []string{"", S3SignatureV2, S3SignatureV4, "v3"} {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.S3.SignatureVersion = version
+
+		err := cfg.Validate()
+		if wantErr := version == "v3"; (err != nil) != wantErr {
+			t.Errorf("Validate() with signature-version %q error = %v, wantErr %v", version, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_Schedule(t *testing.T) {
+	for _, schedule := range []string{"", "22:00-06:00", "08:00-09:00,13:00-14:00", "22:00", "25:00-26:00", "10:00-10:00"} {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem", Schedule: schedule}},
+		}
+
+		err := cfg.Validate()
+		wantErr := schedule == "22:00" || schedule == "25:00-26:00" || schedule == "10:00-10:00"
+		if (err != nil) != wantErr {
+			t.Errorf("Validate() with schedule %q error = %v, wantErr %v", schedule, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_InputOrder(t *testing.T) {
+	for _, order := range []string{"", InputOrderName, InputOrderMtime, "size"} {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+		}
+		cfg.InputOptions.Order = order
+
+		err := cfg.Validate()
+		if wantErr := order == "size"; (err != nil) != wantErr {
+			t.Errorf("Validate() with order %q error = %v, wantErr %v", order, err, wantErr)
+		}
+	}
+}
+
+func TestEnvConfig_Validate_Mode(t *testing.T) {
+	for _, mode := range []string{"", ModeMove, ModeCopy, "mirror"} {
+		cfg := EnvConfig{
+			Input:  testSomeInput,
+			Output: []OutputTarget{{Path: testSomeOutput, Type: "filesystem"}},
+			Mode:   mode,
+		}
+
+		err := cfg.Validate()
+		if wantErr := mode == "mirror"; (err != nil) != wantErr {
+			t.Errorf("Validate() with mode %q error = %v, wantErr %v", mode, err, wantErr)
+		}
+	}
+}
@@ -5,4 +5,6 @@ type FTPConfig struct {
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
 	Port     int    `yaml:"port"` // Optional, default 21 for FTP, 22 for SFTP
+	// PathSeparator of remote paths, a backslash for FTP servers on Windows (empty = "/")
+	PathSeparator string `yaml:"path-separator"`
 }
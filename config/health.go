@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// HealthConfig holds the configuration of the health monitoring server
+type HealthConfig struct {
+	AuthToken   string `yaml:"auth-token"`   // Optional shared secret required for all non-liveness endpoints
+	EnablePprof bool   `yaml:"enable-pprof"` // Register /debug/pprof/* handlers (off by default)
+
+	DiskWarnFreeMB     int `yaml:"disk-warn-free-mb"`     // Filesystem targets below this free space report degraded (0 = off)
+	DiskCriticalFreeMB int `yaml:"disk-critical-free-mb"` // Filesystem targets below this free space report unhealthy (0 = off)
+
+	MaxConcurrentProbes int `yaml:"max-concurrent-probes"` // Target probes of a health check running at once (default 4)
+
+	QueueDegradedPercent  int `yaml:"queue-degraded-percent"`  // Queue fill above this reports degraded (default 80)
+	QueueUnhealthyPercent int `yaml:"queue-unhealthy-percent"` // Queue fill above this reports unhealthy (default 90)
+
+	MaxQueueAge int `yaml:"max-queue-age"` // Milliseconds a file may wait in the queue before the worker pool reports degraded, twice as long unhealthy (0 = off)
+
+	ReadHeaderTimeout int `yaml:"read-header-timeout"` // Milliseconds to read the request headers (default 5000)
+	ReadTimeout       int `yaml:"read-timeout"`        // Milliseconds to read the whole request (default 10000)
+	WriteTimeout      int `yaml:"write-timeout"`       // Milliseconds to write the response (default 60000, covers 30 s pprof profiles)
+
+	OnBindError string `yaml:"on-bind-error"` // Health port not available: "exit" (default) fails the start, "unhealthy" runs on and reports unhealthy
+}
+
+// Supported values of HealthConfig.OnBindError
+const (
+	HealthOnBindErrorExit      = "exit"
+	HealthOnBindErrorUnhealthy = "unhealthy"
+)
+
+// Default queue fill thresholds in percent
+const (
+	DefaultQueueDegradedPercent  = 80
+	DefaultQueueUnhealthyPercent = 90
+)
+
+// DefaultMaxConcurrentProbes bounds the target probes of a health check if MaxConcurrentProbes is not set
+const DefaultMaxConcurrentProbes = 4
+
+// Default health server timeouts in milliseconds
+const (
+	DefaultHealthReadHeaderTimeout = 5000
+	DefaultHealthReadTimeout       = 10000
+	DefaultHealthWriteTimeout      = 60000
+)
+
+// ServerTimeouts returns the health server timeouts, unset values fall back to the defaults
+func (h HealthConfig) ServerTimeouts() (readHeader, read, write time.Duration) {
+	milliseconds := func(value, defaultValue int) time.Duration {
+		if value <= 0 {
+			value = defaultValue
+		}
+		return time.Duration(value) * time.Millisecond
+	}
+	return milliseconds(h.ReadHeaderTimeout, DefaultHealthReadHeaderTimeout),
+		milliseconds(h.ReadTimeout, DefaultHealthReadTimeout),
+		milliseconds(h.WriteTimeout, DefaultHealthWriteTimeout)
+}
+
+// QueueThresholds returns the queue fill thresholds in percent, unset values fall back to the defaults
+func (h HealthConfig) QueueThresholds() (degraded, unhealthy int) {
+	degraded, unhealthy = h.QueueDegradedPercent, h.QueueUnhealthyPercent
+	if degraded == 0 {
+		degraded = DefaultQueueDegradedPercent
+	}
+	if unhealthy == 0 {
+		unhealthy = DefaultQueueUnhealthyPercent
+	}
+	return degraded, unhealthy
+}
+
+// ProbeConcurrency returns MaxConcurrentProbes, DefaultMaxConcurrentProbes if it is not set
+func (h HealthConfig) ProbeConcurrency() int {
+	if h.MaxConcurrentProbes <= 0 {
+		return DefaultMaxConcurrentProbes
+	}
+	return h.MaxConcurrentProbes
+}
+
+// validateMaxConcurrentProbes rejects negative limits, 0 selects the default
+func (h HealthConfig) validateMaxConcurrentProbes() error {
+	if h.MaxConcurrentProbes < 0 {
+		return fmt.Errorf("invalid health max-concurrent-probes %d: must be positive", h.MaxConcurrentProbes)
+	}
+	return nil
+}
+
+// validateQueueThresholds requires 0 < degraded < unhealthy <= 100
+func (h HealthConfig) validateQueueThresholds() error {
+	degraded, unhealthy := h.QueueThresholds()
+	if degraded <= 0 || degraded >= unhealthy || unhealthy > 100 {
+		return fmt.Errorf("invalid health queue thresholds: degraded %d%%, unhealthy %d%% (required: 0 < degraded < unhealthy <= 100)",
+			degraded, unhealthy)
+	}
+	return nil
+}
+
+// validateOnBindError checks OnBindError against the supported values
+func (h HealthConfig) validateOnBindError() error {
+	switch h.OnBindError {
+	case "", HealthOnBindErrorExit, HealthOnBindErrorUnhealthy:
+		return nil
+	default:
+		return fmt.Errorf("invalid health on-bind-error %q (allowed: %s, %s)", h.OnBindError, HealthOnBindErrorExit, HealthOnBindErrorUnhealthy)
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Supported values of InputConfig.Order
+const (
+	InputOrderName  = "name"
+	InputOrderMtime = "mtime"
+)
+
+// Supported values of InputConfig.SizeOrder
+const (
+	InputSizeOrderNone     = "none"
+	InputSizeOrderSmallest = "smallest"
+	InputSizeOrderLargest  = "largest"
+)
+
+// Supported values of InputConfig.TypeChange
+const (
+	InputTypeChangeWatch = "watch"
+	InputTypeChangeSkip  = "skip"
+)
+
+// DefaultInputDirMode is used to create a missing input directory if no DirMode is configured
+const DefaultInputDirMode os.FileMode = 0755
+
+// InputConfig holds additional options for the input directory
+type InputConfig struct {
+	// WatchPatterns restricts watching and scanning to matching subdirectories (relative to the input directory).
+	// Patterns use filepath.Match syntax per path segment, "**" matches any number of segments.
+	WatchPatterns []string `yaml:"watch-patterns"`
+	// SkipHiddenDirs neither watches nor scans directories whose name starts with a dot (e.g. .git).
+	// Snapshot directories like .snapshot are always skipped.
+	SkipHiddenDirs bool `yaml:"skip-hidden-dirs"`
+	// Decompress delivers *.gz files decompressed under the name without the .gz suffix
+	Decompress bool `yaml:"decompress"`
+	// DirMode is the octal permission mode (e.g. "0700") used to create a missing input directory
+	DirMode string `yaml:"dir-mode"`
+	// Order processes existing files serially by "name" or "mtime" during a scan (empty = concurrent, unordered)
+	Order string `yaml:"order"`
+	// SizeOrder hands existing files to the worker pool "smallest" or "largest" first during a scan
+	// ("none" or empty = in scan order); it cannot be combined with Order
+	SizeOrder string `yaml:"size-order"`
+	// DeleteDelay keeps delivered source files for this many milliseconds before removing them (0 = immediately)
+	DeleteDelay int `yaml:"delete-delay"`
+	// RenameComplete treats files renamed into place within the input directory (foo.tmp -> foo) as complete,
+	// the stability check is skipped for them.
+	RenameComplete bool `yaml:"rename-complete"`
+	// RenameCompletePatterns are file name patterns (e.g. "*.csv") of producers that rename files into the
+	// input directory from elsewhere; a created file matching one of them is complete immediately.
+	RenameCompletePatterns []string `yaml:"rename-complete-patterns"`
+	// CompletionMarkerSuffix only treats a file as complete once a companion marker <name><suffix> (e.g. ".done")
+	// exists; the marker is not transferred and is deleted together with the source file
+	CompletionMarkerSuffix string `yaml:"completion-marker-suffix"`
+	// WatchRemoveGrace delays dropping the watch of a removed or renamed directory by this many milliseconds,
+	// a directory recreated in the meantime keeps being watched (0 = services default)
+	WatchRemoveGrace int `yaml:"watch-remove-grace"`
+	// TypeChange handles a file event whose path has become a directory: "watch" (default) watches and scans
+	// the directory, "skip" only logs it and leaves the directory to its own events
+	TypeChange string `yaml:"type-change"`
+	// AllowContentTypes only transfers files whose content, sniffed from the first 512 bytes, has one of these
+	// media types (e.g. "text/plain", "text/*"); other files are skipped and left in place (empty = all)
+	AllowContentTypes []string `yaml:"allow-content-types"`
+	// PauseLockFile halts processing while a file with this name (e.g. ".shifter-pause") exists in the input
+	// root; the lock file itself is never transferred (empty = off)
+	PauseLockFile string `yaml:"pause-lock-file"`
+	// HashCache remembers size, modification time and checksum of delivered files that stay in the input
+	// directory, rescans and events skip them while size and modification time are unchanged
+	HashCache bool `yaml:"hash-cache"`
+	// RequireNonEmpty fails a single run (--once) if the scan finds no file to process, e.g. because an upstream
+	// job did not deliver
+	RequireNonEmpty bool `yaml:"require-non-empty"`
+}
+
+// validateOrder checks Order against the supported values
+func (c InputConfig) validateOrder() error {
+	switch c.Order {
+	case "", InputOrderName, InputOrderMtime:
+		return nil
+	default:
+		return fmt.Errorf("invalid input order %q (allowed: %s, %s)", c.Order, InputOrderName, InputOrderMtime)
+	}
+}
+
+// validateSizeOrder checks SizeOrder against the supported values and rejects it together with Order
+func (c InputConfig) validateSizeOrder() error {
+	switch c.SizeOrder {
+	case "", InputSizeOrderNone:
+		return nil
+	case InputSizeOrderSmallest, InputSizeOrderLargest:
+		if c.Order != "" {
+			return fmt.Errorf("input size-order %q cannot be combined with order %q", c.SizeOrder, c.Order)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid input size-order %q (allowed: %s, %s, %s)", c.SizeOrder,
+			InputSizeOrderNone, InputSizeOrderSmallest, InputSizeOrderLargest)
+	}
+}
+
+// validateCompletionMarkerSuffix rejects suffixes that would name a path instead of a sibling file
+func (c InputConfig) validateCompletionMarkerSuffix() error {
+	if strings.ContainsAny(c.CompletionMarkerSuffix, `/\`) || c.CompletionMarkerSuffix == "." {
+		return fmt.Errorf("invalid input completion-marker-suffix %q: must not contain path separators", c.CompletionMarkerSuffix)
+	}
+	return nil
+}
+
+// validatePauseLockFile accepts only a plain file name, the lock file is looked up in the input root
+func (c InputConfig) validatePauseLockFile() error {
+	if strings.ContainsAny(c.PauseLockFile, `/\`) || c.PauseLockFile == "." || c.PauseLockFile == ".." {
+		return fmt.Errorf("invalid input pause-lock-file %q: must be a file name without path separators", c.PauseLockFile)
+	}
+	return nil
+}
+
+// validateTypeChange checks TypeChange against the supported values
+func (c InputConfig) validateTypeChange() error {
+	switch c.TypeChange {
+	case "", InputTypeChangeWatch, InputTypeChangeSkip:
+		return nil
+	default:
+		return fmt.Errorf("invalid input type-change %q (allowed: %s, %s)", c.TypeChange, InputTypeChangeWatch, InputTypeChangeSkip)
+	}
+}
+
+// InputDirMode returns the parsed DirMode, DefaultInputDirMode if it is not set
+func (c InputConfig) InputDirMode() (os.FileMode, error) {
+	if c.DirMode == "" {
+		return DefaultInputDirMode, nil
+	}
+
+	return parsePermission(c.DirMode, "input dir-mode", "0700")
+}
+
+// parsePermission parses an octal permission mode, name and example are used in the error message
+func parsePermission(value, name, example string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid %s %q: expected an octal permission like %s", name, value, example)
+	}
+	return os.FileMode(mode), nil
+}
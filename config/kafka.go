@@ -0,0 +1,41 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// DefaultKafkaMaxInlineBytes is the largest file published as message content, larger files are published as reference
+const DefaultKafkaMaxInlineBytes = 512 * 1024
+
+type KafkaConfig struct {
+	Brokers        []string
+	Topic          string
+	TLS            bool   // kafkas:// connects with TLS
+	MaxInlineBytes int    // Files up to this size are published with their content
+	ReferenceURL   string // Base URL of the reference published for larger files (empty = larger files fail)
+}
+
+// GetKafkaConfig extracts the Kafka configuration from a target path like kafka://broker1:9092,broker2:9092/topic
+func (ot *OutputTarget) GetKafkaConfig() KafkaConfig {
+	kafkaConfig := KafkaConfig{
+		MaxInlineBytes: ot.MaxInlineBytes,
+		ReferenceURL:   ot.ReferenceURL,
+	}
+	if kafkaConfig.MaxInlineBytes == 0 {
+		kafkaConfig.MaxInlineBytes = DefaultKafkaMaxInlineBytes
+	}
+
+	u, err := url.Parse(ot.Path)
+	if err != nil || (u.Scheme != "kafka" && u.Scheme != "kafkas") {
+		return kafkaConfig
+	}
+	kafkaConfig.TLS = u.Scheme == "kafkas"
+	for _, broker := range strings.Split(u.Host, ",") {
+		if broker != "" {
+			kafkaConfig.Brokers = append(kafkaConfig.Brokers, broker)
+		}
+	}
+	kafkaConfig.Topic = strings.Trim(u.Path, "/")
+	return kafkaConfig
+}
@@ -7,21 +7,63 @@ import (
 )
 
 type OutputTarget struct {
-	Path string `yaml:"path"`
-	Type string `yaml:"type"`
+	Path string `json:"path" yaml:"path"`
+	Type string `json:"type" yaml:"type"`
+	// StripPrefix removes these leading path segments (e.g. "staging/customer") from the relative path before
+	// building the destination; files outside the prefix keep their path
+	StripPrefix string `json:"strip-prefix,omitempty" yaml:"strip-prefix,omitempty"`
+	// RenameRegex is matched against the slash separated relative path (after StripPrefix), matches are replaced
+	// with RenameReplace ($1 etc. refer to groups), e.g. "-\d{8}(\.csv)$" and "$1" deliver data-20240101.csv as data.csv
+	RenameRegex   string `json:"rename-regex,omitempty" yaml:"rename-regex,omitempty"`
+	RenameReplace string `json:"rename-replace,omitempty" yaml:"rename-replace,omitempty"`
+	// PrecreateDirs are created below the target path at startup (filesystem/FTP/SFTP directories,
+	// empty "<dir>/" marker objects on S3)
+	PrecreateDirs []string `json:"precreate-dirs,omitempty" yaml:"precreate-dirs,omitempty"`
+	// Schedule restricts deliveries to daily time windows in local time, e.g. "22:00-06:00" (empty = always)
+	Schedule string `json:"schedule,omitempty" yaml:"schedule,omitempty"`
 
 	// S3-spezifische Konfiguration
-	Endpoint  string `yaml:"endpoint,omitempty"`
-	AccessKey string `yaml:"access-key,omitempty"`
-	SecretKey string `yaml:"secret-key,omitempty"`
-	SSL       *bool  `yaml:"ssl,omitempty"`
-	Region    string `yaml:"region,omitempty"`
+	Endpoint  string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	AccessKey string `json:"access-key,omitempty" yaml:"access-key,omitempty"`
+	SecretKey string `json:"secret-key,omitempty" yaml:"secret-key,omitempty"`
+	SSL       *bool  `json:"ssl,omitempty" yaml:"ssl,omitempty"`
+	Region    string `json:"region,omitempty" yaml:"region,omitempty"`
+	// AdditionalKeys uploads the object to further keys in the same bucket (templated, see README)
+	AdditionalKeys []string `json:"additional-keys,omitempty" yaml:"additional-keys,omitempty"`
+	// UserMetadata is stored as x-amz-meta-* on every object (values are templated like AdditionalKeys)
+	UserMetadata map[string]string `json:"user-metadata,omitempty" yaml:"user-metadata,omitempty"`
+	// StoreChecksumMetadata stores the SHA256 of the file as x-amz-meta-sha256
+	StoreChecksumMetadata bool `json:"store-checksum-metadata,omitempty" yaml:"store-checksum-metadata,omitempty"`
+	// CreateBucketIfMissing creates a missing bucket before the upload (default true)
+	CreateBucketIfMissing *bool `json:"create-bucket-if-missing,omitempty" yaml:"create-bucket-if-missing,omitempty"`
+	// ACL is the canned ACL set on uploaded objects (x-amz-acl), see S3CannedACLs
+	ACL string `json:"acl,omitempty" yaml:"acl,omitempty"`
+	// DisableContentTypeDetection uploads every object as application/octet-stream
+	DisableContentTypeDetection bool `json:"disable-content-type-detection,omitempty" yaml:"disable-content-type-detection,omitempty"`
+	// MaxObjectSize rejects files larger than this many bytes before uploading them (0 = unlimited)
+	MaxObjectSize int64 `json:"max-object-size,omitempty" yaml:"max-object-size,omitempty"`
+	// BatchInterval collects files for this many milliseconds and uploads them as one .tar.gz archive with a
+	// JSON manifest object; the source files are removed after the upload (0 = every file is uploaded on its own)
+	BatchInterval int `json:"batch-interval,omitempty" yaml:"batch-interval,omitempty"`
+	// BatchMaxFiles uploads a batch before its interval has passed once it holds this many files (0 = no limit)
+	BatchMaxFiles int `json:"batch-max-files,omitempty" yaml:"batch-max-files,omitempty"`
 
 	// FTP/SFTP-spezifische Konfiguration
-	Host     string `yaml:"host,omitempty"`
-	Username string `yaml:"username,omitempty"`
-	Password string `yaml:"password,omitempty"`
-	Port     int    `yaml:"port,omitempty"`
+	Host     string `json:"host,omitempty" yaml:"host,omitempty"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	Port     int    `json:"port,omitempty" yaml:"port,omitempty"`
+	// RemotePathSeparator is the path separator of FTP servers, a backslash for Windows servers (default "/")
+	RemotePathSeparator string `json:"remote-path-separator,omitempty" yaml:"remote-path-separator,omitempty"`
+
+	// Kafka-spezifische Konfiguration (Path: kafka://broker1:9092,broker2:9092/topic)
+	// MaxInlineBytes is the largest file published with its content (default DefaultKafkaMaxInlineBytes)
+	MaxInlineBytes int `json:"max-inline-bytes,omitempty" yaml:"max-inline-bytes,omitempty"`
+	// ReferenceURL is the base URL (e.g. of an S3 target) published as reference for larger files
+	ReferenceURL string `json:"reference-url,omitempty" yaml:"reference-url,omitempty"`
+
+	// Transfer limits for this target, combined with the global transfer limits
+	Transfer TargetTransferConfig `json:"transfer,omitzero" yaml:"transfer,omitempty"`
 }
 
 // GetS3Config extrahiert die S3-Konfiguration aus dem OutputTarget
@@ -36,9 +78,28 @@ func (ot *OutputTarget) GetS3Config() S3Config {
 		SecretKey: ot.SecretKey,
 		SSL:       ssl,
 		Region:    ot.Region,
+
+		DisableContentTypeDetection: ot.DisableContentTypeDetection,
+		MaxObjectSize:               ot.MaxObjectSize,
 	}
 }
 
+// S3CannedACLs are the allowed values for OutputTarget.ACL
+var S3CannedACLs = []string{
+	"private",
+	"public-read",
+	"public-read-write",
+	"authenticated-read",
+	"aws-exec-read",
+	"bucket-owner-read",
+	"bucket-owner-full-control",
+}
+
+// BucketCreationEnabled reports whether a missing bucket may be created (default true)
+func (ot *OutputTarget) BucketCreationEnabled() bool {
+	return ot.CreateBucketIfMissing == nil || *ot.CreateBucketIfMissing
+}
+
 // GetFTPConfig extrahiert die FTP-Konfiguration aus dem OutputTarget
 func (ot *OutputTarget) GetFTPConfig() FTPConfig {
 	host := ot.Host
@@ -53,10 +114,11 @@ func (ot *OutputTarget) GetFTPConfig() FTPConfig {
 	}
 
 	return FTPConfig{
-		Host:     host,
-		Username: ot.Username,
-		Password: ot.Password,
-		Port:     port,
+		Host:          host,
+		Username:      ot.Username,
+		Password:      ot.Password,
+		Port:          port,
+		PathSeparator: ot.RemotePathSeparator,
 	}
 }
 
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output targets can be configured via environment variables in three formats.
+// Precedence (first non-empty wins):
+//  1. flat:   OUTPUT_<X>_PATH, OUTPUT_<X>_TYPE, OUTPUT_<X>_ACCESS_KEY, ...
+//  2. dotted: output.<N>.path, output.<N>.type, output.<N>.access_key, ...
+//  3. JSON/YAML array in OUTPUTS, using the same field names as env.yaml
+// All formats support the same set of fields, see outputTargetFields.
+
+// ErrInvalidOutputsEnv is returned for an OUTPUTS value that is neither a JSON nor a YAML list of targets
+var ErrInvalidOutputsEnv = errors.New("output targets are neither valid JSON nor YAML")
+
+// outputTargetField is a target property that can be set from a string value.
+// name is the snake_case field name used in the environment formats.
+type outputTargetField struct {
+	name  string
+	apply func(target *OutputTarget, value string) error
+}
+
+var outputTargetFields = []outputTargetField{
+	{"type", setString(func(t *OutputTarget) *string { return &t.Type })},
+	{"strip_prefix", setString(func(t *OutputTarget) *string { return &t.StripPrefix })},
+	{"rename_regex", setString(func(t *OutputTarget) *string { return &t.RenameRegex })},
+	{"rename_replace", setString(func(t *OutputTarget) *string { return &t.RenameReplace })},
+	{"schedule", setString(func(t *OutputTarget) *string { return &t.Schedule })},
+	{"precreate_dirs", func(t *OutputTarget, v string) error {
+		t.PrecreateDirs = splitList(v)
+		return nil
+	}},
+	{"endpoint", setString(func(t *OutputTarget) *string { return &t.Endpoint })},
+	{"access_key", setString(func(t *OutputTarget) *string { return &t.AccessKey })},
+	{"secret_key", setString(func(t *OutputTarget) *string { return &t.SecretKey })},
+	{"ssl", func(t *OutputTarget, v string) error {
+		ssl, err := parseBool(v)
+		if err != nil {
+			return err
+		}
+		t.SSL = toBoolPtr(ssl)
+		return nil
+	}},
+	{"region", setString(func(t *OutputTarget) *string { return &t.Region })},
+	{"acl", setString(func(t *OutputTarget) *string { return &t.ACL })},
+	{"max_object_size", func(t *OutputTarget, v string) error {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		t.MaxObjectSize = limit
+		return nil
+	}},
+	{"batch_interval", func(t *OutputTarget, v string) error {
+		interval, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		t.BatchInterval = interval
+		return nil
+	}},
+	{"batch_max_files", func(t *OutputTarget, v string) error {
+		maxFiles, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		t.BatchMaxFiles = maxFiles
+		return nil
+	}},
+	{"disable_content_type_detection", func(t *OutputTarget, v string) error {
+		disable, err := parseBool(v)
+		if err != nil {
+			return err
+		}
+		t.DisableContentTypeDetection = disable
+		return nil
+	}},
+	{"host", setString(func(t *OutputTarget) *string { return &t.Host })},
+	{"username", setString(func(t *OutputTarget) *string { return &t.Username })},
+	{"password", setString(func(t *OutputTarget) *string { return &t.Password })},
+	{"remote_path_separator", setString(func(t *OutputTarget) *string { return &t.RemotePathSeparator })},
+	{"port", func(t *OutputTarget, v string) error {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		t.Port = port
+		return nil
+	}},
+	{"max_inline_bytes", func(t *OutputTarget, v string) error {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		t.MaxInlineBytes = limit
+		return nil
+	}},
+	{"reference_url", setString(func(t *OutputTarget) *string { return &t.ReferenceURL })},
+	{"max_bytes_per_sec", func(t *OutputTarget, v string) error {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		t.Transfer.MaxBytesPerSec = limit
+		return nil
+	}},
+}
+
+func setString(field func(t *OutputTarget) *string) func(*OutputTarget, string) error {
+	return func(t *OutputTarget, v string) error {
+		*field(t) = v
+		return nil
+	}
+}
+
+// outputEnvFormat describes how the environment variable of a target field is named
+type outputEnvFormat struct {
+	key         func(index, field string) string
+	requireType bool
+}
+
+var (
+	flatOutputFormat = outputEnvFormat{
+		key: func(index, field string) string {
+			return "OUTPUT_" + index + "_" + strings.ToUpper(field)
+		},
+	}
+	dottedOutputFormat = outputEnvFormat{
+		key: func(index, field string) string {
+			return "output." + index + "." + field
+		},
+		requireType: true,
+	}
+)
+
+// readOutputTarget reads a single target in the given format, the path is always required
+func readOutputTarget(format outputEnvFormat, index string) (OutputTarget, bool) {
+	target := OutputTarget{Path: os.Getenv(format.key(index, "path"))}
+	if target.Path == "" {
+		return OutputTarget{}, false
+	}
+
+	for _, field := range outputTargetFields {
+		key := format.key(index, field.name)
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+		if err := field.apply(&target, value); err != nil {
+			slog.Warn("Ignoring invalid output target value", "key", key, "value", value, "error", err)
+		}
+	}
+
+	if format.requireType && target.Type == "" {
+		return OutputTarget{}, false
+	}
+	return target, true
+}
+
+// readOutputTargets reads all targets for the given indices in index order
+func readOutputTargets(format outputEnvFormat, indices []string) []OutputTarget {
+	sortIndices(indices)
+
+	var targets []OutputTarget
+	for _, index := range indices {
+		if target, ok := readOutputTarget(format, index); ok {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// flatOutputIndices returns all X for which OUTPUT_X_PATH is set
+func flatOutputIndices() []string {
+	var indices []string
+	for _, env := range os.Environ() {
+		key, _, ok := splitEnvVar(env)
+		if !ok {
+			continue
+		}
+		if index, ok := outputPathIndex(key); ok {
+			indices = append(indices, index)
+		}
+	}
+	return indices
+}
+
+// dottedOutputIndices returns the bounded range of scanned output.N indices
+func dottedOutputIndices() []string {
+	indices := make([]string, 0, maxYAMLOutputIndex+1)
+	for i := 0; i <= maxYAMLOutputIndex; i++ {
+		indices = append(indices, strconv.Itoa(i))
+	}
+	return indices
+}
+
+// sortIndices orders numeric indices numerically and any other indices lexically after them
+func sortIndices(indices []string) {
+	sort.SliceStable(indices, func(i, j int) bool {
+		a, errA := strconv.Atoi(indices[i])
+		b, errB := strconv.Atoi(indices[j])
+		switch {
+		case errA == nil && errB == nil:
+			return a < b
+		case errA == nil:
+			return true
+		case errB == nil:
+			return false
+		default:
+			return indices[i] < indices[j]
+		}
+	})
+}
+
+func splitEnvVar(env string) (string, string, bool) {
+	parts := strings.SplitN(env, "=", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func outputPathIndex(key string) (string, bool) {
+	if !strings.HasPrefix(key, "OUTPUT_") || !strings.HasSuffix(key, "_PATH") {
+		return "", false
+	}
+	indexStr := strings.TrimPrefix(key, "OUTPUT_")
+	indexStr = strings.TrimSuffix(indexStr, "_PATH")
+	if indexStr == "" {
+		return "", false
+	}
+	return indexStr, true
+}
+
+func parseOutputTargetsEnv(key string) ([]OutputTarget, error) {
+	outputTargetsStr := os.Getenv(key)
+	if outputTargetsStr == "" {
+		return nil, nil
+	}
+
+	var targets []OutputTarget
+	jsonErr := json.Unmarshal([]byte(outputTargetsStr), &targets)
+	if jsonErr == nil {
+		return targets, nil
+	}
+	targets = nil
+	if err := yaml.Unmarshal([]byte(outputTargetsStr), &targets); err == nil {
+		return targets, nil
+	}
+	return nil, fmt.Errorf("%w: %s: %v", ErrInvalidOutputsEnv, key, jsonErr)
+}
+
+func toBoolPtr(value bool) *bool {
+	return &value
+}
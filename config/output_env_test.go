@@ -0,0 +1,248 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fullOutputTarget is the expected result of every output env format in the parity test
+var fullOutputTarget = OutputTarget{
+	Path:          "ftp://server/upload",
+	Type:          "ftp",
+	StripPrefix:   "staging/customer",
+	RenameRegex:   `\.txt$`,
+	RenameReplace: ".csv",
+	Endpoint:      "minio:9000",
+	AccessKey:     "access",
+	SecretKey:     "secret",
+	SSL:           toBoolPtr(false),
+	Region:        "eu-central-1",
+	Host:          "server",
+	Username:      "user",
+	Password:      "pass",
+	Port:          2121,
+}
+
+func TestEnvConfig_OutputEnvFormatParity(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{
+			name: "flat",
+			env: map[string]string{
+				"OUTPUT_1_PATH":           "ftp://server/upload",
+				"OUTPUT_1_TYPE":           "ftp",
+				"OUTPUT_1_STRIP_PREFIX":   "staging/customer",
+				"OUTPUT_1_RENAME_REGEX":   `\.txt$`,
+				"OUTPUT_1_RENAME_REPLACE": ".csv",
+				"OUTPUT_1_ENDPOINT":       "minio:9000",
+				"OUTPUT_1_ACCESS_KEY":     "access",
+				"OUTPUT_1_SECRET_KEY":     "secret",
+				"OUTPUT_1_SSL":            "false",
+				"OUTPUT_1_REGION":         "eu-central-1",
+				"OUTPUT_1_HOST":           "server",
+				"OUTPUT_1_USERNAME":       "user",
+				"OUTPUT_1_PASSWORD":       "pass",
+				"OUTPUT_1_PORT":           "2121",
+			},
+		},
+		{
+			name: "dotted",
+			env: map[string]string{
+				"output.0.path":           "ftp://server/upload",
+				"output.0.type":           "ftp",
+				"output.0.strip_prefix":   "staging/customer",
+				"output.0.rename_regex":   `\.txt$`,
+				"output.0.rename_replace": ".csv",
+				"output.0.endpoint":       "minio:9000",
+				"output.0.access_key":     "access",
+				"output.0.secret_key":     "secret",
+				"output.0.ssl":            "false",
+				"output.0.region":         "eu-central-1",
+				"output.0.host":           "server",
+				"output.0.username":       "user",
+				"output.0.password":       "pass",
+				"output.0.port":           "2121",
+			},
+		},
+		{
+			name: "json",
+			env: map[string]string{
+				"OUTPUTS": `[{"path":"ftp://server/upload","type":"ftp","endpoint":"minio:9000",` +
+					`"access-key":"access","secret-key":"secret","ssl":false,"region":"eu-central-1",` +
+					`"strip-prefix":"staging/customer","rename-regex":"\\.txt$","rename-replace":".csv","host":"server","username":"user","password":"pass","port":2121}]`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalEnv := backupEnvironment()
+			defer restoreEnvironment(originalEnv)
+			clearTestEnvironment()
+
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg := EnvConfig{}
+			if err := cfg.LoadFromEnvironment(); err != nil {
+				t.Fatalf("LoadFromEnvironment() error = %v", err)
+			}
+
+			if len(cfg.Output) != 1 {
+				t.Fatalf("expected 1 output target, got %d", len(cfg.Output))
+			}
+			if !reflect.DeepEqual(cfg.Output[0], fullOutputTarget) {
+				t.Errorf("target = %+v, want %+v", cfg.Output[0], fullOutputTarget)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_OutputEnvFormatPrecedence(t *testing.T) {
+	originalEnv := backupEnvironment()
+	defer restoreEnvironment(originalEnv)
+	clearTestEnvironment()
+
+	t.Setenv("OUTPUTS", `[{"path":"/out/json","type":"filesystem"}]`)
+	t.Setenv("output.0.path", "/out/dotted")
+	t.Setenv("output.0.type", "filesystem")
+
+	cfg := EnvConfig{}
+	if err := cfg.LoadFromEnvironment(); err != nil {
+		t.Fatalf("LoadFromEnvironment() error = %v", err)
+	}
+	if len(cfg.Output) != 1 || cfg.Output[0].Path != "/out/dotted" {
+		t.Fatalf("dotted targets should win over OUTPUTS, got %+v", cfg.Output)
+	}
+
+	t.Setenv("OUTPUT_1_PATH", "/out/flat")
+
+	cfg = EnvConfig{}
+	if err := cfg.LoadFromEnvironment(); err != nil {
+		t.Fatalf("LoadFromEnvironment() error = %v", err)
+	}
+	if len(cfg.Output) != 1 || cfg.Output[0].Path != "/out/flat" {
+		t.Fatalf("flat targets should win over dotted targets, got %+v", cfg.Output)
+	}
+}
+
+func TestEnvConfig_FlatOutputTargetsOrderedByIndex(t *testing.T) {
+	originalEnv := backupEnvironment()
+	defer restoreEnvironment(originalEnv)
+	clearTestEnvironment()
+
+	for _, index := range []string{"10", "2", "1"} {
+		t.Setenv("OUTPUT_"+index+"_PATH", "/out/"+index)
+	}
+
+	cfg := EnvConfig{}
+	cfg.loadOutputTargetsFromEnv()
+
+	var paths []string
+	for _, target := range cfg.Output {
+		paths = append(paths, target.Path)
+	}
+	if want := []string{"/out/1", "/out/2", "/out/10"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
+func TestEnvConfig_FlatOutputTargetPort(t *testing.T) {
+	tests := []struct {
+		name string
+		port string
+		want int
+	}{
+		{name: "valid port", port: "2121", want: 2121},
+		{name: "invalid port ignored", port: "ftp", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalEnv := backupEnvironment()
+			defer restoreEnvironment(originalEnv)
+			clearTestEnvironment()
+
+			t.Setenv("OUTPUT_1_PATH", "ftp://server/upload")
+			t.Setenv("OUTPUT_1_TYPE", "ftp")
+			t.Setenv("OUTPUT_1_PORT", tt.port)
+
+			cfg := EnvConfig{}
+			cfg.loadOutputTargetsFromEnv()
+
+			if len(cfg.Output) != 1 {
+				t.Fatalf("expected 1 output target, got %d", len(cfg.Output))
+			}
+			if cfg.Output[0].Port != tt.want {
+				t.Errorf("Port = %d, want %d", cfg.Output[0].Port, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_OutputTargetSSLParsing(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  *bool
+	}{
+		{name: "numeric true", value: "1", want: toBoolPtr(true)},
+		{name: "yes", value: "yes", want: toBoolPtr(true)},
+		{name: "on", value: "on", want: toBoolPtr(true)},
+		{name: "upper case false", value: "FALSE", want: toBoolPtr(false)},
+		{name: "garbage left unset", value: "garbage", want: nil},
+		{name: "absent left unset", value: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		for _, format := range []struct {
+			name string
+			key  string
+			load func(c *EnvConfig)
+		}{
+			{name: "flat", key: "OUTPUT_1_SSL", load: (*EnvConfig).loadOutputTargetsFromEnv},
+			{name: "dotted", key: "output.1.ssl", load: (*EnvConfig).loadOutputFromYAMLEnv},
+		} {
+			t.Run(format.name+"/"+tt.name, func(t *testing.T) {
+				originalEnv := backupEnvironment()
+				defer restoreEnvironment(originalEnv)
+				clearTestEnvironment()
+
+				t.Setenv(flatOutputFormat.key("1", "path"), "s3://bucket")
+				t.Setenv(flatOutputFormat.key("1", "type"), "s3")
+				t.Setenv(dottedOutputFormat.key("1", "path"), "s3://bucket")
+				t.Setenv(dottedOutputFormat.key("1", "type"), "s3")
+				if tt.value != "" {
+					t.Setenv(format.key, tt.value)
+				}
+
+				cfg := EnvConfig{}
+				format.load(&cfg)
+
+				if len(cfg.Output) != 1 {
+					t.Fatalf("expected 1 output target, got %d", len(cfg.Output))
+				}
+				got := cfg.Output[0].SSL
+				if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+					t.Errorf("SSL = %v, want %v", formatBoolPtr(got), formatBoolPtr(tt.want))
+				}
+				if tt.want == nil && !cfg.Output[0].GetS3Config().SSL {
+					t.Error("unset SSL should fall back to the S3 default (true)")
+				}
+			})
+		}
+	}
+}
+
+func formatBoolPtr(value *bool) string {
+	if value == nil {
+		return "<nil>"
+	}
+	if *value {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidOutputsFile is returned if the referenced outputs file cannot be read or parsed
+var ErrInvalidOutputsFile = errors.New("outputs file cannot be loaded")
+
+// LoadOutputsFile appends the targets from the referenced outputs file (YAML or JSON list) to Output.
+// Relative paths are resolved against baseDir, the directory of the main configuration file.
+func (c *EnvConfig) LoadOutputsFile(baseDir string) error {
+	if c.OutputsFile == "" {
+		return nil
+	}
+
+	path := c.OutputsFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+
+	targets, err := readOutputsFile(path)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidOutputsFile, err)
+	}
+
+	c.Output = append(c.Output, targets...)
+	return nil
+}
+
+func readOutputsFile(path string) ([]OutputTarget, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading outputs file %s: %w", path, err)
+	}
+
+	// YAML is a superset of JSON, so both formats can be parsed the same way
+	var targets []OutputTarget
+	if err := yaml.Unmarshal(data, &targets); err != nil {
+		return nil, fmt.Errorf("error parsing outputs file %s: %w", path, err)
+	}
+	return targets, nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvConfig_LoadOutputsFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantPaths []string
+	}{
+		{
+			name: "yaml",
+			content: `- path: /out/a
+  type: filesystem
+- path: s3://bucket
+  type: s3
+  access-key: key`,
+			wantPaths: []string{"/out/inline", "/out/a", "s3://bucket"},
+		},
+		{
+			name:      "json",
+			content:   `[{"path":"/out/b","type":"filesystem"}]`,
+			wantPaths: []string{"/out/inline", "/out/b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "targets.yaml"), []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := EnvConfig{
+				Output:      OutputConfig{{Path: "/out/inline", Type: "filesystem"}},
+				OutputsFile: "targets.yaml",
+			}
+			if err := cfg.LoadOutputsFile(dir); err != nil {
+				t.Fatalf("LoadOutputsFile() error = %v", err)
+			}
+
+			if len(cfg.Output) != len(tt.wantPaths) {
+				t.Fatalf("expected %d targets, got %+v", len(tt.wantPaths), cfg.Output)
+			}
+			for i, want := range tt.wantPaths {
+				if cfg.Output[i].Path != want {
+					t.Errorf("Output[%d].Path = %q, want %q", i, cfg.Output[i].Path, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEnvConfig_LoadOutputsFile_AbsolutePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.yaml")
+	if err := os.WriteFile(path, []byte("- path: /out/abs\n  type: filesystem\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := EnvConfig{OutputsFile: path}
+	if err := cfg.LoadOutputsFile("/does/not/matter"); err != nil {
+		t.Fatalf("LoadOutputsFile() error = %v", err)
+	}
+	if len(cfg.Output) != 1 || cfg.Output[0].Path != "/out/abs" {
+		t.Errorf("unexpected targets: %+v", cfg.Output)
+	}
+}
+
+func TestEnvConfig_LoadOutputsFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("path: [unclosed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"missing.yaml", "broken.yaml"} {
+		cfg := EnvConfig{OutputsFile: file}
+		if err := cfg.LoadOutputsFile(dir); err == nil {
+			t.Errorf("LoadOutputsFile(%s) expected error", file)
+		}
+	}
+}
+
+func TestEnvConfig_LoadOutputsFile_EnvOverrides(t *testing.T) {
+	originalEnv := backupEnvironment()
+	defer restoreEnvironment(originalEnv)
+	clearTestEnvironment()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "targets.yaml"), []byte("- path: /out/file\n  type: filesystem\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := EnvConfig{OutputsFile: "targets.yaml"}
+	if err := cfg.LoadOutputsFile(dir); err != nil {
+		t.Fatalf("LoadOutputsFile() error = %v", err)
+	}
+
+	t.Setenv("OUTPUT_1_PATH", "/out/env")
+	t.Setenv("OUTPUT_1_TYPE", "filesystem")
+	if err := cfg.LoadFromEnvironment(); err != nil {
+		t.Fatalf("LoadFromEnvironment() error = %v", err)
+	}
+
+	if len(cfg.Output) != 1 || cfg.Output[0].Path != "/out/env" {
+		t.Errorf("environment targets should replace targets from the outputs file, got %+v", cfg.Output)
+	}
+}
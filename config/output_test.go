@@ -1,6 +1,7 @@
 package config
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -462,3 +463,45 @@ func BenchmarkOutputTarget_GetFTPConfig(b *testing.B) {
 		target.GetFTPConfig()
 	}
 }
+
+func TestOutputTarget_GetKafkaConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      OutputTarget
+		wantBrokers []string
+		wantTopic   string
+		wantTLS     bool
+		wantInline  int
+	}{
+		{
+			name:        "single broker",
+			target:      OutputTarget{Type: "kafka", Path: "kafka://broker:9092/files"},
+			wantBrokers: []string{"broker:9092"},
+			wantTopic:   "files",
+			wantInline:  DefaultKafkaMaxInlineBytes,
+		},
+		{
+			name:        "multiple brokers with TLS",
+			target:      OutputTarget{Type: "kafka", Path: "kafkas://b1:9093,b2:9093/events.files", MaxInlineBytes: 1024},
+			wantBrokers: []string{"b1:9093", "b2:9093"},
+			wantTopic:   "events.files",
+			wantTLS:     true,
+			wantInline:  1024,
+		},
+		{
+			name:       "not a kafka path",
+			target:     OutputTarget{Type: "kafka", Path: "/local/path"},
+			wantInline: DefaultKafkaMaxInlineBytes,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.target.GetKafkaConfig()
+			if !reflect.DeepEqual(got.Brokers, tt.wantBrokers) || got.Topic != tt.wantTopic || got.TLS != tt.wantTLS ||
+				got.MaxInlineBytes != tt.wantInline {
+				t.Errorf("GetKafkaConfig() = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Configuration layers in the order they are applied (see loadConfiguration in main.go)
+const (
+	SourceYAML    = "yaml"
+	SourceDefault = "default"
+	SourceEnv     = "env"
+	SourceCLI     = "cli"
+)
+
+// Provenance maps each setting, named by its YAML path (e.g. "worker-pool.workers" or "output[0].path"),
+// to the layer that set its effective value. Settings that are not set at all are missing.
+type Provenance map[string]string
+
+// MarkProvenance attributes all settings that are currently set to source
+func (c *EnvConfig) MarkProvenance(source string) {
+	c.recordProvenance(nil, source)
+}
+
+// ApplyLayer runs apply and attributes every setting it changed to source. A layer that sets a
+// value equal to the one before does not change its source.
+func (c *EnvConfig) ApplyLayer(source string, apply func() error) error {
+	before := c.settingValues()
+	err := apply()
+	c.recordProvenance(before, source)
+	return err
+}
+
+func (c *EnvConfig) recordProvenance(before map[string]string, source string) {
+	after := c.settingValues()
+	if c.Provenance == nil {
+		c.Provenance = make(Provenance)
+	}
+	for path, value := range after {
+		if previous, ok := before[path]; !ok || previous != value {
+			c.Provenance[path] = source
+		}
+	}
+	// Settings removed by a layer (e.g. output targets replaced by fewer ones)
+	for path := range c.Provenance {
+		if _, ok := after[path]; !ok {
+			delete(c.Provenance, path)
+		}
+	}
+}
+
+// settingValues flattens all non-zero settings to their YAML path
+func (c *EnvConfig) settingValues() map[string]string {
+	values := make(map[string]string)
+	flattenSettings(reflect.ValueOf(*c), "", values)
+	return values
+}
+
+func flattenSettings(value reflect.Value, path string, values map[string]string) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			flattenSettings(value.Elem(), path, values)
+		}
+	case reflect.Struct:
+		for i := range value.NumField() {
+			field := value.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			flattenSettings(value.Field(i), joinSettingPath(path, name), values)
+		}
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.Struct {
+			if value.Len() > 0 {
+				values[path] = fmt.Sprint(value.Interface())
+			}
+			return
+		}
+		for i := range value.Len() {
+			flattenSettings(value.Index(i), fmt.Sprintf("%s[%d]", path, i), values)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			values[joinSettingPath(path, fmt.Sprint(key.Interface()))] = fmt.Sprint(value.MapIndex(key).Interface())
+		}
+	default:
+		if !value.IsZero() {
+			values[path] = fmt.Sprint(value.Interface())
+		}
+	}
+}
+
+func joinSettingPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
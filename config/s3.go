@@ -1,9 +1,43 @@
 package config
 
+import "fmt"
+
+// Supported values of S3Defaults.SignatureVersion
+const (
+	S3SignatureV2 = "v2"
+	S3SignatureV4 = "v4"
+)
+
 type S3Config struct {
 	Endpoint  string `yaml:"endpoint"`
 	AccessKey string `yaml:"access-key"`
 	SecretKey string `yaml:"secret-key"`
 	SSL       bool   `yaml:"ssl"`
 	Region    string `yaml:"region"`
+	// DisableContentTypeDetection uploads every object as application/octet-stream
+	DisableContentTypeDetection bool `yaml:"disable-content-type-detection"`
+	// MaxObjectSize rejects files larger than this many bytes before uploading them (0 = unlimited)
+	MaxObjectSize int64 `yaml:"max-object-size"`
+}
+
+// S3Defaults contains settings applied to all S3 targets that don't override them
+type S3Defaults struct {
+	DefaultSSL *bool `yaml:"default-ssl"` // SSL used for S3 targets without an explicit ssl setting (default: true)
+	// Idle HTTP connections kept per S3 client, 0 scales the minio-go defaults (256/16) with the worker count
+	MaxIdleConns        int `yaml:"max-idle-conns"`
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host"`
+	// HealthCheckRetries retries a failed health check of a new client with exponential backoff (0 = no retry)
+	HealthCheckRetries int `yaml:"health-check-retries"`
+	// SignatureVersion signs the requests of all S3 clients with "v4" (default) or "v2" for legacy stores
+	SignatureVersion string `yaml:"signature-version"`
+}
+
+// validateSignatureVersion checks SignatureVersion against the supported values
+func (s S3Defaults) validateSignatureVersion() error {
+	switch s.SignatureVersion {
+	case "", S3SignatureV2, S3SignatureV4:
+		return nil
+	default:
+		return fmt.Errorf("invalid s3 signature-version %q (allowed: %s, %s)", s.SignatureVersion, S3SignatureV4, S3SignatureV2)
+	}
 }
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a daily delivery window as offsets from midnight; End before Start crosses midnight
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseSchedule parses a comma separated list of daily time windows like "22:00-06:00,12:00-12:30"
+func ParseSchedule(value string) ([]TimeWindow, error) {
+	var windows []TimeWindow
+	for _, item := range splitList(value) {
+		start, end, found := strings.Cut(item, "-")
+		if !found {
+			return nil, fmt.Errorf("invalid schedule window %q: expected HH:MM-HH:MM", item)
+		}
+		startOffset, err := parseTimeOfDay(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule window %q: %w", item, err)
+		}
+		endOffset, err := parseTimeOfDay(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule window %q: %w", item, err)
+		}
+		if startOffset == endOffset {
+			return nil, fmt.Errorf("invalid schedule window %q: start and end must differ", item)
+		}
+		windows = append(windows, TimeWindow{Start: startOffset, End: endOffset})
+	}
+	return windows, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// ScheduleOpen reports whether t (in its location) lies in one of the windows; no windows means always open
+func ScheduleOpen(windows []TimeWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	for _, window := range windows {
+		if window.Start < window.End {
+			if offset >= window.Start && offset < window.End {
+				return true
+			}
+		} else if offset >= window.Start || offset < window.End {
+			return true
+		}
+	}
+	return false
+}
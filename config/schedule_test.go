@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleOpen(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2025, 3, 10, hour, minute, 0, 0, time.Local) }
+
+	tests := []struct {
+		schedule string
+		time     time.Time
+		want     bool
+	}{
+		{"", at(3, 0), true},
+		{"08:00-17:00", at(8, 0), true},
+		{"08:00-17:00", at(16, 59), true},
+		{"08:00-17:00", at(17, 0), false},
+		{"22:00-06:00", at(23, 30), true},
+		{"22:00-06:00", at(5, 59), true},
+		{"22:00-06:00", at(12, 0), false},
+		{"01:00-02:00, 12:00-12:30", at(12, 15), true},
+	}
+
+	for _, tt := range tests {
+		windows, err := ParseSchedule(tt.schedule)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q) error = %v", tt.schedule, err)
+		}
+		if got := ScheduleOpen(windows, tt.time); got != tt.want {
+			t.Errorf("ScheduleOpen(%q, %s) = %v, want %v", tt.schedule, tt.time.Format("15:04"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TargetsConfig holds restrictions that apply to all output targets
+type TargetsConfig struct {
+	// AllowedHosts restricts FTP and SFTP connections to these host names, IP addresses or CIDR ranges
+	// (e.g. "sftp.partner.example", "10.0.0.0/8"). A host name matches by name, any other host has to
+	// resolve to allowed addresses only (empty = all hosts allowed).
+	AllowedHosts []string `yaml:"allowed-hosts"`
+}
+
+// validateAllowedHosts checks that every CIDR range of AllowedHosts can be parsed
+func (c TargetsConfig) validateAllowedHosts() error {
+	for _, entry := range c.AllowedHosts {
+		if !strings.Contains(entry, "/") {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil {
+			return fmt.Errorf("invalid targets allowed-hosts entry %q: %w", entry, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// TLSConfig configures TLS connections to S3, HTTPS, Kafka and tracing endpoints
+type TLSConfig struct {
+	CAFile             string `yaml:"ca-file"`              // PEM file with additional trusted CA certificates (system roots are kept)
+	InsecureSkipVerify bool   `yaml:"insecure-skip-verify"` // Disable certificate verification (development only, reports degraded health)
+	MinVersion         string `yaml:"min-version"`          // Lowest accepted TLS version, "1.2" (default) or "1.3"
+}
+
+// Supported values of TLS.MinVersion
+const (
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+// DefaultTLSMinVersion is the lowest accepted TLS version if TLS.MinVersion is not set
+const DefaultTLSMinVersion = TLSVersion12
+
+// MinTLSVersion returns the crypto/tls constant of MinVersion, DefaultTLSMinVersion if it is not set
+func (t TLSConfig) MinTLSVersion() (uint16, error) {
+	version := t.MinVersion
+	if version == "" {
+		version = DefaultTLSMinVersion
+	}
+	switch version {
+	case TLSVersion12:
+		return tls.VersionTLS12, nil
+	case TLSVersion13:
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("invalid tls min-version %q (allowed: %s, %s)", t.MinVersion, TLSVersion12, TLSVersion13)
+	}
+}
+
+// validateMinVersion rejects TLS versions below 1.2 and unknown values
+func (t TLSConfig) validateMinVersion() error {
+	_, err := t.MinTLSVersion()
+	return err
+}
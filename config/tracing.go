@@ -0,0 +1,22 @@
+package config
+
+import "fmt"
+
+// TracingConfig configures the export of OpenTelemetry traces of file deliveries
+type TracingConfig struct {
+	Enabled     bool   `yaml:"enabled"`      // Export a span per processed file with a child span per target
+	Endpoint    string `yaml:"endpoint"`     // OTLP/HTTP collector endpoint, e.g. "otel-collector:4318" or a full URL
+	Insecure    bool   `yaml:"insecure"`     // Send to a plain HTTP endpoint (ignored for URLs, their scheme decides)
+	ServiceName string `yaml:"service-name"` // service.name resource attribute (default "file-shifter")
+}
+
+// DefaultTracingServiceName is the service.name of exported spans if Tracing.ServiceName is not set
+const DefaultTracingServiceName = "file-shifter"
+
+// validateEndpoint requires an endpoint when tracing is enabled
+func (t TracingConfig) validateEndpoint() error {
+	if t.Enabled && t.Endpoint == "" {
+		return fmt.Errorf("tracing is enabled but no tracing endpoint is configured")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// TransferConfig holds limits that apply to all transfers
+type TransferConfig struct {
+	MaxInMemoryBytes    int `yaml:"max-in-memory-bytes"`   // Upper bound for file content that has to be buffered in memory
+	SourceRemoveRetries int `yaml:"source-remove-retries"` // Retries if removing the source after a transfer fails
+	MaxBytesPerSec      int `yaml:"max-bytes-per-sec"`     // Bandwidth limit shared by all transfers (0 = unlimited)
+	MaxFilesPerSec      int `yaml:"max-files-per-sec"`     // Number of files started per second by all workers (0 = unlimited)
+	// SuccessMarker is the name of an empty file (e.g. "_SUCCESS") written to every target once the backlog of the
+	// initial scan has been delivered (empty = no marker)
+	SuccessMarker string `yaml:"success-marker"`
+	// SuccessMarkerQuiet also writes the marker whenever no file has been in flight for this many milliseconds
+	// after new deliveries (0 = only after the initial scan)
+	SuccessMarkerQuiet int `yaml:"success-marker-quiet"`
+	// QuickVerify compares size and modification time after a transfer and only re-reads the file for the final
+	// checksum if the modification time changed; for append-heavy files where the full re-read is expensive
+	QuickVerify bool `yaml:"quick-verify"`
+	// FileTimeout caps the milliseconds a single file may take across all targets; running transfers are cancelled,
+	// partial outputs removed and the file is moved to DeadLetterDir (0 = no limit)
+	FileTimeout int `yaml:"file-timeout"`
+	// DeadLetterDir receives files that exceeded FileTimeout below their relative path, required with FileTimeout
+	DeadLetterDir string `yaml:"dead-letter-dir"`
+}
+
+// validateSuccessMarker checks that SuccessMarker is a plain file name
+func (c TransferConfig) validateSuccessMarker() error {
+	if strings.ContainsAny(c.SuccessMarker, `/\`) || c.SuccessMarker == "." || c.SuccessMarker == ".." {
+		return fmt.Errorf("invalid transfer success-marker %q: must be a plain file name", c.SuccessMarker)
+	}
+	return nil
+}
+
+// validateFileTimeout requires a dead-letter directory outside the input directory for the file timeout
+func (c TransferConfig) validateFileTimeout(input string) error {
+	if c.FileTimeout <= 0 {
+		return nil
+	}
+	if c.DeadLetterDir == "" {
+		return fmt.Errorf("transfer file-timeout requires transfer dead-letter-dir")
+	}
+	inputDir, inputErr := filepath.Abs(input)
+	deadLetterDir, dirErr := filepath.Abs(c.DeadLetterDir)
+	if rel, err := filepath.Rel(inputDir, deadLetterDir); inputErr == nil && dirErr == nil && err == nil && filepath.IsLocal(rel) {
+		return fmt.Errorf("invalid transfer dead-letter-dir %q: must be outside the input directory", c.DeadLetterDir)
+	}
+	return nil
+}
+
+// TargetTransferConfig holds limits of a single output target
+type TargetTransferConfig struct {
+	MaxBytesPerSec int `json:"max-bytes-per-sec,omitempty" yaml:"max-bytes-per-sec,omitempty"` // Bandwidth limit of this target (0 = unlimited)
+}
@@ -0,0 +1,40 @@
+package main
+
+import "errors"
+
+// Process exit codes, distinct per failure class for automation
+const (
+	exitOK                = 0
+	exitFailure           = 1 // Unclassified failure
+	exitConfigError       = 2 // CLI arguments or configuration could not be parsed or applied
+	exitValidationError   = 3 // Configuration is complete but invalid
+	exitWorkerInitError   = 4 // Worker or file watcher could not be initialized
+	exitShutdownTimeout   = 5 // Graceful shutdown timed out, in-flight files were abandoned
+	exitHealthServerError = 6 // Health server could not bind its port (health.on-bind-error: exit)
+	exitEmptyInput        = 7 // Single run (--once) found no input files (input-options.require-non-empty)
+)
+
+// exitError attaches the exit code of its failure class to an error
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	return &exitError{code: code, err: err}
+}
+
+// exitCodeFor maps an error returned by the startup helpers to the process exit code
+func exitCodeFor(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var classified *exitError
+	if errors.As(err, &classified) {
+		return classified.code
+	}
+	return exitFailure
+}
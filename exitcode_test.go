@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	cause := errors.New("boom")
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "no error", err: nil, want: exitOK},
+		{name: "unclassified", err: cause, want: exitFailure},
+		{name: "config error", err: withExitCode(exitConfigError, cause), want: exitConfigError},
+		{name: "validation error", err: withExitCode(exitValidationError, cause), want: exitValidationError},
+		{name: "worker init error", err: withExitCode(exitWorkerInitError, cause), want: exitWorkerInitError},
+		{name: "wrapped classified error", err: fmt.Errorf("startup: %w", withExitCode(exitValidationError, cause)), want: exitValidationError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeFor(tt.err); got != tt.want {
+				t.Errorf("exitCodeFor() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if err := withExitCode(exitConfigError, cause); !errors.Is(err, cause) || err.Error() != "boom" {
+		t.Errorf("classified error should wrap its cause, got %v", err)
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"errors"
 	"file-shifter/config"
 	"file-shifter/services"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
@@ -19,12 +23,13 @@ type workerService interface {
 }
 
 type healthService interface {
-	Start()
+	Start() error
 	Stop()
 }
 
 type realWorkerService struct {
 	worker *services.Worker
+	cfg    *config.EnvConfig
 }
 
 func (w *realWorkerService) Start() {
@@ -35,12 +40,33 @@ func (w *realWorkerService) Stop() {
 	w.worker.Stop()
 }
 
+func (w *realWorkerService) InFlightFiles() []string {
+	if w.worker.FileWatcher == nil {
+		return nil
+	}
+	return w.worker.FileWatcher.InFlightFiles()
+}
+
+func (w *realWorkerService) OnceError() error {
+	return w.worker.OnceError()
+}
+
+// inFlightReporter is implemented by worker services that can report files still being processed
+type inFlightReporter interface {
+	InFlightFiles() []string
+}
+
+// onceReporter is implemented by worker services whose single run (--once) can fail
+type onceReporter interface {
+	OnceError() error
+}
+
 func newRealWorkerService(inputDir string, outputTargets []config.OutputTarget, cfg *config.EnvConfig) (workerService, error) {
 	worker, err := services.NewWorker(inputDir, outputTargets, cfg)
 	if err != nil {
 		return nil, err
 	}
-	return &realWorkerService{worker: worker}, nil
+	return &realWorkerService{worker: worker, cfg: cfg}, nil
 }
 
 func newRealHealthService(worker workerService, port string) healthService {
@@ -48,7 +74,13 @@ func newRealHealthService(worker workerService, port string) healthService {
 	// It attempts to extract the underlying *services.Worker from *realWorkerService.
 	// If a test mock is used instead, it returns a no-op implementation.
 	if realWorker, ok := worker.(*realWorkerService); ok {
-		return services.NewHealthMonitor(realWorker.worker, port)
+		healthMonitor := services.NewHealthMonitor(realWorker.worker, port)
+		if realWorker.cfg != nil {
+			healthMonitor.Config = realWorker.cfg.Health
+			healthMonitor.ConfigSources = realWorker.cfg.Provenance
+		}
+		healthMonitor.LogLevel = logLevel
+		return healthMonitor
 	}
 	// For test mocks or other implementations, return a no-op health monitor
 	return &noOpHealthMonitor{}
@@ -57,27 +89,16 @@ func newRealHealthService(worker workerService, port string) healthService {
 // noOpHealthMonitor is a no-op implementation of healthService for testing.
 type noOpHealthMonitor struct{}
 
-func (h *noOpHealthMonitor) Start() {}
-func (h *noOpHealthMonitor) Stop()  {}
-
-func loadEnvYaml() (*config.EnvConfig, error) {
-	// Check which files are available
-	yamlExists := fileExists("env.yaml")
-	ymlExists := fileExists("env.yml")
+func (h *noOpHealthMonitor) Start() error { return nil }
+func (h *noOpHealthMonitor) Stop()        {}
 
-	// Error if both files exist
-	if yamlExists && ymlExists {
-		return nil, fmt.Errorf("conflict: both env.yaml and env.yml are present, please use only one of the two files")
-	}
+// errNoConfigFile is returned if neither env.yaml nor env.yml exists, the service then starts without a file
+var errNoConfigFile = fmt.Errorf("no configuration file found (env.yaml or env.yml): %w", os.ErrNotExist)
 
-	// Determine which file should be loaded
-	var configFile string
-	if yamlExists {
-		configFile = "env.yaml"
-	} else if ymlExists {
-		configFile = "env.yml"
-	} else {
-		return nil, fmt.Errorf("no configuration file found (env.yaml or env.yml)")
+func loadEnvYaml() (*config.EnvConfig, error) {
+	configFile, err := selectConfigFile(strictConfigEnabled())
+	if err != nil {
+		return nil, err
 	}
 
 	data, err := os.ReadFile(configFile)
@@ -90,14 +111,48 @@ func loadEnvYaml() (*config.EnvConfig, error) {
 		return nil, fmt.Errorf("error parsing %s: %w", configFile, err)
 	}
 
+	if err := cfg.LoadOutputsFile(filepath.Dir(configFile)); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// strictConfigEnabled reports whether CONFIG_STRICT is set, e.g. to reject ambiguous config files
+func strictConfigEnabled() bool {
+	strict, err := strconv.ParseBool(os.Getenv("CONFIG_STRICT"))
+	return err == nil && strict
+}
+
+// selectConfigFile picks env.yaml or env.yml. If both exist, env.yaml wins unless strict mode is enabled.
+func selectConfigFile(strict bool) (string, error) {
+	yamlExists := fileExists("env.yaml")
+	ymlExists := fileExists("env.yml")
+
+	switch {
+	case yamlExists && ymlExists:
+		if strict {
+			return "", fmt.Errorf("conflict: both env.yaml and env.yml are present, please use only one of the two files")
+		}
+		slog.Warn("Both env.yaml and env.yml are present - using env.yaml, env.yml is ignored (set CONFIG_STRICT=true to fail instead)")
+		return "env.yaml", nil
+	case yamlExists:
+		return "env.yaml", nil
+	case ymlExists:
+		return "env.yml", nil
+	default:
+		return "", errNoConfigFile
+	}
+}
+
 func fileExists(filename string) bool {
 	_, err := os.Stat(filename)
 	return err == nil
 }
 
+// logLevel is shared with the health server so the level can be changed at runtime
+var logLevel = new(slog.LevelVar)
+
 func setupLogger(cfg *config.EnvConfig) {
 	levelStr := cfg.GetLogLevel()
 	var lvl slog.Level
@@ -113,7 +168,8 @@ func setupLogger(cfg *config.EnvConfig) {
 	default:
 		lvl = slog.LevelInfo
 	}
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: lvl})
+	logLevel.Set(lvl)
+	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 }
@@ -126,96 +182,174 @@ func runApp(
 	createHealthMonitor func(workerService, string) healthService,
 	notifySignals func(chan<- os.Signal, ...os.Signal),
 ) int {
-	cliCfg := parseCLI()
+	cfg, err := loadConfiguration(parseCLI(), loadEnvYamlFunc, loadDotEnv)
+	if err != nil {
+		return exitWith(err)
+	}
+
+	// Initialise and start workers
+	workerSvc, err := createWorker(cfg.Input, cfg.Output, cfg)
+	if err != nil {
+		return exitWith(withExitCode(exitWorkerInitError, fmt.Errorf("failed to create worker: %w", err)))
+	}
+
+	// Start Health-Monitor
+	healthMonitor := createHealthMonitor(workerSvc, "8080")
+	if err := healthMonitor.Start(); err != nil {
+		return exitWith(withExitCode(exitHealthServerError, fmt.Errorf("failed to start health server: %w", err)))
+	}
+
+	// Graceful Shutdown Handler
+	sigChan := make(chan os.Signal, 1)
+	notifySignals(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	shutdownTimeout := time.Duration(cfg.Shutdown.Timeout) * time.Millisecond
+	forceExit := make(chan struct{})
+
+	go func() {
+		<-sigChan
+		slog.Info("Shutdown signal received...")
+
+		stopped := make(chan struct{})
+		go func() {
+			healthMonitor.Stop()
+			workerSvc.Stop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-time.After(shutdownTimeout):
+			logStuckWorkers(workerSvc, shutdownTimeout)
+			close(forceExit)
+		}
+	}()
+
+	// Start worker (blocked until Stop is called)
+	workerDone := make(chan struct{})
+	go func() {
+		workerSvc.Start()
+		close(workerDone)
+	}()
+
+	select {
+	case <-workerDone:
+		return onceExitCode(workerSvc)
+	case <-forceExit:
+		return exitShutdownTimeout
+	}
+}
+
+// loadConfiguration builds the configuration and sets up the logger. The configuration order is:
+// - Load env.yaml or env.yml (if available)
+// - Load .env (if available)
+// - Load environment variables
+// - Apply CLI parameters (overrides everything else)
+// Errors carry the exit code of their failure class.
+func loadConfiguration(
+	cliCfg *config.CLIConfig,
+	loadEnvYamlFunc func() (*config.EnvConfig, error),
+	loadDotEnv func() error,
+) (*config.EnvConfig, error) {
 	// Validate CLI configuration
 	if err := cliCfg.Validate(); err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Fehler in Kommandozeilen-Argumenten: %v\n", err)
-		return 1
+		return nil, withExitCode(exitConfigError, fmt.Errorf("fehler in Kommandozeilen-Argumenten: %w", err))
 	}
 
-	// 2. Configuration order:
-	// - Load env.yaml or env.yml (if available)
-	// - Load .env (if available)
-	// - Load environment variables
-	// - Apply CLI parameters (overrides everything else)
-
 	cfg, err := loadEnvYamlFunc()
-	if err != nil {
+	if errors.Is(err, os.ErrNotExist) && !errors.Is(err, config.ErrInvalidOutputsFile) {
 		fmt.Println("Konfigurationsdatei konnte nicht geladen werden:", err)
 		cfg = &config.EnvConfig{} // leere Konfiguration
+	} else if err != nil {
+		// Falling back to an empty configuration would drop everything else configured in env.yaml
+		return nil, withExitCode(exitConfigError, fmt.Errorf("error loading configuration file: %w", err))
 	}
+	// Each layer records the settings it changed (see config/provenance.go)
+	cfg.MarkProvenance(config.SourceYAML)
 
 	_ = loadDotEnv()
 
 	// Set defaults
-	cfg.SetDefaults()
+	_ = cfg.ApplyLayer(config.SourceDefault, func() error {
+		cfg.SetDefaults()
+		return nil
+	})
 
 	// Load environment variables (overwrites YAML and .env)
-	err = cfg.LoadFromEnvironment()
-	if err != nil {
-		fmt.Println("Error loading environment variables:", err)
+	if err := cfg.ApplyLayer(config.SourceEnv, cfg.LoadFromEnvironment); err != nil {
+		return nil, withExitCode(exitConfigError, fmt.Errorf("error loading environment variables: %w", err))
 	}
 
 	// Apply CLI parameters (highest priority)
-	err = cliCfg.ApplyToCfg(cfg)
-	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Error applying CLI parameters: %v\n", err)
-		return 1
+	if err := cfg.ApplyLayer(config.SourceCLI, func() error { return cliCfg.ApplyToCfg(cfg) }); err != nil {
+		return nil, withExitCode(exitConfigError, fmt.Errorf("error applying CLI parameters: %w", err))
 	}
 
 	// Logger configuration
 	setupLogger(cfg)
 
-	// Input Directory
-	inputDir := cfg.Input
-
-	// Output Targets
-	outputTargets := cfg.Output
+	// A missing target is a misconfiguration if explicit outputs are required
+	if len(cfg.Output) == 0 && cfg.RequireExplicitOutput {
+		return nil, withExitCode(exitValidationError,
+			fmt.Errorf("invalid configuration: %w (require-explicit-output is set, ./output is not used)", config.ErrOutputRequired))
+	}
 
-	// Standard default if no targets are configured
-	if len(outputTargets) == 0 {
-		outputTargets = []config.OutputTarget{
-			{
-				Path: "./output",
-				Type: "filesystem",
-			},
+	_ = cfg.ApplyLayer(config.SourceDefault, func() error {
+		// Standard default if no targets are configured
+		if len(cfg.Output) == 0 {
+			cfg.Output = []config.OutputTarget{
+				{
+					Path: "./output",
+					Type: "filesystem",
+				},
+			}
+			slog.Info("No output configuration found - use standard default", "target", "./output")
 		}
-		cfg.Output = outputTargets // Also set in cfg for validation
-		slog.Info("No output configuration found - use standard default", "target", "./output")
-	}
+
+		cfg.ApplyS3Defaults()
+		return nil
+	})
+	slog.Debug("Configuration sources", "settings", cfg.Provenance)
 
 	// Validate configuration (after setting the default targets)
 	if err := cfg.Validate(); err != nil {
-		slog.Error("Invalid configuration", "error", err)
-		return 1
-	}
-
-	// Initialise and start workers
-	workerSvc, err := createWorker(inputDir, outputTargets, cfg)
-	if err != nil {
-		slog.Error("Failed to create worker", "error", err)
-		return 1
+		return nil, withExitCode(exitValidationError, fmt.Errorf("invalid configuration: %w", err))
 	}
 
-	// Start Health-Monitor
-	healthMonitor := createHealthMonitor(workerSvc, "8080")
-	healthMonitor.Start()
+	return cfg, nil
+}
 
-	// Graceful Shutdown Handler
-	sigChan := make(chan os.Signal, 1)
-	notifySignals(sigChan, syscall.SIGINT, syscall.SIGTERM)
+// exitWith reports a startup error and returns its exit code
+func exitWith(err error) int {
+	code := exitCodeFor(err)
+	slog.Error("Startup failed", "error", err, "exit_code", code)
+	return code
+}
 
-	go func() {
-		<-sigChan
-		slog.Info("Shutdown signal received...")
-		healthMonitor.Stop()
-		workerSvc.Stop()
-	}()
+// onceExitCode returns the exit code of a finished worker, failing a single run (--once) that did not succeed
+func onceExitCode(workerSvc workerService) int {
+	reporter, ok := workerSvc.(onceReporter)
+	if !ok {
+		return exitOK
+	}
+	err := reporter.OnceError()
+	if err == nil {
+		return exitOK
+	}
+	slog.Error("Single run failed", "error", err)
+	if errors.Is(err, services.ErrEmptyInput) {
+		return exitEmptyInput
+	}
+	return exitFailure
+}
 
-	// Start worker (blocked until Stop is called)
-	workerSvc.Start()
-	return 0
+// logStuckWorkers reports the files that were still being processed when the shutdown timed out
+func logStuckWorkers(workerSvc workerService, timeout time.Duration) {
+	var inFlight []string
+	if reporter, ok := workerSvc.(inFlightReporter); ok {
+		inFlight = reporter.InFlightFiles()
+	}
+	slog.Error("Graceful shutdown timed out - forcing exit", "timeout", timeout, "in_flight_files", inFlight)
 }
 
 func main() {
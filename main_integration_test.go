@@ -1,11 +1,16 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"syscall"
 	"testing"
+	"time"
 
 	"file-shifter/config"
+	"file-shifter/services"
 )
 
 type fakeWorker struct {
@@ -27,12 +32,14 @@ func (w *fakeWorker) Stop() {
 }
 
 type fakeHealthMonitor struct {
-	started bool
-	stopped bool
+	started  bool
+	stopped  bool
+	startErr error
 }
 
-func (h *fakeHealthMonitor) Start() {
+func (h *fakeHealthMonitor) Start() error {
 	h.started = true
+	return h.startErr
 }
 
 func (h *fakeHealthMonitor) Stop() {
@@ -51,8 +58,8 @@ func TestRunApp_CLIValidationError(t *testing.T) {
 		func(chan<- os.Signal, ...os.Signal) {},
 	)
 
-	if code != 1 {
-		t.Fatalf("expected exit code 1 for invalid CLI config, got %d", code)
+	if code != exitConfigError {
+		t.Fatalf("expected exit code %d for invalid CLI config, got %d", exitConfigError, code)
 	}
 }
 
@@ -68,8 +75,8 @@ func TestRunApp_ApplyCLIError(t *testing.T) {
 		func(chan<- os.Signal, ...os.Signal) {},
 	)
 
-	if code != 1 {
-		t.Fatalf("expected exit code 1 for invalid outputs JSON, got %d", code)
+	if code != exitConfigError {
+		t.Fatalf("expected exit code %d for invalid outputs JSON, got %d", exitConfigError, code)
 	}
 }
 
@@ -156,7 +163,272 @@ func TestRunApp_WorkerCreationFailure(t *testing.T) {
 		func(chan<- os.Signal, ...os.Signal) {},
 	)
 
-	if code != 1 {
-		t.Fatalf("expected exit code 1 for worker creation failure, got %d", code)
+	if code != exitWorkerInitError {
+		t.Fatalf("expected exit code %d for worker creation failure, got %d", exitWorkerInitError, code)
+	}
+}
+
+func TestRunApp_HealthServerStartFailure(t *testing.T) {
+	worker := &fakeWorker{done: make(chan struct{})}
+	health := &fakeHealthMonitor{startErr: errors.New("health port 8080 is not available: address already in use")}
+
+	code := runApp(
+		func() *config.CLIConfig { return &config.CLIConfig{} },
+		func() (*config.EnvConfig, error) { return &config.EnvConfig{}, nil },
+		func() error { return nil },
+		func(_ string, _ []config.OutputTarget, _ *config.EnvConfig) (workerService, error) {
+			return worker, nil
+		},
+		func(_ workerService, _ string) healthService { return health },
+		func(chan<- os.Signal, ...os.Signal) {},
+	)
+
+	if code != exitHealthServerError {
+		t.Fatalf("expected exit code %d for a taken health port, got %d", exitHealthServerError, code)
+	}
+	if worker.started {
+		t.Error("worker should not start without a health server")
+	}
+}
+
+func TestRunApp_ValidationError(t *testing.T) {
+	cfg := &config.EnvConfig{}
+	cfg.InputOptions.Order = "random"
+
+	workerCreated := false
+	code := runApp(
+		func() *config.CLIConfig { return &config.CLIConfig{} },
+		func() (*config.EnvConfig, error) { return cfg, nil },
+		func() error { return nil },
+		func(string, []config.OutputTarget, *config.EnvConfig) (workerService, error) {
+			workerCreated = true
+			return &fakeWorker{done: make(chan struct{})}, nil
+		},
+		func(workerService, string) healthService { return &fakeHealthMonitor{} },
+		func(chan<- os.Signal, ...os.Signal) {},
+	)
+
+	if code != exitValidationError {
+		t.Fatalf("expected exit code %d for invalid configuration, got %d", exitValidationError, code)
+	}
+	if workerCreated {
+		t.Error("no worker should be created for an invalid configuration")
+	}
+}
+
+type stuckWorker struct {
+	done chan struct{}
+}
+
+func (w *stuckWorker) Start() {
+	<-w.done
+}
+
+func (w *stuckWorker) Stop() {
+	// Simulates a worker that never finishes its in-flight file
+	select {}
+}
+
+func (w *stuckWorker) InFlightFiles() []string {
+	return []string{"/input/stuck.bin"}
+}
+
+func TestRunApp_ShutdownTimeoutForcesExit(t *testing.T) {
+	cfg := &config.EnvConfig{}
+	cfg.Shutdown.Timeout = 100
+
+	result := make(chan int, 1)
+	start := time.Now()
+	go func() {
+		result <- runApp(
+			func() *config.CLIConfig { return &config.CLIConfig{} },
+			func() (*config.EnvConfig, error) { return cfg, nil },
+			func() error { return nil },
+			func(string, []config.OutputTarget, *config.EnvConfig) (workerService, error) {
+				return &stuckWorker{done: make(chan struct{})}, nil
+			},
+			func(workerService, string) healthService { return &fakeHealthMonitor{} },
+			func(ch chan<- os.Signal, _ ...os.Signal) {
+				go func() { ch <- syscall.SIGTERM }()
+			},
+		)
+	}()
+
+	select {
+	case code := <-result:
+		if code != exitShutdownTimeout {
+			t.Fatalf("expected exit code %d after forced shutdown, got %d", exitShutdownTimeout, code)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("expected forced exit shortly after the timeout, took %v", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runApp did not return within the shutdown timeout")
+	}
+}
+
+func TestLoadConfiguration_Provenance(t *testing.T) {
+	t.Setenv("WORKER_POOL_WORKERS", "7")
+
+	yamlCfg := &config.EnvConfig{Input: "/data/in"}
+	yamlCfg.Output = []config.OutputTarget{{Type: "filesystem", Path: "/data/out"}}
+
+	cfg, err := loadConfiguration(
+		&config.CLIConfig{LogLevel: "DEBUG"},
+		func() (*config.EnvConfig, error) { return yamlCfg, nil },
+		func() error { return nil },
+	)
+	if err != nil {
+		t.Fatalf("loadConfiguration() error = %v", err)
+	}
+
+	want := map[string]string{
+		"worker-pool.workers":    config.SourceEnv,
+		"input":                  config.SourceYAML,
+		"output[0].path":         config.SourceYAML,
+		"worker-pool.queue-size": config.SourceDefault,
+		"log.level":              config.SourceCLI,
+	}
+	for setting, source := range want {
+		if got := cfg.Provenance[setting]; got != source {
+			t.Errorf("Provenance[%q] = %q, want %q", setting, got, source)
+		}
+	}
+	if _, ok := cfg.Provenance["manifest.path"]; ok {
+		t.Error("settings that are not set should have no source")
+	}
+}
+
+func TestLoadConfiguration_BrokenOutputsFile(t *testing.T) {
+	yamlCfg := &config.EnvConfig{Input: "/data/in", OutputsFile: filepath.Join(t.TempDir(), "missing.yaml")}
+
+	cfg, err := loadConfiguration(
+		&config.CLIConfig{},
+		func() (*config.EnvConfig, error) {
+			if err := yamlCfg.LoadOutputsFile("."); err != nil {
+				return nil, err
+			}
+			return yamlCfg, nil
+		},
+		func() error { return nil },
+	)
+	if cfg != nil {
+		t.Fatalf("a broken outputs-file must not fall back to an empty configuration, got %+v", cfg)
+	}
+	if !errors.Is(err, config.ErrInvalidOutputsFile) || exitCodeFor(err) != exitConfigError {
+		t.Fatalf("loadConfiguration() error = %v (exit code %d), want ErrInvalidOutputsFile with exit code %d",
+			err, exitCodeFor(err), exitConfigError)
+	}
+}
+
+func TestLoadConfiguration_BrokenConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile(filepath.Join(dir, "env.yaml"), []byte("input: [unclosed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfiguration(&config.CLIConfig{}, loadEnvYaml, func() error { return nil })
+	if cfg != nil {
+		t.Fatalf("a broken env.yaml must not fall back to an empty configuration, got %+v", cfg)
+	}
+	if exitCodeFor(err) != exitConfigError {
+		t.Fatalf("loadConfiguration() error = %v (exit code %d), want exit code %d", err, exitCodeFor(err), exitConfigError)
+	}
+}
+
+func TestLoadConfiguration_NoConfigFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	cfg, err := loadConfiguration(&config.CLIConfig{}, loadEnvYaml, func() error { return nil })
+	if err != nil || cfg == nil {
+		t.Fatalf("without env.yaml the start should continue with an empty configuration, got %v", err)
+	}
+}
+
+func TestLoadConfiguration_RequireExplicitOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		cli     *config.CLIConfig
+		yamlCfg *config.EnvConfig
+		env     string
+		wantErr bool
+	}{
+		{name: "default fallback", cli: &config.CLIConfig{}, yamlCfg: &config.EnvConfig{}},
+		{name: "required by flag", cli: &config.CLIConfig{RequireExplicitOutput: true}, yamlCfg: &config.EnvConfig{}, wantErr: true},
+		{name: "required by yaml", cli: &config.CLIConfig{}, yamlCfg: &config.EnvConfig{RequireExplicitOutput: true}, wantErr: true},
+		{name: "required by env", cli: &config.CLIConfig{}, yamlCfg: &config.EnvConfig{}, env: "true", wantErr: true},
+		{
+			name:    "required and configured",
+			cli:     &config.CLIConfig{RequireExplicitOutput: true},
+			yamlCfg: &config.EnvConfig{Output: config.OutputConfig{{Type: "filesystem", Path: "/data/out"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("REQUIRE_EXPLICIT_OUTPUT", tt.env)
+			}
+
+			cfg, err := loadConfiguration(tt.cli,
+				func() (*config.EnvConfig, error) { return tt.yamlCfg, nil },
+				func() error { return nil },
+			)
+			if tt.wantErr {
+				if !errors.Is(err, config.ErrOutputRequired) || exitCodeFor(err) != exitValidationError {
+					t.Fatalf("loadConfiguration() error = %v (exit code %d), want ErrOutputRequired with exit code %d",
+						err, exitCodeFor(err), exitValidationError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadConfiguration() error = %v", err)
+			}
+			if len(cfg.Output) != 1 {
+				t.Fatalf("expected exactly one output target, got %+v", cfg.Output)
+			}
+		})
+	}
+}
+
+// onceWorker finishes on its own like a single run (--once)
+type onceWorker struct {
+	err error
+}
+
+func (w *onceWorker) Start()           {}
+func (w *onceWorker) Stop()            {}
+func (w *onceWorker) OnceError() error { return w.err }
+
+func TestRunApp_OnceExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "files processed", want: exitOK},
+		{name: "empty input", err: fmt.Errorf("%w: /input", services.ErrEmptyInput), want: exitEmptyInput},
+		{name: "delivery failed", err: fmt.Errorf("%w: 1 of 1 files", services.ErrDeliveryFailed), want: exitFailure},
+		{name: "other failure", err: errors.New("boom"), want: exitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := runApp(
+				func() *config.CLIConfig { return &config.CLIConfig{Once: true} },
+				func() (*config.EnvConfig, error) { return &config.EnvConfig{}, nil },
+				func() error { return nil },
+				func(string, []config.OutputTarget, *config.EnvConfig) (workerService, error) {
+					return &onceWorker{err: tt.err}, nil
+				},
+				func(workerService, string) healthService { return &fakeHealthMonitor{} },
+				func(chan<- os.Signal, ...os.Signal) {},
+			)
+
+			if code != tt.want {
+				t.Errorf("exit code = %d, want %d", code, tt.want)
+			}
+		})
 	}
 }
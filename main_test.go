@@ -4,6 +4,7 @@ import (
 	"file-shifter/config"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -60,6 +61,37 @@ log:
 				// Log.Level tests entfernt da YAML unmarshaling komplexer ist
 			},
 		},
+		{
+			name: "env.yaml mit outputs-file",
+			setupFiles: func(t *testing.T) {
+				outputsFile := filepath.Join(t.TempDir(), "targets.json")
+				targets := `[{"path":"/test/file-output","type":"filesystem"}]`
+				if err := os.WriteFile(outputsFile, []byte(targets), 0644); err != nil {
+					t.Fatalf("Fehler beim Schreiben der Outputs-Datei: %v", err)
+				}
+				yamlContent := "input: /test/input\noutputs-file: " + outputsFile
+				if err := os.WriteFile("env.yaml", []byte(yamlContent), 0644); err != nil {
+					t.Fatalf("Fehler beim Schreiben von env.yaml: %v", err)
+				}
+			},
+			expectError: false,
+			expectedValues: func(t *testing.T, cfg *config.EnvConfig) {
+				if len(cfg.Output) != 1 || cfg.Output[0].Path != "/test/file-output" {
+					t.Errorf("Output-Targets aus outputs-file erwartet, Bekommen: %+v", cfg.Output)
+				}
+			},
+		},
+		{
+			name: "env.yaml mit fehlender outputs-file",
+			setupFiles: func(t *testing.T) {
+				yamlContent := "input: /test/input\noutputs-file: " + filepath.Join(t.TempDir(), "missing.json")
+				if err := os.WriteFile("env.yaml", []byte(yamlContent), 0644); err != nil {
+					t.Fatalf("Fehler beim Schreiben von env.yaml: %v", err)
+				}
+			},
+			expectError:    true,
+			expectedValues: nil,
+		},
 		{
 			name: "invalid YAML",
 			setupFiles: func(t *testing.T) {
@@ -74,20 +106,23 @@ invalid_yaml: [unclosed_bracket`
 			expectedValues: nil,
 		},
 		{
-			name: "both files present - conflict",
+			name: "both files present - env.yaml takes precedence",
 			setupFiles: func(t *testing.T) {
-				yamlContent := `input: /test/yaml`
-				ymlContent := `input: /test/yml`
-
-				err := os.WriteFile("env.yaml", []byte(yamlContent), 0644)
-				if err != nil {
-					t.Fatalf("Fehler beim Schreiben von env.yaml: %v", err)
-				}
-				err = os.WriteFile("env.yml", []byte(ymlContent), 0644)
-				if err != nil {
-					t.Fatalf("Error writing env.yml: %v", err)
+				writeBothConfigFiles(t)
+			},
+			expectError: false,
+			expectedValues: func(t *testing.T, cfg *config.EnvConfig) {
+				if cfg.Input != "/test/yaml" {
+					t.Errorf("env.yaml erwartet. Erwartet: /test/yaml, Bekommen: %s", cfg.Input)
 				}
 			},
+		},
+		{
+			name: "both files present - conflict in strict mode",
+			setupFiles: func(t *testing.T) {
+				t.Setenv("CONFIG_STRICT", "true")
+				writeBothConfigFiles(t)
+			},
 			expectError:    true,
 			expectedValues: nil,
 		},
@@ -140,6 +175,16 @@ invalid_yaml: [unclosed_bracket`
 	}
 }
 
+func writeBothConfigFiles(t *testing.T) {
+	t.Helper()
+	if err := os.WriteFile("env.yaml", []byte(`input: /test/yaml`), 0644); err != nil {
+		t.Fatalf("Fehler beim Schreiben von env.yaml: %v", err)
+	}
+	if err := os.WriteFile("env.yml", []byte(`input: /test/yml`), 0644); err != nil {
+		t.Fatalf("Error writing env.yml: %v", err)
+	}
+}
+
 func TestFileExists(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrHostNotAllowed is returned when a target host is not in the allowlist
+var ErrHostNotAllowed = errors.New("host is not in the allowed hosts")
+
+// lookupHost resolves host names for the allowlist check, replaceable in tests
+var lookupHost = net.LookupHost
+
+// checkAllowedHost verifies a target host (host:port) against AllowedHosts before connecting and returns the
+// address to dial. The host is allowed if its name is listed or all its resolved addresses are listed. In the
+// latter case the returned address is a checked IP, so a DNS answer changing before the dial (rebinding) cannot
+// bypass the allowlist.
+func (fh *FileHandler) checkAllowedHost(hostPort string) (string, error) {
+	if len(fh.AllowedHosts) == 0 {
+		return hostPort, nil
+	}
+
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+	}
+	for _, entry := range fh.AllowedHosts {
+		if strings.EqualFold(strings.TrimSuffix(entry, "."), strings.TrimSuffix(host, ".")) {
+			return hostPort, nil
+		}
+	}
+
+	addrs, err := lookupHost(host)
+	if err != nil {
+		return "", fmt.Errorf("error resolving host %s for the allowed hosts check: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !addressAllowed(net.ParseIP(addr), fh.AllowedHosts) {
+			return "", fmt.Errorf("%w: %s (resolved to %s)", ErrHostNotAllowed, host, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+	if port == "" {
+		return addrs[0], nil
+	}
+	return net.JoinHostPort(addrs[0], port), nil
+}
+
+// addressAllowed reports whether ip equals an allowed address or lies in an allowed CIDR range
+func addressAllowed(ip net.IP, allowed []string) bool {
+	if ip == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(entry); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
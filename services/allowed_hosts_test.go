@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"file-shifter/config"
+)
+
+func TestFileHandler_CheckAllowedHost(t *testing.T) {
+	original := lookupHost
+	lookupHost = func(host string) ([]string, error) {
+		switch host {
+		case "internal.example":
+			return []string{"10.20.1.5"}, nil
+		case "mixed.example":
+			return []string{"10.20.1.6", "203.0.113.7"}, nil
+		case "external.example":
+			return []string{"203.0.113.8"}, nil
+		default:
+			return []string{host}, nil
+		}
+	}
+	defer func() { lookupHost = original }()
+
+	fh := NewFileHandler(nil, nil)
+	fh.AllowedHosts = []string{"sftp.partner.example", "10.20.0.0/16", "192.0.2.10"}
+
+	tests := []struct {
+		host    string
+		allowed bool
+		address string
+	}{
+		{host: "sftp.partner.example:22", allowed: true, address: "sftp.partner.example:22"},
+		{host: "SFTP.Partner.Example:22", allowed: true, address: "SFTP.Partner.Example:22"},
+		// Resolved hosts are dialed at the checked address, not resolved again
+		{host: "internal.example:2222", allowed: true, address: "10.20.1.5:2222"},
+		{host: "192.0.2.10:21", allowed: true, address: "192.0.2.10:21"},
+		{host: "external.example:22", allowed: false},
+		{host: "mixed.example:22", allowed: false},
+		{host: "192.0.2.11:21", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			address, err := fh.checkAllowedHost(tt.host)
+			if tt.allowed && err != nil {
+				t.Errorf("checkAllowedHost(%q) error = %v, want allowed", tt.host, err)
+			}
+			if tt.allowed && address != tt.address {
+				t.Errorf("checkAllowedHost(%q) address = %q, want %q", tt.host, address, tt.address)
+			}
+			if !tt.allowed && !errors.Is(err, ErrHostNotAllowed) {
+				t.Errorf("checkAllowedHost(%q) error = %v, want ErrHostNotAllowed", tt.host, err)
+			}
+		})
+	}
+
+	fh.AllowedHosts = nil
+	if address, err := fh.checkAllowedHost("external.example:22"); err != nil || address != "external.example:22" {
+		t.Errorf("without allowlist every host must be allowed unchanged, got %q, %v", address, err)
+	}
+}
+
+func TestFileHandler_DisallowedHostNotDialed(t *testing.T) {
+	original := lookupHost
+	lookupHost = func(host string) ([]string, error) { return []string{"203.0.113.8"}, nil }
+	defer func() { lookupHost = original }()
+
+	for _, targetType := range []string{"ftp", "sftp"} {
+		t.Run(targetType, func(t *testing.T) {
+			target := config.OutputTarget{
+				Type:     targetType,
+				Path:     targetType + "://external.example/upload",
+				Username: "user",
+				Password: "pass",
+			}
+			fh := NewFileHandler([]config.OutputTarget{target}, nil)
+			fh.AllowedHosts = []string{"sftp.partner.example"}
+
+			// Fails before any connection attempt to the (unreachable) host
+			err := fh.copyToTarget("/does/not/matter", "report.csv", target, nil)
+			if !errors.Is(err, ErrHostNotAllowed) {
+				t.Fatalf("copyToTarget() error = %v, want ErrHostNotAllowed", err)
+			}
+		})
+	}
+}
+
+func TestFileHandler_AllowedHostDialsCheckedAddress(t *testing.T) {
+	original := lookupHost
+	lookupHost = func(host string) ([]string, error) { return []string{"127.0.0.1"}, nil }
+	defer func() { lookupHost = original }()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	accepted := make(chan struct{}, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- struct{}{}
+		conn.Close()
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	target := config.OutputTarget{
+		Type:     "sftp",
+		Path:     "sftp://rebind.invalid:" + port + "/upload",
+		Username: "user",
+		Password: "pass",
+	}
+	fh := NewFileHandler([]config.OutputTarget{target}, nil)
+	fh.AllowedHosts = []string{"127.0.0.0/8"}
+
+	// rebind.invalid cannot be resolved by the system resolver, so the connection only
+	// arrives if the address that passed the check is dialed
+	if err := fh.copyToTarget("/does/not/matter", "report.csv", target, nil); err == nil {
+		t.Fatal("copyToTarget() succeeded without an SSH server")
+	}
+	select {
+	case <-accepted:
+	default:
+		t.Fatal("the checked address was not dialed")
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// defaultMaxInMemoryBytes is used if no MaxInMemoryBytes is configured (64 MiB)
+const defaultMaxInMemoryBytes = 64 * 1024 * 1024
+
+// ErrExceedsMemoryLimit is returned if content that must be buffered is larger than MaxInMemoryBytes
+var ErrExceedsMemoryLimit = errors.New("content exceeds the in-memory limit")
+
+// readAllLimited reads r completely into memory, but fails once more than limit bytes would be buffered.
+// Regular transfers and checksums stream the file; this is only for code paths that cannot.
+func readAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w (%d bytes)", ErrExceedsMemoryLimit, limit)
+	}
+	return data, nil
+}
+
+// bufferContent reads r into memory within the configured MaxInMemoryBytes
+func (fh *FileHandler) bufferContent(r io.Reader) ([]byte, error) {
+	limit := fh.MaxInMemoryBytes
+	if limit <= 0 {
+		limit = defaultMaxInMemoryBytes
+	}
+	return readAllLimited(r, limit)
+}
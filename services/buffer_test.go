@@ -0,0 +1,121 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// countingReader records how many bytes were requested and read in total
+type countingReader struct {
+	r       io.Reader
+	total   int64
+	maxRead int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if len(p) > c.maxRead {
+		c.maxRead = len(p)
+	}
+	n, err := c.r.Read(p)
+	c.total += int64(n)
+	return n, err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestChecksumReader_StreamsLargeContent(t *testing.T) {
+	const size = 256 * 1024 * 1024
+	counter := &countingReader{r: io.LimitReader(zeroReader{}, size)}
+
+	if _, err := checksumReader(counter); err != nil {
+		t.Fatalf("checksumReader() error = %v", err)
+	}
+
+	if counter.total != size {
+		t.Errorf("read %d bytes, want %d", counter.total, size)
+	}
+	// Streaming reads in small chunks; buffering the whole file would request much larger reads
+	if counter.maxRead > 1024*1024 {
+		t.Errorf("largest read was %d bytes, content should be streamed in small chunks", counter.maxRead)
+	}
+}
+
+func TestFileHandler_CopyToFilesystemLargeFile(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	srcPath := filepath.Join(srcDir, "large.bin")
+
+	src, err := os.Create(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sparse file, so the test doesn't need real disk space
+	const size = 128 * 1024 * 1024
+	if err := src.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	src.Close()
+
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler(nil, NewS3ClientManager())
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := fh.copyToFilesystem(srcPath, "large.bin", dstDir, info); err != nil {
+		t.Fatalf("copyToFilesystem() error = %v", err)
+	}
+	runtime.ReadMemStats(&after)
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/8 {
+		t.Errorf("copy allocated %d bytes for a %d byte file, content should be streamed", allocated, size)
+	}
+
+	dstInfo, err := os.Stat(filepath.Join(dstDir, "large.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dstInfo.Size() != info.Size() {
+		t.Errorf("copied size = %d, want %d", dstInfo.Size(), info.Size())
+	}
+}
+
+func TestReadAllLimited(t *testing.T) {
+	data, err := readAllLimited(bytes.NewReader([]byte("small")), 10)
+	if err != nil || string(data) != "small" {
+		t.Fatalf("readAllLimited() = %q, %v", data, err)
+	}
+
+	counter := &countingReader{r: zeroReader{}}
+	_, err = readAllLimited(counter, 1024)
+	if !errors.Is(err, ErrExceedsMemoryLimit) {
+		t.Fatalf("expected ErrExceedsMemoryLimit, got %v", err)
+	}
+	if counter.total > 1025 {
+		t.Errorf("read %d bytes from an endless reader, want at most limit+1", counter.total)
+	}
+}
+
+func TestFileHandler_BufferContentDefaultLimit(t *testing.T) {
+	fh := NewFileHandler(nil, NewS3ClientManager())
+	counter := &countingReader{r: zeroReader{}}
+
+	if _, err := fh.bufferContent(counter); !errors.Is(err, ErrExceedsMemoryLimit) {
+		t.Fatalf("expected ErrExceedsMemoryLimit, got %v", err)
+	}
+	if counter.total > defaultMaxInMemoryBytes+1 {
+		t.Errorf("read %d bytes, want at most %d", counter.total, defaultMaxInMemoryBytes+1)
+	}
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"file-shifter/config"
+)
+
+// checksumDuringCopyTarget returns the only output target if the initial checksum can be calculated
+// while copying: a single filesystem target receiving the unmodified content
+func (fh *FileHandler) checksumDuringCopyTarget(targets []config.OutputTarget, filePath string) (config.OutputTarget, bool) {
+	if !fh.ChecksumDuringCopy || fh.DryRun || fh.decompresses(filePath) {
+		return config.OutputTarget{}, false
+	}
+	if len(targets) != 1 || targets[0].Type != "filesystem" {
+		return config.OutputTarget{}, false
+	}
+	return targets[0], true
+}
+
+// copyToFilesystemWithChecksum copies the file to a single filesystem target and returns the SHA256
+// of the copied content, saving the separate read for the initial checksum
+func (fh *FileHandler) copyToFilesystemWithChecksum(srcPath, relPath string, target config.OutputTarget, fileInfo os.FileInfo) (string, error) {
+	hash := sha256.New()
+	if err := fh.copyToFilesystemsTee(srcPath, targetRelPath(target, relPath), []string{target.Path}, fileInfo, hash)[0]; err != nil {
+		slog.Error("Filesystem-Transfer failed", "target", target.Path, "error", err)
+		return "", fmt.Errorf("file system transfer failed: %w", err)
+	}
+
+	checksum := fmt.Sprintf("%x", hash.Sum(nil))
+	slog.Debug("Initial checksum calculated during copy", "file", srcPath, "checksum", checksum)
+	return checksum, nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"file-shifter/config"
+)
+
+func TestFileHandler_ChecksumDuringCopy(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		targets   int
+		wantReads int32
+	}{
+		{name: "disabled", enabled: false, targets: 1, wantReads: 3},
+		{name: "single filesystem target", enabled: true, targets: 1, wantReads: 2},
+		{name: "multiple targets fall back", enabled: true, targets: 2, wantReads: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputDir := t.TempDir()
+			srcFile := filepath.Join(inputDir, "report.csv")
+			content := []byte("a,b,c\n1,2,3\n")
+			if err := os.WriteFile(srcFile, content, 0644); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+
+			var targets []config.OutputTarget
+			for range tt.targets {
+				targets = append(targets, config.OutputTarget{Type: "filesystem", Path: t.TempDir()})
+			}
+			fh := NewFileHandler(targets, nil)
+			fh.ChecksumDuringCopy = tt.enabled
+			reads := countSourceOpens(t)
+
+			if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+				t.Fatalf("ProcessFile() error = %v", err)
+			}
+
+			if got := reads.Load(); got != tt.wantReads {
+				t.Errorf("source reads = %d, want %d", got, tt.wantReads)
+			}
+			for _, target := range targets {
+				if got, err := os.ReadFile(filepath.Join(target.Path, "report.csv")); err != nil || string(got) != string(content) {
+					t.Errorf("target content = %q (error %v), want %q", got, err, content)
+				}
+			}
+			if _, err := os.Stat(srcFile); !os.IsNotExist(err) {
+				t.Error("source file should have been removed")
+			}
+		})
+	}
+}
+
+func TestFileHandler_ChecksumDuringCopyTarget(t *testing.T) {
+	fh := NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: "/out"}}, nil)
+	fh.ChecksumDuringCopy = true
+	fh.Decompress = true
+
+	if target, ok := fh.checksumDuringCopyTarget(fh.OutputTargets(), "in/report.csv"); !ok || target.Path != "/out" {
+		t.Errorf("checksumDuringCopyTarget() = %q, %v, want /out, true", target.Path, ok)
+	}
+	if _, ok := fh.checksumDuringCopyTarget(fh.OutputTargets(), "in/report.csv.gz"); ok {
+		t.Error("decompressed content must not be used for the checksum of the source file")
+	}
+
+	fh.SetOutputTargets([]config.OutputTarget{{Type: "s3", Path: "s3://bucket"}})
+	if _, ok := fh.checksumDuringCopyTarget(fh.OutputTargets(), "in/report.csv"); ok {
+		t.Error("remote targets must keep the separate checksum read")
+	}
+}
+
+func BenchmarkFileHandler_ProcessFileChecksumDuringCopy(b *testing.B) {
+	for _, enabled := range []bool{false, true} {
+		name := "separate-checksum"
+		if enabled {
+			name = "checksum-during-copy"
+		}
+		b.Run(name, func(b *testing.B) {
+			inputDir := b.TempDir()
+			srcFile := filepath.Join(inputDir, "bench.bin")
+			content := make([]byte, 1<<20)
+
+			fh := NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: b.TempDir()}}, nil)
+			fh.ChecksumDuringCopy = enabled
+			reads := countSourceOpens(b)
+
+			b.SetBytes(int64(len(content)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				if err := os.WriteFile(srcFile, content, 0644); err != nil {
+					b.Fatalf("failed to create source file: %v", err)
+				}
+				b.StartTimer()
+
+				if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+					b.Fatalf("ProcessFile() error = %v", err)
+				}
+			}
+			b.ReportMetric(float64(reads.Load())/float64(b.N), "source-reads/op")
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"maps"
+)
+
+// sourceChecksum returns the initial checksum of a file being transferred, calculated from the source if it is
+// not known (e.g. a direct transfer outside ProcessFile)
+func (fh *FileHandler) sourceChecksum(srcPath string) (string, error) {
+	if checksum, ok := fh.sourceChecksums.Load(srcPath); ok {
+		return checksum.(string), nil
+	}
+	return fh.calculateFileChecksum(srcPath)
+}
+
+// withChecksumMetadata returns a copy of the user metadata with the checksum added
+func withChecksumMetadata(metadata map[string]string, checksum string) map[string]string {
+	withChecksum := make(map[string]string, len(metadata)+1)
+	maps.Copy(withChecksum, metadata)
+	withChecksum[checksumMetadataKey] = checksum
+	return withChecksum
+}
+
+// uploadWithContentChecksum uploads decompressed content while checksumming it, then stores the checksum of the
+// uploaded object as its metadata. The options are updated with the checksum for further keys.
+func uploadWithContentChecksum(minioClient *MinIO, srcPath, bucketName, objectKey string, opts *UploadOptions) (string, error) {
+	hash := sha256.New()
+	hashOpts := *opts
+	hashOpts.contentHash = hash
+	if _, err := minioClient.UploadFileWithOptions(srcPath, bucketName, objectKey, hashOpts); err != nil {
+		return "", err
+	}
+
+	checksum := fmt.Sprintf("%x", hash.Sum(nil))
+	opts.UserMetadata = withChecksumMetadata(opts.UserMetadata, checksum)
+	ctx := opts.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := minioClient.ReplaceUserMetadata(ctx, bucketName, objectKey, *opts); err != nil {
+		return "", fmt.Errorf("error storing the checksum metadata: %w", err)
+	}
+	return checksum, nil
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"syscall"
+)
+
+// warnChtimesFailed logs a failure to set the timestamp of a copied file. Filesystems without timestamp
+// support (ENOSYS/ENOTSUP, e.g. some FUSE or network mounts) fail for every file, so that is only logged
+// once per target base path.
+func (fh *FileHandler) warnChtimesFailed(basePath, targetPath string, err error) {
+	if !errors.Is(err, errors.ErrUnsupported) && !errors.Is(err, syscall.ENOSYS) {
+		slog.Warn("Could not set timestamp", "file", targetPath, "error", err)
+		return
+	}
+
+	if _, logged := fh.chtimesUnsupported.LoadOrStore(basePath, struct{}{}); logged {
+		slog.Debug("Could not set timestamp - not supported by the target", "file", targetPath)
+		return
+	}
+	slog.Warn("Target filesystem does not support setting timestamps - further warnings suppressed",
+		"target", basePath, "file", targetPath, "error", err)
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// isCompletionMarker reports whether the file is a companion marker (e.g. data.csv.done), markers are never transferred
+func (fw *FileWatcher) isCompletionMarker(filePath string) bool {
+	return fw.completionMarkerSuffix != "" && strings.HasSuffix(filePath, fw.completionMarkerSuffix)
+}
+
+// hasCompletionMarker reports whether the producer has already written the marker of a data file
+func (fw *FileWatcher) hasCompletionMarker(filePath string) bool {
+	_, err := os.Lstat(filePath + fw.completionMarkerSuffix)
+	return err == nil
+}
+
+// handleCompletionMarker processes the data file of a marker that has just appeared
+func (fw *FileWatcher) handleCompletionMarker(markerPath string) {
+	dataPath := strings.TrimSuffix(markerPath, fw.completionMarkerSuffix)
+	slog.Debug("Completion marker detected", "marker", markerPath, "file", dataPath)
+	fw.processFile(dataPath)
+}
+
+// removeCompletionMarker deletes the marker of a removed source file; a missing marker is not an error
+func (fh *FileHandler) removeCompletionMarker(filePath string) {
+	if fh.CompletionMarkerSuffix == "" {
+		return
+	}
+	markerPath := filePath + fh.CompletionMarkerSuffix
+	if err := removeFile(markerPath); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Error deleting the completion marker", "marker", markerPath, "error", err)
+	}
+}
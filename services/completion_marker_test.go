@@ -0,0 +1,71 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileWatcher_CompletionMarker(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	fileHandler.CompletionMarkerSuffix = ".done"
+	fw, err := NewFileWatcher(inputDir, fileHandler, 5, 10*time.Millisecond, 20*time.Millisecond, 2, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	fw.lsofAvailable = false
+	fw.completionMarkerSuffix = ".done"
+
+	go func() {
+		if err := fw.Start(); err != nil {
+			t.Logf("FileWatcher stopped with error: %v", err)
+		}
+	}()
+	defer fw.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	dataFile := filepath.Join(inputDir, "data.csv")
+	if err := os.WriteFile(dataFile, []byte("a,b\n1,2\n"), 0644); err != nil {
+		t.Fatalf("failed to create data file: %v", err)
+	}
+
+	// Stable, but without its marker the file must be left alone
+	time.Sleep(300 * time.Millisecond)
+	if _, err := os.Stat(filepath.Join(outputDir, "data.csv")); !os.IsNotExist(err) {
+		t.Fatal("data file must not be delivered before its marker exists")
+	}
+	if _, err := os.Stat(dataFile); err != nil {
+		t.Fatalf("data file must stay in the input directory: %v", err)
+	}
+
+	markerFile := dataFile + ".done"
+	if err := os.WriteFile(markerFile, nil, 0644); err != nil {
+		t.Fatalf("failed to create marker file: %v", err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		_, dataErr := os.Stat(dataFile)
+		_, markerErr := os.Stat(markerFile)
+		if os.IsNotExist(dataErr) && os.IsNotExist(markerErr) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("data file and marker should have been removed (data: %v, marker: %v)", dataErr, markerErr)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if got, err := os.ReadFile(filepath.Join(outputDir, "data.csv")); err != nil || string(got) != "a,b\n1,2\n" {
+		t.Errorf("delivered content = %q (error %v)", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "data.csv.done")); !os.IsNotExist(err) {
+		t.Error("marker must not be transferred")
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// sniffLength is the number of leading bytes http.DetectContentType considers
+const sniffLength = 512
+
+// sniffContentType detects the content type of a file from its first 512 bytes
+func sniffContentType(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(buffer[:n]), nil
+}
+
+// contentTypeAllowed reports whether the sniffed content type matches one of the allowed media types.
+// Parameters like charset are ignored, "text/*" matches every text type.
+func contentTypeAllowed(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAllowedContent checks the file content against the configured allowlist (empty = everything allowed)
+func (fw *FileWatcher) hasAllowedContent(filePath string) bool {
+	if len(fw.allowContentTypes) == 0 {
+		return true
+	}
+
+	contentType, err := sniffContentType(filePath)
+	if err != nil {
+		slog.Error("Error detecting content type - processing skipped", "file", filePath, "error", err)
+		return false
+	}
+	if !contentTypeAllowed(contentType, fw.allowContentTypes) {
+		slog.Warn("Content type not allowed - processing skipped", "file", filePath, "contentType", contentType)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileWatcher_AllowContentTypes(t *testing.T) {
+	tests := []struct {
+		name       string
+		fileName   string
+		content    string
+		wantQueued bool
+	}{
+		{
+			name:       "real csv allowed",
+			fileName:   "export.csv",
+			content:    "id;name;amount\n1;Alice;10.50\n2;Bob;7.25\n",
+			wantQueued: true,
+		},
+		{
+			name:     "html error page rejected",
+			fileName: "export.csv",
+			content: "<!DOCTYPE html>\n<html><head><title>502 Bad Gateway</title></head>" +
+				"<body><h1>Bad Gateway</h1></body></html>\n",
+			wantQueued: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputDir := t.TempDir()
+			filePath := filepath.Join(inputDir, tt.fileName)
+			if err := os.WriteFile(filePath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+
+			fw, err := NewFileWatcher(inputDir, nil, 1, time.Millisecond, time.Millisecond, 1, 10)
+			if err != nil {
+				t.Fatalf("failed to create file watcher: %v", err)
+			}
+			defer fw.watcher.Close()
+			fw.lsofAvailable = false
+			fw.procAvailable = false
+			fw.allowContentTypes = []string{"text/plain"}
+
+			fw.processFile(filePath)
+
+			if queued := fw.QueueSize() == 1; queued != tt.wantQueued {
+				t.Errorf("queued = %v, want %v", queued, tt.wantQueued)
+			}
+			if !tt.wantQueued && fw.isMarkedForProcessing(filePath) {
+				t.Error("rejected file must not stay marked for processing")
+			}
+			if _, err := os.Stat(filePath); err != nil {
+				t.Errorf("file must be left in place: %v", err)
+			}
+		})
+	}
+}
+
+func TestContentTypeAllowed(t *testing.T) {
+	tests := []struct {
+		contentType string
+		allowed     []string
+		want        bool
+	}{
+		{contentType: "text/plain; charset=utf-8", allowed: []string{"text/plain"}, want: true},
+		{contentType: "text/html; charset=utf-8", allowed: []string{"text/plain"}, want: false},
+		{contentType: "text/html; charset=utf-8", allowed: []string{"text/*"}, want: true},
+		{contentType: "application/pdf", allowed: []string{"text/*", " Application/PDF "}, want: true},
+		{contentType: "application/octet-stream", allowed: []string{"application/pdf"}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := contentTypeAllowed(tt.contentType, tt.allowed); got != tt.want {
+			t.Errorf("contentTypeAllowed(%q, %v) = %v, want %v", tt.contentType, tt.allowed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CopiedFiles tracks the originals delivered in copy mode by path and checksum, so the watcher does not enqueue
+// them again while their content is unchanged. It only keeps the last delivered checksum of a path. With a state
+// file (see LoadCopiedFiles) the set survives restarts, so the initial scan skips originals copied before.
+// All methods are no-ops on a nil set.
+type CopiedFiles struct {
+	mu        sync.Mutex
+	checksums map[string]string
+	statePath string
+}
+
+// NewCopiedFiles creates a set that is kept in memory only
+func NewCopiedFiles() *CopiedFiles {
+	return &CopiedFiles{checksums: make(map[string]string)}
+}
+
+// LoadCopiedFiles creates a set that is persisted as JSON (path -> checksum) at statePath and loads the originals
+// recorded there by a previous run. A missing state file starts an empty set, originals that no longer exist are
+// dropped.
+func LoadCopiedFiles(statePath string) (*CopiedFiles, error) {
+	c := &CopiedFiles{checksums: make(map[string]string), statePath: statePath}
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the copy state file %s: %w", statePath, err)
+	}
+	if err := json.Unmarshal(data, &c.checksums); err != nil {
+		return nil, fmt.Errorf("error parsing the copy state file %s: %w", statePath, err)
+	}
+	for filePath := range c.checksums {
+		if _, err := os.Lstat(filePath); os.IsNotExist(err) {
+			delete(c.checksums, filePath)
+		}
+	}
+	return c, nil
+}
+
+// save writes the set to the state file, replacing it atomically. The caller holds c.mu.
+func (c *CopiedFiles) save() {
+	if c.statePath == "" {
+		return
+	}
+	data, err := json.Marshal(c.checksums)
+	if err == nil {
+		tmpPath := c.statePath + ".tmp"
+		if err = os.WriteFile(tmpPath, data, 0600); err == nil {
+			err = os.Rename(tmpPath, c.statePath)
+		}
+	}
+	if err != nil {
+		slog.Warn("Copy state file could not be written, copied originals may be copied again after a restart",
+			"file", c.statePath, "error", err)
+	}
+}
+
+// Add records a source file delivered with the given checksum
+func (c *CopiedFiles) Add(filePath, checksum string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checksums[filePath] = checksum
+	c.save()
+}
+
+// Contains reports whether a source file has been delivered with the given checksum
+func (c *CopiedFiles) Contains(filePath, checksum string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delivered, ok := c.checksums[filePath]
+	return ok && delivered == checksum
+}
+
+// has reports whether a source file has been delivered at all, so new files are not hashed by the watcher
+func (c *CopiedFiles) has(filePath string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.checksums[filePath]
+	return ok
+}
+
+// Forget drops a removed file, or all files below a removed directory
+func (c *CopiedFiles) Forget(path string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix := path + string(filepath.Separator)
+	forgotten := false
+	for filePath := range c.checksums {
+		if filePath == path || strings.HasPrefix(filePath, prefix) {
+			delete(c.checksums, filePath)
+			forgotten = true
+		}
+	}
+	if forgotten {
+		c.save()
+	}
+}
+
+// recordCopiedSource remembers an original kept in copy mode. The hash cache additionally skips it on rescans
+// without hashing it while its size and modification time are unchanged.
+func (fh *FileHandler) recordCopiedSource(filePath string, info os.FileInfo, checksum string) {
+	fh.CopiedFiles.Add(filePath, checksum)
+	fh.recordKeptSource(filePath, info, checksum)
+}
+
+// alreadyCopied reports whether a file has been delivered in copy mode with its current content, e.g. an
+// original that was only touched since its delivery
+func (fw *FileWatcher) alreadyCopied(filePath string, info os.FileInfo) bool {
+	if fw.fileHandler == nil || !fw.fileHandler.CopiedFiles.has(filePath) {
+		return false
+	}
+	checksum, err := fw.fileHandler.calculateFileChecksum(filePath)
+	if err != nil || !fw.fileHandler.CopiedFiles.Contains(filePath, checksum) {
+		return false
+	}
+	fw.fileHandler.HashCache.Record(filePath, info, checksum)
+	slog.Debug("File already copied with the same content - skipped", "file", filePath, "checksum", checksum)
+	return true
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipSuffix marks input files that are decompressed during transfer if Decompress is enabled
+const gzipSuffix = ".gz"
+
+// openSourceFile opens input files for transfers, replaceable in tests
+var openSourceFile = os.Open
+
+// decompresses reports whether the content of the input file is delivered decompressed
+func (fh *FileHandler) decompresses(filePath string) bool {
+	return fh.Decompress && strings.HasSuffix(strings.ToLower(filePath), gzipSuffix)
+}
+
+// deliveryPath returns the relative path under which a file is delivered to the targets
+func (fh *FileHandler) deliveryPath(relPath string) string {
+	if fh.decompresses(relPath) {
+		return relPath[:len(relPath)-len(gzipSuffix)]
+	}
+	return relPath
+}
+
+// openSource opens an input file for a transfer, decompressing gzip content if enabled
+func (fh *FileHandler) openSource(srcPath string) (io.ReadCloser, error) {
+	reader, _, err := openSourceReader(srcPath, fh.decompresses(srcPath))
+	if err != nil {
+		return nil, err
+	}
+	return fh.withFileContext(srcPath, reader), nil
+}
+
+// openSourceReader opens a file and returns its content size, -1 if the size is unknown (decompressed content)
+func openSourceReader(srcPath string, decompress bool) (io.ReadCloser, int64, error) {
+	file, err := openSourceFile(srcPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !decompress {
+		stat, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+		return file, stat.Size(), nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return &gzipFile{Reader: gz, file: file}, -1, nil
+}
+
+// gzipFile closes the decompressor and the underlying file
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	gzErr := g.Reader.Close()
+	if err := g.file.Close(); err != nil {
+		return err
+	}
+	return gzErr
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"file-shifter/config"
+)
+
+const decompressedContent = "id;name\n1;first\n2;second\n"
+
+func writeGzipFile(t *testing.T, path, content string) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileHandler_DecompressToFilesystem(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	srcPath := filepath.Join(inputDir, "exports", "report.csv.gz")
+	writeGzipFile(t, srcPath, decompressedContent)
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	fh.Decompress = true
+	if err := fh.ProcessFile(srcPath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(outputDir, "exports", "report.csv"))
+	if err != nil {
+		t.Fatalf("decompressed file not delivered: %v", err)
+	}
+	if string(got) != decompressedContent {
+		t.Errorf("content = %q, want %q", got, decompressedContent)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "exports", "report.csv.gz")); !os.IsNotExist(err) {
+		t.Error("the compressed name must not be delivered")
+	}
+	if _, err := os.Stat(srcPath); !os.IsNotExist(err) {
+		t.Error("source file should be removed after delivery")
+	}
+}
+
+func TestFileHandler_DecompressDisabled(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	srcPath := filepath.Join(inputDir, "report.csv.gz")
+	writeGzipFile(t, srcPath, decompressedContent)
+	original, err := os.ReadFile(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	if err := fh.ProcessFile(srcPath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(outputDir, "report.csv.gz"))
+	if err != nil {
+		t.Fatalf("compressed file not delivered: %v", err)
+	}
+	if !bytes.Equal(got, original) {
+		t.Error("without Decompress the file must be delivered unchanged")
+	}
+}
+
+func TestFileHandler_DecompressInvalidGzipKeepsSource(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	srcPath := filepath.Join(inputDir, "broken.gz")
+	if err := os.WriteFile(srcPath, []byte("not gzip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	fh.Decompress = true
+	if err := fh.ProcessFile(srcPath, inputDir); err == nil {
+		t.Fatal("expected an error for invalid gzip content")
+	}
+	if _, err := os.Stat(srcPath); err != nil {
+		t.Errorf("source file must be kept after a failed transfer: %v", err)
+	}
+}
+
+func TestFileHandler_DecompressToS3WithFakeServer(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	target := config.OutputTarget{
+		Type:      "s3",
+		Path:      "s3://bucket-a",
+		Endpoint:  strings.TrimPrefix(ts.URL, "http://"),
+		AccessKey: "key",
+		SecretKey: "secret",
+		SSL:       boolPtr(false),
+		Region:    "us-east-1",
+	}
+
+	manager := NewS3ClientManager()
+	defer manager.Close()
+	fh := NewFileHandler([]config.OutputTarget{target}, manager)
+	fh.Decompress = true
+
+	inputDir := t.TempDir()
+	srcPath := filepath.Join(inputDir, "report.csv.GZ")
+	writeGzipFile(t, srcPath, decompressedContent)
+	if err := fh.ProcessFile(srcPath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if got := string(fake.buckets["bucket-a"]["report.csv"]); got != decompressedContent {
+		t.Errorf("object content = %q, want %q", got, decompressedContent)
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+// pendingDeletion is a transferred source file whose removal is deferred by DeleteDelay
+type pendingDeletion struct {
+	timer   *time.Timer
+	size    int64
+	modTime time.Time
+}
+
+// scheduleSourceRemoval removes the source file after DeleteDelay. The file is left alone
+// if it is changed during the delay, it is then treated as a new file.
+func (fh *FileHandler) scheduleSourceRemoval(filePath string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	fh.pendingMutex.Lock()
+	defer fh.pendingMutex.Unlock()
+
+	if fh.pendingDeletions == nil {
+		fh.pendingDeletions = make(map[string]*pendingDeletion)
+	}
+	if previous, ok := fh.pendingDeletions[filePath]; ok && previous.timer.Stop() {
+		fh.pendingWG.Done()
+	}
+
+	pending := &pendingDeletion{size: info.Size(), modTime: info.ModTime()}
+	fh.pendingWG.Add(1)
+	pending.timer = time.AfterFunc(fh.DeleteDelay, func() { fh.removePendingSource(filePath, pending) })
+	fh.pendingDeletions[filePath] = pending
+	slog.Info("Removal of the original file scheduled", "file", filePath, "delay", fh.DeleteDelay)
+	return nil
+}
+
+// removePendingSource removes a source file once its delay has expired
+func (fh *FileHandler) removePendingSource(filePath string, pending *pendingDeletion) {
+	defer fh.pendingWG.Done()
+
+	fh.pendingMutex.Lock()
+	current := fh.pendingDeletions[filePath] == pending
+	if current {
+		delete(fh.pendingDeletions, filePath)
+	}
+	fh.pendingMutex.Unlock()
+
+	if current {
+		fh.removeUnchangedSource(filePath, pending)
+	}
+}
+
+func (fh *FileHandler) removeUnchangedSource(filePath string, pending *pendingDeletion) {
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil && (info.Size() != pending.size || !info.ModTime().Equal(pending.modTime)) {
+		slog.Warn("Original file changed during the delete delay - kept", "file", filePath)
+		return
+	}
+
+	if err := fh.removeSourceFile(filePath); err != nil {
+		slog.Error("Error deleting the original file", "file", filePath, "error", err)
+		return
+	}
+	slog.Info("Original file removed after delete delay", "file", filePath)
+}
+
+// isPendingDeletion reports whether a source file has been delivered and waits for its removal
+func (fh *FileHandler) isPendingDeletion(filePath string) bool {
+	fh.pendingMutex.Lock()
+	defer fh.pendingMutex.Unlock()
+	_, ok := fh.pendingDeletions[filePath]
+	return ok
+}
+
+// FlushPendingDeletions removes all scheduled source files immediately and waits for running removals.
+// It is called on shutdown so delivered files are not delivered again after a restart.
+func (fh *FileHandler) FlushPendingDeletions() {
+	fh.pendingMutex.Lock()
+	flushed := make(map[string]*pendingDeletion)
+	for filePath, pending := range fh.pendingDeletions {
+		if pending.timer.Stop() {
+			flushed[filePath] = pending
+			delete(fh.pendingDeletions, filePath)
+		}
+	}
+	fh.pendingMutex.Unlock()
+
+	if len(flushed) > 0 {
+		slog.Info("Removing original files with pending delete delay", "count", len(flushed))
+	}
+	for filePath, pending := range flushed {
+		fh.removeUnchangedSource(filePath, pending)
+		fh.pendingWG.Done()
+	}
+	fh.pendingWG.Wait()
+}
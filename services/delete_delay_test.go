@@ -0,0 +1,75 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func newDelayedDeleteHandler(t *testing.T, delay time.Duration) (*FileHandler, string, string) {
+	t.Helper()
+	inputDir := t.TempDir()
+	filePath := filepath.Join(inputDir, "file.txt")
+	if err := os.WriteFile(filePath, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}, nil)
+	fh.DeleteDelay = delay
+	if err := fh.ProcessFile(filePath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	return fh, filePath, inputDir
+}
+
+func TestFileHandler_DeleteDelay(t *testing.T) {
+	fh, filePath, inputDir := newDelayedDeleteHandler(t, 100*time.Millisecond)
+
+	if _, err := os.Stat(filePath); err != nil {
+		t.Fatalf("source file should still exist right after the transfer: %v", err)
+	}
+	// A rescan during the delay must not deliver the file again
+	if err := fh.ProcessFile(filePath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() during delay error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("source file should be removed after the delete delay")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if fh.isPendingDeletion(filePath) {
+		t.Error("removed file should no longer be pending")
+	}
+}
+
+func TestFileHandler_FlushPendingDeletions(t *testing.T) {
+	fh, filePath, _ := newDelayedDeleteHandler(t, time.Hour)
+
+	fh.FlushPendingDeletions()
+
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Error("flush should remove pending source files immediately")
+	}
+}
+
+func TestFileHandler_DeleteDelayKeepsChangedFile(t *testing.T) {
+	fh, filePath, _ := newDelayedDeleteHandler(t, time.Hour)
+
+	if err := os.WriteFile(filePath, []byte("new content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fh.FlushPendingDeletions()
+
+	if _, err := os.Stat(filePath); err != nil {
+		t.Errorf("file changed during the delay should be kept: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// deliveryCounters accumulates the outcome of all deliveries, safe for concurrent workers
+type deliveryCounters struct {
+	processed atomic.Int64
+	failed    atomic.Int64
+	bytes     atomic.Int64
+	panics    atomic.Int64
+}
+
+// record counts a finished delivery, the bytes only if it succeeded
+func (c *deliveryCounters) record(size int64, err error) {
+	if err != nil {
+		c.failed.Add(1)
+		return
+	}
+	c.processed.Add(1)
+	c.bytes.Add(size)
+}
+
+// recordPanic counts a file whose processing panicked as failed (see worker_panic.go)
+func (c *deliveryCounters) recordPanic() {
+	c.panics.Add(1)
+	c.failed.Add(1)
+}
+
+// DeliverySummary are the totals logged when the service stops
+type DeliverySummary struct {
+	Processed int64
+	Failed    int64
+	Bytes     int64
+	Panics    int64 // Files whose processing panicked, also counted as failed
+	Uptime    time.Duration
+	// SessionHash is the combined hash of all delivered files, nil if not enabled
+	SessionHash *SessionHashStatus
+}
+
+// Summary returns the delivery totals since the worker was created
+func (w *Worker) Summary() DeliverySummary {
+	summary := DeliverySummary{Uptime: time.Since(w.startedAt)}
+	if w.FileHandler != nil {
+		summary.Processed = w.FileHandler.counters.processed.Load()
+		summary.Failed = w.FileHandler.counters.failed.Load()
+		summary.Bytes = w.FileHandler.counters.bytes.Load()
+		summary.Panics = w.FileHandler.counters.panics.Load()
+		if w.FileHandler.SessionHash != nil {
+			status := w.FileHandler.SessionHash.Status()
+			summary.SessionHash = &status
+		}
+	}
+	return summary
+}
+
+// logSummary logs the delivery totals at shutdown
+func (w *Worker) logSummary() {
+	summary := w.Summary()
+	attrs := []any{
+		"processed", summary.Processed,
+		"failed", summary.Failed,
+		"bytes", summary.Bytes,
+		"uptime", summary.Uptime.Round(time.Second).String(),
+	}
+	if summary.Panics > 0 {
+		attrs = append(attrs, "panics", summary.Panics)
+	}
+	if summary.SessionHash != nil {
+		attrs = append(attrs, "session_hash", summary.SessionHash.Root, "session_files", summary.SessionHash.Files)
+	}
+	slog.Info("Shutdown summary", attrs...)
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package services
+
+import "errors"
+
+func statfsFreeBytes(string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
+
+func statfsFreeInodes(string) (free, total uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package services
+
+import "syscall"
+
+// statfsFreeBytes returns the space available to unprivileged users on the volume containing path
+func statfsFreeBytes(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// statfsFreeInodes returns the free and total number of inodes on the volume containing path
+func statfsFreeInodes(path string) (free, total uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return uint64(stat.Ffree), uint64(stat.Files), nil
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"file-shifter/config"
+	"log/slog"
+)
+
+// skipDryRun reports whether the transfer to a target is skipped in dry-run mode.
+// S3 targets are not skipped here: copyToS3 still resolves the client and checks the bucket
+// (read-only) before it skips the upload, so configuration errors show up in a dry run.
+func (fh *FileHandler) skipDryRun(relPath string, target config.OutputTarget) bool {
+	if !fh.DryRun || target.Type == "s3" {
+		return false
+	}
+	slog.Info("Dry run - transfer skipped", "file", relPath, "target", target.Path, "type", target.Type)
+	return true
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"file-shifter/config"
+)
+
+func TestFileHandler_DryRunKeepsSourceAndSkipsTargets(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	srcPath := filepath.Join(inputDir, "file.txt")
+	if err := os.WriteFile(srcPath, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifestPath := filepath.Join(t.TempDir(), "manifest.jsonl")
+	manifest, err := NewDeliveryManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manifest.Close()
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	fh.DryRun = true
+	fh.Manifest = manifest
+
+	if err := fh.ProcessFile(srcPath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	if _, err := os.Stat(srcPath); err != nil {
+		t.Errorf("source file must be kept in dry-run mode: %v", err)
+	}
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+		t.Errorf("dry run must not write to targets, found %d entries", len(entries))
+	}
+	if info, err := os.Stat(manifestPath); err == nil && info.Size() > 0 {
+		t.Error("dry run must not record deliveries in the manifest")
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"file-shifter/config"
+)
+
+// errFileTimeout is returned for a file whose delivery took longer than FileTimeout
+var errFileTimeout = errors.New("file timeout exceeded")
+
+// processFileWithTimeout delivers a file within FileTimeout. Once the timeout has passed the transfers are
+// cancelled, the targets cleaned up and the file is moved to DeadLetterDir.
+func (fh *FileHandler) processFileWithTimeout(parent context.Context, filePath, inputDir string) error {
+	ctx, cancel := context.WithTimeout(parent, fh.FileTimeout)
+	defer cancel()
+	fh.fileContexts.Store(filePath, ctx)
+
+	targets, err := fh.processFile(filePath, inputDir)
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	slog.Error("File timeout exceeded - transfers cancelled", "file", filePath, "timeout", fh.FileTimeout, "error", err)
+	relPath, relErr := filepath.Rel(inputDir, filePath)
+	if relErr != nil {
+		return fmt.Errorf("%w after %s: %w", errFileTimeout, fh.FileTimeout, relErr)
+	}
+	// Transfers that were still running may have left partial outputs on the targets the file was delivered to,
+	// even if the targets have been reloaded since
+	if cleanupErr := fh.cleanupTargetFiles(targets, fh.deliveryPath(relPath)); cleanupErr != nil {
+		slog.Error("Error cleaning target files of a timed out file", "file", filePath, "error", cleanupErr)
+	}
+	fh.completeDeferredDelivery(filePath)
+
+	if deadLetterErr := fh.moveToDeadLetter(filePath, relPath); deadLetterErr != nil {
+		slog.Error("Error moving the timed out file to the dead-letter directory - original file kept",
+			"file", filePath, "error", deadLetterErr)
+		return fmt.Errorf("%w after %s: %w", errFileTimeout, fh.FileTimeout, deadLetterErr)
+	}
+	message := "Timed out file moved to the dead-letter directory"
+	if fh.Mode == config.ModeCopy {
+		message = "Timed out file copied to the dead-letter directory, original file kept (copy mode)"
+	}
+	slog.Warn(message, "file", filePath, "dead_letter", filepath.Join(fh.DeadLetterDir, relPath))
+	return fmt.Errorf("%w after %s: %s", errFileTimeout, fh.FileTimeout, relPath)
+}
+
+// moveToDeadLetter moves a source file below DeadLetterDir, copying it if a rename is not possible.
+// In copy mode the original stays in place and is only copied.
+func (fh *FileHandler) moveToDeadLetter(filePath, relPath string) error {
+	dst := filepath.Join(fh.DeadLetterDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if fh.Mode == config.ModeCopy {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(filePath, dst); err != nil {
+			return err
+		}
+		// Like a moved file it is not retried by rescans, until it changes
+		fh.HashCache.Record(filePath, info, "")
+		return nil
+	}
+
+	fh.noteSourceRemoval(filePath)
+	if err := os.Rename(filePath, dst); err == nil {
+		return nil
+	}
+	fh.forgetSourceRemoval(filePath)
+
+	// E.g. on another volume
+	if err := copyFile(filePath, dst); err != nil {
+		return err
+	}
+	return fh.removeSourceFile(filePath)
+}
+
+// copyFile copies the content of src to a new file dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		removeIncompleteTarget(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// fileContext returns the context of a file being processed, carrying its FileTimeout and trace span, and
+// context.Background() for files delivered outside ProcessFile
+func (fh *FileHandler) fileContext(filePath string) context.Context {
+	if ctx, ok := fh.fileContexts.Load(filePath); ok {
+		return ctx.(context.Context)
+	}
+	return context.Background()
+}
+
+// withFileContext makes reads of a source file fail once its FileTimeout has passed, which cancels the
+// transfer streaming from it
+func (fh *FileHandler) withFileContext(filePath string, reader io.ReadCloser) io.ReadCloser {
+	ctx := fh.fileContext(filePath)
+	if ctx.Done() == nil {
+		return reader
+	}
+	return &contextReader{ReadCloser: reader, ctx: ctx}
+}
+
+// dialFileConn dials a connection that is closed once ctx (see fileContext) is done. This interrupts stalled
+// transfers of protocols without context support (SFTP, FTP control and data connections).
+func dialFileConn(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, network, address)
+	if err != nil || ctx.Done() == nil {
+		return conn, err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	return &contextConn{Conn: conn, stop: stop}, nil
+}
+
+// contextConn releases the close-on-timeout of dialFileConn when it is closed
+type contextConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *contextConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// contextReader fails reads once its context is done
+type contextReader struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadCloser.Read(p)
+}
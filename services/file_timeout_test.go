@@ -0,0 +1,218 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileHandler_FileTimeoutDeadLetter(t *testing.T) {
+	inputDir := t.TempDir()
+	deadLetterDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "sub", "slow.bin")
+	if err := os.MkdirAll(filepath.Dir(srcFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := bytes.Repeat([]byte("x"), 64*1024)
+	if err := os.WriteFile(srcFile, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 KiB/s makes the 64 KiB transfer take about a minute
+	slow := config.OutputTarget{Type: "filesystem", Path: t.TempDir(), Transfer: config.TargetTransferConfig{MaxBytesPerSec: 1024}}
+	fast := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+	fh := NewFileHandler([]config.OutputTarget{fast, slow}, nil)
+	fh.FileTimeout = 200 * time.Millisecond
+	fh.DeadLetterDir = deadLetterDir
+
+	start := time.Now()
+	err := fh.ProcessFile(srcFile, inputDir)
+	if !errors.Is(err, errFileTimeout) {
+		t.Fatalf("ProcessFile() error = %v, want %v", err, errFileTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the slow transfer should be cancelled at the timeout, took %s", elapsed)
+	}
+
+	if _, err := os.Stat(srcFile); !os.IsNotExist(err) {
+		t.Error("timed out file should be removed from the input directory")
+	}
+	deadLettered, err := os.ReadFile(filepath.Join(deadLetterDir, "sub", "slow.bin"))
+	if err != nil {
+		t.Fatalf("timed out file should be in the dead-letter directory: %v", err)
+	}
+	if !bytes.Equal(deadLettered, content) {
+		t.Error("dead-lettered file content differs from the original")
+	}
+
+	// Neither target keeps a partial output
+	for _, target := range []config.OutputTarget{fast, slow} {
+		if _, err := os.Stat(filepath.Join(target.Path, "sub", "slow.bin")); !os.IsNotExist(err) {
+			t.Errorf("target %s should not keep an output of the timed out file", target.Path)
+		}
+	}
+}
+
+func TestFileHandler_FileTimeoutCleansDeliveredTargetsAfterReload(t *testing.T) {
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "slow.bin")
+	if err := os.WriteFile(srcFile, bytes.Repeat([]byte("x"), 64*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	slow := config.OutputTarget{Type: "filesystem", Path: t.TempDir(), Transfer: config.TargetTransferConfig{MaxBytesPerSec: 1024}}
+	fast := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+	// The replacement target never receives the file, its own file of the same name must survive the cleanup
+	replacement := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+	unrelated := filepath.Join(replacement.Path, "slow.bin")
+	if err := os.WriteFile(unrelated, []byte("unrelated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler([]config.OutputTarget{fast, slow}, nil)
+	fh.FileTimeout = 300 * time.Millisecond
+	fh.DeadLetterDir = t.TempDir()
+	reload := time.AfterFunc(50*time.Millisecond, func() {
+		fh.SetOutputTargets([]config.OutputTarget{replacement})
+	})
+	defer reload.Stop()
+
+	if err := fh.ProcessFile(srcFile, inputDir); !errors.Is(err, errFileTimeout) {
+		t.Fatalf("ProcessFile() error = %v, want %v", err, errFileTimeout)
+	}
+
+	for _, target := range []config.OutputTarget{fast, slow} {
+		if _, err := os.Stat(filepath.Join(target.Path, "slow.bin")); !os.IsNotExist(err) {
+			t.Errorf("removed target %s should not keep an output of the timed out file", target.Path)
+		}
+	}
+	if data, err := os.ReadFile(unrelated); err != nil || string(data) != "unrelated" {
+		t.Errorf("the cleanup must not touch targets that never received the file, got %q, %v", data, err)
+	}
+}
+
+func TestFileHandler_FileTimeoutDeadLetterCopyMode(t *testing.T) {
+	inputDir := t.TempDir()
+	deadLetterDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "slow.bin")
+	content := bytes.Repeat([]byte("x"), 64*1024)
+	if err := os.WriteFile(srcFile, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	slow := config.OutputTarget{Type: "filesystem", Path: t.TempDir(), Transfer: config.TargetTransferConfig{MaxBytesPerSec: 1024}}
+	fh := NewFileHandler([]config.OutputTarget{slow}, nil)
+	fh.FileTimeout = 200 * time.Millisecond
+	fh.DeadLetterDir = deadLetterDir
+	fh.Mode = config.ModeCopy
+	fh.HashCache = NewHashCache()
+
+	if err := fh.ProcessFile(srcFile, inputDir); !errors.Is(err, errFileTimeout) {
+		t.Fatalf("ProcessFile() error = %v, want %v", err, errFileTimeout)
+	}
+
+	original, err := os.ReadFile(srcFile)
+	if err != nil || !bytes.Equal(original, content) {
+		t.Fatalf("the original must stay in the input directory in copy mode: %v", err)
+	}
+	deadLettered, err := os.ReadFile(filepath.Join(deadLetterDir, "slow.bin"))
+	if err != nil || !bytes.Equal(deadLettered, content) {
+		t.Fatalf("timed out file should be copied to the dead-letter directory: %v", err)
+	}
+	if _, skipped := fh.HashCache.Unchanged(srcFile, mustStat(t, srcFile)); !skipped {
+		t.Error("the dead-lettered original should not be retried by rescans while unchanged")
+	}
+}
+
+func TestFileHandler_FileTimeoutInterruptsMetadataPost(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "report.csv")
+	if err := os.WriteFile(srcFile, []byte("a,b,c\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: server.URL, Type: "metadata"}}, nil)
+	fh.FileTimeout = 200 * time.Millisecond
+	fh.DeadLetterDir = t.TempDir()
+
+	start := time.Now()
+	if err := fh.ProcessFile(srcFile, inputDir); !errors.Is(err, errFileTimeout) {
+		t.Fatalf("ProcessFile() error = %v, want %v", err, errFileTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the stalled metadata POST should be cancelled at the timeout, took %s", elapsed)
+	}
+}
+
+func TestDialFileConn_ClosedWhenContextDone(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		// Accept without ever answering, like a stalled server
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	conn, err := dialFileConn(ctx, "tcp", listener.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatalf("dialFileConn() error = %v", err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected the read to fail once the context is done")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the stalled read should be interrupted at the timeout, took %s", elapsed)
+	}
+}
+
+func TestFileHandler_FileTimeoutNotReached(t *testing.T) {
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "quick.txt")
+	if err := os.WriteFile(srcFile, []byte("quick"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+	fh := NewFileHandler([]config.OutputTarget{target}, nil)
+	fh.FileTimeout = 10 * time.Second
+	fh.DeadLetterDir = t.TempDir()
+
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target.Path, "quick.txt")); err != nil {
+		t.Errorf("file should be delivered within the timeout: %v", err)
+	}
+	if _, ok := fh.fileContexts.Load(srcFile); ok {
+		t.Error("the file context should be released after processing")
+	}
+}
@@ -1,34 +1,148 @@
 package services
 
 import (
+	"context"
 	"crypto/sha256"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"file-shifter/config"
 
 	"github.com/jlaffaye/ftp"
 	"github.com/pkg/sftp"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/ssh"
 )
 
+// checksumMetadataKey is the S3 user metadata key (x-amz-meta-sha256) for StoreChecksumMetadata
+const checksumMetadataKey = "sha256"
+
+// Indirections for metadata preservation and fsync, replaceable in tests
+var (
+	chmodFile   = os.Chmod
+	chtimesFile = os.Chtimes
+	syncFile    = (*os.File).Sync
+	syncDir     = syncDirectory
+)
+
 type FileHandler struct {
 	S3ClientManager *S3ClientManager
-	OutputTargets   []config.OutputTarget
+	// outputTargets can be replaced while files are processed, see OutputTargets and SetOutputTargets
+	outputTargets atomic.Pointer[[]config.OutputTarget]
+	Manifest      *DeliveryManifest // Optional audit trail of all deliveries
+	SessionHash   *SessionHash      // Optional combined hash of all delivered files (see session_hash.go)
+	// Target base paths whose filesystem does not support setting timestamps (see chtimes_unsupported.go)
+	chtimesUnsupported sync.Map
+	// RequireMetadataPreservation turns Chmod/Chtimes failures on filesystem targets into transfer errors
+	RequireMetadataPreservation bool
+	// Fsync syncs copied files (FsyncDir also their directory) before the copy counts as complete
+	Fsync    bool
+	FsyncDir bool
+	// MinFreeInodes refuses filesystem writes when fewer inodes are free on the target volume (0 = off)
+	MinFreeInodes uint64
+	// ChecksumDuringCopy calculates the initial checksum while copying to a single filesystem target (see checksum_copy.go)
+	ChecksumDuringCopy bool
+	// QuickVerify skips the final checksum if size and mtime are unchanged (see quick_verify.go)
+	QuickVerify bool
+	// TempSuffix makes filesystem copies atomic: content is written to a hidden temp file ending in TempSuffix
+	// (see tempPath) and renamed when complete
+	TempSuffix string
+	// ForceFileMode is applied to copied files instead of the source mode (0 = keep the source mode)
+	ForceFileMode os.FileMode
+	// MaxInMemoryBytes limits content that has to be buffered completely (see buffer.go)
+	MaxInMemoryBytes int64
+	// SourceRemoveRetries is the number of retries if removing the source fails (see source_remove.go)
+	SourceRemoveRetries int
+	// CompletionMarkerSuffix names the marker (<name><suffix>) removed together with a source file (see completion_marker.go)
+	CompletionMarkerSuffix string
+	// PropagateDeletes removes files deleted from the input directory from the targets (see propagate_deletes.go)
+	PropagateDeletes bool
+	removedSources   sync.Map
+	// HashCache skips delivered files kept in the input directory while they are unchanged (nil = off, see hash_cache.go)
+	HashCache *HashCache
+	// Initial checksums of the files being transferred, by source path (see checksum_metadata.go)
+	sourceChecksums sync.Map
+	// Additional S3 keys with date placeholders written by the files being processed (see templates.go)
+	writtenS3Keys sync.Map
+	// Mode config.ModeCopy keeps delivered source files, config.ModeMove or empty removes them.
+	// Kept originals are tracked in CopiedFiles, which is persisted so they are not copied again after a restart.
+	Mode string
+	// CopiedFiles holds the originals delivered in copy mode by path and checksum (see copy_mode.go)
+	CopiedFiles *CopiedFiles
+	// Files waiting for targets outside their schedule (see schedule.go)
+	deferredDeliveries map[string]*deferredDelivery
+	deferredMutex      sync.Mutex
+	// Files waiting for the archive upload of batched S3 targets (see s3_batch.go)
+	batchMutex   sync.Mutex
+	batches      map[string]*s3Batch
+	batchedFiles map[string]*batchedFile
+	batchSeq     atomic.Int64
+	// DeleteDelay defers the removal of delivered source files (see delete_delay.go)
+	DeleteDelay      time.Duration
+	pendingMutex     sync.Mutex
+	pendingDeletions map[string]*pendingDeletion
+	pendingWG        sync.WaitGroup
+	// MaxBytesPerSec limits the bandwidth of all transfers together, targets may set a lower limit (see throttle.go)
+	MaxBytesPerSec int
+	// FileTimeout caps the delivery of a single file, timed out files are moved to DeadLetterDir (see file_timeout.go)
+	FileTimeout   time.Duration
+	DeadLetterDir string
+	fileContexts  sync.Map
+	// DryRun logs transfers instead of writing to targets and keeps the source files (see dryrun.go)
+	DryRun bool
+	// Decompress delivers *.gz files decompressed without the suffix (see decompress.go)
+	Decompress    bool
+	limitersMutex sync.Mutex
+	limiters      map[string]*rateLimiter
+	// AllowedHosts restricts FTP/SFTP connections to these hosts, addresses or CIDR ranges (see allowed_hosts.go)
+	AllowedHosts []string
+	// Tracer records a span per processed file with a child span per target (nil = no tracing, see tracing.go)
+	Tracer trace.Tracer
+	// TLSConfig is used for HTTPS connections of metadata targets (nil = system defaults)
+	TLSConfig *tls.Config
+	// Kafka producers by target path (see kafka_target.go)
+	kafkaMutex     sync.Mutex
+	kafkaProducers map[string]kafkaProducer
+	// HTTP client of metadata targets (see metadata_target.go)
+	metadataMutex      sync.Mutex
+	metadataHTTPClient *http.Client
+	// Totals of all deliveries for the shutdown summary (see delivery_stats.go)
+	counters deliveryCounters
 }
 
 func NewFileHandler(targets []config.OutputTarget, s3ClientManager *S3ClientManager) *FileHandler {
-	return &FileHandler{
+	fh := &FileHandler{
 		S3ClientManager: s3ClientManager,
-		OutputTargets:   targets,
 	}
+	fh.SetOutputTargets(targets)
+	return fh
+}
+
+// OutputTargets returns the current output targets. The slice is shared and must not be modified.
+func (fh *FileHandler) OutputTargets() []config.OutputTarget {
+	if targets := fh.outputTargets.Load(); targets != nil {
+		return *targets
+	}
+	return nil
+}
+
+// SetOutputTargets replaces the output targets, e.g. on a configuration reload. Files that are
+// already being processed are delivered to the targets they started with.
+func (fh *FileHandler) SetOutputTargets(targets []config.OutputTarget) {
+	fh.outputTargets.Store(&targets)
 }
 
 // normaliseRemotePath converts Windows paths to Unix style for remote transfer
@@ -36,6 +150,15 @@ func normalizeRemotePath(path string) string {
 	return strings.ReplaceAll(path, "\\", "/")
 }
 
+// ftpRemotePath converts a remote path to the separator of the FTP server (empty = "/")
+func ftpRemotePath(path, separator string) string {
+	path = normalizeRemotePath(path)
+	if separator == `\` {
+		return strings.ReplaceAll(path, "/", `\`)
+	}
+	return path
+}
+
 // parseRemotePath parses FTP/SFTP URLs and returns host, remotePath and default port
 func parseRemotePath(targetPath, relPath string, defaultPort string) (host, remotePath string, err error) {
 	u, err := url.Parse(targetPath)
@@ -71,9 +194,28 @@ func createSSHConfig(ftpConfig config.FTPConfig) *ssh.ClientConfig {
 	}
 }
 
-// connectAndLoginFTP establishes an FTP connection and logs in
-func connectAndLoginFTP(host string, ftpConfig config.FTPConfig) (*ftp.ServerConn, error) {
-	client, err := ftp.Dial(host, ftp.DialWithTimeout(30*time.Second))
+// dialSSH establishes an SSH connection to address like ssh.Dial, closing it once ctx is done. host is the
+// target as configured and is used for the handshake (host key check).
+func dialSSH(ctx context.Context, address, host string, sshConfig *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := dialFileConn(ctx, "tcp", address, sshConfig.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, host, sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// connectAndLoginFTP establishes an FTP connection and logs in. The control and data connections are closed
+// once ctx is done.
+func connectAndLoginFTP(ctx context.Context, host string, ftpConfig config.FTPConfig) (*ftp.ServerConn, error) {
+	client, err := ftp.Dial(host, ftp.DialWithTimeout(30*time.Second),
+		ftp.DialWithDialFunc(func(network, address string) (net.Conn, error) {
+			return dialFileConn(ctx, network, address, 30*time.Second)
+		}))
 	if err != nil {
 		return nil, fmt.Errorf("FTP connection failed: %w", err)
 	}
@@ -117,67 +259,173 @@ func parseS3Path(targetPath, relPath string) (s3PathInfo, error) {
 
 // calculateFileChecksum calculates the SHA256 checksum of a file
 func (fh *FileHandler) calculateFileChecksum(filePath string) (string, error) {
-	file, err := os.Open(filePath)
+	file, err := openSourceFile(filePath)
 	if err != nil {
 		return "", fmt.Errorf("error opening file for checksum: %w", err)
 	}
 	defer file.Close()
 
+	return checksumReader(fh.withFileContext(filePath, file))
+}
+
+// checksumReader streams r through SHA256 without buffering the content
+func checksumReader(r io.Reader) (string, error) {
 	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
+	if _, err := io.Copy(hash, r); err != nil {
 		return "", fmt.Errorf("error calculating checksum: %w", err)
 	}
 
 	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
-func (fh *FileHandler) ProcessFile(filePath, inputDir string) error {
+func (fh *FileHandler) ProcessFile(filePath, inputDir string) (err error) {
+	// The transfers are traced as children of the file span (see tracing.go)
+	ctx, span := fh.startFileSpan(filePath)
+	defer func() { endSpan(span, err) }()
+	fh.fileContexts.Store(filePath, ctx)
+	defer fh.fileContexts.Delete(filePath)
+	// The written S3 keys are only needed by the cleanups of this delivery (see templates.go)
+	defer fh.forgetS3KeysOf(filePath)
+
+	// The whole delivery of the file is limited by FileTimeout (see file_timeout.go)
+	if fh.FileTimeout > 0 {
+		return fh.processFileWithTimeout(ctx, filePath, inputDir)
+	}
+	_, err = fh.processFile(filePath, inputDir)
+	return err
+}
+
+// processFile delivers a file and returns the targets it was delivered to immediately, i.e. without the targets
+// outside their schedule and the batched targets
+func (fh *FileHandler) processFile(filePath, inputDir string) ([]config.OutputTarget, error) {
 	const maxChecksumRetries = 5
 
+	if fh.isPendingDeletion(filePath) {
+		slog.Debug("File already delivered, removal is pending - skipped", "file", filePath)
+		return nil, nil
+	}
+
+	// All attempts deliver to the same targets, even if they are replaced in the meantime
+	targets := fh.OutputTargets()
+	if remaining, ok := fh.remainingTargets(filePath); ok {
+		targets = remaining
+	} else if fh.isBatched(filePath) {
+		slog.Debug("File already delivered, waiting for the batch upload - skipped", "file", filePath)
+		return nil, nil
+	}
+	// Targets outside their schedule receive the file later (see schedule.go)
+	targets, deferred := splitBySchedule(targets, scheduleNow())
+	// Batched targets receive the file with their next archive (see s3_batch.go)
+	targets, batched := splitBatched(targets)
+	if len(targets) == 0 && len(batched) == 0 && len(deferred) > 0 {
+		fh.deferDelivery(filePath, deferred)
+		return nil, nil
+	}
+
 	for attempt := 1; attempt <= maxChecksumRetries; attempt++ {
-		retry, err := fh.processFileAttempt(filePath, inputDir, attempt, maxChecksumRetries)
+		retry, err := fh.processFileAttempt(targets, deferred, batched, filePath, inputDir, attempt, maxChecksumRetries)
 		if err != nil {
-			return err
+			return targets, err
 		}
 		if retry {
 			continue
 		}
-		return nil
+		return targets, nil
 	}
 
-	return fmt.Errorf("processing aborted after retries: %s", filePath)
+	return targets, fmt.Errorf("processing aborted after retries: %s", filePath)
 }
 
-func (fh *FileHandler) processFileAttempt(filePath, inputDir string, attempt, maxChecksumRetries int) (bool, error) {
+func (fh *FileHandler) processFileAttempt(targets, deferred, batched []config.OutputTarget, filePath, inputDir string, attempt, maxChecksumRetries int) (bool, error) {
 	slog.Info("Process file", "file", filePath, "attempt", attempt, "max_attempts", maxChecksumRetries)
 
-	initialChecksum, err := fh.calculateFileChecksum(filePath)
-	if err != nil {
-		return false, fmt.Errorf("error calculating initial checksum: %w", err)
-	}
-	slog.Debug("Initial checksum calculated", "file", filePath, "checksum", initialChecksum)
-
 	relPath, err := filepath.Rel(inputDir, filePath)
 	if err != nil {
 		return false, fmt.Errorf("error determining relative path: %w", err)
 	}
+	relPath = fh.deliveryPath(relPath)
 
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
 		return false, fmt.Errorf("error reading file information: %w", err)
 	}
 
-	if err := fh.copyToAllTargets(filePath, relPath, fileInfo); err != nil {
+	var initialChecksum string
+	if target, ok := fh.checksumDuringCopyTarget(targets, filePath); ok {
+		// The copy is the first read, only the final checksum needs another one (see checksum_copy.go)
+		initialChecksum, err = fh.copyToFilesystemWithChecksum(filePath, relPath, target, fileInfo)
+	} else {
+		initialChecksum, err = fh.calculateFileChecksum(filePath)
+		if err != nil {
+			return false, fmt.Errorf("error calculating initial checksum: %w", err)
+		}
+		slog.Debug("Initial checksum calculated", "file", filePath, "checksum", initialChecksum)
+		// Targets storing the checksum reuse it instead of reading the source again (see checksum_metadata.go)
+		fh.sourceChecksums.Store(filePath, initialChecksum)
+		defer fh.sourceChecksums.Delete(filePath)
+
+		err = fh.copyToAllTargets(targets, filePath, relPath, fileInfo)
+	}
+	if err != nil {
+		fh.recordDelivery(targets, relPath, initialChecksum, fileInfo.Size(), err)
 		return false, err
 	}
 
-	return fh.finalizeProcessedFile(filePath, relPath, initialChecksum, attempt, maxChecksumRetries)
+	retry, err := fh.finalizeProcessedFile(targets, deferred, batched, filePath, relPath, initialChecksum, fileInfo, attempt, maxChecksumRetries)
+	if !retry {
+		fh.recordDelivery(targets, relPath, initialChecksum, fileInfo.Size(), err)
+	}
+	if !retry && err == nil {
+		fh.recordKeptSource(filePath, fileInfo, initialChecksum)
+	}
+	return retry, err
+}
+
+// recordDelivery counts the outcome of a delivery and writes it to the manifest, if configured
+func (fh *FileHandler) recordDelivery(targets []config.OutputTarget, relPath, checksum string, size int64, deliveryErr error) {
+	fh.counters.record(size, deliveryErr)
+	if fh.SessionHash != nil && deliveryErr == nil && !fh.DryRun {
+		fh.SessionHash.Add(filepath.ToSlash(relPath), checksum)
+	}
+	if fh.Manifest == nil || fh.DryRun {
+		return
+	}
+
+	targetPaths := make([]string, 0, len(targets))
+	for _, target := range targets {
+		targetPaths = append(targetPaths, target.Path)
+	}
+
+	entry := ManifestEntry{
+		Timestamp: time.Now().UTC(),
+		RelPath:   filepath.ToSlash(relPath),
+		Checksum:  checksum,
+		Size:      size,
+		Targets:   targetPaths,
+		Result:    deliveryResultSuccess,
+	}
+	if deliveryErr != nil {
+		entry.Result = deliveryResultFailed
+		entry.Error = deliveryErr.Error()
+	}
+
+	if err := fh.Manifest.Record(entry); err != nil {
+		slog.Error("Error writing manifest entry", "file", relPath, "error", err)
+	}
 }
 
-func (fh *FileHandler) copyToAllTargets(filePath, relPath string, fileInfo os.FileInfo) error {
+func (fh *FileHandler) copyToAllTargets(targets []config.OutputTarget, filePath, relPath string, fileInfo os.FileInfo) error {
 	var transferErrors []error
 
-	for _, target := range fh.OutputTargets {
+	// Several filesystem targets are written from a single read of the source (see filesystem_fanout.go)
+	if fanOut := fh.fanOutFilesystemTargets(targets); len(fanOut) > 1 {
+		transferErrors = append(transferErrors, fh.copyToFilesystemTargets(filePath, relPath, fanOut, fileInfo)...)
+		targets = slices.DeleteFunc(slices.Clone(targets), func(target config.OutputTarget) bool {
+			return target.Type == "filesystem"
+		})
+	}
+
+	for _, target := range targets {
 		if err := fh.copyToTarget(filePath, relPath, target, fileInfo); err != nil {
 			transferErrors = append(transferErrors, err)
 		}
@@ -191,7 +439,14 @@ func (fh *FileHandler) copyToAllTargets(filePath, relPath string, fileInfo os.Fi
 	return nil
 }
 
-func (fh *FileHandler) copyToTarget(filePath, relPath string, target config.OutputTarget, fileInfo os.FileInfo) error {
+func (fh *FileHandler) copyToTarget(filePath, relPath string, target config.OutputTarget, fileInfo os.FileInfo) (err error) {
+	relPath = targetRelPath(target, relPath)
+	if fh.skipDryRun(relPath, target) {
+		return nil
+	}
+	span := fh.startTargetSpan(filePath, relPath, target.Type, target.Path)
+	defer func() { endSpan(span, err) }()
+
 	switch target.Type {
 	case "filesystem":
 		if err := fh.copyToFilesystem(filePath, relPath, target.Path, fileInfo); err != nil {
@@ -213,6 +468,16 @@ func (fh *FileHandler) copyToTarget(filePath, relPath string, target config.Outp
 			slog.Error("SFTP-Transfer failed", "target", target.Path, "error", err)
 			return fmt.Errorf("SFTP transfer failed: %w", err)
 		}
+	case "metadata":
+		if err := fh.copyToMetadata(filePath, relPath, target, fileInfo); err != nil {
+			slog.Error("Metadata-Transfer failed", "target", target.Path, "error", err)
+			return fmt.Errorf("metadata transfer failed: %w", err)
+		}
+	case "kafka":
+		if err := fh.copyToKafka(filePath, relPath, target, fileInfo); err != nil {
+			slog.Error("Kafka-Transfer failed", "target", target.Path, "error", err)
+			return fmt.Errorf("kafka transfer failed: %w", err)
+		}
 	default:
 		return fmt.Errorf("unknown target type: %s", target.Type)
 	}
@@ -220,11 +485,16 @@ func (fh *FileHandler) copyToTarget(filePath, relPath string, target config.Outp
 	return nil
 }
 
-func (fh *FileHandler) finalizeProcessedFile(filePath, relPath, initialChecksum string, attempt, maxChecksumRetries int) (bool, error) {
-	finalChecksum, checksumErr := fh.calculateFileChecksum(filePath)
+func (fh *FileHandler) finalizeProcessedFile(targets, deferred, batched []config.OutputTarget, filePath, relPath, initialChecksum string, fileInfo os.FileInfo, attempt, maxChecksumRetries int) (bool, error) {
+	if fh.DryRun {
+		slog.Info("Dry run - original file kept", "file", filePath)
+		return false, nil
+	}
+
+	finalChecksum, checksumErr := fh.finalChecksum(filePath, initialChecksum, fileInfo)
 	if checksumErr != nil {
 		slog.Error("Error calculating final checksum", "file", filePath, "error", checksumErr)
-		if cleanupErr := fh.cleanupTargetFiles(relPath); cleanupErr != nil {
+		if cleanupErr := fh.cleanupTargetFiles(targets, relPath); cleanupErr != nil {
 			return false, fmt.Errorf("error cleaning target files: %w", cleanupErr)
 		}
 		return false, fmt.Errorf("error calculating the final checksum: %w", checksumErr)
@@ -238,7 +508,7 @@ func (fh *FileHandler) finalizeProcessedFile(filePath, relPath, initialChecksum
 			"attempt", attempt,
 			"max_attempts", maxChecksumRetries)
 
-		if err := fh.cleanupTargetFiles(relPath); err != nil {
+		if err := fh.cleanupTargetFiles(targets, relPath); err != nil {
 			slog.Error("Error deleting target files", "file", relPath, "error", err)
 		}
 
@@ -249,7 +519,37 @@ func (fh *FileHandler) finalizeProcessedFile(filePath, relPath, initialChecksum
 		return true, nil
 	}
 
-	if err := os.Remove(filePath); err != nil {
+	// The source is removed after the upload of the batch archives (see s3_batch.go)
+	if len(batched) > 0 {
+		fh.addToBatches(filePath, relPath, initialChecksum, fileInfo, batched)
+	}
+
+	// The source is only removed once the scheduled targets have received it as well
+	if len(deferred) > 0 {
+		fh.deferDelivery(filePath, deferred)
+		return false, nil
+	}
+	fh.completeDeferredDelivery(filePath)
+	if fh.isBatched(filePath) {
+		return false, nil
+	}
+
+	// The source is remembered so rescans do not deliver it again (see copy_mode.go)
+	if fh.Mode == config.ModeCopy {
+		fh.CopiedFiles.Add(filePath, initialChecksum)
+		slog.Info("File successfully processed, original file kept (copy mode)", "file", relPath)
+		return false, nil
+	}
+
+	if fh.DeleteDelay > 0 {
+		if err := fh.scheduleSourceRemoval(filePath); err != nil {
+			return false, fmt.Errorf("error scheduling the removal of the original file: %w", err)
+		}
+		slog.Info("File successfully processed, removal of the original file pending", "file", relPath)
+		return false, nil
+	}
+
+	if err := fh.removeSourceFile(filePath); err != nil {
 		slog.Error("Error deleting the original file", "file", filePath, "error", err)
 		return false, fmt.Errorf("error deleting the original file: %w", err)
 	}
@@ -258,42 +558,78 @@ func (fh *FileHandler) finalizeProcessedFile(filePath, relPath, initialChecksum
 	return false, nil
 }
 
+// fileInTargetPath returns the first existing component of dir that is not a directory, if any
+func fileInTargetPath(dir string) string {
+	for current := filepath.Clean(dir); ; current = filepath.Dir(current) {
+		if info, err := os.Stat(current); err == nil {
+			if !info.IsDir() {
+				return current
+			}
+			return ""
+		}
+		if parent := filepath.Dir(current); parent == current {
+			return ""
+		}
+	}
+}
+
 func (fh *FileHandler) copyToFilesystem(srcPath, relPath, targetBasePath string, fileInfo os.FileInfo) error {
-	targetPath := filepath.Join(targetBasePath, relPath)
-	targetDir := filepath.Dir(targetPath)
+	return fh.copyToFilesystems(srcPath, relPath, []string{targetBasePath}, fileInfo)[0]
+}
 
-	// Create target directory
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return fmt.Errorf("error creating the target directory: %w", err)
+// removeIncompleteTarget removes a target file whose copy could not be completed
+func removeIncompleteTarget(targetPath string) {
+	if err := os.Remove(targetPath); err != nil && !os.IsNotExist(err) {
+		slog.Error("Error removing incomplete target file", "file", targetPath, "error", err)
 	}
+}
 
-	// Copy file
-	srcFile, err := os.Open(srcPath)
-	if err != nil {
-		return fmt.Errorf("error opening source file: %w", err)
+// syncCopiedFile flushes the new file (and its directory entry) to disk if fsync is configured
+func (fh *FileHandler) syncCopiedFile(dstFile *os.File, targetDir string) error {
+	if fh.Fsync {
+		if err := syncFile(dstFile); err != nil {
+			return fmt.Errorf("error syncing the target file: %w", err)
+		}
 	}
-	defer srcFile.Close()
+	if fh.FsyncDir {
+		if err := syncDir(targetDir); err != nil {
+			return fmt.Errorf("error syncing the target directory: %w", err)
+		}
+	}
+	return nil
+}
 
-	dstFile, err := os.Create(targetPath)
+// syncDirectory fsyncs a directory so that newly created entries are durable
+func syncDirectory(path string) error {
+	dir, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("error creating target file: %w", err)
+		return err
 	}
-	defer dstFile.Close()
+	defer dir.Close()
+	return dir.Sync()
+}
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
-		return fmt.Errorf("error copying the file: %w", err)
+// preserveMetadata applies the source permissions and timestamps to the target file.
+// Failures are only logged unless metadata preservation is required.
+func (fh *FileHandler) preserveMetadata(basePath, targetPath string, fileInfo os.FileInfo) error {
+	mode := fileInfo.Mode()
+	if fh.ForceFileMode != 0 {
+		mode = fh.ForceFileMode
 	}
-
-	// Set file permissions and timestamps
-	if err := os.Chmod(targetPath, fileInfo.Mode()); err != nil {
+	if err := chmodFile(targetPath, mode); err != nil {
+		if fh.RequireMetadataPreservation {
+			return fmt.Errorf("error setting file permissions: %w", err)
+		}
 		slog.Warn("Could not set file permissions", "file", targetPath, "error", err)
 	}
 
-	if err := os.Chtimes(targetPath, fileInfo.ModTime(), fileInfo.ModTime()); err != nil {
-		slog.Warn("Could not set timestamp", "file", targetPath, "error", err)
+	if err := chtimesFile(targetPath, fileInfo.ModTime(), fileInfo.ModTime()); err != nil {
+		if fh.RequireMetadataPreservation {
+			return fmt.Errorf("error setting timestamp: %w", err)
+		}
+		fh.warnChtimesFailed(basePath, targetPath, err)
 	}
 
-	slog.Info("File successfully copied to file system", "source", relPath, "target", targetPath)
 	return nil
 }
 
@@ -305,6 +641,11 @@ func (fh *FileHandler) copyToS3(srcPath, relPath string, target config.OutputTar
 	// S3-Konfiguration aus dem Target extrahieren
 	s3Config := target.GetS3Config()
 
+	// Fail fast before connecting if the store would reject the object anyway
+	if err := checkS3ObjectSize(srcPath, s3Config.MaxObjectSize); err != nil {
+		return err
+	}
+
 	// Den entsprechenden MinIO-Client für diese Konfiguration holen
 	minioClient, err := fh.S3ClientManager.GetOrCreateClient(s3Config)
 	if err != nil {
@@ -321,23 +662,90 @@ func (fh *FileHandler) copyToS3(srcPath, relPath string, target config.OutputTar
 	bucketName := minioClient.SanitizeBucketName(s3Path.bucketName)
 
 	// Bucket sicherstellen
-	if err := minioClient.EnsureBucket(bucketName); err != nil {
+	if err := fh.ensureS3Bucket(minioClient, bucketName, target); err != nil {
 		return fmt.Errorf("fehler beim Sicherstellen des Buckets: %w", err)
 	}
+	if fh.DryRun {
+		slog.Info("Dry run - S3 upload skipped", "quelle", relPath, "bucket", bucketName, "endpoint", s3Config.Endpoint)
+		return nil
+	}
 
-	// Datei hochladen
-	if _, err := minioClient.UploadFile(srcPath, bucketName, s3Path.objectKey); err != nil {
-		return fmt.Errorf("fehler beim S3-Upload: %w", err)
+	// Datei hochladen (Haupt-Key und zusätzliche Keys)
+	uploadOptions := UploadOptions{
+		UserMetadata:                s3UserMetadata(target.UserMetadata, relPath),
+		ACL:                         target.ACL,
+		DisableContentTypeDetection: target.DisableContentTypeDetection,
+		limiters:                    fh.transferLimiters(target.Type, target.Path),
+		decompress:                  fh.decompresses(srcPath),
+		ctx:                         fh.fileContext(srcPath),
+	}
+	// The checksum of decompressed content is calculated while the first key is uploaded (see checksum_metadata.go)
+	var checksum string
+	if target.StoreChecksumMetadata && !uploadOptions.decompress {
+		if checksum, err = fh.sourceChecksum(srcPath); err != nil {
+			return err
+		}
+		uploadOptions.UserMetadata = withChecksumMetadata(uploadOptions.UserMetadata, checksum)
+	}
+	objectKeys := append([]string{s3Path.objectKey}, fh.uploadS3Keys(srcPath, target, relPath)...)
+	for _, objectKey := range objectKeys {
+		if target.StoreChecksumMetadata && checksum == "" {
+			if checksum, err = uploadWithContentChecksum(minioClient, srcPath, bucketName, objectKey, &uploadOptions); err != nil {
+				return fmt.Errorf("fehler beim S3-Upload: %w", err)
+			}
+			continue
+		}
+		if _, err := minioClient.UploadFileWithOptions(srcPath, bucketName, objectKey, uploadOptions); err != nil {
+			return fmt.Errorf("fehler beim S3-Upload: %w", err)
+		}
 	}
 
 	slog.Info("Datei erfolgreich zu S3 hochgeladen",
 		"quelle", relPath,
 		"bucket", bucketName,
-		"key", s3Path.objectKey,
+		"keys", objectKeys,
 		"endpoint", s3Config.Endpoint)
 	return nil
 }
 
+// ErrObjectTooLarge is returned when a file exceeds the MaxObjectSize of an S3 target
+var ErrObjectTooLarge = errors.New("file exceeds the maximum object size of the S3 target")
+
+// checkS3ObjectSize rejects source files larger than maxSize (0 = unlimited)
+func checkS3ObjectSize(srcPath string, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return fmt.Errorf("error reading file information: %w", err)
+	}
+	if info.Size() > maxSize {
+		return fmt.Errorf("%w: %d bytes > %d bytes", ErrObjectTooLarge, info.Size(), maxSize)
+	}
+	return nil
+}
+
+// ensureS3Bucket creates a missing bucket unless the target disables it; dry-run mode never creates buckets
+func (fh *FileHandler) ensureS3Bucket(minioClient *MinIO, bucketName string, target config.OutputTarget) error {
+	exists, err := minioClient.BucketExists(bucketName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	if !target.BucketCreationEnabled() {
+		return fmt.Errorf("bucket %q does not exist and create-bucket-if-missing is disabled", bucketName)
+	}
+	if fh.DryRun {
+		slog.Info("Dry run - bucket would be created", "bucket", bucketName)
+		return nil
+	}
+	return minioClient.CreateBucket(bucketName)
+}
+
 func (fh *FileHandler) copyToFTP(srcPath, relPath string, target config.OutputTarget) error {
 	host, remotePath, err := parseRemotePath(target.Path, relPath, "21")
 	if err != nil {
@@ -357,11 +765,20 @@ func (fh *FileHandler) copyToSFTP(srcPath, relPath string, target config.OutputT
 }
 
 func (fh *FileHandler) copyToSFTPClient(srcPath, remotePath, host string, target config.OutputTarget) error {
+	address, err := fh.checkAllowedHost(host)
+	if err != nil {
+		return err
+	}
+	if err := checkPathLength(remotePath); err != nil {
+		return err
+	}
+
 	// SSH-Verbindung aufbauen
 	ftpConfig := target.GetFTPConfig()
 	sshConfig := createSSHConfig(ftpConfig)
 
-	conn, err := ssh.Dial("tcp", host, sshConfig)
+	// The connection is closed once the FileTimeout has passed (see file_timeout.go)
+	conn, err := dialSSH(fh.fileContext(srcPath), address, host, sshConfig)
 	if err != nil {
 		return fmt.Errorf("SSH-Verbindung fehlgeschlagen: %w", err)
 	}
@@ -381,7 +798,7 @@ func (fh *FileHandler) copyToSFTPClient(srcPath, remotePath, host string, target
 	}
 
 	// Quelldatei öffnen
-	srcFile, err := os.Open(srcPath)
+	srcFile, err := fh.openSource(srcPath)
 	if err != nil {
 		return fmt.Errorf("fehler beim Öffnen der Quelldatei: %w", err)
 	}
@@ -395,7 +812,7 @@ func (fh *FileHandler) copyToSFTPClient(srcPath, remotePath, host string, target
 	defer dstFile.Close()
 
 	// Datei übertragen
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+	if _, err := io.Copy(dstFile, throttleReader(srcFile, fh.transferLimiters(target.Type, target.Path))); err != nil {
 		return fmt.Errorf("fehler beim SFTP-Upload: %w", err)
 	}
 
@@ -404,46 +821,34 @@ func (fh *FileHandler) copyToSFTPClient(srcPath, remotePath, host string, target
 }
 
 func (fh *FileHandler) copyToFTPRegular(srcPath, remotePath, host string, target config.OutputTarget) error {
+	address, err := fh.checkAllowedHost(host)
+	if err != nil {
+		return err
+	}
+
 	// FTP-Verbindung aufbauen und anmelden
 	ftpConfig := target.GetFTPConfig()
-	client, err := connectAndLoginFTP(host, ftpConfig)
+	client, err := connectAndLoginFTP(fh.fileContext(srcPath), address, ftpConfig)
 	if err != nil {
 		return err
 	}
 	defer client.Quit()
 
 	// Remote-Verzeichnis erstellen (falls nötig)
-	remoteDir := filepath.Dir(remotePath)
-	if remoteDir != "." && remoteDir != "/" {
-		// Verzeichnisse schrittweise erstellen
-		dirs := strings.Split(remoteDir, "/")
-		currentPath := ""
-		for _, dir := range dirs {
-			if dir == "" {
-				continue
-			}
-			currentPath = filepath.Join(currentPath, dir)
-			// Unix-Style Pfad für FTP
-			currentPath = normalizeRemotePath(currentPath)
-			if err := client.MakeDir(currentPath); err != nil {
-				// Fehler ignorieren falls Verzeichnis bereits existiert
-				slog.Debug("Verzeichnis existiert möglicherweise bereits", "verzeichnis", currentPath)
-			}
-		}
-	}
+	makeFTPDirs(client, filepath.Dir(remotePath), ftpConfig.PathSeparator)
 
 	// Quelldatei öffnen
-	srcFile, err := os.Open(srcPath)
+	srcFile, err := fh.openSource(srcPath)
 	if err != nil {
 		return fmt.Errorf("fehler beim Öffnen der Quelldatei: %w", err)
 	}
 	defer srcFile.Close()
 
-	// Unix-Style Pfad für FTP verwenden
-	remotePath = normalizeRemotePath(remotePath)
+	// Pfad im Format des FTP-Servers verwenden
+	remotePath = ftpRemotePath(remotePath, ftpConfig.PathSeparator)
 
 	// Datei übertragen
-	if err := client.Stor(remotePath, srcFile); err != nil {
+	if err := client.Stor(remotePath, throttleReader(srcFile, fh.transferLimiters(target.Type, target.Path))); err != nil {
 		return fmt.Errorf("fehler beim FTP-Upload: %w", err)
 	}
 
@@ -451,49 +856,96 @@ func (fh *FileHandler) copyToFTPRegular(srcPath, remotePath, host string, target
 	return nil
 }
 
+// makeFTPDirs creates a remote directory and its parents step by step, existing directories are ignored
+func makeFTPDirs(client *ftp.ServerConn, remoteDir, pathSeparator string) {
+	if remoteDir == "." || remoteDir == "/" {
+		return
+	}
+
+	currentPath := ""
+	for _, dir := range strings.Split(remoteDir, "/") {
+		if dir == "" {
+			continue
+		}
+		currentPath = filepath.Join(currentPath, dir)
+		// Pfad im Format des FTP-Servers
+		remoteDirPath := ftpRemotePath(currentPath, pathSeparator)
+		if err := client.MakeDir(remoteDirPath); err != nil {
+			// Fehler ignorieren falls Verzeichnis bereits existiert
+			slog.Debug("Verzeichnis existiert möglicherweise bereits", "verzeichnis", remoteDirPath)
+		}
+	}
+}
+
 // cleanupTargetFiles löscht bereits übertragene Dateien in allen konfigurierten Zielen
-func (fh *FileHandler) cleanupTargetFiles(relPath string) error {
+func (fh *FileHandler) cleanupTargetFiles(targets []config.OutputTarget, relPath string) error {
 	slog.Info("Lösche bereits übertragene Dateien", "file", relPath)
 	var cleanupErrors []error
 
-	for _, target := range fh.OutputTargets {
+	for _, target := range targets {
+		relPath := targetRelPath(target, relPath)
 		switch target.Type {
 		case "filesystem":
-			if err := fh.deleteFromFilesystem(relPath, target.Path); err != nil {
+			if err := retryDelete(relPath, target, func() error { return fh.deleteFromFilesystem(relPath, target.Path) }); err != nil {
 				cleanupErrors = append(cleanupErrors, fmt.Errorf("filesystem-löschung fehlgeschlagen: %w", err))
 				slog.Error("Filesystem-Löschung fehlgeschlagen", "target", target.Path, "error", err)
 			}
 		case "s3":
-			if err := fh.deleteFromS3(relPath, target); err != nil {
+			if err := retryDelete(relPath, target, func() error { return fh.deleteFromS3(relPath, target) }); err != nil {
 				cleanupErrors = append(cleanupErrors, fmt.Errorf("s3-löschung fehlgeschlagen: %w", err))
 				slog.Error("S3-Löschung fehlgeschlagen", "target", target.Path, "error", err)
 			}
 		case "ftp":
-			if err := fh.deleteFromFTP(relPath, target); err != nil {
+			if err := retryDelete(relPath, target, func() error { return fh.deleteFromFTP(relPath, target) }); err != nil {
 				cleanupErrors = append(cleanupErrors, fmt.Errorf("ftp-löschung fehlgeschlagen: %w", err))
 				slog.Error("FTP-Löschung fehlgeschlagen", "target", target.Path, "error", err)
 			}
 		case "sftp":
-			if err := fh.deleteFromSFTP(relPath, target); err != nil {
+			if err := retryDelete(relPath, target, func() error { return fh.deleteFromSFTP(relPath, target) }); err != nil {
 				cleanupErrors = append(cleanupErrors, fmt.Errorf("sftp-löschung fehlgeschlagen: %w", err))
 				slog.Error("SFTP-Löschung fehlgeschlagen", "target", target.Path, "error", err)
 			}
+		case "metadata":
+			if err := retryDelete(relPath, target, func() error { return fh.deleteFromMetadata(relPath, target) }); err != nil {
+				cleanupErrors = append(cleanupErrors, fmt.Errorf("metadata-löschung fehlgeschlagen: %w", err))
+				slog.Error("Metadata-Löschung fehlgeschlagen", "target", target.Path, "error", err)
+			}
+		case "kafka":
+			if err := retryDelete(relPath, target, func() error { return fh.deleteFromKafka(relPath, target) }); err != nil {
+				cleanupErrors = append(cleanupErrors, fmt.Errorf("kafka-tombstone fehlgeschlagen: %w", err))
+				slog.Error("Kafka-Tombstone fehlgeschlagen", "target", target.Path, "error", err)
+			}
 		}
 	}
 
 	if len(cleanupErrors) > 0 {
-		return fmt.Errorf("cleanup-fehler: %v", cleanupErrors)
+		return fmt.Errorf("cleanup-fehler: %w", errors.Join(cleanupErrors...))
 	}
 
 	slog.Info("Alle Zieldateien erfolgreich gelöscht", "file", relPath)
 	return nil
 }
 
+// Bounded retry of deletions during the cleanup, transient errors must not leave orphaned files on targets
+const cleanupDeleteAttempts = 3
+
+// cleanupDeleteBackoff is the delay before the second attempt, doubled for every further attempt
+var cleanupDeleteBackoff = 100 * time.Millisecond
+
+// retryDelete runs a delete of the cleanup until it succeeds or cleanupDeleteAttempts is reached
+func retryDelete(relPath string, target config.OutputTarget, deleteFn func() error) error {
+	return retryWithBackoff(cleanupDeleteAttempts, cleanupDeleteBackoff, func(int) error { return deleteFn() },
+		func(attempt int, retryIn time.Duration, err error) {
+			slog.Warn("Löschen im Ziel fehlgeschlagen - neuer Versuch",
+				"file", relPath, "target", target.Path, "attempt", attempt, "retry_in", retryIn, "error", err)
+		})
+}
+
 // deleteFromFilesystem löscht eine Datei vom Filesystem
 func (fh *FileHandler) deleteFromFilesystem(relPath, targetBasePath string) error {
 	targetPath := filepath.Join(targetBasePath, relPath)
 
-	if err := os.Remove(targetPath); err != nil {
+	if err := removeFile(targetPath); err != nil {
 		if os.IsNotExist(err) {
 			slog.Debug("Datei existiert nicht im Filesystem-Ziel", "path", targetPath)
 			return nil // Datei existiert nicht - kein Fehler
@@ -529,14 +981,18 @@ func (fh *FileHandler) deleteFromS3(relPath string, target config.OutputTarget)
 	// Bucket-Name sanitarisieren
 	bucketName := minioClient.SanitizeBucketName(s3Path.bucketName)
 
-	// Datei löschen
-	if err := minioClient.DeleteFile(bucketName, s3Path.objectKey); err != nil {
-		return fmt.Errorf("fehler beim S3-Löschen: %w", err)
+	// Datei unter allen Keys löschen
+	objectKeys := append([]string{s3Path.objectKey}, fh.deliveredS3Keys(target, relPath)...)
+	for _, objectKey := range objectKeys {
+		if err := minioClient.DeleteFile(bucketName, objectKey); err != nil {
+			return fmt.Errorf("fehler beim S3-Löschen: %w", err)
+		}
 	}
+	fh.forgetS3Keys(target, relPath)
 
 	slog.Debug("Datei erfolgreich von S3 gelöscht",
 		"bucket", bucketName,
-		"key", s3Path.objectKey,
+		"keys", objectKeys,
 		"endpoint", s3Config.Endpoint)
 	return nil
 }
@@ -548,16 +1004,21 @@ func (fh *FileHandler) deleteFromFTP(relPath string, target config.OutputTarget)
 		return fmt.Errorf("fehler beim Parsen des FTP-Pfads: %w", err)
 	}
 
+	address, err := fh.checkAllowedHost(host)
+	if err != nil {
+		return err
+	}
+
 	// Establish FTP connection and log in
 	ftpConfig := target.GetFTPConfig()
-	client, err := connectAndLoginFTP(host, ftpConfig)
+	client, err := connectAndLoginFTP(context.Background(), address, ftpConfig)
 	if err != nil {
 		return err
 	}
 	defer client.Quit()
 
-	// Use Unix-style path for FTP
-	remotePath = normalizeRemotePath(remotePath)
+	// Use the path separator of the FTP server
+	remotePath = ftpRemotePath(remotePath, ftpConfig.PathSeparator)
 
 	if err := client.Delete(remotePath); err != nil {
 		// Check whether file exists (550 is the standard code for ‘file not found’)
@@ -578,11 +1039,15 @@ func (fh *FileHandler) deleteFromSFTP(relPath string, target config.OutputTarget
 	if err != nil {
 		return fmt.Errorf("fehler beim Parsen des SFTP-Pfads: %w", err)
 	}
+	address, err := fh.checkAllowedHost(host)
+	if err != nil {
+		return err
+	}
 
 	ftpConfig := target.GetFTPConfig()
 	sshConfig := createSSHConfig(ftpConfig)
 
-	conn, err := ssh.Dial("tcp", host, sshConfig)
+	conn, err := dialSSH(context.Background(), address, host, sshConfig)
 	if err != nil {
 		return fmt.Errorf("SSH connection failed: %w", err)
 	}
@@ -1,10 +1,18 @@
 package services
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"file-shifter/config"
 )
@@ -378,7 +386,7 @@ func TestFileHandler_cleanupTargetFiles_Extended(t *testing.T) {
 	fh := NewFileHandler(targets, NewS3ClientManager())
 
 	// Test cleanup
-	err = fh.cleanupTargetFiles("test.txt")
+	err = fh.cleanupTargetFiles(fh.OutputTargets(), "test.txt")
 	if err != nil {
 		t.Errorf("cleanupTargetFiles() error = %v", err)
 	}
@@ -606,11 +614,77 @@ func TestNewFileHandler(t *testing.T) {
 	if fh.S3ClientManager != s3Manager {
 		t.Error("S3ClientManager not set correctly")
 	}
-	if len(fh.OutputTargets) != len(targets) {
-		t.Errorf("OutputTargets length = %d, want %d", len(fh.OutputTargets), len(targets))
+	if len(fh.OutputTargets()) != len(targets) {
+		t.Errorf("OutputTargets length = %d, want %d", len(fh.OutputTargets()), len(targets))
 	}
-	if fh.OutputTargets[0].Path != targets[0].Path {
-		t.Errorf("OutputTargets[0].Path = %q, want %q", fh.OutputTargets[0].Path, targets[0].Path)
+	if fh.OutputTargets()[0].Path != targets[0].Path {
+		t.Errorf("OutputTargets[0].Path = %q, want %q", fh.OutputTargets()[0].Path, targets[0].Path)
+	}
+}
+
+// Läuft sinnvoll mit -race: Ziele werden ausgetauscht, während Dateien verarbeitet werden
+func TestFileHandler_SetOutputTargetsWhileProcessing(t *testing.T) {
+	inputDir := t.TempDir()
+	targetSets := [][]config.OutputTarget{
+		{{Type: "filesystem", Path: t.TempDir()}, {Type: "filesystem", Path: t.TempDir()}},
+		{{Type: "filesystem", Path: t.TempDir()}, {Type: "filesystem", Path: t.TempDir()}},
+	}
+	fh := NewFileHandler(targetSets[0], nil)
+
+	const fileCount = 20
+	stop := make(chan struct{})
+	swapped := make(chan struct{})
+	go func() {
+		defer close(swapped)
+		for i := 1; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				fh.SetOutputTargets(targetSets[i%len(targetSets)])
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := range fileCount {
+		name := fmt.Sprintf("file-%02d.txt", i)
+		srcFile := filepath.Join(inputDir, name)
+		if err := os.WriteFile(srcFile, []byte(name), 0644); err != nil {
+			t.Fatalf("failed to create source file: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+				t.Errorf("ProcessFile(%s) error = %v", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-swapped
+
+	// Jede Datei muss vollständig in genau einem Zielsatz liegen
+	for i := range fileCount {
+		name := fmt.Sprintf("file-%02d.txt", i)
+		var completeSets int
+		for _, targets := range targetSets {
+			var delivered int
+			for _, target := range targets {
+				if _, err := os.Stat(filepath.Join(target.Path, name)); err == nil {
+					delivered++
+				}
+			}
+			if delivered == len(targets) {
+				completeSets++
+			} else if delivered != 0 {
+				t.Errorf("%s delivered to %d of %d targets of one set", name, delivered, len(targets))
+			}
+		}
+		if completeSets != 1 {
+			t.Errorf("%s delivered to %d target sets, want 1", name, completeSets)
+		}
 	}
 }
 
@@ -1173,3 +1247,454 @@ func TestFileHandler_ProcessFile_MultipleTargets(t *testing.T) {
 		}
 	}
 }
+
+func TestFileHandler_copyToFilesystem_MetadataPreservation(t *testing.T) {
+	originalChtimes := chtimesFile
+	defer func() { chtimesFile = originalChtimes }()
+	chtimesFile = func(string, time.Time, time.Time) error {
+		return errors.New("chtimes not supported")
+	}
+
+	srcFile := filepath.Join(t.TempDir(), "source.txt")
+	if err := os.WriteFile(srcFile, []byte("metadata"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatalf("failed to stat source file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		require     bool
+		expectError bool
+	}{
+		{"best effort keeps file", false, false},
+		{"required fails and cleans up", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetDir := t.TempDir()
+			fh := NewFileHandler(nil, nil)
+			fh.RequireMetadataPreservation = tt.require
+
+			err := fh.copyToFilesystem(srcFile, "source.txt", targetDir, fileInfo)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("copyToFilesystem() error = %v, expectError %v", err, tt.expectError)
+			}
+
+			_, statErr := os.Stat(filepath.Join(targetDir, "source.txt"))
+			if tt.expectError && !os.IsNotExist(statErr) {
+				t.Error("expected target file to be removed after metadata failure")
+			}
+			if !tt.expectError && statErr != nil {
+				t.Errorf("expected target file to exist, got: %v", statErr)
+			}
+		})
+	}
+}
+
+func TestFileHandler_copyToFilesystem_ChtimesUnsupportedWarnsOnce(t *testing.T) {
+	originalChtimes := chtimesFile
+	defer func() { chtimesFile = originalChtimes }()
+	chtimesFile = func(name string, _, _ time.Time) error {
+		return &os.PathError{Op: "chtimes", Path: name, Err: syscall.ENOSYS}
+	}
+
+	var logs bytes.Buffer
+	originalLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(originalLogger)
+
+	fh := NewFileHandler(nil, nil)
+	targetDirs := []string{t.TempDir(), t.TempDir()}
+	for i := range 3 {
+		srcFile := filepath.Join(t.TempDir(), fmt.Sprintf("source-%d.txt", i))
+		if err := os.WriteFile(srcFile, []byte("metadata"), 0644); err != nil {
+			t.Fatalf("failed to create source file: %v", err)
+		}
+		fileInfo, err := os.Stat(srcFile)
+		if err != nil {
+			t.Fatalf("failed to stat source file: %v", err)
+		}
+		for _, targetDir := range targetDirs {
+			if err := fh.copyToFilesystem(srcFile, filepath.Base(srcFile), targetDir, fileInfo); err != nil {
+				t.Fatalf("copyToFilesystem() error = %v", err)
+			}
+		}
+	}
+
+	// One warning per target, none per file
+	if got := strings.Count(logs.String(), "level=WARN"); got != len(targetDirs) {
+		t.Errorf("expected %d warnings, got %d:\n%s", len(targetDirs), got, logs.String())
+	}
+}
+
+func TestFileHandler_copyToFilesystem_ForceFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permission bits are not supported on Windows")
+	}
+
+	srcFile := filepath.Join(t.TempDir(), "source.txt")
+	if err := os.WriteFile(srcFile, []byte("mode"), 0600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	if err := os.Chmod(srcFile, 0600); err != nil {
+		t.Fatalf("failed to chmod source file: %v", err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatalf("failed to stat source file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		force    os.FileMode
+		wantMode os.FileMode
+	}{
+		{"source mode preserved", 0, 0600},
+		{"forced mode ignores source", 0644, 0644},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetDir := t.TempDir()
+			fh := NewFileHandler(nil, nil)
+			fh.ForceFileMode = tt.force
+
+			if err := fh.copyToFilesystem(srcFile, "source.txt", targetDir, fileInfo); err != nil {
+				t.Fatalf("copyToFilesystem() error = %v", err)
+			}
+
+			info, err := os.Stat(filepath.Join(targetDir, "source.txt"))
+			if err != nil {
+				t.Fatalf("failed to stat target file: %v", err)
+			}
+			if info.Mode().Perm() != tt.wantMode {
+				t.Errorf("target mode = %o, want %o", info.Mode().Perm(), tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestFileHandler_CleanupTargetFilesRetriesDelete(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "fails once then succeeds", failures: 1, wantErr: false, wantCalls: 2},
+		{name: "fails permanently", failures: 100, wantErr: true, wantCalls: cleanupDeleteAttempts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalBackoff := cleanupDeleteBackoff
+			defer func() { cleanupDeleteBackoff = originalBackoff }()
+			cleanupDeleteBackoff = time.Millisecond
+			calls := stubRemoveFile(t, tt.failures)
+
+			targetDir := t.TempDir()
+			targetFile := filepath.Join(targetDir, "partial.txt")
+			if err := os.WriteFile(targetFile, []byte("partial"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			fh := NewFileHandler([]config.OutputTarget{{Path: targetDir, Type: "filesystem"}}, nil)
+			err := fh.cleanupTargetFiles(fh.OutputTargets(), "partial.txt")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cleanupTargetFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, errSharingViolation) {
+				t.Errorf("aggregated error should wrap the delete error, got: %v", err)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("delete attempts = %d, want %d", *calls, tt.wantCalls)
+			}
+			if _, statErr := os.Stat(targetFile); os.IsNotExist(statErr) == tt.wantErr {
+				t.Errorf("target file removed = %v, want %v", os.IsNotExist(statErr), !tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileHandler_CopyToFilesystemTargetIsFile(t *testing.T) {
+	srcFile := filepath.Join(t.TempDir(), "source.txt")
+	if err := os.WriteFile(srcFile, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetFile := filepath.Join(t.TempDir(), "output")
+	if err := os.WriteFile(targetFile, []byte("not a directory"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler(nil, nil)
+	err = fh.copyToFilesystem(srcFile, filepath.Join("sub", "source.txt"), targetFile, fileInfo)
+	if !errors.Is(err, config.ErrTargetPathIsFile) {
+		t.Fatalf("copyToFilesystem() error = %v, want %v", err, config.ErrTargetPathIsFile)
+	}
+	if !strings.Contains(err.Error(), targetFile) {
+		t.Errorf("error should name the file in the way, got: %v", err)
+	}
+}
+
+func TestFileHandler_CopyToFilesystemFsync(t *testing.T) {
+	srcFile := filepath.Join(t.TempDir(), "source.txt")
+	if err := os.WriteFile(srcFile, []byte("durable"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		fsync         bool
+		fsyncDir      bool
+		syncErr       error
+		wantFileSyncs int
+		wantDirSyncs  int
+		wantErr       bool
+	}{
+		{name: "disabled", wantFileSyncs: 0, wantDirSyncs: 0},
+		{name: "file only", fsync: true, wantFileSyncs: 1, wantDirSyncs: 0},
+		{name: "file and directory", fsync: true, fsyncDir: true, wantFileSyncs: 1, wantDirSyncs: 1},
+		{name: "sync failure", fsync: true, syncErr: errors.New("input/output error"), wantFileSyncs: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalSyncFile, originalSyncDir := syncFile, syncDir
+			defer func() { syncFile, syncDir = originalSyncFile, originalSyncDir }()
+
+			fileSyncs, dirSyncs := 0, 0
+			syncFile = func(f *os.File) error {
+				fileSyncs++
+				if tt.syncErr != nil {
+					return tt.syncErr
+				}
+				return f.Sync()
+			}
+			syncDir = func(path string) error {
+				dirSyncs++
+				return syncDirectory(path)
+			}
+
+			targetDir := t.TempDir()
+			fh := NewFileHandler(nil, nil)
+			fh.Fsync = tt.fsync
+			fh.FsyncDir = tt.fsyncDir
+
+			err := fh.copyToFilesystem(srcFile, "source.txt", targetDir, fileInfo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("copyToFilesystem() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fileSyncs != tt.wantFileSyncs || dirSyncs != tt.wantDirSyncs {
+				t.Errorf("syncs = file %d, dir %d, want file %d, dir %d", fileSyncs, dirSyncs, tt.wantFileSyncs, tt.wantDirSyncs)
+			}
+			if _, statErr := os.Stat(filepath.Join(targetDir, "source.txt")); tt.wantErr != os.IsNotExist(statErr) {
+				t.Errorf("target file exists = %v after error = %v", statErr == nil, err)
+			}
+		})
+	}
+}
+
+func TestFileHandler_ProcessFile_Mode(t *testing.T) {
+	tests := []struct {
+		mode       string
+		wantSource bool
+	}{
+		{mode: "", wantSource: false},
+		{mode: config.ModeMove, wantSource: false},
+		{mode: config.ModeCopy, wantSource: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("mode "+tt.mode, func(t *testing.T) {
+			inputDir, targetDir := t.TempDir(), t.TempDir()
+			srcFile := filepath.Join(inputDir, "report.csv")
+			if err := os.WriteFile(srcFile, []byte("a,b,c\n"), 0644); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+
+			fh := NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: targetDir}}, nil)
+			fh.Mode = tt.mode
+			fh.HashCache = NewHashCache()
+			fh.CopiedFiles = NewCopiedFiles()
+
+			if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+				t.Fatalf("ProcessFile() error = %v", err)
+			}
+
+			if data, err := os.ReadFile(filepath.Join(targetDir, "report.csv")); err != nil || string(data) != "a,b,c\n" {
+				t.Errorf("target content = %q, %v", data, err)
+			}
+			if _, err := os.Stat(srcFile); (err == nil) != tt.wantSource {
+				t.Errorf("source exists = %v, want %v", err == nil, tt.wantSource)
+			}
+			if !tt.wantSource {
+				return
+			}
+			checksum, cached := fh.HashCache.Unchanged(srcFile, mustStat(t, srcFile))
+			if !cached {
+				t.Error("the kept original should be cached so scans skip it")
+			}
+			if !fh.CopiedFiles.Contains(srcFile, checksum) {
+				t.Error("the kept original should be tracked by path and checksum")
+			}
+		})
+	}
+}
+
+func TestFileHandler_CopyModeNotReprocessedAfterRestart(t *testing.T) {
+	inputDir, targetDir := t.TempDir(), t.TempDir()
+	statePath := filepath.Join(t.TempDir(), "copied-files.json")
+	for _, name := range []string{"report.csv", "changed.csv"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("a,b,c\n"), 0644); err != nil {
+			t.Fatalf("failed to create source file: %v", err)
+		}
+	}
+
+	manifestPath := filepath.Join(t.TempDir(), "manifest.jsonl")
+	manifest, err := NewDeliveryManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manifest.Close()
+
+	// Each run starts with a new handler and watcher, like a restarted service
+	run := func() {
+		copiedFiles, err := LoadCopiedFiles(statePath)
+		if err != nil {
+			t.Fatalf("LoadCopiedFiles() error = %v", err)
+		}
+		fh := NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: targetDir}}, nil)
+		fh.Mode = config.ModeCopy
+		fh.HashCache = NewHashCache()
+		fh.CopiedFiles = copiedFiles
+		fh.Manifest = manifest
+		fw, err := NewFileWatcher(inputDir, fh, 5, 5*time.Millisecond, 10*time.Millisecond, 1, 10)
+		if err != nil {
+			t.Fatalf("Failed to create FileWatcher: %v", err)
+		}
+		defer fw.watcher.Close()
+		fw.lsofAvailable = false
+		fw.scanOrder = config.InputOrderName
+		fw.processExistingFiles()
+	}
+
+	run()
+	if got := manifestRelPaths(t, manifestPath); len(got) != 2 {
+		t.Fatalf("first run delivered %d files, want 2: %v", len(got), got)
+	}
+
+	// Changed while the service was down
+	if err := os.WriteFile(filepath.Join(inputDir, "changed.csv"), []byte("a,b,c\nd,e,f\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run()
+
+	got := manifestRelPaths(t, manifestPath)
+	if len(got) != 3 || got[2] != "changed.csv" {
+		t.Errorf("after the restart only the changed original should be copied again, deliveries: %v", got)
+	}
+}
+
+func TestLoadCopiedFiles(t *testing.T) {
+	dir := t.TempDir()
+	kept, removed := filepath.Join(dir, "kept.csv"), filepath.Join(dir, "removed.csv")
+	if err := os.WriteFile(kept, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	statePath := filepath.Join(dir, "state", "copied-files.json")
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	copied, err := LoadCopiedFiles(statePath)
+	if err != nil {
+		t.Fatalf("a missing state file should start an empty set, got %v", err)
+	}
+	copied.Add(kept, "sum-kept")
+	copied.Add(removed, "sum-removed")
+
+	reloaded, err := LoadCopiedFiles(statePath)
+	if err != nil {
+		t.Fatalf("LoadCopiedFiles() error = %v", err)
+	}
+	if !reloaded.Contains(kept, "sum-kept") {
+		t.Error("the recorded original should be loaded from the state file")
+	}
+	if reloaded.has(removed) {
+		t.Error("originals that no longer exist should be dropped on load")
+	}
+
+	if err := os.WriteFile(statePath, []byte("{broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCopiedFiles(statePath); err == nil {
+		t.Error("a corrupt state file should be reported")
+	}
+}
+
+func TestFileHandler_CopyModeNotReprocessedByScan(t *testing.T) {
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "report.csv")
+	if err := os.WriteFile(srcFile, []byte("a,b,c\n"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	manifestPath := filepath.Join(t.TempDir(), "manifest.jsonl")
+	manifest, err := NewDeliveryManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manifest.Close()
+
+	fh := NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: t.TempDir()}}, nil)
+	fh.Mode = config.ModeCopy
+	fh.HashCache = NewHashCache()
+	fh.CopiedFiles = NewCopiedFiles()
+	fh.Manifest = manifest
+	fw, err := NewFileWatcher(inputDir, fh, 5, 5*time.Millisecond, 10*time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	defer fw.watcher.Close()
+	fw.lsofAvailable = false
+	fw.scanOrder = config.InputOrderName
+
+	for range 3 {
+		fw.processExistingFiles()
+	}
+
+	// Touching the original invalidates the size/mtime cache, its unchanged checksum still skips it
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(srcFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	fw.processExistingFiles()
+
+	if got := manifestRelPaths(t, manifestPath); len(got) != 1 {
+		t.Errorf("copied file delivered %d times by repeated scans, want once: %v", len(got), got)
+	}
+
+	// Changed content is copied again
+	if err := os.WriteFile(srcFile, []byte("a,b,c\nd,e,f\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fw.processExistingFiles()
+	if got := manifestRelPaths(t, manifestPath); len(got) != 2 {
+		t.Errorf("changed original delivered %d times in total, want twice: %v", len(got), got)
+	}
+	if _, err := os.Stat(srcFile); err != nil {
+		t.Errorf("original should be kept in copy mode: %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"file-shifter/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// filesystemDestination is a target file written by a fan-out copy
+type filesystemDestination struct {
+	index      int // Position in the target base paths
+	basePath   string
+	targetPath string
+	writePath  string // targetPath, or the temp file renamed to it when complete (see temp_files.go)
+	targetDir  string
+	file       *os.File
+	writer     io.Writer // file, throttled by the limiters of this target
+	err        error     // First write error, the destination is skipped for the rest of the copy
+}
+
+// errAllDestinationsFailed stops a fan-out copy once no destination is left to write to
+var errAllDestinationsFailed = errors.New("all target files failed")
+
+// fanOutWriter writes to all destinations. A destination that fails records its error and drops out,
+// the others are still written.
+type fanOutWriter struct {
+	destinations []*filesystemDestination
+}
+
+func (f *fanOutWriter) Write(p []byte) (int, error) {
+	written := 0
+	for _, dst := range f.destinations {
+		if dst.err != nil {
+			continue
+		}
+		if _, err := dst.writer.Write(p); err != nil {
+			slog.Warn("Error writing to file system target - other targets continue", "target", dst.writePath, "error", err)
+			dst.err = err
+			continue
+		}
+		written++
+	}
+	if written == 0 {
+		return 0, errAllDestinationsFailed
+	}
+	return len(p), nil
+}
+
+// fanOutFilesystemTargets returns the filesystem targets that are written from a single read of the source.
+// In dry-run mode every target is handled on its own so the skipped transfers are logged.
+func (fh *FileHandler) fanOutFilesystemTargets(targets []config.OutputTarget) []config.OutputTarget {
+	if fh.DryRun {
+		return nil
+	}
+
+	var filesystemTargets []config.OutputTarget
+	for _, target := range targets {
+		if target.Type == "filesystem" {
+			filesystemTargets = append(filesystemTargets, target)
+		}
+	}
+	return filesystemTargets
+}
+
+// copyToFilesystemTargets copies a file to several filesystem targets, returning the errors of failed targets
+func (fh *FileHandler) copyToFilesystemTargets(srcPath, relPath string, targets []config.OutputTarget, fileInfo os.FileInfo) []error {
+	// Targets stripping different prefixes (see strip_prefix.go) are written by one copy per relative path
+	var targetRelPaths []string
+	basePaths := make(map[string][]string)
+	for _, target := range targets {
+		targetRel := targetRelPath(target, relPath)
+		if _, ok := basePaths[targetRel]; !ok {
+			targetRelPaths = append(targetRelPaths, targetRel)
+		}
+		basePaths[targetRel] = append(basePaths[targetRel], target.Path)
+	}
+
+	var transferErrors []error
+	for _, targetRel := range targetRelPaths {
+		// Each target gets its own span, although they are written together
+		spans := make([]trace.Span, len(basePaths[targetRel]))
+		for i, basePath := range basePaths[targetRel] {
+			spans[i] = fh.startTargetSpan(srcPath, targetRel, "filesystem", basePath)
+		}
+		for i, err := range fh.copyToFilesystems(srcPath, targetRel, basePaths[targetRel], fileInfo) {
+			endSpan(spans[i], err)
+			if err != nil {
+				slog.Error("Filesystem-Transfer failed", "target", basePaths[targetRel][i], "error", err)
+				transferErrors = append(transferErrors, fmt.Errorf("file system transfer failed: %w", err))
+			}
+		}
+	}
+	return transferErrors
+}
+
+// copyToFilesystems opens the source once and writes it to every target base path. A target that fails while
+// writing drops out, the others are completed. Each target is throttled by its own limiters only.
+// The returned errors are aligned with targetBasePaths, nil for successful targets.
+func (fh *FileHandler) copyToFilesystems(srcPath, relPath string, targetBasePaths []string, fileInfo os.FileInfo) []error {
+	return fh.copyToFilesystemsTee(srcPath, relPath, targetBasePaths, fileInfo, nil)
+}
+
+// copyToFilesystemsTee is copyToFilesystems, additionally writing the source content to tee if it is not nil
+func (fh *FileHandler) copyToFilesystemsTee(srcPath, relPath string, targetBasePaths []string, fileInfo os.FileInfo, tee io.Writer) []error {
+	errs := make([]error, len(targetBasePaths))
+	defer func() {
+		for i, basePath := range targetBasePaths {
+			errs[i] = pathLengthError(filepath.Join(basePath, relPath), errs[i])
+		}
+	}()
+
+	var destinations []*filesystemDestination
+	for i, basePath := range targetBasePaths {
+		targetPath := filepath.Join(basePath, relPath)
+		targetDir := filepath.Dir(targetPath)
+		if err := fh.prepareTargetDir(targetDir); err != nil {
+			errs[i] = err
+			continue
+		}
+		destinations = append(destinations, &filesystemDestination{
+			index:      i,
+			basePath:   basePath,
+			targetPath: targetPath,
+			writePath:  fh.tempPath(targetPath),
+			targetDir:  targetDir,
+		})
+	}
+	if len(destinations) == 0 {
+		return errs
+	}
+
+	// Copy file
+	srcFile, err := fh.openSource(srcPath)
+	if err != nil {
+		for _, dst := range destinations {
+			errs[dst.index] = fmt.Errorf("error opening source file: %w", err)
+		}
+		return errs
+	}
+	defer srcFile.Close()
+
+	// Reads are as small as the smallest throttled write, so a cancelled source (file timeout) is noticed soon
+	chunk := maxThrottleChunk
+	for _, dst := range destinations {
+		dst.file, err = os.Create(dst.writePath)
+		if err != nil {
+			errs[dst.index] = fmt.Errorf("error creating target file: %w", err)
+			continue
+		}
+		defer dst.file.Close()
+
+		limiters := fh.transferLimiters("filesystem", targetBasePaths[dst.index])
+		dst.writer = throttleWriter(dst.file, limiters)
+		if len(limiters) > 0 {
+			chunk = min(chunk, throttleChunk(limiters))
+		}
+	}
+	destinations = slices.DeleteFunc(destinations, func(dst *filesystemDestination) bool { return dst.file == nil })
+	if len(destinations) == 0 {
+		return errs
+	}
+
+	var reader io.Reader = srcFile
+	if tee != nil {
+		reader = io.TeeReader(reader, tee)
+	}
+	if _, err := io.CopyBuffer(&fanOutWriter{destinations: destinations}, reader, make([]byte, chunk)); err != nil {
+		for _, dst := range destinations {
+			_ = dst.file.Close()
+			removeIncompleteTarget(dst.writePath)
+			errs[dst.index] = fmt.Errorf("error copying the file: %w", cmp.Or(dst.err, err))
+		}
+		return errs
+	}
+
+	for _, dst := range destinations {
+		if dst.err != nil {
+			_ = dst.file.Close()
+			removeIncompleteTarget(dst.writePath)
+			errs[dst.index] = fmt.Errorf("error copying the file: %w", dst.err)
+			continue
+		}
+		if err := fh.finishFilesystemTarget(dst, fileInfo); err != nil {
+			_ = dst.file.Close()
+			removeIncompleteTarget(dst.writePath)
+			errs[dst.index] = err
+			continue
+		}
+		slog.Info("File successfully copied to file system", "source", relPath, "target", dst.targetPath)
+	}
+
+	return errs
+}
+
+// prepareTargetDir creates the target directory and checks its free inodes
+func (fh *FileHandler) prepareTargetDir(targetDir string) error {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		if filePath := fileInTargetPath(targetDir); filePath != "" {
+			return fmt.Errorf("error creating the target directory: %w: %s", config.ErrTargetPathIsFile, filePath)
+		}
+		return fmt.Errorf("error creating the target directory: %w", err)
+	}
+	return fh.checkFreeInodes(targetDir)
+}
+
+// finishFilesystemTarget syncs a copied target file, applies the source metadata and
+// renames a temp file to the target path
+func (fh *FileHandler) finishFilesystemTarget(dst *filesystemDestination, fileInfo os.FileInfo) error {
+	if dst.writePath == dst.targetPath {
+		if err := fh.syncCopiedFile(dst.file, dst.targetDir); err != nil {
+			return err
+		}
+
+		// Set file permissions and timestamps
+		return fh.preserveMetadata(dst.basePath, dst.targetPath, fileInfo)
+	}
+
+	if fh.Fsync {
+		if err := syncFile(dst.file); err != nil {
+			return fmt.Errorf("error syncing the target file: %w", err)
+		}
+	}
+	if err := fh.preserveMetadata(dst.basePath, dst.writePath, fileInfo); err != nil {
+		return err
+	}
+	if err := dst.file.Close(); err != nil {
+		return fmt.Errorf("error closing the temp file: %w", err)
+	}
+	if err := os.Rename(dst.writePath, dst.targetPath); err != nil {
+		return fmt.Errorf("error renaming the temp file: %w", err)
+	}
+	// The directory entry of the renamed file has to be durable
+	if fh.FsyncDir {
+		if err := syncDir(dst.targetDir); err != nil {
+			return fmt.Errorf("error syncing the target directory: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+//go:build linux
+
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileHandler_CopyToFilesystemTargetsFullTargetDropsOut(t *testing.T) {
+	srcFile := filepath.Join(t.TempDir(), "payload.bin")
+	if err := os.WriteFile(srcFile, make([]byte, 20*1024), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatalf("failed to stat source file: %v", err)
+	}
+
+	// Writes to the throttled target fail like on a full volume
+	full := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+	full.Transfer.MaxBytesPerSec = 40 * 1024
+	if err := os.Symlink("/dev/full", filepath.Join(full.Path, "payload.bin")); err != nil {
+		t.Fatalf("failed to link /dev/full: %v", err)
+	}
+	healthy := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+	fh := NewFileHandler([]config.OutputTarget{full, healthy}, nil)
+
+	start := time.Now()
+	errs := fh.copyToFilesystems(srcFile, "payload.bin", []string{full.Path, healthy.Path}, fileInfo)
+	elapsed := time.Since(start)
+
+	if !errors.Is(errs[0], syscall.ENOSPC) {
+		t.Errorf("full target error = %v, want %v", errs[0], syscall.ENOSPC)
+	}
+	if errs[1] != nil {
+		t.Fatalf("healthy target error = %v, want it to complete", errs[1])
+	}
+	if data, err := os.ReadFile(filepath.Join(healthy.Path, "payload.bin")); err != nil || len(data) != 20*1024 {
+		t.Errorf("healthy target has %d bytes, %v, want the full content", len(data), err)
+	}
+	// 20 KiB at the 40 KiB/s of the failed target would take about 500ms
+	if elapsed > 300*time.Millisecond {
+		t.Errorf("copy took %v, the limit of the failed target should not slow the healthy one", elapsed)
+	}
+}
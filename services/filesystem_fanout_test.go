@@ -0,0 +1,150 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"file-shifter/config"
+)
+
+// countSourceOpens counts the source files opened for transfers until the test ends
+func countSourceOpens(t testing.TB) *atomic.Int32 {
+	t.Helper()
+	var opens atomic.Int32
+	original := openSourceFile
+	openSourceFile = func(name string) (*os.File, error) {
+		opens.Add(1)
+		return original(name)
+	}
+	t.Cleanup(func() { openSourceFile = original })
+	return &opens
+}
+
+func TestFileHandler_CopyToAllTargetsSingleSourceRead(t *testing.T) {
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "report.csv")
+	content := []byte("a,b,c\n1,2,3\n")
+	if err := os.WriteFile(srcFile, content, 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatalf("failed to stat source file: %v", err)
+	}
+
+	targetDirs := []string{t.TempDir(), t.TempDir(), t.TempDir()}
+	var targets []config.OutputTarget
+	for _, dir := range targetDirs {
+		targets = append(targets, config.OutputTarget{Type: "filesystem", Path: dir})
+	}
+	fh := NewFileHandler(targets, nil)
+	opens := countSourceOpens(t)
+
+	if err := fh.copyToAllTargets(fh.OutputTargets(), srcFile, "nested/report.csv", fileInfo); err != nil {
+		t.Fatalf("copyToAllTargets() error = %v", err)
+	}
+
+	if got := opens.Load(); got != 1 {
+		t.Errorf("source opened %d times for %d filesystem targets, want 1", got, len(targetDirs))
+	}
+	for _, dir := range targetDirs {
+		data, err := os.ReadFile(filepath.Join(dir, "nested", "report.csv"))
+		if err != nil {
+			t.Fatalf("expected copy in %s: %v", dir, err)
+		}
+		if string(data) != string(content) {
+			t.Errorf("content in %s = %q, want %q", dir, data, content)
+		}
+	}
+}
+
+func TestFileHandler_CopyToFilesystemTargetsPartialFailure(t *testing.T) {
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "report.csv")
+	if err := os.WriteFile(srcFile, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatalf("failed to stat source file: %v", err)
+	}
+
+	goodDir := t.TempDir()
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(blocked, []byte("not a directory"), 0644); err != nil {
+		t.Fatalf("failed to create blocking file: %v", err)
+	}
+	fh := NewFileHandler([]config.OutputTarget{
+		{Type: "filesystem", Path: blocked},
+		{Type: "filesystem", Path: goodDir},
+	}, nil)
+
+	err = fh.copyToAllTargets(fh.OutputTargets(), srcFile, "report.csv", fileInfo)
+	if !errors.Is(err, config.ErrTargetPathIsFile) {
+		t.Fatalf("copyToAllTargets() error = %v, want %v", err, config.ErrTargetPathIsFile)
+	}
+	if _, err := os.Stat(filepath.Join(goodDir, "report.csv")); err != nil {
+		t.Errorf("expected the remaining target to be written: %v", err)
+	}
+}
+
+// failingWriter fails every write, like a full or unreachable target volume
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, syscall.ENOSPC }
+
+func TestFanOutWriter_FailedDestinationDropsOut(t *testing.T) {
+	var healthy bytes.Buffer
+	failed := &filesystemDestination{writer: failingWriter{}}
+	writer := &fanOutWriter{destinations: []*filesystemDestination{failed, {writer: &healthy}}}
+
+	if _, err := io.Copy(writer, strings.NewReader("content")); err != nil {
+		t.Fatalf("io.Copy() error = %v, want the healthy destination to complete", err)
+	}
+	if healthy.String() != "content" {
+		t.Errorf("healthy destination = %q, want %q", healthy.String(), "content")
+	}
+	if !errors.Is(failed.err, syscall.ENOSPC) {
+		t.Errorf("failed destination error = %v, want %v", failed.err, syscall.ENOSPC)
+	}
+
+	// Once no destination is left the copy stops
+	writer = &fanOutWriter{destinations: []*filesystemDestination{{writer: failingWriter{}}}}
+	if _, err := io.Copy(writer, strings.NewReader("content")); !errors.Is(err, errAllDestinationsFailed) {
+		t.Errorf("io.Copy() error = %v, want %v", err, errAllDestinationsFailed)
+	}
+}
+
+func BenchmarkFileHandler_CopyToFilesystemTargets(b *testing.B) {
+	srcFile := filepath.Join(b.TempDir(), "bench.bin")
+	if err := os.WriteFile(srcFile, make([]byte, 1<<20), 0644); err != nil {
+		b.Fatalf("failed to create source file: %v", err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		b.Fatalf("failed to stat source file: %v", err)
+	}
+
+	var targets []config.OutputTarget
+	for range 4 {
+		targets = append(targets, config.OutputTarget{Type: "filesystem", Path: b.TempDir()})
+	}
+	fh := NewFileHandler(targets, nil)
+	opens := countSourceOpens(b)
+
+	b.SetBytes(fileInfo.Size())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := fh.copyToAllTargets(fh.OutputTargets(), srcFile, "bench.bin", fileInfo); err != nil {
+			b.Fatalf("copyToAllTargets() error = %v", err)
+		}
+	}
+	b.ReportMetric(float64(opens.Load())/float64(b.N), "source-reads/op")
+}
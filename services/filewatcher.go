@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,6 +16,8 @@ import (
 	"syscall"
 	"time"
 
+	"file-shifter/config"
+
 	"github.com/fsnotify/fsnotify"
 )
 
@@ -26,45 +29,114 @@ type FileWatcher struct {
 	maxRetries      int
 	checkInterval   time.Duration
 	stabilityPeriod time.Duration
-	lsofAvailable   bool
+	stabilityMode   string // config.FileStabilityModeStat (default) or config.FileStabilityModeChecksum
+	// Process names that are relevant for lsof even if they are harmless
+	relevantProcesses []string
+	lsofAvailable     bool
+	procAvailable     bool // /proc/<pid>/fd scan as fallback without lsof (Linux only)
+	watchPatterns     watchPatternMatcher
 	// Worker pool for parallel processing
 	fileQueue   chan string
 	workerCount int
 	workers     sync.WaitGroup
+	// Additional workers only running during the initial scan (total, 0 = no boost)
+	initialScanWorkers int
+	activeWorkers      atomic.Int32
+	// Caps concurrent files per top-level subdirectory (see subdir_limit.go, nil = unlimited)
+	subdirLimit *subdirLimiter
+	// Caps the total size of queued and in-process files (see queue_bytes.go, nil = unlimited)
+	queueBytes *queueByteLimit
+	// Processing halts while this file exists in the input root (see pause_lock.go, empty = off)
+	pauseLockFile string
+	paused        atomic.Bool
+	// Limits how many files all workers start per second (nil = unlimited)
+	filesLimiter *rateLimiter
+	// Order of existing files during a scan, processed serially if set (see filewatcher_order.go)
+	scanOrder string
+	// Order of existing files handed to the worker pool during a scan, "smallest" or "largest" first
+	// (see filewatcher_size_order.go)
+	sizeOrder string
 	// Queue monitoring
 	queueCapacity      int
 	queueWarningLogged bool
 	queueMutex         sync.Mutex
+	queuedAt           map[string]time.Time // Enqueue time of every queued file, see OldestQueuedAge
 	// Deduplicate file events so each file is queued at most once at a time.
 	processingFiles map[string]struct{}
 	processingMutex sync.Mutex
-	producersWG     sync.WaitGroup
-	stopOnce        sync.Once
-	stopping        atomic.Bool
+	// Files that passed the checks of prepareFile and files whose delivery failed, the result of a single run
+	// (see once.go)
+	detectedFiles    atomic.Int64
+	failedDeliveries atomic.Int64
+	// Rename correlation (see filewatcher_rename.go)
+	pendingRenames map[string]time.Time
+	renameMutex    sync.Mutex
+	movedAway      map[string]struct{}
+	movedMutex     sync.Mutex
+	// Files created by an atomic rename skip the stability check (see filewatcher_rename.go)
+	renameComplete         bool
+	renameCompletePatterns []string
+	completeFiles          map[string]struct{}
+	// Files are only complete once <name><suffix> exists, the marker is not transferred (see completion_marker.go)
+	completionMarkerSuffix string
+	// Watches of removed directories are dropped after a grace period (see filewatcher_remove.go)
+	watchRemoveGrace   time.Duration
+	watchRemovals      map[string]*time.Timer
+	watchRemovalsMutex sync.Mutex
+	// Handling of file events whose path has become a directory (see filewatcher_typechange.go)
+	typeChange string
+	// Media types a file's sniffed content must match (see content_sniff.go, empty = all)
+	allowContentTypes []string
+	producersWG       sync.WaitGroup
+	producersMutex    sync.Mutex // Orders producers started after Start (Rescan, RunOnce) before Stop's producersWG.Wait
+	stopOnce          sync.Once
+	stopping          atomic.Bool
+	// Empty marker file written to all targets after a batch (see success_marker.go)
+	successMarker        string
+	successMarkerQuiet   time.Duration
+	deliveredSinceMarker atomic.Bool
+	markerFailed         atomic.Bool
+	lastDelivery         atomic.Int64
+	// Set while the initial scan or a rescan walks the input directory (see filewatcher_rescan.go)
+	scanning atomic.Bool
+	// Set while the initial scan walks the input directory; the files it enqueues form the initial backlog,
+	// guarded by processingMutex, which the additional initial scan workers process
+	initialScan    atomic.Bool
+	initialBacklog map[string]struct{}
 }
 
 func NewFileWatcher(inputDir string, fileHandler *FileHandler, maxRetries int, checkInterval, stabilityPeriod time.Duration, workerCount, queueSize int) (*FileWatcher, error) {
+	// An unbuffered queue would block every enqueue until a worker is free and reports a capacity of zero
+	if queueSize <= 0 {
+		return nil, fmt.Errorf("invalid queue size %d: must be positive", queueSize)
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
 
 	fw := &FileWatcher{
-		watcher:         watcher,
-		inputDir:        inputDir,
-		fileHandler:     fileHandler,
-		stopChan:        make(chan bool),
-		maxRetries:      maxRetries,
-		checkInterval:   checkInterval,
-		stabilityPeriod: stabilityPeriod,
-		fileQueue:       make(chan string, queueSize), // Configurable queue size
-		workerCount:     workerCount,                  // Configurable worker count
-		queueCapacity:   queueSize,                    // Store capacity for monitoring
-		processingFiles: make(map[string]struct{}),
+		watcher:          watcher,
+		inputDir:         inputDir,
+		fileHandler:      fileHandler,
+		stopChan:         make(chan bool),
+		maxRetries:       maxRetries,
+		checkInterval:    checkInterval,
+		stabilityPeriod:  stabilityPeriod,
+		fileQueue:        make(chan string, queueSize), // Configurable queue size
+		workerCount:      workerCount,                  // Configurable worker count
+		queueCapacity:    queueSize,                    // Store capacity for monitoring
+		processingFiles:  make(map[string]struct{}),
+		watchRemoveGrace: defaultWatchRemoveGrace,
 	}
 
 	// Check lsof availability
 	fw.lsofAvailable = checkLsofAvailable()
+	if !fw.lsofAvailable && procFDAvailable() {
+		slog.Debug("lsof not available - open files are checked via /proc instead")
+		fw.procAvailable = true
+	}
 
 	return fw, nil
 }
@@ -79,14 +151,40 @@ func (fw *FileWatcher) Start() error {
 	slog.Info("File-Watcher started", "directory", fw.inputDir)
 
 	// Process existing files at startup
+	scanDone := make(chan struct{})
+	fw.scanning.Store(true)
+	fw.initialScan.Store(true)
 	fw.producersWG.Add(1)
 	go func() {
 		defer fw.producersWG.Done()
+		defer close(scanDone)
+		defer fw.scanning.Store(false)
+		defer fw.initialScan.Store(false)
 		fw.processExistingFiles()
 	}()
 
 	// Start worker pool
 	fw.startWorkers()
+	fw.startInitialScanWorkers(scanDone)
+	// Also without scheduled or batched targets, they may be added by replacing the output targets
+	if fw.fileHandler != nil {
+		fw.producersWG.Add(2)
+		go func() {
+			defer fw.producersWG.Done()
+			fw.runScheduledDeliveries()
+		}()
+		go func() {
+			defer fw.producersWG.Done()
+			fw.runBatchUploads()
+		}()
+	}
+	if fw.successMarker != "" {
+		fw.producersWG.Add(1)
+		go func() {
+			defer fw.producersWG.Done()
+			fw.runSuccessMarker(scanDone)
+		}()
+	}
 
 	// Event-Loop
 	for {
@@ -99,6 +197,9 @@ func (fw *FileWatcher) Start() error {
 			if !ok {
 				return nil
 			}
+			// Correlate renames in event order before handling events concurrently
+			_, renamed := fw.correlateRename(event)
+			fw.noteRenameComplete(event, renamed)
 			// Avoid blocking the event loop for too long
 			fw.producersWG.Add(1)
 			go func(evt fsnotify.Event) {
@@ -117,7 +218,9 @@ func (fw *FileWatcher) Start() error {
 
 func (fw *FileWatcher) Stop() {
 	fw.stopOnce.Do(func() {
+		fw.producersMutex.Lock()
 		fw.stopping.Store(true)
+		fw.producersMutex.Unlock()
 		close(fw.stopChan)
 
 		if err := fw.watcher.Close(); err != nil {
@@ -126,6 +229,7 @@ func (fw *FileWatcher) Stop() {
 
 		// Wait for all producer goroutines to stop enqueuing new files before closing the queue.
 		fw.producersWG.Wait()
+		fw.stopWatchRemovals()
 		close(fw.fileQueue)
 		fw.workers.Wait()
 
@@ -133,18 +237,43 @@ func (fw *FileWatcher) Stop() {
 	})
 }
 
+// addProducer registers a producer goroutine unless the watcher is stopping. Stop sets stopping under the same
+// mutex, so a producer is either registered before producersWG.Wait or not started at all.
+func (fw *FileWatcher) addProducer() bool {
+	fw.producersMutex.Lock()
+	defer fw.producersMutex.Unlock()
+	if fw.stopping.Load() {
+		return false
+	}
+	fw.producersWG.Add(1)
+	return true
+}
+
 func (fw *FileWatcher) addRecursiveWatcher(root string) error {
 	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if info.IsDir() {
+			if !fw.watchPatterns.shouldWatchDir(fw.relativePath(path)) {
+				slog.Debug("Directory does not match watch patterns - skipped", "directory", path)
+				return filepath.SkipDir
+			}
 			return fw.watcher.Add(path)
 		}
 		return nil
 	})
 }
 
+// relativePath returns the path relative to the input directory
+func (fw *FileWatcher) relativePath(path string) string {
+	rel, err := filepath.Rel(fw.inputDir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
 func (fw *FileWatcher) handleEvent(event fsnotify.Event) {
 	slog.Debug("File-System event received", "event", event.Name, "op", event.Op)
 
@@ -173,13 +302,19 @@ func (fw *FileWatcher) isModificationEvent(event fsnotify.Event) bool {
 // handleRemoveEvent handles file or directory removal/rename events
 func (fw *FileWatcher) handleRemoveEvent(event fsnotify.Event) {
 	slog.Info("Path removed or renamed", "path", event.Name, "op", event.Op)
+	if fw.fileHandler != nil {
+		fw.fileHandler.HashCache.Forget(event.Name)
+		fw.fileHandler.CopiedFiles.Forget(event.Name)
+	}
 
-	// Remove the watcher if it exists (will fail silently if not watched)
-	// This is important for cleanup and memory management
-	if err := fw.watcher.Remove(event.Name); err != nil {
-		// Log as debug because this is expected for many sub-paths when a parent is deleted
-		slog.Debug("Info: Watcher already removed or not watched", "path", event.Name, "error", err)
+	// Must be checked before the watch is dropped (see propagate_deletes.go)
+	if fw.propagatesDelete(event.Name) {
+		fw.propagateDelete(event.Name)
 	}
+
+	// Remove the watcher if it exists once the grace period has expired (see filewatcher_remove.go)
+	// This is important for cleanup and memory management
+	fw.scheduleWatchRemoval(event.Name)
 }
 
 // handleModificationEvent handles file creation, modification, or permission change events
@@ -195,6 +330,11 @@ func (fw *FileWatcher) handleModificationEvent(event fsnotify.Event) {
 		return
 	}
 
+	fw.dropReplacedDirectoryWatch(event.Name)
+	if fw.isCompletionMarker(event.Name) {
+		fw.handleCompletionMarker(event.Name)
+		return
+	}
 	fw.processFile(event.Name)
 }
 
@@ -205,6 +345,9 @@ func (fw *FileWatcher) handleDirectoryCreation(event fsnotify.Event) {
 		return
 	}
 
+	// A directory recreated within the grace period keeps its watch
+	fw.cancelWatchRemoval(event.Name)
+
 	// Wait for directory to be ready
 	time.Sleep(100 * time.Millisecond)
 
@@ -232,48 +375,100 @@ func (fw *FileWatcher) handleDirectoryCreation(event fsnotify.Event) {
 }
 
 func (fw *FileWatcher) processFile(filePath string) {
-	if fw.stopping.Load() {
+	if !fw.prepareFile(filePath) {
 		return
 	}
 
+	// Enqueue file for processing with queue monitoring
+	fw.enqueueFileWithMonitoring(filePath)
+}
+
+// prepareFile runs all checks of a new file and waits until it is complete.
+// It returns true if the file has been marked for processing and can be handed to a worker.
+func (fw *FileWatcher) prepareFile(filePath string) bool {
+	if fw.stopping.Load() || fw.isPauseLock(filePath) {
+		return false
+	}
+	renamedIntoPlace := fw.takeRenameComplete(filePath)
+
 	// Check whether the file still exists (it may have been deleted in the meantime).
 	fileInfo, err := os.Lstat(filePath)
 	if os.IsNotExist(err) {
 		slog.Debug("File no longer exists", "file", filePath)
-		return
+		return false
 	}
 	if err != nil {
 		slog.Debug("Error reading file info", "file", filePath, "error", err)
-		return
+		return false
 	}
 
 	if fileInfo.Mode()&os.ModeSymlink != 0 {
 		slog.Warn("Rejecting symlink file", "file", filePath)
-		return
+		return false
+	}
+
+	if fileInfo.IsDir() {
+		fw.handleFileBecameDirectory(filePath)
+		return false
+	}
+
+	if fw.unchangedSinceDelivery(filePath, fileInfo) || fw.alreadyCopied(filePath, fileInfo) {
+		return false
+	}
+
+	if !fw.watchPatterns.matchesDir(fw.relativePath(filepath.Dir(filePath))) {
+		slog.Debug("File is outside of the watch patterns - skipped", "file", filePath)
+		return false
 	}
 
 	if !fw.tryMarkFileForProcessing(filePath) {
 		slog.Debug("File already queued or processing - skip duplicate event", "file", filePath)
-		return
+		return false
 	}
 
 	fileName := filepath.Base(filePath)
 	if fileName == "" || fileName[0] == '.' || fileName[0] == '~' {
 		fw.unmarkFileForProcessing(filePath)
 		slog.Debug("Ignore temporary/hidden file", "file", filePath)
-		return
+		return false
+	}
+
+	if fw.isCompletionMarker(filePath) {
+		fw.unmarkFileForProcessing(filePath)
+		slog.Debug("Ignore completion marker", "file", filePath)
+		return false
+	}
+	markedComplete := fw.completionMarkerSuffix != "" && fw.hasCompletionMarker(filePath)
+	if fw.completionMarkerSuffix != "" && !markedComplete {
+		fw.unmarkFileForProcessing(filePath)
+		slog.Debug("Completion marker missing - waiting", "file", filePath, "suffix", fw.completionMarkerSuffix)
+		return false
 	}
 
 	slog.Info("New file detected", "file", filePath)
+	fw.detectedFiles.Add(1)
+
+	if renamedIntoPlace {
+		slog.Info("File was renamed into place - stability check skipped", "file", filePath)
+	} else if markedComplete {
+		slog.Info("Completion marker found - stability check skipped", "file", filePath)
+	} else if err := fw.waitForCompleteFile(filePath); err != nil {
+		if fw.wasMovedAway(filePath) {
+			slog.Debug("File was renamed during completeness check - skipped", "file", filePath)
+		} else {
+			slog.Error("File is not complete - processing skipped", "file", filePath, "error", err)
+		}
+		fw.unmarkFileForProcessing(filePath)
+		return false
+	}
 
-	if err := fw.waitForCompleteFile(filePath); err != nil {
+	// The content can only be sniffed once the file is complete
+	if !fw.hasAllowedContent(filePath) {
 		fw.unmarkFileForProcessing(filePath)
-		slog.Error("File is not complete - processing skipped", "file", filePath, "error", err)
-		return
+		return false
 	}
 
-	// Enqueue file for processing with queue monitoring
-	fw.enqueueFileWithMonitoring(filePath)
+	return true
 }
 
 // enqueueFileWithMonitoring adds a file to the queue and monitors capacity
@@ -283,9 +478,19 @@ func (fw *FileWatcher) enqueueFileWithMonitoring(filePath string) {
 		return
 	}
 
-	// Add file to queue
+	// Backpressure of the byte limit, released when the file has been processed (see queue_bytes.go)
+	if !fw.acquireQueueBytes(filePath) {
+		fw.unmarkFileForProcessing(filePath)
+		return
+	}
+
+	// Add file to queue, the enqueue time is recorded first so a fast worker cannot dequeue it before
+	fw.trackQueued(filePath)
+	fw.trackInitialBacklog(filePath)
 	select {
 	case <-fw.stopChan:
+		fw.untrackQueued(filePath)
+		fw.releaseQueueBytes(filePath)
 		fw.unmarkFileForProcessing(filePath)
 		return
 	case fw.fileQueue <- filePath:
@@ -295,6 +500,34 @@ func (fw *FileWatcher) enqueueFileWithMonitoring(filePath string) {
 	fw.checkQueueCapacity()
 }
 
+func (fw *FileWatcher) trackQueued(filePath string) {
+	fw.queueMutex.Lock()
+	defer fw.queueMutex.Unlock()
+	if fw.queuedAt == nil {
+		fw.queuedAt = make(map[string]time.Time)
+	}
+	fw.queuedAt[filePath] = time.Now()
+}
+
+func (fw *FileWatcher) untrackQueued(filePath string) {
+	fw.queueMutex.Lock()
+	defer fw.queueMutex.Unlock()
+	delete(fw.queuedAt, filePath)
+}
+
+// OldestQueuedAge returns how long the oldest file has been waiting in the queue (0 = queue empty)
+func (fw *FileWatcher) OldestQueuedAge() time.Duration {
+	fw.queueMutex.Lock()
+	defer fw.queueMutex.Unlock()
+
+	var oldest time.Duration
+	now := time.Now()
+	for _, queuedAt := range fw.queuedAt {
+		oldest = max(oldest, now.Sub(queuedAt))
+	}
+	return oldest
+}
+
 // checkQueueCapacity monitors queue fill level and outputs warnings
 func (fw *FileWatcher) checkQueueCapacity() {
 	fw.queueMutex.Lock()
@@ -332,15 +565,63 @@ func (fw *FileWatcher) checkQueueCapacity() {
 
 func (fw *FileWatcher) worker() {
 	defer fw.workers.Done()
+	fw.activeWorkers.Add(1)
+	defer fw.activeWorkers.Add(-1)
 
 	for filePath := range fw.fileQueue {
-		if err := fw.fileHandler.ProcessFile(filePath, fw.inputDir); err != nil {
+		fw.processWithSubdirLimit(filePath, fw.processUnlessPaused)
+	}
+}
+
+// initialScanWorker processes the queue like worker but exits once the initial backlog has been processed
+func (fw *FileWatcher) initialScanWorker(backlogDone <-chan struct{}) {
+	defer fw.workers.Done()
+	fw.activeWorkers.Add(1)
+	defer fw.activeWorkers.Add(-1)
+
+	for {
+		select {
+		case <-backlogDone:
+			return
+		case filePath, ok := <-fw.fileQueue:
+			if !ok {
+				return
+			}
+			fw.processWithSubdirLimit(filePath, fw.processUnlessPaused)
+		}
+	}
+}
+
+func (fw *FileWatcher) processQueuedFile(filePath string) {
+	fw.untrackQueued(filePath)
+	if fw.wasMovedAway(filePath) {
+		slog.Debug("File was renamed before processing - skipped", "file", filePath)
+	} else if fw.isDirectory(filePath) {
+		// The watcher's own events of the directory take care of it, see filewatcher_typechange.go
+		slog.Warn("Queued file has become a directory - skipped", "path", filePath)
+	} else {
+		fw.waitForProcessingTurn()
+		err := fw.processFileRecovered(filePath)
+		if err != nil {
 			slog.Error("Error processing file", "file", filePath, "error", err)
 		}
-		fw.unmarkFileForProcessing(filePath)
+		fw.noteDeliveryResult(err)
+	}
+	fw.releaseQueueBytes(filePath)
+	fw.unmarkFileForProcessing(filePath)
 
-		// Queue monitoring after processing a file
-		fw.checkQueueCapacity()
+	// Queue monitoring after processing a file
+	fw.checkQueueCapacity()
+}
+
+// waitForProcessingTurn blocks until the files-per-second limit allows to start the next file
+func (fw *FileWatcher) waitForProcessingTurn() {
+	if fw.filesLimiter == nil {
+		return
+	}
+	if delay := fw.filesLimiter.reserveTurn(); delay > 0 {
+		slog.Debug("Files per second limit reached - waiting", "delay", delay)
+		time.Sleep(delay)
 	}
 }
 
@@ -357,10 +638,33 @@ func (fw *FileWatcher) tryMarkFileForProcessing(filePath string) bool {
 }
 
 func (fw *FileWatcher) unmarkFileForProcessing(filePath string) {
+	fw.processingMutex.Lock()
+	delete(fw.processingFiles, filePath)
+	delete(fw.initialBacklog, filePath)
+	fw.processingMutex.Unlock()
+
+	fw.clearMovedAway(filePath)
+}
+
+// InFlightFiles returns all files that are currently queued or being processed
+func (fw *FileWatcher) InFlightFiles() []string {
 	fw.processingMutex.Lock()
 	defer fw.processingMutex.Unlock()
 
-	delete(fw.processingFiles, filePath)
+	files := make([]string, 0, len(fw.processingFiles))
+	for filePath := range fw.processingFiles {
+		files = append(files, filePath)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (fw *FileWatcher) isMarkedForProcessing(filePath string) bool {
+	fw.processingMutex.Lock()
+	defer fw.processingMutex.Unlock()
+
+	_, exists := fw.processingFiles[filePath]
+	return exists
 }
 
 func (fw *FileWatcher) startWorkers() {
@@ -371,18 +675,94 @@ func (fw *FileWatcher) startWorkers() {
 	}
 }
 
+// startInitialScanWorkers boosts the pool to initialScanWorkers until the files of the initial scan have been
+// processed
+func (fw *FileWatcher) startInitialScanWorkers(scanDone <-chan struct{}) {
+	extra := fw.initialScanWorkers - fw.workerCount
+	if extra <= 0 {
+		return
+	}
+
+	slog.Info("Starting additional workers for the initial scan", "count", extra)
+	backlogDone := make(chan struct{})
+	go func() {
+		defer close(backlogDone)
+		fw.waitForInitialBacklog(scanDone)
+		slog.Info("Initial backlog processed - stopping the additional workers", "count", extra)
+	}()
+	fw.workers.Add(extra)
+	for i := 0; i < extra; i++ {
+		go fw.initialScanWorker(backlogDone)
+	}
+}
+
+// trackInitialBacklog remembers a file enqueued by the initial scan until it has been processed
+func (fw *FileWatcher) trackInitialBacklog(filePath string) {
+	if !fw.initialScan.Load() {
+		return
+	}
+	fw.processingMutex.Lock()
+	defer fw.processingMutex.Unlock()
+	if fw.initialBacklog == nil {
+		fw.initialBacklog = make(map[string]struct{})
+	}
+	fw.initialBacklog[filePath] = struct{}{}
+}
+
+func (fw *FileWatcher) initialBacklogCount() int {
+	fw.processingMutex.Lock()
+	defer fw.processingMutex.Unlock()
+	return len(fw.initialBacklog)
+}
+
+// waitForInitialBacklog waits until the initial scan is done and the files it enqueued have been processed,
+// or the watcher is stopped
+func (fw *FileWatcher) waitForInitialBacklog(scanDone <-chan struct{}) {
+	select {
+	case <-fw.stopChan:
+		return
+	case <-scanDone:
+	}
+
+	ticker := time.NewTicker(successMarkerPollInterval)
+	defer ticker.Stop()
+	for fw.initialBacklogCount() > 0 {
+		select {
+		case <-fw.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (fw *FileWatcher) processExistingFiles() {
 	slog.Info("Search for existing files in the input directory")
 
+	var ordered []orderedFile
+	bySize := &sizeQueue{largest: fw.sizeOrder == config.InputSizeOrderLargest}
+
 	err := filepath.Walk(fw.inputDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		if info.IsDir() {
+			if !fw.watchPatterns.shouldWatchDir(fw.relativePath(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// Only process files, not directories
-		if !info.IsDir() {
-			fw.processFile(path)
+		if fw.scanOrder != "" {
+			ordered = append(ordered, orderedFile{path: path, modTime: info.ModTime()})
+			return nil
 		}
+		if fw.sizeOrdered() {
+			fw.queueBySize(bySize, path, info.Size())
+			return nil
+		}
+		fw.processFile(path)
 
 		return nil
 	})
@@ -390,6 +770,13 @@ func (fw *FileWatcher) processExistingFiles() {
 	if err != nil {
 		slog.Error("Error processing existing files", "error", err)
 	}
+
+	if len(ordered) > 0 {
+		fw.processFilesInOrder(ordered)
+	}
+	if bySize.Len() > 0 {
+		fw.enqueueBySize(bySize)
+	}
 }
 
 // waitForCompleteFile waits until a file is complete (no more writing is taking place)
@@ -398,7 +785,7 @@ func (fw *FileWatcher) waitForCompleteFile(filePath string) error {
 
 	for retry := 0; retry < fw.maxRetries; retry++ {
 		// 1. File stability check
-		if !fw.isFileStable(filePath, fw.stabilityPeriod) {
+		if !fw.isStable(filePath) {
 			slog.Debug("File is not yet stable - please continue to wait", "file", filePath, "attempt", retry+1)
 			continue
 		}
@@ -417,6 +804,13 @@ func (fw *FileWatcher) waitForCompleteFile(filePath string) error {
 			continue
 		}
 
+		// 4. /proc check as fallback without lsof (Linux only)
+		if !fw.lsofAvailable && fw.procAvailable && fw.isFileOpenInProc(filePath) {
+			slog.Debug("File is still open according to /proc", "file", filePath, "attempt", retry+1)
+			time.Sleep(fw.checkInterval)
+			continue
+		}
+
 		slog.Info("File is complete and ready for processing", "file", filePath, "attempt", retry+1)
 		return nil
 	}
@@ -424,6 +818,40 @@ func (fw *FileWatcher) waitForCompleteFile(filePath string) error {
 	return fmt.Errorf("file is still incomplete after %d attempts: %s", fw.maxRetries, filePath)
 }
 
+// isStable runs the stability check of the configured mode
+func (fw *FileWatcher) isStable(filePath string) bool {
+	if fw.stabilityMode == config.FileStabilityModeChecksum {
+		return fw.isChecksumStable(filePath, fw.checkInterval)
+	}
+	return fw.isFileStable(filePath, fw.stabilityPeriod)
+}
+
+// isChecksumStable checks whether the content checksum does not change via checkDuration
+func (fw *FileWatcher) isChecksumStable(filePath string, checkDuration time.Duration) bool {
+	initialChecksum, err := fw.fileHandler.calculateFileChecksum(filePath)
+	if err != nil {
+		slog.Debug("Error calculating initial checksum", "file", filePath, "error", err)
+		return false
+	}
+
+	time.Sleep(checkDuration)
+
+	finalChecksum, err := fw.fileHandler.calculateFileChecksum(filePath)
+	if err != nil {
+		slog.Debug("Error calculating second checksum", "file", filePath, "error", err)
+		return false
+	}
+
+	if initialChecksum != finalChecksum {
+		slog.Debug("File content instability detected",
+			"file", filePath,
+			"checksum_old", initialChecksum,
+			"checksum_new", finalChecksum)
+		return false
+	}
+	return true
+}
+
 // isFileStable checks whether file size and ModTime do not change via checkDuration
 func (fw *FileWatcher) isFileStable(filePath string, checkDuration time.Duration) bool {
 	initialStat, err := os.Stat(filePath)
@@ -525,9 +953,20 @@ func (fw *FileWatcher) isHarmlessProcess(processName string) bool {
 		"antivir", "avguard", "avscan", // Antivirus (read-only scans)
 	}
 
+	return matchesProcessName(processName, harmlessProcesses)
+}
+
+// isForcedRelevantProcess checks the configured relevant processes, they override the harmless list
+func (fw *FileWatcher) isForcedRelevantProcess(processName string) bool {
+	return matchesProcessName(processName, fw.relevantProcesses)
+}
+
+// matchesProcessName reports whether processName contains one of names (case-insensitive).
+// lsof truncates command names, so substrings are matched.
+func matchesProcessName(processName string, names []string) bool {
 	lowerProcessName := strings.ToLower(processName)
-	for _, harmless := range harmlessProcesses {
-		if strings.Contains(lowerProcessName, strings.ToLower(harmless)) {
+	for _, name := range names {
+		if strings.Contains(lowerProcessName, strings.ToLower(name)) {
 			return true
 		}
 	}
@@ -586,6 +1025,11 @@ func (fw *FileWatcher) WorkerCount() int {
 	return fw.workerCount
 }
 
+// ActiveWorkers returns the number of running workers including initial scan workers
+func (fw *FileWatcher) ActiveWorkers() int {
+	return int(fw.activeWorkers.Load())
+}
+
 // isRelevantProcess checks whether a process in the lsof line is relevant
 func (fw *FileWatcher) isRelevantProcess(filePath, line string) bool {
 	if strings.TrimSpace(line) == "" {
@@ -602,15 +1046,21 @@ func (fw *FileWatcher) isRelevantProcess(filePath, line string) bool {
 		return false
 	}
 
-	processName := fields[0]
-	pid := fields[1]
+	return fw.isRelevantHolder(filePath, fields[0], fields[1])
+}
 
+// isRelevantHolder checks whether a process holding the file open keeps it from being complete
+func (fw *FileWatcher) isRelevantHolder(filePath, processName, pid string) bool {
 	// Ignore own process
 	if pid == strconv.Itoa(os.Getpid()) {
 		return false
 	}
 
-	// Ignore known harmless processes
+	// Configured relevant processes, then known harmless processes
+	if fw.isForcedRelevantProcess(processName) {
+		slog.Debug("Configured relevant process detected", "file", filePath, "process", processName, "pid", pid)
+		return true
+	}
 	if fw.isHarmlessProcess(processName) {
 		return false
 	}
@@ -225,3 +225,100 @@ func TestFileWatcher_DirectoryRecreation(t *testing.T) {
 		t.Error("Output file should be a file, not a directory")
 	}
 }
+
+func TestFileWatcher_DirectoryRecreatedWithinGracePeriod(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	subDir := filepath.Join(inputDir, "subdir")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, NewS3ClientManager())
+	fw, err := NewFileWatcher(inputDir, fileHandler, 30, 50*time.Millisecond, 100*time.Millisecond, 2, 100)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	fw.lsofAvailable = false
+	fw.watchRemoveGrace = 300 * time.Millisecond
+
+	go func() {
+		if err := fw.Start(); err != nil {
+			t.Logf("FileWatcher stopped with error: %v", err)
+		}
+	}()
+	defer fw.Stop()
+
+	time.Sleep(300 * time.Millisecond)
+
+	// Atomic replace: delete and immediately recreate the watched subdirectory
+	for i := 0; i < 3; i++ {
+		if err := os.RemoveAll(subDir); err != nil {
+			t.Fatalf("Failed to delete subdirectory: %v", err)
+		}
+		if err := os.Mkdir(subDir, 0755); err != nil {
+			t.Fatalf("Failed to recreate subdirectory: %v", err)
+		}
+	}
+
+	// Wait until every pending removal has expired
+	time.Sleep(2 * fw.watchRemoveGrace)
+
+	newFile := filepath.Join(subDir, "after.txt")
+	if err := os.WriteFile(newFile, []byte("after recreate"), 0644); err != nil {
+		t.Fatalf("Failed to create new file: %v", err)
+	}
+
+	outputFile := filepath.Join(outputDir, "subdir", "after.txt")
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(outputFile); err == nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("file in recreated subdirectory was not detected, watches: %v", fw.watcher.WatchList())
+}
+
+func TestFileWatcher_CancelWatchRemoval(t *testing.T) {
+	inputDir := t.TempDir()
+	fw, err := NewFileWatcher(inputDir, nil, 1, time.Millisecond, time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	defer fw.watcher.Close()
+	fw.watchRemoveGrace = 50 * time.Millisecond
+
+	if err := fw.watcher.Add(inputDir); err != nil {
+		t.Fatalf("Failed to add watch: %v", err)
+	}
+
+	fw.scheduleWatchRemoval(inputDir)
+	fw.cancelWatchRemoval(inputDir)
+	time.Sleep(3 * fw.watchRemoveGrace)
+
+	if len(fw.watcher.WatchList()) != 1 {
+		t.Fatalf("expected watch to be kept after cancellation, got %v", fw.watcher.WatchList())
+	}
+
+	// Without cancellation the watch of a path that is gone is dropped
+	gone := filepath.Join(inputDir, "gone")
+	if err := os.Mkdir(gone, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := fw.watcher.Add(gone); err != nil {
+		t.Fatalf("Failed to add watch: %v", err)
+	}
+	fw.scheduleWatchRemoval(gone)
+	if err := os.Remove(gone); err != nil {
+		t.Fatalf("Failed to remove directory: %v", err)
+	}
+	time.Sleep(3 * fw.watchRemoveGrace)
+
+	for _, path := range fw.watcher.WatchList() {
+		if path == gone {
+			t.Fatal("expected watch of removed directory to be dropped")
+		}
+	}
+}
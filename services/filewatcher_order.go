@@ -0,0 +1,40 @@
+package services
+
+import (
+	"file-shifter/config"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// orderedFile is an existing file collected by a scan with a configured order
+type orderedFile struct {
+	path    string
+	modTime time.Time
+}
+
+// processFilesInOrder sorts the files by the scan order and processes them one after another,
+// bypassing the concurrent worker pool so the delivery order is deterministic
+func (fw *FileWatcher) processFilesInOrder(files []orderedFile) {
+	sortOrderedFiles(files, fw.scanOrder)
+	slog.Info("Processing existing files serially", "order", fw.scanOrder, "count", len(files))
+
+	for _, file := range files {
+		if fw.stopping.Load() {
+			return
+		}
+		if fw.prepareFile(file.path) {
+			fw.processUnlessPaused(file.path)
+		}
+	}
+}
+
+// sortOrderedFiles sorts by path or by modification time (oldest first, ties by path)
+func sortOrderedFiles(files []orderedFile, order string) {
+	sort.SliceStable(files, func(i, j int) bool {
+		if order == config.InputOrderMtime && !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		return files[i].path < files[j].path
+	})
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileWatcher_ScanOrder(t *testing.T) {
+	// Name order and modification time order differ on purpose
+	files := []struct {
+		name string
+		age  time.Duration
+	}{
+		{"seq-001.txt", 1 * time.Hour},
+		{"seq-002.txt", 3 * time.Hour},
+		{"seq-003.txt", 2 * time.Hour},
+		{"sub/seq-000.txt", 30 * time.Minute},
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{config.InputOrderName, []string{"seq-001.txt", "seq-002.txt", "seq-003.txt", "sub/seq-000.txt"}},
+		{config.InputOrderMtime, []string{"seq-002.txt", "seq-003.txt", "seq-001.txt", "sub/seq-000.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			inputDir := t.TempDir()
+			now := time.Now()
+			for _, file := range files {
+				path := filepath.Join(inputDir, filepath.FromSlash(file.name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(file.name), 0644); err != nil {
+					t.Fatal(err)
+				}
+				modTime := now.Add(-file.age)
+				if err := os.Chtimes(path, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			manifestPath := filepath.Join(t.TempDir(), "manifest.jsonl")
+			manifest, err := NewDeliveryManifest(manifestPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer manifest.Close()
+
+			fileHandler := NewFileHandler([]config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}, nil)
+			fileHandler.Manifest = manifest
+			fw, err := NewFileWatcher(inputDir, fileHandler, 5, 5*time.Millisecond, 10*time.Millisecond, 4, 10)
+			if err != nil {
+				t.Fatalf("Failed to create FileWatcher: %v", err)
+			}
+			defer fw.watcher.Close()
+			fw.lsofAvailable = false
+			fw.scanOrder = tt.order
+
+			fw.processExistingFiles()
+
+			if got := manifestRelPaths(t, manifestPath); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("delivery order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func manifestRelPaths(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var relPaths []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid manifest line %q: %v", scanner.Text(), err)
+		}
+		relPaths = append(relPaths, entry.RelPath)
+	}
+	return relPaths
+}
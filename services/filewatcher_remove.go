@@ -0,0 +1,80 @@
+package services
+
+import (
+	"log/slog"
+	"time"
+)
+
+// defaultWatchRemoveGrace is the delay before the watch of a removed or renamed path is dropped.
+// Editors replacing a directory atomically delete and recreate it within a few milliseconds.
+const defaultWatchRemoveGrace = 500 * time.Millisecond
+
+// scheduleWatchRemoval drops the watch of path once the grace period has expired.
+// A Create event for the same path within the grace period cancels the removal.
+func (fw *FileWatcher) scheduleWatchRemoval(path string) {
+	if fw.watchRemoveGrace <= 0 || fw.stopping.Load() {
+		fw.removeWatch(path)
+		return
+	}
+
+	fw.watchRemovalsMutex.Lock()
+	defer fw.watchRemovalsMutex.Unlock()
+
+	if fw.watchRemovals == nil {
+		fw.watchRemovals = make(map[string]*time.Timer)
+	}
+	if timer, ok := fw.watchRemovals[path]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(fw.watchRemoveGrace, func() {
+		fw.watchRemovalsMutex.Lock()
+		if fw.watchRemovals[path] != timer {
+			// Cancelled or rescheduled in the meantime
+			fw.watchRemovalsMutex.Unlock()
+			return
+		}
+		delete(fw.watchRemovals, path)
+		fw.watchRemovalsMutex.Unlock()
+
+		// The Create event of a recreated directory may still be on its way, keep the new watch
+		if fw.isDirectory(path) {
+			slog.Debug("Path recreated within grace period - watcher kept", "path", path)
+			return
+		}
+		fw.removeWatch(path)
+	})
+	fw.watchRemovals[path] = timer
+}
+
+// cancelWatchRemoval keeps the watch of a path that was recreated within the grace period
+func (fw *FileWatcher) cancelWatchRemoval(path string) {
+	fw.watchRemovalsMutex.Lock()
+	defer fw.watchRemovalsMutex.Unlock()
+
+	if timer, ok := fw.watchRemovals[path]; ok {
+		timer.Stop()
+		delete(fw.watchRemovals, path)
+		slog.Debug("Path recreated within grace period - removal cancelled", "path", path)
+	}
+}
+
+// stopWatchRemovals cancels all pending removals, the watcher is closed anyway
+func (fw *FileWatcher) stopWatchRemovals() {
+	fw.watchRemovalsMutex.Lock()
+	defer fw.watchRemovalsMutex.Unlock()
+
+	for path, timer := range fw.watchRemovals {
+		timer.Stop()
+		delete(fw.watchRemovals, path)
+	}
+}
+
+// removeWatch removes the watcher of path if it exists (fails silently if not watched)
+func (fw *FileWatcher) removeWatch(path string) {
+	if err := fw.watcher.Remove(path); err != nil {
+		// Log as debug because this is expected for many sub-paths when a parent is deleted
+		slog.Debug("Info: Watcher already removed or not watched", "path", path, "error", err)
+	}
+}
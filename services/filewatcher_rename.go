@@ -0,0 +1,116 @@
+package services
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// renameCorrelationWindow is the maximum delay between a Rename event and the Create event of the new name
+const renameCorrelationWindow = 500 * time.Millisecond
+
+// correlateRename pairs Rename (old name) and Create (new name) events within the input directory.
+// It must be called from the event loop in event order. When a pair is detected, any in-flight
+// handling of the old name is abandoned so the rename results in a single new-file event.
+func (fw *FileWatcher) correlateRename(event fsnotify.Event) (string, bool) {
+	fw.renameMutex.Lock()
+	defer fw.renameMutex.Unlock()
+
+	if fw.pendingRenames == nil {
+		fw.pendingRenames = make(map[string]time.Time)
+	}
+
+	now := time.Now()
+	for path, renamedAt := range fw.pendingRenames {
+		if now.Sub(renamedAt) > renameCorrelationWindow {
+			delete(fw.pendingRenames, path)
+		}
+	}
+
+	if event.Has(fsnotify.Rename) {
+		fw.pendingRenames[event.Name] = now
+		return "", false
+	}
+
+	if !event.Has(fsnotify.Create) || len(fw.pendingRenames) == 0 {
+		return "", false
+	}
+
+	// Pick the most recent pending rename, inotify emits both events back to back
+	var oldPath string
+	var latest time.Time
+	for path, renamedAt := range fw.pendingRenames {
+		if path != event.Name && renamedAt.After(latest) {
+			oldPath, latest = path, renamedAt
+		}
+	}
+	if oldPath == "" {
+		return "", false
+	}
+	delete(fw.pendingRenames, oldPath)
+
+	if fw.isMarkedForProcessing(oldPath) {
+		fw.movedMutex.Lock()
+		if fw.movedAway == nil {
+			fw.movedAway = make(map[string]struct{})
+		}
+		fw.movedAway[oldPath] = struct{}{}
+		fw.movedMutex.Unlock()
+	}
+
+	slog.Info("File renamed within input directory - handled as new file", "from", oldPath, "to", event.Name)
+	return oldPath, true
+}
+
+// wasMovedAway reports whether an in-flight file has been renamed in the meantime
+func (fw *FileWatcher) wasMovedAway(filePath string) bool {
+	fw.movedMutex.Lock()
+	defer fw.movedMutex.Unlock()
+	_, moved := fw.movedAway[filePath]
+	return moved
+}
+
+func (fw *FileWatcher) clearMovedAway(filePath string) {
+	fw.movedMutex.Lock()
+	defer fw.movedMutex.Unlock()
+	delete(fw.movedAway, filePath)
+}
+
+// noteRenameComplete remembers a Create event of a file that is complete by construction: it was renamed
+// into place (tracked rename with rename-complete) or its name matches a rename-complete pattern.
+// Like correlateRename it must be called from the event loop before the event is handled.
+func (fw *FileWatcher) noteRenameComplete(event fsnotify.Event, renamed bool) {
+	if !event.Has(fsnotify.Create) {
+		return
+	}
+	if !(renamed && fw.renameComplete) && !matchesAnyName(fw.renameCompletePatterns, filepath.Base(event.Name)) {
+		return
+	}
+
+	fw.renameMutex.Lock()
+	defer fw.renameMutex.Unlock()
+	if fw.completeFiles == nil {
+		fw.completeFiles = make(map[string]struct{})
+	}
+	fw.completeFiles[event.Name] = struct{}{}
+}
+
+// takeRenameComplete reports and forgets whether filePath was noted as complete by noteRenameComplete
+func (fw *FileWatcher) takeRenameComplete(filePath string) bool {
+	fw.renameMutex.Lock()
+	defer fw.renameMutex.Unlock()
+	_, complete := fw.completeFiles[filePath]
+	delete(fw.completeFiles, filePath)
+	return complete
+}
+
+func matchesAnyName(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
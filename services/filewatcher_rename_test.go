@@ -0,0 +1,157 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestFileWatcher_CorrelateRenameEventPair(t *testing.T) {
+	inputDir := t.TempDir()
+	oldPath := filepath.Join(inputDir, "report.csv.tmp")
+	newPath := filepath.Join(inputDir, "report.csv")
+	if err := os.WriteFile(newPath, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	fw, err := NewFileWatcher(inputDir, nil, 1, time.Millisecond, time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("failed to create file watcher: %v", err)
+	}
+	defer fw.watcher.Close()
+	fw.lsofAvailable = false
+
+	// The old name is still in flight (queued) when the producer renames it into place
+	if !fw.tryMarkFileForProcessing(oldPath) {
+		t.Fatal("expected old path to be marked")
+	}
+	fw.fileQueue <- oldPath
+
+	if _, ok := fw.correlateRename(fsnotify.Event{Name: oldPath, Op: fsnotify.Rename}); ok {
+		t.Fatal("rename event alone must not be correlated")
+	}
+	from, ok := fw.correlateRename(fsnotify.Event{Name: newPath, Op: fsnotify.Create})
+	if !ok || from != oldPath {
+		t.Fatalf("expected create to be correlated with %s, got %q (ok=%v)", oldPath, from, ok)
+	}
+	if !fw.wasMovedAway(oldPath) {
+		t.Fatal("expected old path to be marked as moved away")
+	}
+
+	fw.handleEvent(fsnotify.Event{Name: oldPath, Op: fsnotify.Rename})
+	fw.handleEvent(fsnotify.Event{Name: newPath, Op: fsnotify.Create})
+
+	if fw.QueueSize() != 2 {
+		t.Fatalf("expected old and new path in queue, got %d entries", fw.QueueSize())
+	}
+
+	// The worker must skip the stale old name and only process the new one
+	outputDir := t.TempDir()
+	fw.fileHandler = NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: outputDir}}, nil)
+	close(fw.fileQueue)
+	fw.workers.Add(1)
+	fw.worker()
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatalf("failed to read output directory: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "report.csv" {
+		t.Fatalf("expected only report.csv to be delivered, got %v", entries)
+	}
+	if fw.wasMovedAway(oldPath) {
+		t.Fatal("expected moved-away marker to be cleared after skipping")
+	}
+}
+
+func TestFileWatcher_CorrelateRenameOutsideWindow(t *testing.T) {
+	fw := &FileWatcher{processingFiles: map[string]struct{}{}}
+	fw.pendingRenames = map[string]time.Time{"/in/old.txt": time.Now().Add(-2 * renameCorrelationWindow)}
+
+	if _, ok := fw.correlateRename(fsnotify.Event{Name: "/in/new.txt", Op: fsnotify.Create}); ok {
+		t.Fatal("expected stale rename not to be correlated")
+	}
+	if len(fw.pendingRenames) != 0 {
+		t.Fatal("expected stale rename to be pruned")
+	}
+}
+
+func TestFileWatcher_RenameCompleteSkipsStabilityCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		renameComplete bool
+		patterns       []string
+		events         func(oldPath, newPath string) []fsnotify.Event
+	}{
+		{
+			name:           "tracked rename",
+			renameComplete: true,
+			events: func(oldPath, newPath string) []fsnotify.Event {
+				return []fsnotify.Event{{Name: oldPath, Op: fsnotify.Rename}, {Name: newPath, Op: fsnotify.Create}}
+			},
+		},
+		{
+			name:     "rename-complete pattern",
+			patterns: []string{"*.csv"},
+			events: func(_, newPath string) []fsnotify.Event {
+				return []fsnotify.Event{{Name: newPath, Op: fsnotify.Create}}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputDir := t.TempDir()
+			oldPath := filepath.Join(inputDir, "report.csv.tmp")
+			newPath := filepath.Join(inputDir, "report.csv")
+			if err := os.WriteFile(newPath, []byte("data"), 0644); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+
+			// The stability check alone would take at least 10 seconds
+			fw, err := NewFileWatcher(inputDir, nil, 1, time.Millisecond, 10*time.Second, 1, 10)
+			if err != nil {
+				t.Fatalf("failed to create file watcher: %v", err)
+			}
+			defer fw.watcher.Close()
+			fw.lsofAvailable = false
+			fw.renameComplete = tt.renameComplete
+			fw.renameCompletePatterns = tt.patterns
+
+			start := time.Now()
+			for _, event := range tt.events(oldPath, newPath) {
+				_, renamed := fw.correlateRename(event)
+				fw.noteRenameComplete(event, renamed)
+				fw.handleEvent(event)
+			}
+
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("renamed file should be queued immediately, took %v", elapsed)
+			}
+			if fw.QueueSize() != 1 {
+				t.Fatalf("expected the renamed file in the queue, got %d entries", fw.QueueSize())
+			}
+			if queued := <-fw.fileQueue; queued != newPath {
+				t.Errorf("queued %s, want %s", queued, newPath)
+			}
+		})
+	}
+}
+
+func TestFileWatcher_NoteRenameCompleteRequiresOption(t *testing.T) {
+	fw := &FileWatcher{renameCompletePatterns: []string{"*.csv"}}
+	fw.noteRenameComplete(fsnotify.Event{Name: "/in/renamed.txt", Op: fsnotify.Create}, true)
+	fw.noteRenameComplete(fsnotify.Event{Name: "/in/data.csv", Op: fsnotify.Write}, false)
+
+	if fw.takeRenameComplete("/in/renamed.txt") {
+		t.Error("tracked renames must only skip the check with rename-complete enabled")
+	}
+	if fw.takeRenameComplete("/in/data.csv") {
+		t.Error("only create events are complete by pattern")
+	}
+}
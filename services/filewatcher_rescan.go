@@ -0,0 +1,33 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+)
+
+var (
+	// ErrScanInProgress is returned by Rescan while the initial scan or another rescan is running
+	ErrScanInProgress = errors.New("scan of the input directory already in progress")
+	// ErrWatcherStopped is returned by Rescan once the watcher is shutting down
+	ErrWatcherStopped = errors.New("file watcher is stopped")
+)
+
+// Rescan walks the input directory again in the background, e.g. for files fsnotify has missed.
+// Files that are already queued or processing are skipped by the usual deduplication.
+func (fw *FileWatcher) Rescan() error {
+	if !fw.scanning.CompareAndSwap(false, true) {
+		return ErrScanInProgress
+	}
+	if !fw.addProducer() {
+		fw.scanning.Store(false)
+		return ErrWatcherStopped
+	}
+
+	slog.Info("Rescan of the input directory requested", "directory", fw.inputDir)
+	go func() {
+		defer fw.producersWG.Done()
+		defer fw.scanning.Store(false)
+		fw.processExistingFiles()
+	}()
+	return nil
+}
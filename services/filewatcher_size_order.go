@@ -0,0 +1,66 @@
+package services
+
+import (
+	"container/heap"
+	"file-shifter/config"
+	"log/slog"
+	"os"
+)
+
+// sizedFile is an existing file collected by a scan with a configured size order
+type sizedFile struct {
+	path string
+	size int64
+}
+
+// sizeQueue is a priority queue (container/heap) of scanned files, the smallest or largest file comes first
+type sizeQueue struct {
+	files   []sizedFile
+	largest bool
+}
+
+func (q *sizeQueue) Len() int { return len(q.files) }
+
+func (q *sizeQueue) Less(i, j int) bool {
+	if q.files[i].size != q.files[j].size {
+		return (q.files[i].size < q.files[j].size) != q.largest
+	}
+	return q.files[i].path < q.files[j].path
+}
+
+func (q *sizeQueue) Swap(i, j int) { q.files[i], q.files[j] = q.files[j], q.files[i] }
+
+func (q *sizeQueue) Push(x any) { q.files = append(q.files, x.(sizedFile)) }
+
+func (q *sizeQueue) Pop() any {
+	last := q.files[len(q.files)-1]
+	q.files = q.files[:len(q.files)-1]
+	return last
+}
+
+// sizeOrdered reports whether the scan hands files to the worker pool by size
+func (fw *FileWatcher) sizeOrdered() bool {
+	return fw.sizeOrder == config.InputSizeOrderSmallest || fw.sizeOrder == config.InputSizeOrderLargest
+}
+
+// queueBySize prepares a scanned file and holds it back in the priority queue instead of the file queue
+func (fw *FileWatcher) queueBySize(queue *sizeQueue, filePath string, size int64) {
+	if !fw.prepareFile(filePath) {
+		return
+	}
+	// The file may have grown until it was complete
+	if info, err := os.Stat(filePath); err == nil {
+		size = info.Size()
+	}
+	heap.Push(queue, sizedFile{path: filePath, size: size})
+}
+
+// enqueueBySize hands the scanned files to the worker pool in size order once the scan has seen all of them
+func (fw *FileWatcher) enqueueBySize(queue *sizeQueue) {
+	slog.Info("Queueing existing files by size", "order", fw.sizeOrder, "count", queue.Len())
+
+	for queue.Len() > 0 {
+		file := heap.Pop(queue).(sizedFile)
+		fw.enqueueFileWithMonitoring(file.path)
+	}
+}
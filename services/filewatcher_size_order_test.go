@@ -0,0 +1,67 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileWatcher_SizeOrder(t *testing.T) {
+	// Name order and size order differ on purpose
+	files := map[string]int{
+		"a.txt":     300,
+		"b.txt":     10,
+		"c.txt":     200,
+		"sub/d.txt": 10,
+	}
+
+	tests := []struct {
+		sizeOrder string
+		want      []string
+	}{
+		{config.InputSizeOrderNone, []string{"a.txt", "b.txt", "c.txt", "sub/d.txt"}},
+		{config.InputSizeOrderSmallest, []string{"b.txt", "sub/d.txt", "c.txt", "a.txt"}},
+		{config.InputSizeOrderLargest, []string{"a.txt", "c.txt", "b.txt", "sub/d.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sizeOrder, func(t *testing.T) {
+			inputDir := t.TempDir()
+			for name, size := range files {
+				path := filepath.Join(inputDir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			fileHandler := NewFileHandler([]config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}, nil)
+			fw, err := NewFileWatcher(inputDir, fileHandler, 5, 5*time.Millisecond, 10*time.Millisecond, 1, len(files))
+			if err != nil {
+				t.Fatalf("Failed to create FileWatcher: %v", err)
+			}
+			defer fw.watcher.Close()
+			fw.lsofAvailable = false
+			fw.sizeOrder = tt.sizeOrder
+
+			// No workers are running, the queue shows the order in which they would dequeue the files
+			fw.processExistingFiles()
+			close(fw.fileQueue)
+
+			var got []string
+			for filePath := range fw.fileQueue {
+				got = append(got, fw.relativePath(filePath))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dequeue order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
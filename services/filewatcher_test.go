@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"file-shifter/config"
 	"fmt"
 	"os"
@@ -48,6 +49,12 @@ func TestNewFileWatcher(t *testing.T) { // NOSONAR - deckt viele Konfigurationsp
 		},
 	}
 
+	for _, queueSize := range []int{0, -1} {
+		if _, err := NewFileWatcher(tempDir, fileHandler, 3, 100*time.Millisecond, 200*time.Millisecond, 4, queueSize); err == nil {
+			t.Errorf("NewFileWatcher() with queue size %d should fail", queueSize)
+		}
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			watcher, err := NewFileWatcher(tt.inputDir, fileHandler, tt.maxRetries, tt.checkInterval, tt.stabilityPeriod, 4, 100)
@@ -538,6 +545,54 @@ func TestFileWatcher_IsFileStable(t *testing.T) {
 	}
 }
 
+func TestFileWatcher_IsStableChecksumMode(t *testing.T) {
+	tempDir := t.TempDir()
+	watcher, err := NewFileWatcher(tempDir, NewFileHandler(nil, nil), 3, 200*time.Millisecond, 200*time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("Fehler beim Erstellen des FileWatchers: %v", err)
+	}
+	defer watcher.watcher.Close()
+	watcher.stabilityMode = config.FileStabilityModeChecksum
+
+	stableFile := filepath.Join(tempDir, "stable.txt")
+	if err := os.WriteFile(stableFile, []byte("stable content"), 0644); err != nil {
+		t.Fatalf("Fehler beim Erstellen der Datei: %v", err)
+	}
+	if !watcher.isStable(stableFile) {
+		t.Error("Datei mit unverändertem Inhalt sollte stabil sein")
+	}
+
+	// Same size and mtime, only the content changes between both checksums
+	changingFile := filepath.Join(tempDir, "changing.txt")
+	if err := os.WriteFile(changingFile, []byte("content A"), 0644); err != nil {
+		t.Fatalf("Fehler beim Erstellen der Datei: %v", err)
+	}
+	info, err := os.Stat(changingFile)
+	if err != nil {
+		t.Fatalf("Fehler beim Lesen der Datei-Info: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if err := os.WriteFile(changingFile, []byte("content B"), 0644); err != nil {
+			done <- err
+			return
+		}
+		done <- os.Chtimes(changingFile, info.ModTime(), info.ModTime())
+	}()
+
+	if watcher.isStable(changingFile) {
+		t.Error("Datei mit geändertem Inhalt sollte nicht stabil sein")
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Fehler beim Ändern der Datei: %v", err)
+	}
+
+	if watcher.isStable(filepath.Join(tempDir, "nonexistent.txt")) {
+		t.Error("Nicht existierende Datei sollte nicht stabil sein")
+	}
+}
+
 func TestFileWatcher_CanOpenExclusively(t *testing.T) {
 	tempDir, cleanup := setupTempDir(t, "exclusive_open_test_*")
 	defer cleanup()
@@ -822,3 +877,209 @@ func TestFileWatcher_isRelevantProcess(t *testing.T) {
 		})
 	}
 }
+
+func TestFileWatcher_isRelevantProcessConfigured(t *testing.T) {
+	watcher := &FileWatcher{relevantProcesses: []string{"MDWorker", "backupd"}}
+	testFilePath := "/tmp/test-file.txt"
+
+	tests := []struct {
+		name     string
+		line     string
+		expected bool
+	}{
+		{
+			name:     "harmless process forced relevant",
+			line:     "mdworker   1234 user    3r   REG    8,1      100  12345 /tmp/test-file.txt",
+			expected: true,
+		},
+		{
+			name:     "site daemon forced relevant",
+			line:     "backupd    1234 user    3r   REG    8,1      100  12345 /tmp/test-file.txt",
+			expected: true,
+		},
+		{
+			name:     "other harmless process stays harmless",
+			line:     "Finder     1234 user    3r   REG    8,1      100  12345 /tmp/test-file.txt",
+			expected: false,
+		},
+		{
+			name:     "own process stays ignored",
+			line:     fmt.Sprintf("backupd    %d user    3r   REG    8,1      100  12345 /tmp/test-file.txt", os.Getpid()),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := watcher.isRelevantProcess(testFilePath, tt.line); result != tt.expected {
+				t.Errorf("isRelevantProcess(%q) = %v, expected %v", tt.line, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFileWatcher_InFlightFiles(t *testing.T) {
+	fw := &FileWatcher{processingFiles: map[string]struct{}{}}
+	fw.tryMarkFileForProcessing("/in/b.txt")
+	fw.tryMarkFileForProcessing("/in/a.txt")
+
+	files := fw.InFlightFiles()
+	if len(files) != 2 || files[0] != "/in/a.txt" || files[1] != "/in/b.txt" {
+		t.Fatalf("InFlightFiles() = %v, want sorted in-flight files", files)
+	}
+
+	fw.unmarkFileForProcessing("/in/a.txt")
+	if files := fw.InFlightFiles(); len(files) != 1 {
+		t.Fatalf("InFlightFiles() = %v, want one remaining file", files)
+	}
+}
+
+func TestFileWatcher_InitialScanWorkers(t *testing.T) {
+	const files = 12
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	for i := 0; i < files; i++ {
+		if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("file%02d.txt", i)), []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Slow deliveries leave a backlog in the queue when the scan has finished enqueuing
+	original := openSourceFile
+	openSourceFile = func(name string) (*os.File, error) {
+		time.Sleep(100 * time.Millisecond)
+		return os.Open(name)
+	}
+	defer func() { openSourceFile = original }()
+
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, NewS3ClientManager())
+	fw, err := NewFileWatcher(inputDir, fileHandler, 5, 5*time.Millisecond, 10*time.Millisecond, 2, files)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	fw.lsofAvailable = false
+	fw.initialScanWorkers = 6
+
+	go func() {
+		if err := fw.Start(); err != nil {
+			t.Logf("FileWatcher stopped with error: %v", err)
+		}
+	}()
+	defer fw.Stop()
+
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitFor("the boosted pool", func() bool { return fw.ActiveWorkers() == 6 })
+	waitFor("the end of the initial scan", func() bool { return !fw.initialScan.Load() })
+
+	// The scan is done, but its backlog is still processed by the boosted pool
+	if backlog := fw.initialBacklogCount(); backlog == 0 {
+		t.Fatal("expected a backlog of the initial scan to remain, the deliveries are too fast for this test")
+	}
+	if got := fw.ActiveWorkers(); got != 6 {
+		t.Errorf("ActiveWorkers() = %d right after the scan, want 6 until the backlog is processed", got)
+	}
+
+	waitFor("the pool to shrink", func() bool { return fw.ActiveWorkers() == 2 })
+	if backlog := fw.initialBacklogCount(); backlog != 0 {
+		t.Errorf("additional workers stopped with %d files of the initial scan left", backlog)
+	}
+
+	waitFor("all existing files to be delivered", func() bool {
+		entries, _ := os.ReadDir(outputDir)
+		return len(entries) == files
+	})
+}
+
+func TestFileWatcher_MaxFilesPerSec(t *testing.T) {
+	const files = 10
+
+	run := func(t *testing.T, limiter *rateLimiter) time.Duration {
+		t.Helper()
+		inputDir := t.TempDir()
+		outputDir := t.TempDir()
+
+		fileHandler := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, NewS3ClientManager())
+		fw, err := NewFileWatcher(inputDir, fileHandler, 5, 10*time.Millisecond, 20*time.Millisecond, 4, files)
+		if err != nil {
+			t.Fatalf("Failed to create FileWatcher: %v", err)
+		}
+		fw.filesLimiter = limiter
+		defer fw.Stop()
+
+		for i := 0; i < files; i++ {
+			filePath := filepath.Join(inputDir, fmt.Sprintf("file%d.txt", i))
+			if err := os.WriteFile(filePath, []byte("content"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			fw.tryMarkFileForProcessing(filePath)
+			fw.fileQueue <- filePath
+		}
+
+		start := time.Now()
+		fw.startWorkers()
+		deadline := start.Add(5 * time.Second)
+		for {
+			entries, _ := os.ReadDir(outputDir)
+			if len(entries) == files {
+				return time.Since(start)
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d delivered files, got %d", files, len(entries))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// 10 files at 20 files/s: the last file starts after 450ms
+	if elapsed := run(t, newRateLimiter(20)); elapsed < 400*time.Millisecond {
+		t.Errorf("rate limited processing took %v, expected at least 400ms", elapsed)
+	}
+	if elapsed := run(t, nil); elapsed > 300*time.Millisecond {
+		t.Errorf("unlimited processing took %v, expected no rate limit", elapsed)
+	}
+}
+
+func TestFileWatcher_RescanDuringStop(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		inputDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(inputDir, "file.txt"), []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		fileHandler := NewFileHandler([]config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}, nil)
+		fw, err := NewFileWatcher(inputDir, fileHandler, 5, 5*time.Millisecond, 10*time.Millisecond, 1, 10)
+		if err != nil {
+			t.Fatalf("Failed to create FileWatcher: %v", err)
+		}
+		fw.lsofAvailable = false
+		fw.startWorkers()
+
+		// Rescans requested concurrently with Stop must neither panic nor enqueue into the closed queue
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				if err := fw.Rescan(); errors.Is(err, ErrWatcherStopped) {
+					return
+				}
+			}
+		}()
+		fw.Stop()
+		<-done
+
+		if err := fw.Rescan(); !errors.Is(err, ErrWatcherStopped) {
+			t.Fatalf("Rescan() after Stop = %v, want ErrWatcherStopped", err)
+		}
+	}
+}
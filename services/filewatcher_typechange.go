@@ -0,0 +1,45 @@
+package services
+
+import (
+	"log/slog"
+	"os"
+
+	"file-shifter/config"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// handleFileBecameDirectory handles a file event whose path is a directory by the time it is checked,
+// e.g. after a rename race. The path is never queued as a file.
+func (fw *FileWatcher) handleFileBecameDirectory(path string) {
+	if fw.typeChange == config.InputTypeChangeSkip {
+		slog.Info("Path changed type from file to directory - skipped", "path", path)
+		return
+	}
+
+	slog.Info("Path changed type from file to directory - watching directory", "path", path)
+	fw.handleDirectoryCreation(fsnotify.Event{Name: path, Op: fsnotify.Create})
+}
+
+// dropReplacedDirectoryWatch removes the watch of a directory that has been replaced by a file
+// without waiting for the grace period (see filewatcher_remove.go)
+func (fw *FileWatcher) dropReplacedDirectoryWatch(path string) {
+	fw.watchRemovalsMutex.Lock()
+	timer, pending := fw.watchRemovals[path]
+	if pending {
+		timer.Stop()
+		delete(fw.watchRemovals, path)
+	}
+	fw.watchRemovalsMutex.Unlock()
+
+	if pending {
+		slog.Info("Path changed type from directory to file", "path", path)
+		fw.removeWatch(path)
+	}
+}
+
+// isDirectory reports whether path currently is a directory (symlinks are not followed)
+func (fw *FileWatcher) isDirectory(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func newTypeChangeTestWatcher(t *testing.T, inputDir string) *FileWatcher {
+	t.Helper()
+	fw, err := NewFileWatcher(inputDir, nil, 1, time.Millisecond, time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("failed to create file watcher: %v", err)
+	}
+	t.Cleanup(func() { fw.watcher.Close() })
+	fw.lsofAvailable = false
+	return fw
+}
+
+func TestFileWatcher_FileBecameDirectory(t *testing.T) {
+	tests := []struct {
+		typeChange  string
+		wantWatched bool
+		wantQueued  int
+	}{
+		{typeChange: "", wantWatched: true, wantQueued: 1},
+		{typeChange: config.InputTypeChangeWatch, wantWatched: true, wantQueued: 1},
+		{typeChange: config.InputTypeChangeSkip, wantWatched: false, wantQueued: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run("type-change="+tt.typeChange, func(t *testing.T) {
+			inputDir := t.TempDir()
+			path := filepath.Join(inputDir, "report")
+			fw := newTypeChangeTestWatcher(t, inputDir)
+			fw.typeChange = tt.typeChange
+
+			// The Create event was for a file, by the time it is handled the path is a directory
+			if err := os.Mkdir(path, 0755); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(path, "part.csv"), []byte("data"), 0644); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+			fw.processFile(path)
+
+			if fw.isMarkedForProcessing(path) {
+				t.Fatal("directory must not be marked for processing as a file")
+			}
+			if watched := slices.Contains(fw.watcher.WatchList(), path); watched != tt.wantWatched {
+				t.Errorf("directory watched = %v, want %v", watched, tt.wantWatched)
+			}
+			if fw.QueueSize() != tt.wantQueued {
+				t.Fatalf("queue size = %d, want %d", fw.QueueSize(), tt.wantQueued)
+			}
+			if tt.wantQueued > 0 {
+				if queued := <-fw.fileQueue; queued != filepath.Join(path, "part.csv") {
+					t.Errorf("queued %q, want the file inside the directory", queued)
+				}
+			}
+		})
+	}
+}
+
+func TestFileWatcher_QueuedFileBecameDirectory(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	path := filepath.Join(inputDir, "report")
+	fw := newTypeChangeTestWatcher(t, inputDir)
+	fw.fileHandler = NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: outputDir}}, nil)
+
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if !fw.prepareFile(path) {
+		t.Fatal("expected file to be prepared")
+	}
+	fw.enqueueFileWithMonitoring(path)
+
+	// Replaced by a directory while waiting in the queue
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	close(fw.fileQueue)
+	fw.workers.Add(1)
+	fw.worker()
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatalf("failed to read output directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected nothing to be delivered, got %v", entries)
+	}
+	if !fw.isDirectory(path) {
+		t.Fatal("expected directory to be left untouched")
+	}
+	if fw.isMarkedForProcessing(path) {
+		t.Fatal("expected path to be unmarked after skipping")
+	}
+}
+
+func TestFileWatcher_DirectoryBecameFile(t *testing.T) {
+	inputDir := t.TempDir()
+	path := filepath.Join(inputDir, "report")
+	fw := newTypeChangeTestWatcher(t, inputDir)
+	fw.watchRemoveGrace = time.Hour
+
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := fw.watcher.Add(path); err != nil {
+		t.Fatalf("failed to add watch: %v", err)
+	}
+
+	fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Remove})
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+
+	if slices.Contains(fw.watcher.WatchList(), path) {
+		t.Error("expected watch of the replaced directory to be dropped immediately")
+	}
+	if len(fw.watchRemovals) != 0 {
+		t.Error("expected pending watch removal to be cleared")
+	}
+	if fw.QueueSize() != 1 {
+		t.Fatalf("expected the new file to be queued, got %d entries", fw.QueueSize())
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"file-shifter/config"
+)
+
+// fakeFTPServer is a minimal passive-mode FTP server recording the commands it receives
+type fakeFTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	commands []string
+	files    map[string][]byte
+}
+
+func newFakeFTPServer(t *testing.T) *fakeFTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeFTPServer{listener: listener, files: make(map[string][]byte)}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeFTPServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+// commandsWithVerb returns the arguments of all received commands with the given verb
+func (s *fakeFTPServer) commandsWithVerb(verb string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var args []string
+	for _, command := range s.commands {
+		if v, arg, _ := strings.Cut(command, " "); v == verb {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+func (s *fakeFTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		_, _ = fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var dataListener net.Listener
+	defer func() {
+		if dataListener != nil {
+			_ = dataListener.Close()
+		}
+	}()
+
+	reply("220 fake FTP ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, command)
+		s.mu.Unlock()
+
+		verb, arg, _ := strings.Cut(command, " ")
+		switch verb {
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			reply("230 logged in")
+		case "TYPE":
+			reply("200 type set")
+		case "EPSV":
+			if dataListener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply("425 cannot open data connection")
+				continue
+			}
+			reply("229 Entering Extended Passive Mode (|||%d|)", dataListener.Addr().(*net.TCPAddr).Port)
+		case "MKD":
+			reply("257 %q created", arg)
+		case "STOR":
+			dataConn, err := dataListener.Accept()
+			if err != nil {
+				reply("425 no data connection")
+				continue
+			}
+			reply("150 ok to send data")
+			content, _ := io.ReadAll(dataConn)
+			_ = dataConn.Close()
+			s.mu.Lock()
+			s.files[arg] = content
+			s.mu.Unlock()
+			reply("226 transfer complete")
+		case "DELE":
+			s.mu.Lock()
+			_, exists := s.files[arg]
+			delete(s.files, arg)
+			s.mu.Unlock()
+			if !exists {
+				reply("550 file not found")
+				continue
+			}
+			reply("250 deleted")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+func TestFileHandler_FTPRemotePathSeparator(t *testing.T) {
+	tests := []struct {
+		name      string
+		separator string
+		wantDirs  []string
+		wantFile  string
+	}{
+		{name: "default forward slash", separator: "", wantDirs: []string{"upload", "upload/2025", "upload/2025/reports"}, wantFile: "upload/2025/reports/report.csv"},
+		{name: "explicit forward slash", separator: "/", wantDirs: []string{"upload", "upload/2025", "upload/2025/reports"}, wantFile: "upload/2025/reports/report.csv"},
+		{name: "windows backslash", separator: `\`, wantDirs: []string{"upload", `upload\2025`, `upload\2025\reports`}, wantFile: `upload\2025\reports\report.csv`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeFTPServer(t)
+			srcFile := filepath.Join(t.TempDir(), "report.csv")
+			if err := os.WriteFile(srcFile, []byte("a;b"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			target := config.OutputTarget{
+				Type:                "ftp",
+				Path:                "ftp://" + server.addr() + "/upload",
+				Username:            "user",
+				Password:            "pass",
+				RemotePathSeparator: tt.separator,
+			}
+			fh := NewFileHandler([]config.OutputTarget{target}, nil)
+			relPath := filepath.Join("2025", "reports", "report.csv")
+
+			if err := fh.copyToFTP(srcFile, relPath, target); err != nil {
+				t.Fatalf("copyToFTP() error = %v", err)
+			}
+			if got := server.commandsWithVerb("MKD"); strings.Join(got, "|") != strings.Join(tt.wantDirs, "|") {
+				t.Errorf("MKD = %q, want %q", got, tt.wantDirs)
+			}
+			if got := server.commandsWithVerb("STOR"); len(got) != 1 || got[0] != tt.wantFile {
+				t.Errorf("STOR = %q, want %q", got, tt.wantFile)
+			}
+
+			if err := fh.deleteFromFTP(relPath, target); err != nil {
+				t.Fatalf("deleteFromFTP() error = %v", err)
+			}
+			if got := server.commandsWithVerb("DELE"); len(got) != 1 || got[0] != tt.wantFile {
+				t.Errorf("DELE = %q, want %q", got, tt.wantFile)
+			}
+		})
+	}
+}
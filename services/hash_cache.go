@@ -0,0 +1,96 @@
+package services
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HashCache remembers delivered files that stayed in the input directory (e.g. in a dry run) by size,
+// modification time and checksum. Scans and events skip such files while size and modification time are
+// unchanged, so frequent rescans neither re-hash nor re-transfer them. A changed file drops its entry.
+// All methods are no-ops on a nil cache.
+type HashCache struct {
+	mu      sync.Mutex
+	entries map[string]hashCacheEntry
+}
+
+type hashCacheEntry struct {
+	size     int64
+	modTime  time.Time
+	checksum string
+}
+
+func NewHashCache() *HashCache {
+	return &HashCache{entries: make(map[string]hashCacheEntry)}
+}
+
+// Record remembers a delivered file with the size and modification time it was delivered with
+func (c *HashCache) Record(filePath string, info os.FileInfo, checksum string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[filePath] = hashCacheEntry{size: info.Size(), modTime: info.ModTime(), checksum: checksum}
+}
+
+// Unchanged returns the checksum a file has been delivered with if its size and modification time are
+// the same. The entry of a changed file is dropped, it is delivered again.
+func (c *HashCache) Unchanged(filePath string, info os.FileInfo) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[filePath]
+	if !ok {
+		return "", false
+	}
+	if entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.checksum, true
+	}
+	delete(c.entries, filePath)
+	return "", false
+}
+
+// Forget drops the entries of a removed file, or of all files below a removed directory
+func (c *HashCache) Forget(path string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix := path + string(filepath.Separator)
+	for filePath := range c.entries {
+		if filePath == path || strings.HasPrefix(filePath, prefix) {
+			delete(c.entries, filePath)
+		}
+	}
+}
+
+// recordKeptSource caches a delivered file that is still in the input directory. Files waiting for
+// scheduled or batched targets or for their delayed removal are tracked there instead.
+func (fh *FileHandler) recordKeptSource(filePath string, info os.FileInfo, checksum string) {
+	if fh.HashCache == nil || fh.hasDeferredDelivery(filePath) || fh.isBatched(filePath) || fh.isPendingDeletion(filePath) {
+		return
+	}
+	if _, err := os.Lstat(filePath); err == nil {
+		fh.HashCache.Record(filePath, info, checksum)
+	}
+}
+
+// unchangedSinceDelivery reports whether a file has been delivered before and is unchanged since
+func (fw *FileWatcher) unchangedSinceDelivery(filePath string, info os.FileInfo) bool {
+	if fw.fileHandler == nil {
+		return false
+	}
+	checksum, ok := fw.fileHandler.HashCache.Unchanged(filePath, info)
+	if ok {
+		slog.Debug("File unchanged since its delivery - skipped", "file", filePath, "checksum", checksum)
+	}
+	return ok
+}
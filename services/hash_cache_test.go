@@ -0,0 +1,106 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileWatcher_HashCacheSkipsUnchangedFilesOnRescan(t *testing.T) {
+	// Delivered files stay in the input directory
+	originalRemove := removeFile
+	removeFile = func(string) error { return nil }
+	defer func() { removeFile = originalRemove }()
+
+	inputDir := t.TempDir()
+	unchanged := filepath.Join(inputDir, "a.txt")
+	changed := filepath.Join(inputDir, "b.txt")
+	past := time.Now().Add(-time.Hour)
+	for _, path := range []string{unchanged, changed} {
+		if err := os.WriteFile(path, []byte("v1"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifestPath := filepath.Join(t.TempDir(), "manifest.jsonl")
+	manifest, err := NewDeliveryManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manifest.Close()
+
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}, nil)
+	fileHandler.Manifest = manifest
+	fileHandler.HashCache = NewHashCache()
+	fw, err := NewFileWatcher(inputDir, fileHandler, 5, 5*time.Millisecond, 10*time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	defer fw.watcher.Close()
+	fw.lsofAvailable = false
+	fw.scanOrder = config.InputOrderName
+
+	fw.processExistingFiles()
+	if got, want := manifestRelPaths(t, manifestPath), []string{"a.txt", "b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first scan delivered %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(changed, []byte("v2, longer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fw.processExistingFiles()
+
+	if got, want := manifestRelPaths(t, manifestPath), []string{"a.txt", "b.txt", "b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deliveries after the rescan = %v, want %v (only the changed file again)", got, want)
+	}
+	if _, ok := fileHandler.HashCache.Unchanged(changed, mustStat(t, changed)); !ok {
+		t.Error("the changed file should be cached again after its new delivery")
+	}
+}
+
+func TestHashCache_ForgetDirectory(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "sub", "a.txt")
+	other := filepath.Join(dir, "subway.txt")
+	for _, path := range []string{file, other} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache := NewHashCache()
+	cache.Record(file, mustStat(t, file), "sum-a")
+	cache.Record(other, mustStat(t, other), "sum-other")
+	cache.Forget(filepath.Join(dir, "sub"))
+
+	if _, ok := cache.Unchanged(file, mustStat(t, file)); ok {
+		t.Error("files below a removed directory should be forgotten")
+	}
+	if checksum, ok := cache.Unchanged(other, mustStat(t, other)); !ok || checksum != "sum-other" {
+		t.Errorf("unrelated file = %q, %v, want it still cached", checksum, ok)
+	}
+
+	var disabled *HashCache
+	if _, ok := disabled.Unchanged(file, mustStat(t, file)); ok {
+		t.Error("a nil cache must not skip files")
+	}
+}
+
+func mustStat(t *testing.T, path string) os.FileInfo {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
@@ -1,11 +1,17 @@
 package services
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"file-shifter/config"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
@@ -33,9 +39,31 @@ type HealthCheck struct {
 	Status     HealthStatus               `json:"status"`
 	Timestamp  time.Time                  `json:"timestamp"`
 	Components map[string]ComponentHealth `json:"components"`
+	// SessionHash is the combined hash of all files delivered since the start, if enabled
+	SessionHash *SessionHashStatus `json:"session_hash,omitempty"`
+}
+
+// RuntimeStats contains Go runtime metrics for leak detection
+type RuntimeStats struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Goroutines   int       `json:"goroutines"`
+	HeapAlloc    uint64    `json:"heap_alloc_bytes"`
+	HeapInuse    uint64    `json:"heap_inuse_bytes"`
+	HeapObjects  uint64    `json:"heap_objects"`
+	Sys          uint64    `json:"sys_bytes"`
+	TotalAlloc   uint64    `json:"total_alloc_bytes"`
+	NumGC        uint32    `json:"num_gc"`
+	LastGC       time.Time `json:"last_gc,omitzero"`
+	PauseTotalNs uint64    `json:"gc_pause_total_ns"`
 }
 
 type HealthMonitor struct {
+	Config config.HealthConfig
+	// LogLevel enables POST /control/log-level if set
+	LogLevel *slog.LevelVar
+	// ConfigSources enables GET /config, reporting where each setting came from
+	ConfigSources config.Provenance
+
 	worker      *Worker
 	port        string
 	server      *http.Server
@@ -44,6 +72,10 @@ type HealthMonitor struct {
 	isHealthy   bool
 	stopChan    chan bool
 	checkTicker *time.Ticker
+
+	diskComponents map[string]ComponentHealth
+	// bindErr is set if the health port was not available and OnBindError keeps the service running
+	bindErr error
 }
 
 func NewHealthMonitor(worker *Worker, port string) *HealthMonitor {
@@ -55,29 +87,127 @@ func NewHealthMonitor(worker *Worker, port string) *HealthMonitor {
 	}
 }
 
-func (hm *HealthMonitor) Start() {
-	// HTTP Server for Health-Check
-	mux := http.NewServeMux()
-	mux.HandleFunc("/health", hm.healthHandler)
-	mux.HandleFunc("/health/live", hm.livenessHandler)
-	mux.HandleFunc("/health/ready", hm.readinessHandler)
-
-	hm.server = &http.Server{
-		Addr:    ":" + hm.port,
-		Handler: mux,
+// Start binds the health port and serves the endpoints. If the port is not available, the error is
+// returned, or with OnBindError "unhealthy" logged and reported as unhealthy component instead.
+func (hm *HealthMonitor) Start() error {
+	// HTTP Server for Health-Check, the port is bound up front so a taken port is not only logged
+	server := hm.newServer()
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		err = fmt.Errorf("health port %s is not available: %w", hm.port, err)
+		if hm.Config.OnBindError != config.HealthOnBindErrorUnhealthy {
+			return err
+		}
+		slog.Error("Health-Check server not started, reporting unhealthy", "error", err)
+		hm.mu.Lock()
+		hm.bindErr = err
+		hm.mu.Unlock()
 	}
 
 	// Periodic Health-Checks
+	hm.performHealthCheck()
 	hm.checkTicker = time.NewTicker(10 * time.Second)
 	go hm.periodicHealthCheck()
 
+	if listener == nil {
+		return nil
+	}
+
 	// Start HTTP Server
+	hm.server = server
 	go func() {
 		slog.Info("Health-Check server started", "port", hm.port)
-		if err := hm.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("Health-Check server error", "error", err)
 		}
 	}()
+	return nil
+}
+
+// newServer creates the health HTTP server, the timeouts protect it against slow or hung clients
+func (hm *HealthMonitor) newServer() *http.Server {
+	readHeaderTimeout, readTimeout, writeTimeout := hm.Config.ServerTimeouts()
+	return &http.Server{
+		Addr:              ":" + hm.port,
+		Handler:           hm.newMux(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+	}
+}
+
+// newMux registers all health endpoints. Liveness always stays open for orchestrator probes.
+func (hm *HealthMonitor) newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", hm.requireAuth(hm.healthHandler))
+	mux.HandleFunc("/health/live", hm.livenessHandler)
+	mux.HandleFunc("/health/ready", hm.requireAuth(hm.readinessHandler))
+	mux.HandleFunc("/debug/stats", hm.requireAuth(hm.statsHandler))
+
+	if hm.LogLevel != nil {
+		mux.HandleFunc("/control/log-level", hm.requireControlAuth(hm.logLevelHandler))
+	}
+	if hm.ConfigSources != nil {
+		mux.HandleFunc("/config", hm.requireAuth(hm.configHandler))
+	}
+	if hm.worker != nil && hm.worker.FileWatcher != nil {
+		mux.HandleFunc("/control/rescan", hm.requireControlAuth(hm.rescanHandler))
+	}
+
+	if hm.Config.EnablePprof {
+		slog.Warn("pprof endpoints enabled - do not expose the health port publicly")
+		mux.HandleFunc("/debug/pprof/", hm.requireAuth(pprof.Index))
+		mux.HandleFunc("/debug/pprof/cmdline", hm.requireAuth(pprof.Cmdline))
+		mux.HandleFunc("/debug/pprof/profile", hm.requireAuth(pprof.Profile))
+		mux.HandleFunc("/debug/pprof/symbol", hm.requireAuth(pprof.Symbol))
+		mux.HandleFunc("/debug/pprof/trace", hm.requireAuth(pprof.Trace))
+	}
+	return mux
+}
+
+// requireAuth rejects requests without a matching bearer token if an auth token is configured
+func (hm *HealthMonitor) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hm.Config.AuthToken == "" {
+			next(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(hm.Config.AuthToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// requireControlAuth protects endpoints that change the service; they are disabled without an auth token
+func (hm *HealthMonitor) requireControlAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hm.Config.AuthToken == "" {
+			writeJSONError(w, http.StatusForbidden, "control endpoints require health.auth-token")
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		hm.requireAuth(next)(w, r)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	}); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
 }
 
 func (hm *HealthMonitor) Stop() {
@@ -105,11 +235,16 @@ func (hm *HealthMonitor) periodicHealthCheck() {
 }
 
 func (hm *HealthMonitor) performHealthCheck() {
+	// A hung statfs (e.g. on a stale NFS mount) must not block the health endpoints, so the volumes are probed
+	// before taking the lock
+	diskComponents := hm.checkDiskSpace()
+
 	hm.mu.Lock()
 	defer hm.mu.Unlock()
 
 	hm.lastCheck = time.Now()
-	hm.isHealthy = true
+	hm.isHealthy = hm.bindErr == nil
+	hm.diskComponents = diskComponents
 
 	// Check FileWatcher status
 	if hm.worker.FileWatcher == nil {
@@ -118,12 +253,13 @@ func (hm *HealthMonitor) performHealthCheck() {
 		return
 	}
 
-	// Check if the file queue is too full (over 90%)
+	// Check if the file queue is too full
+	_, unhealthyPercent := hm.Config.QueueThresholds()
 	queueSize := hm.worker.FileWatcher.QueueSize()
 	queueCapacity := hm.worker.FileWatcher.QueueCapacity()
 	if queueCapacity > 0 {
 		fillPercentage := float64(queueSize) / float64(queueCapacity) * 100
-		if fillPercentage > 90 {
+		if fillPercentage > float64(unhealthyPercent) {
 			slog.Warn("Health-Check: FileQueue is critically full",
 				"fill_percentage", fillPercentage,
 				"queue_size", queueSize,
@@ -131,6 +267,11 @@ func (hm *HealthMonitor) performHealthCheck() {
 			hm.isHealthy = false
 		}
 	}
+
+	if component := hm.workerPoolComponent(); component.Status == HealthStatusUnhealthy {
+		slog.Warn("Health-Check: files wait too long in the queue", "message", component.Message)
+		hm.isHealthy = false
+	}
 }
 
 func (hm *HealthMonitor) healthHandler(w http.ResponseWriter, _ *http.Request) {
@@ -175,6 +316,129 @@ func (hm *HealthMonitor) readinessHandler(w http.ResponseWriter, _ *http.Request
 	}
 }
 
+// logLevelHandler changes the log level at runtime, e.g. {"level":"DEBUG"}
+func (hm *HealthMonitor) logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Level string `json:"level"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(request.Level)); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid log level %q", request.Level))
+		return
+	}
+
+	previous := hm.LogLevel.Level()
+	hm.LogLevel.Set(level)
+	slog.Warn("Log level changed at runtime", "from", previous, "to", level)
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"level": level.String(),
+	}); err != nil {
+		slog.Error("Failed to encode log level response", "error", err)
+	}
+}
+
+// rescanHandler starts a rescan of the input directory, running scans are not started twice
+func (hm *HealthMonitor) rescanHandler(w http.ResponseWriter, _ *http.Request) {
+	switch err := hm.worker.FileWatcher.Rescan(); {
+	case errors.Is(err, ErrScanInProgress):
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"status": "rescan started",
+	}); err != nil {
+		slog.Error("Failed to encode rescan response", "error", err)
+	}
+}
+
+// configHandler reports the source (default, yaml, env or cli) of every setting. Values are left out,
+// they may contain credentials.
+func (hm *HealthMonitor) configHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]config.Provenance{"sources": hm.ConfigSources}); err != nil {
+		slog.Error("Failed to encode config sources response", "error", err)
+	}
+}
+
+func (hm *HealthMonitor) statsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(collectRuntimeStats()); err != nil {
+		slog.Error("Failed to encode runtime stats response", "error", err)
+	}
+}
+
+// collectRuntimeStats gathers goroutine, memory and GC statistics
+func collectRuntimeStats() RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := RuntimeStats{
+		Timestamp:    time.Now(),
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapInuse:    mem.HeapInuse,
+		HeapObjects:  mem.HeapObjects,
+		Sys:          mem.Sys,
+		TotalAlloc:   mem.TotalAlloc,
+		NumGC:        mem.NumGC,
+		PauseTotalNs: mem.PauseTotalNs,
+	}
+	if mem.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(mem.LastGC))
+	}
+	return stats
+}
+
+// workerPoolComponent reports the workers and how long the oldest queued file has been waiting.
+// Files waiting longer than MaxQueueAge mean the workers cannot keep up.
+func (hm *HealthMonitor) workerPoolComponent() ComponentHealth {
+	component := ComponentHealth{
+		Status:      HealthStatusHealthy,
+		LastChecked: time.Now(),
+		Message:     fmt.Sprintf("%d workers active", hm.worker.FileWatcher.WorkerCount()),
+	}
+
+	// A deliberate pause is reported, but queued files are expected to wait meanwhile
+	if hm.worker.FileWatcher.Paused() {
+		component.Status = HealthStatusDegraded
+		component.Message += ", processing paused by the pause lock file"
+		return component
+	}
+
+	age := hm.worker.FileWatcher.OldestQueuedAge()
+	if age > 0 {
+		component.Message += fmt.Sprintf(", oldest queued file waiting %s", age.Round(time.Millisecond))
+	}
+
+	maxAge := time.Duration(hm.Config.MaxQueueAge) * time.Millisecond
+	switch {
+	case maxAge <= 0:
+	case age > 2*maxAge:
+		component.Status = HealthStatusUnhealthy
+		component.Message += fmt.Sprintf(" (more than twice the max queue age of %s)", maxAge)
+	case age > maxAge:
+		component.Status = HealthStatusDegraded
+		component.Message += fmt.Sprintf(" (above max queue age of %s)", maxAge)
+	}
+	return component
+}
+
 func (hm *HealthMonitor) HealthStatus() HealthCheck {
 	hm.mu.RLock()
 	defer hm.mu.RUnlock()
@@ -196,14 +460,15 @@ func (hm *HealthMonitor) HealthStatus() HealthCheck {
 			message = "FileWatcher queue capacity is zero (misconfiguration)"
 			overallStatus = HealthStatusUnhealthy
 		} else {
+			degradedPercent, unhealthyPercent := hm.Config.QueueThresholds()
 			fillPercentage = float64(queueSize) / float64(queueCapacity) * 100
-			if fillPercentage > 90 {
+			if fillPercentage > float64(unhealthyPercent) {
 				status = HealthStatusUnhealthy
-				message = "FileQueue is critically full (>90%)"
+				message = fmt.Sprintf("FileQueue is critically full (>%d%%)", unhealthyPercent)
 				overallStatus = HealthStatusUnhealthy
-			} else if fillPercentage > 80 {
+			} else if fillPercentage > float64(degradedPercent) {
 				status = HealthStatusDegraded
-				message = "FileQueue is heavily loaded (>80%)"
+				message = fmt.Sprintf("FileQueue is heavily loaded (>%d%%)", degradedPercent)
 				overallStatus = HealthStatusDegraded
 			}
 		}
@@ -234,16 +499,45 @@ func (hm *HealthMonitor) HealthStatus() HealthCheck {
 
 	// Worker Pool Status
 	if hm.worker.FileWatcher != nil {
-		components["worker_pool"] = ComponentHealth{
-			Status:      HealthStatusHealthy,
+		component := hm.workerPoolComponent()
+		components["worker_pool"] = component
+		overallStatus = worseStatus(overallStatus, component.Status)
+	}
+
+	// Disabled TLS verification must never go unnoticed
+	if hm.worker.InsecureTLS {
+		components["tls"] = ComponentHealth{
+			Status:      HealthStatusDegraded,
 			LastChecked: time.Now(),
-			Message:     fmt.Sprintf("%d workers active", hm.worker.FileWatcher.WorkerCount()),
+			Message:     "TLS certificate verification is disabled (tls.insecure-skip-verify)",
 		}
+		overallStatus = worseStatus(overallStatus, HealthStatusDegraded)
 	}
 
-	return HealthCheck{
+	// The health port could not be bound, the endpoints are unreachable
+	if hm.bindErr != nil {
+		components["health_server"] = ComponentHealth{
+			Status:      HealthStatusUnhealthy,
+			LastChecked: time.Now(),
+			Message:     hm.bindErr.Error(),
+		}
+		overallStatus = HealthStatusUnhealthy
+	}
+
+	// Free disk space of filesystem targets
+	for name, component := range hm.diskComponents {
+		components[name] = component
+		overallStatus = worseStatus(overallStatus, component.Status)
+	}
+
+	healthCheck := HealthCheck{
 		Status:     overallStatus,
 		Timestamp:  time.Now(),
 		Components: components,
 	}
+	if hm.worker.FileHandler != nil && hm.worker.FileHandler.SessionHash != nil {
+		status := hm.worker.FileHandler.SessionHash.Status()
+		healthCheck.SessionHash = &status
+	}
+	return healthCheck
 }
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// freeDiskBytes and freeDiskInodes are replaceable in tests to simulate full volumes
+var (
+	freeDiskBytes  = statfsFreeBytes
+	freeDiskInodes = statfsFreeInodes
+)
+
+// ErrInodesExhausted is returned when a filesystem target has fewer free inodes than required
+var ErrInodesExhausted = errors.New("not enough free inodes on the target volume")
+
+const bytesPerMB = 1024 * 1024
+
+// checkDiskSpace probes the free space of every filesystem target's volume. The probes run in parallel, but at
+// most MaxConcurrentProbes at once, so many (network) volumes neither stall the check nor flood the remotes.
+// Without a warning or critical threshold nothing is probed.
+func (hm *HealthMonitor) checkDiskSpace() map[string]ComponentHealth {
+	if hm.Config.DiskWarnFreeMB <= 0 && hm.Config.DiskCriticalFreeMB <= 0 {
+		return nil
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	components := make(map[string]ComponentHealth)
+	probes := make(chan struct{}, hm.Config.ProbeConcurrency())
+	for _, target := range hm.worker.OutputTargets {
+		if target.Type != "filesystem" {
+			continue
+		}
+		probes <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-probes }()
+			component := hm.diskComponent(target.Path)
+			mu.Lock()
+			components["disk:"+target.Path] = component
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	return components
+}
+
+func (hm *HealthMonitor) diskComponent(path string) ComponentHealth {
+	component := ComponentHealth{
+		Status:      HealthStatusHealthy,
+		LastChecked: time.Now(),
+	}
+
+	free, err := freeDiskBytes(existingAncestor(path))
+	// E.g. on Windows, the thresholds cannot be checked there
+	if errors.Is(err, errors.ErrUnsupported) {
+		component.Message = "free space is not supported on this platform"
+		return component
+	}
+	if err != nil {
+		component.Status = HealthStatusDegraded
+		component.Message = fmt.Sprintf("free space could not be determined: %v", err)
+		return component
+	}
+
+	freeMB := free / bytesPerMB
+	component.Message = fmt.Sprintf("%d MB free", freeMB)
+
+	switch {
+	case hm.Config.DiskCriticalFreeMB > 0 && freeMB < uint64(hm.Config.DiskCriticalFreeMB):
+		component.Status = HealthStatusUnhealthy
+		component.Message += fmt.Sprintf(" (below critical threshold of %d MB)", hm.Config.DiskCriticalFreeMB)
+	case hm.Config.DiskWarnFreeMB > 0 && freeMB < uint64(hm.Config.DiskWarnFreeMB):
+		component.Status = HealthStatusDegraded
+		component.Message += fmt.Sprintf(" (below warning threshold of %d MB)", hm.Config.DiskWarnFreeMB)
+	}
+	return component
+}
+
+// checkFreeInodes refuses writes to dir when its volume has fewer than MinFreeInodes free inodes.
+// Volumes without a fixed inode table (e.g. btrfs) and platforms without statfs are not checked.
+func (fh *FileHandler) checkFreeInodes(dir string) error {
+	if fh.MinFreeInodes == 0 {
+		return nil
+	}
+	free, total, err := freeDiskInodes(existingAncestor(dir))
+	if errors.Is(err, errors.ErrUnsupported) || (err == nil && total == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("free inodes could not be determined: %w", err)
+	}
+	if free < fh.MinFreeInodes {
+		return fmt.Errorf("%w: %s has %d free inodes, at least %d required", ErrInodesExhausted, dir, free, fh.MinFreeInodes)
+	}
+	return nil
+}
+
+// existingAncestor returns path or its nearest existing parent, targets may not be created yet
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// worseStatus returns the more severe of two health states
+func worseStatus(a, b HealthStatus) HealthStatus {
+	severity := map[HealthStatus]int{
+		HealthStatusHealthy:   0,
+		HealthStatusDegraded:  1,
+		HealthStatusUnhealthy: 2,
+	}
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
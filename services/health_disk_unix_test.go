@@ -0,0 +1,257 @@
+//go:build unix
+
+package services
+
+import (
+	"errors"
+	"file-shifter/config"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthMonitor_DiskSpaceComponent(t *testing.T) {
+	tests := []struct {
+		name       string
+		freeMB     uint64
+		wantStatus HealthStatus
+	}{
+		{name: "plenty of space", freeMB: 10000, wantStatus: HealthStatusHealthy},
+		{name: "below warning threshold", freeMB: 500, wantStatus: HealthStatusDegraded},
+		{name: "below critical threshold", freeMB: 50, wantStatus: HealthStatusUnhealthy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetDir := t.TempDir()
+			original := freeDiskBytes
+			freeDiskBytes = func(path string) (uint64, error) {
+				if path != targetDir {
+					t.Errorf("statfs called with %q, want %q", path, targetDir)
+				}
+				return tt.freeMB * bytesPerMB, nil
+			}
+			defer func() { freeDiskBytes = original }()
+
+			hm := NewHealthMonitor(&Worker{
+				FileWatcher:   &FileWatcher{fileQueue: make(chan string, 10), queueCapacity: 10, workerCount: 1},
+				OutputTargets: []config.OutputTarget{{Path: targetDir, Type: "filesystem"}, {Path: "s3://bucket", Type: "s3"}},
+			}, "0")
+			hm.Config.DiskWarnFreeMB = 1024
+			hm.Config.DiskCriticalFreeMB = 100
+			hm.performHealthCheck()
+
+			health := hm.HealthStatus()
+			component, ok := health.Components["disk:"+targetDir]
+			if !ok {
+				t.Fatalf("disk component missing, got %v", health.Components)
+			}
+			if component.Status != tt.wantStatus {
+				t.Errorf("component status = %s, want %s (%s)", component.Status, tt.wantStatus, component.Message)
+			}
+			if health.Status != tt.wantStatus {
+				t.Errorf("overall status = %s, want %s", health.Status, tt.wantStatus)
+			}
+			if _, ok := health.Components["disk:s3://bucket"]; ok {
+				t.Error("non-filesystem targets must not get a disk component")
+			}
+		})
+	}
+}
+
+func TestHealthMonitor_DiskSpaceComponent_StatfsError(t *testing.T) {
+	original := freeDiskBytes
+	freeDiskBytes = func(string) (uint64, error) { return 0, errors.New("boom") }
+	defer func() { freeDiskBytes = original }()
+
+	hm := NewHealthMonitor(&Worker{OutputTargets: []config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}}, "0")
+	hm.Config.DiskWarnFreeMB = 1024
+	components := hm.checkDiskSpace()
+
+	for _, component := range components {
+		if component.Status != HealthStatusDegraded {
+			t.Errorf("statfs errors should report degraded, got %s", component.Status)
+		}
+	}
+	if len(components) != 1 {
+		t.Errorf("expected 1 disk component, got %d", len(components))
+	}
+}
+
+func TestHealthMonitor_DiskSpaceComponent_Unsupported(t *testing.T) {
+	original := freeDiskBytes
+	freeDiskBytes = func(string) (uint64, error) { return 0, errors.ErrUnsupported }
+	defer func() { freeDiskBytes = original }()
+
+	hm := NewHealthMonitor(&Worker{OutputTargets: []config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}}, "0")
+	hm.Config.DiskWarnFreeMB = 1024
+	components := hm.checkDiskSpace()
+
+	if len(components) != 1 {
+		t.Fatalf("expected 1 disk component, got %d", len(components))
+	}
+	for _, component := range components {
+		if component.Status != HealthStatusHealthy || component.Message == "" {
+			t.Errorf("unsupported platforms should report healthy with a note, got %s (%q)", component.Status, component.Message)
+		}
+	}
+}
+
+func TestHealthMonitor_DiskSpaceThresholdsOff(t *testing.T) {
+	original := freeDiskBytes
+	freeDiskBytes = func(string) (uint64, error) {
+		t.Error("volumes must not be probed without thresholds")
+		return 0, errors.ErrUnsupported
+	}
+	defer func() { freeDiskBytes = original }()
+
+	hm := NewHealthMonitor(&Worker{OutputTargets: []config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}}, "0")
+	if components := hm.checkDiskSpace(); len(components) != 0 {
+		t.Errorf("expected no disk components without thresholds, got %v", components)
+	}
+}
+
+func TestFileHandler_CopyToFilesystemInodesExhausted(t *testing.T) {
+	srcFile := filepath.Join(t.TempDir(), "tiny.txt")
+	if err := os.WriteFile(srcFile, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		minFreeInodes uint64
+		free, total   uint64
+		wantErr       bool
+	}{
+		{name: "no free inodes", minFreeInodes: 1000, free: 0, total: 1 << 20, wantErr: true},
+		{name: "enough free inodes", minFreeInodes: 1000, free: 5000, total: 1 << 20},
+		{name: "check disabled", minFreeInodes: 0, free: 0, total: 1 << 20},
+		{name: "dynamic inodes", minFreeInodes: 1000, free: 0, total: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := freeDiskInodes
+			freeDiskInodes = func(string) (uint64, uint64, error) { return tt.free, tt.total, nil }
+			defer func() { freeDiskInodes = original }()
+
+			targetDir := t.TempDir()
+			fh := NewFileHandler(nil, nil)
+			fh.MinFreeInodes = tt.minFreeInodes
+
+			err := fh.copyToFilesystem(srcFile, "tiny.txt", targetDir, fileInfo)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInodesExhausted) {
+					t.Fatalf("copyToFilesystem() error = %v, want %v", err, ErrInodesExhausted)
+				}
+				if _, statErr := os.Stat(filepath.Join(targetDir, "tiny.txt")); !os.IsNotExist(statErr) {
+					t.Error("no file should be written when inodes are exhausted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("copyToFilesystem() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestStatfsFreeInodes(t *testing.T) {
+	free, total, err := statfsFreeInodes(t.TempDir())
+	if err != nil {
+		t.Fatalf("statfsFreeInodes() error = %v", err)
+	}
+	if free > total {
+		t.Errorf("free inodes %d exceed total %d", free, total)
+	}
+}
+
+func TestStatfsFreeBytes(t *testing.T) {
+	free, err := statfsFreeBytes(t.TempDir())
+	if err != nil {
+		t.Fatalf("statfsFreeBytes() error = %v", err)
+	}
+	if free == 0 {
+		t.Error("expected free space on the temp volume")
+	}
+}
+
+func TestHealthMonitor_DiskSpaceProbeConcurrency(t *testing.T) {
+	const targetCount, limit = 20, 3
+
+	var active, peak atomic.Int32
+	original := freeDiskBytes
+	freeDiskBytes = func(string) (uint64, error) {
+		current := active.Add(1)
+		defer active.Add(-1)
+		for {
+			seen := peak.Load()
+			if current <= seen || peak.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return 10 * 1024 * bytesPerMB, nil
+	}
+	defer func() { freeDiskBytes = original }()
+
+	var targets []config.OutputTarget
+	for i := range targetCount {
+		targets = append(targets, config.OutputTarget{Path: filepath.Join(t.TempDir(), fmt.Sprint(i)), Type: "filesystem"})
+	}
+	hm := NewHealthMonitor(&Worker{OutputTargets: targets}, "0")
+	hm.Config.MaxConcurrentProbes = limit
+	hm.Config.DiskWarnFreeMB = 1024
+
+	components := hm.checkDiskSpace()
+
+	if len(components) != targetCount {
+		t.Fatalf("got %d disk components, want %d", len(components), targetCount)
+	}
+	if got := peak.Load(); got > limit {
+		t.Errorf("%d probes ran at once, limit is %d", got, limit)
+	}
+}
+
+func TestHealthMonitor_HungDiskProbeDoesNotBlockStatus(t *testing.T) {
+	release := make(chan struct{})
+	probing := make(chan struct{}, 1)
+	original := freeDiskBytes
+	freeDiskBytes = func(string) (uint64, error) {
+		probing <- struct{}{}
+		<-release
+		return 10 * 1024 * bytesPerMB, nil
+	}
+	defer func() { freeDiskBytes = original }()
+
+	hm := NewHealthMonitor(&Worker{OutputTargets: []config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}}, "0")
+	hm.Config.DiskWarnFreeMB = 1024
+
+	checked := make(chan struct{})
+	go func() {
+		hm.performHealthCheck()
+		close(checked)
+	}()
+	<-probing
+
+	status := make(chan struct{})
+	go func() {
+		hm.HealthStatus()
+		close(status)
+	}()
+	select {
+	case <-status:
+	case <-time.After(2 * time.Second):
+		t.Error("HealthStatus() blocked by a hung disk probe")
+	}
+
+	close(release)
+	<-checked
+}
@@ -130,7 +130,7 @@ func TestFileHandler_TargetAndFinalizeBranches(t *testing.T) {
 
 	t.Run("copyToAllTargets returns joined error", func(t *testing.T) {
 		fh := NewFileHandler([]config.OutputTarget{{Type: "unknown"}}, nil)
-		err := fh.copyToAllTargets(inputFile, "in.txt", fi)
+		err := fh.copyToAllTargets(fh.OutputTargets(), inputFile, "in.txt", fi)
 		if err == nil {
 			t.Fatal("expected joined error for failing targets")
 		}
@@ -146,7 +146,7 @@ func TestFileHandler_TargetAndFinalizeBranches(t *testing.T) {
 			t.Fatalf("failed to create retry file: %v", err)
 		}
 
-		retry, err := fh.finalizeProcessedFile(fileRetry, "retry.txt", "different", 1, 3)
+		retry, err := fh.finalizeProcessedFile(fh.OutputTargets(), nil, nil, fileRetry, "retry.txt", "different", nil, 1, 3)
 		if err != nil {
 			t.Fatalf("expected no error before max retries, got: %v", err)
 		}
@@ -154,7 +154,7 @@ func TestFileHandler_TargetAndFinalizeBranches(t *testing.T) {
 			t.Fatal("expected retry=true when checksum mismatch and attempts remain")
 		}
 
-		_, err = fh.finalizeProcessedFile(fileRetry, "retry.txt", "different", 3, 3)
+		_, err = fh.finalizeProcessedFile(fh.OutputTargets(), nil, nil, fileRetry, "retry.txt", "different", nil, 3, 3)
 		if err == nil {
 			t.Fatal("expected error when checksum mismatch reaches max retries")
 		}
@@ -163,7 +163,7 @@ func TestFileHandler_TargetAndFinalizeBranches(t *testing.T) {
 	t.Run("finalizeProcessedFile checksum-error and success remove", func(t *testing.T) {
 		fh := NewFileHandler(nil, nil)
 
-		_, err := fh.finalizeProcessedFile(filepath.Join(tempDir, "missing.txt"), "missing.txt", "x", 1, 2)
+		_, err := fh.finalizeProcessedFile(fh.OutputTargets(), nil, nil, filepath.Join(tempDir, "missing.txt"), "missing.txt", "x", nil, 1, 2)
 		if err == nil {
 			t.Fatal("expected final checksum error for missing file")
 		}
@@ -177,7 +177,7 @@ func TestFileHandler_TargetAndFinalizeBranches(t *testing.T) {
 			t.Fatalf("failed to calculate checksum: %v", err)
 		}
 
-		retry, err := fh.finalizeProcessedFile(fileOK, "ok.txt", checksum, 1, 2)
+		retry, err := fh.finalizeProcessedFile(fh.OutputTargets(), nil, nil, fileOK, "ok.txt", checksum, nil, 1, 2)
 		if err != nil {
 			t.Fatalf("expected success remove, got: %v", err)
 		}
@@ -204,7 +204,7 @@ func TestFileHandler_TargetAndFinalizeBranches(t *testing.T) {
 			{Type: "s3", Path: "s3://bucket/prefix"},
 		}, nil)
 
-		err := fh.cleanupTargetFiles("x.txt")
+		err := fh.cleanupTargetFiles(fh.OutputTargets(), "x.txt")
 		if err == nil {
 			t.Fatal("expected cleanup error because s3 deletion cannot run without manager")
 		}
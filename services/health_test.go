@@ -1,9 +1,18 @@
 package services
 
 import (
+	"bytes"
 	"encoding/json"
 	"file-shifter/config"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -32,7 +41,9 @@ func TestHealthMonitor(t *testing.T) { // NOSONAR - umfassender Health-Endpunkt-
 
 	// Create health monitor
 	healthMonitor := NewHealthMonitor(worker, "8081")
-	healthMonitor.Start()
+	if err := healthMonitor.Start(); err != nil {
+		t.Fatalf("Failed to start health monitor: %v", err)
+	}
 	defer healthMonitor.Stop()
 
 	// Wait for server to start
@@ -93,3 +104,408 @@ func TestHealthMonitor(t *testing.T) { // NOSONAR - umfassender Health-Endpunkt-
 	// Stop worker before cleanup
 	worker.Stop()
 }
+
+func TestHealthMonitor_AuthToken(t *testing.T) {
+	fw := &FileWatcher{fileQueue: make(chan string, 10), queueCapacity: 10, workerCount: 1}
+	hm := NewHealthMonitor(&Worker{FileWatcher: fw}, "0")
+	hm.Config.AuthToken = "s3cr3t"
+	mux := hm.newMux()
+
+	tests := []struct {
+		name           string
+		path           string
+		authorization  string
+		expectedStatus int
+	}{
+		{"health without token", "/health", "", http.StatusUnauthorized},
+		{"health with wrong token", "/health", "Bearer wrong", http.StatusUnauthorized},
+		{"health with non-bearer scheme", "/health", "Basic s3cr3t", http.StatusUnauthorized},
+		{"health with valid token", "/health", "Bearer s3cr3t", http.StatusOK},
+		{"readiness without token", "/health/ready", "", http.StatusUnauthorized},
+		{"readiness with valid token", "/health/ready", "Bearer s3cr3t", http.StatusOK},
+		{"liveness stays open", "/health/live", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHealthMonitor_NoAuthTokenConfigured(t *testing.T) {
+	fw := &FileWatcher{fileQueue: make(chan string, 10), queueCapacity: 10, workerCount: 1}
+	hm := NewHealthMonitor(&Worker{FileWatcher: fw}, "0")
+	mux := hm.newMux()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 without configured token, got %d", rec.Code)
+	}
+}
+
+func TestHealthMonitor_DebugStats(t *testing.T) {
+	hm := NewHealthMonitor(&Worker{}, "0")
+	rec := httptest.NewRecorder()
+	hm.newMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/stats", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var stats RuntimeStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.Goroutines <= 0 {
+		t.Errorf("Expected goroutine count > 0, got %d", stats.Goroutines)
+	}
+	if stats.Sys == 0 {
+		t.Error("Expected non-zero memory statistics")
+	}
+}
+
+func TestHealthMonitor_ServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name                                string
+		cfg                                 config.HealthConfig
+		wantReadHeader, wantRead, wantWrite time.Duration
+	}{
+		{
+			name:           "defaults",
+			wantReadHeader: 5 * time.Second,
+			wantRead:       10 * time.Second,
+			wantWrite:      time.Minute,
+		},
+		{
+			name:           "configured",
+			cfg:            config.HealthConfig{ReadHeaderTimeout: 1000, ReadTimeout: 2000, WriteTimeout: 3000},
+			wantReadHeader: time.Second,
+			wantRead:       2 * time.Second,
+			wantWrite:      3 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hm := NewHealthMonitor(&Worker{}, "0")
+			hm.Config = tt.cfg
+
+			server := hm.newServer()
+			if server.ReadHeaderTimeout != tt.wantReadHeader {
+				t.Errorf("ReadHeaderTimeout = %v, want %v", server.ReadHeaderTimeout, tt.wantReadHeader)
+			}
+			if server.ReadTimeout != tt.wantRead {
+				t.Errorf("ReadTimeout = %v, want %v", server.ReadTimeout, tt.wantRead)
+			}
+			if server.WriteTimeout != tt.wantWrite {
+				t.Errorf("WriteTimeout = %v, want %v", server.WriteTimeout, tt.wantWrite)
+			}
+		})
+	}
+}
+
+func TestHealthMonitor_PprofGatedByConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		enabled        bool
+		expectedStatus int
+	}{
+		{"disabled by default", false, http.StatusNotFound},
+		{"enabled", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hm := NewHealthMonitor(&Worker{}, "0")
+			hm.Config.EnablePprof = tt.enabled
+
+			rec := httptest.NewRecorder()
+			hm.newMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHealthMonitor_LogLevelControl(t *testing.T) {
+	var logs bytes.Buffer
+	level := new(slog.LevelVar)
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: level})))
+	defer slog.SetDefault(previous)
+
+	hm := NewHealthMonitor(&Worker{}, "0")
+	hm.Config.AuthToken = "s3cr3t"
+	hm.LogLevel = level
+	mux := hm.newMux()
+
+	post := func(token, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/control/log-level", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	slog.Debug("hidden debug line")
+	if strings.Contains(logs.String(), "hidden debug line") {
+		t.Fatal("debug line must not be logged at INFO level")
+	}
+
+	if code := post("", `{"level":"DEBUG"}`); code != http.StatusUnauthorized {
+		t.Errorf("without token: status = %d, want 401", code)
+	}
+	if code := post("s3cr3t", `{"level":"LOUD"}`); code != http.StatusBadRequest {
+		t.Errorf("invalid level: status = %d, want 400", code)
+	}
+	if code := post("s3cr3t", `{"level":"DEBUG"}`); code != http.StatusOK {
+		t.Fatalf("valid request: status = %d, want 200", code)
+	}
+
+	slog.Debug("visible debug line")
+	if !strings.Contains(logs.String(), "visible debug line") {
+		t.Errorf("debug line should be logged after raising the level, got %q", logs.String())
+	}
+}
+
+func TestHealthMonitor_LogLevelControlRequiresToken(t *testing.T) {
+	hm := NewHealthMonitor(&Worker{}, "0")
+	hm.LogLevel = new(slog.LevelVar)
+
+	req := httptest.NewRequest(http.MethodPost, "/control/log-level", strings.NewReader(`{"level":"DEBUG"}`))
+	rec := httptest.NewRecorder()
+	hm.newMux().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403 without a configured auth token", rec.Code)
+	}
+	if hm.LogLevel.Level() != slog.LevelInfo {
+		t.Errorf("log level changed to %s without authentication", hm.LogLevel.Level())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/control/log-level", nil)
+	rec = httptest.NewRecorder()
+	hm.Config.AuthToken = "s3cr3t"
+	hm.newMux().ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", rec.Code)
+	}
+}
+
+func TestHealthMonitor_RescanControl(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, NewS3ClientManager())
+	fw, err := NewFileWatcher(inputDir, fileHandler, 5, 10*time.Millisecond, 20*time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	fw.lsofAvailable = false
+	// Only the workers run, no directory is watched - like a file that fsnotify has missed
+	fw.startWorkers()
+	defer fw.Stop()
+
+	if err := os.WriteFile(filepath.Join(inputDir, "missed.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hm := NewHealthMonitor(&Worker{FileWatcher: fw}, "0")
+	hm.Config.AuthToken = "s3cr3t"
+	mux := hm.newMux()
+	rescan := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/control/rescan", nil)
+		req.Header.Set("Authorization", "Bearer s3cr3t")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	fw.scanning.Store(true)
+	if code := rescan(); code != http.StatusConflict {
+		t.Errorf("status during a running scan = %d, want 409", code)
+	}
+	fw.scanning.Store(false)
+
+	if code := rescan(); code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", code)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(outputDir, "missed.txt")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("file was not delivered after the rescan")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHealthMonitor_CustomQueueThresholds(t *testing.T) {
+	tests := []struct {
+		queued      int
+		wantStatus  HealthStatus
+		wantHealthy bool
+	}{
+		{queued: 0, wantStatus: HealthStatusHealthy, wantHealthy: true},
+		{queued: 5, wantStatus: HealthStatusHealthy, wantHealthy: true},
+		{queued: 6, wantStatus: HealthStatusDegraded, wantHealthy: true},
+		{queued: 7, wantStatus: HealthStatusDegraded, wantHealthy: true},
+		{queued: 8, wantStatus: HealthStatusUnhealthy, wantHealthy: false},
+		{queued: 10, wantStatus: HealthStatusUnhealthy, wantHealthy: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d of 10 queued", tt.queued), func(t *testing.T) {
+			fw := &FileWatcher{fileQueue: make(chan string, 10), queueCapacity: 10, workerCount: 1}
+			for i := 0; i < tt.queued; i++ {
+				fw.fileQueue <- fmt.Sprintf("file%d", i)
+			}
+
+			hm := NewHealthMonitor(&Worker{FileWatcher: fw}, "0")
+			hm.Config.QueueDegradedPercent = 50
+			hm.Config.QueueUnhealthyPercent = 70
+
+			hm.performHealthCheck()
+			if hm.isHealthy != tt.wantHealthy {
+				t.Errorf("isHealthy = %v, want %v", hm.isHealthy, tt.wantHealthy)
+			}
+			if got := hm.HealthStatus().Components["file_watcher"].Status; got != tt.wantStatus {
+				t.Errorf("file_watcher status = %s, want %s", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHealthMonitor_MaxQueueAge(t *testing.T) {
+	// No worker consumes the queue, the queued file gets older with every check
+	fw := &FileWatcher{fileQueue: make(chan string, 10), queueCapacity: 10, workerCount: 1, stopChan: make(chan bool)}
+	fw.enqueueFileWithMonitoring("stalled.txt")
+
+	hm := NewHealthMonitor(&Worker{FileWatcher: fw}, "0")
+	hm.Config.MaxQueueAge = 50
+
+	firstAge := fw.OldestQueuedAge()
+	if firstAge <= 0 {
+		t.Fatal("expected the queued file to have an age")
+	}
+
+	waitForWorkerPoolStatus := func(want HealthStatus) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			component := hm.HealthStatus().Components["worker_pool"]
+			if component.Status == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("worker_pool status = %s (%s), want %s", component.Status, component.Message, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitForWorkerPoolStatus(HealthStatusDegraded)
+	if age := fw.OldestQueuedAge(); age <= firstAge {
+		t.Errorf("oldest queued age should grow, got %v after %v", age, firstAge)
+	}
+	waitForWorkerPoolStatus(HealthStatusUnhealthy)
+	if status := hm.HealthStatus(); status.Status != HealthStatusUnhealthy {
+		t.Errorf("overall status = %s, want unhealthy", status.Status)
+	}
+	hm.performHealthCheck()
+	if hm.isHealthy {
+		t.Error("isHealthy should be false while files wait more than twice the max queue age")
+	}
+
+	// Once the worker picks the file up the pool is healthy again
+	fw.untrackQueued(<-fw.fileQueue)
+	waitForWorkerPoolStatus(HealthStatusHealthy)
+}
+
+func TestHealthMonitor_ConfigSources(t *testing.T) {
+	fw := &FileWatcher{fileQueue: make(chan string, 10), queueCapacity: 10, workerCount: 1}
+	hm := NewHealthMonitor(&Worker{FileWatcher: fw}, "0")
+
+	rec := httptest.NewRecorder()
+	hm.newMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("/config without sources: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	hm.ConfigSources = config.Provenance{"worker-pool.workers": config.SourceEnv, "input": config.SourceYAML}
+	rec = httptest.NewRecorder()
+	hm.newMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	var response struct {
+		Sources map[string]string `json:"sources"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode config response: %v", err)
+	}
+	if response.Sources["worker-pool.workers"] != config.SourceEnv || response.Sources["input"] != config.SourceYAML {
+		t.Errorf("sources = %v", response.Sources)
+	}
+}
+
+func TestHealthMonitor_PortInUse(t *testing.T) {
+	// Occupy a free port before the monitor tries to bind it
+	occupied, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to occupy a port: %v", err)
+	}
+	defer occupied.Close()
+	port := strconv.Itoa(occupied.Addr().(*net.TCPAddr).Port)
+
+	t.Run("exit", func(t *testing.T) {
+		fw := &FileWatcher{fileQueue: make(chan string, 10), queueCapacity: 10, workerCount: 1}
+		hm := NewHealthMonitor(&Worker{FileWatcher: fw}, port)
+
+		err := hm.Start()
+		if err == nil {
+			hm.Stop()
+			t.Fatal("Start() should fail while the port is in use")
+		}
+		if !strings.Contains(err.Error(), "health port "+port) {
+			t.Errorf("error should name the port, got %v", err)
+		}
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		fw := &FileWatcher{fileQueue: make(chan string, 10), queueCapacity: 10, workerCount: 1}
+		hm := NewHealthMonitor(&Worker{FileWatcher: fw}, port)
+		hm.Config.OnBindError = config.HealthOnBindErrorUnhealthy
+
+		if err := hm.Start(); err != nil {
+			t.Fatalf("Start() error = %v, want the bind failure reported as unhealthy", err)
+		}
+		defer hm.Stop()
+
+		if hm.isHealthy {
+			t.Error("isHealthy should be false without a health server")
+		}
+		status := hm.HealthStatus()
+		if status.Status != HealthStatusUnhealthy {
+			t.Errorf("overall status = %s, want unhealthy", status.Status)
+		}
+		if component := status.Components["health_server"]; component.Status != HealthStatusUnhealthy {
+			t.Errorf("health_server component = %+v, want unhealthy", component)
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"file-shifter/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaPublishTimeout limits publishing a single message including retries of the writer
+const kafkaPublishTimeout = 30 * time.Second
+
+// Header describing the kind of a published message
+const (
+	kafkaMessageTypeHeader = "file-shifter-type"
+	kafkaMessageContent    = "content"   // Value is the file content
+	kafkaMessageReference  = "reference" // Value is a kafkaReference document
+)
+
+// ErrKafkaMessageTooLarge is returned for files above max-inline-bytes when no reference-url is configured
+var ErrKafkaMessageTooLarge = errors.New("file exceeds max-inline-bytes and no reference-url is configured")
+
+// kafkaProducer publishes messages to the topic of a target, *kafka.Writer implements it
+type kafkaProducer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Writer settings: a message carries the content of one file and is written synchronously, so batches are only
+// bounded by the largest message and the writer does not wait for more messages
+const (
+	kafkaMessageOverhead = 64 * 1024 // Key, headers and record framing on top of the inline content
+	kafkaMinBatchBytes   = 1024 * 1024
+	kafkaBatchTimeout    = 10 * time.Millisecond
+)
+
+// kafkaBatchBytes returns the batch size limit of a writer, large enough for an inline message of max-inline-bytes
+func kafkaBatchBytes(maxInlineBytes int) int64 {
+	return max(int64(maxInlineBytes)+kafkaMessageOverhead, kafkaMinBatchBytes)
+}
+
+// newKafkaProducer is replaceable in tests to capture messages without brokers
+var newKafkaProducer = func(kafkaConfig config.KafkaConfig, tlsConfig *tls.Config) kafkaProducer {
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(kafkaConfig.Brokers...),
+		Topic:        kafkaConfig.Topic,
+		Balancer:     &kafka.Hash{}, // same key, same partition: messages of a file stay in order
+		RequiredAcks: kafka.RequireAll,
+		BatchBytes:   kafkaBatchBytes(kafkaConfig.MaxInlineBytes),
+		BatchTimeout: kafkaBatchTimeout,
+	}
+	if kafkaConfig.TLS {
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		writer.Transport = &kafka.Transport{TLS: tlsConfig}
+	}
+	return writer
+}
+
+// kafkaReference is published instead of the content for files above max-inline-bytes
+type kafkaReference struct {
+	FileMetadata
+	URL string `json:"url"`
+}
+
+// copyToKafka publishes the file keyed by its relative path: small files with their content,
+// larger files as a reference to reference-url
+func (fh *FileHandler) copyToKafka(srcPath, relPath string, target config.OutputTarget, fileInfo os.FileInfo) error {
+	kafkaConfig := target.GetKafkaConfig()
+	key := filepath.ToSlash(relPath)
+
+	content, inline, checksum, err := fh.readInlineContent(srcPath, kafkaConfig.MaxInlineBytes)
+	if err != nil {
+		return err
+	}
+
+	message := kafka.Message{
+		Key: []byte(key),
+		Headers: []kafka.Header{
+			{Key: kafkaMessageTypeHeader, Value: []byte(kafkaMessageContent)},
+			{Key: checksumMetadataKey, Value: []byte(checksum)},
+		},
+		Value: content,
+	}
+	if !inline {
+		if kafkaConfig.ReferenceURL == "" {
+			return fmt.Errorf("%w (limit %d bytes): %s", ErrKafkaMessageTooLarge, kafkaConfig.MaxInlineBytes, relPath)
+		}
+		reference, err := json.Marshal(kafkaReference{
+			FileMetadata: FileMetadata{
+				Name:     fileInfo.Name(),
+				RelPath:  key,
+				Size:     fileInfo.Size(),
+				Checksum: checksum,
+				ModTime:  fileInfo.ModTime().UTC(),
+			},
+			URL: strings.TrimSuffix(kafkaConfig.ReferenceURL, "/") + "/" + key,
+		})
+		if err != nil {
+			return fmt.Errorf("error encoding the Kafka reference: %w", err)
+		}
+		message.Headers[0].Value = []byte(kafkaMessageReference)
+		message.Value = reference
+	}
+
+	if err := fh.publishKafka(fh.fileContext(srcPath), target, message); err != nil {
+		return err
+	}
+
+	slog.Info("File successfully published to Kafka", "source", relPath, "topic", kafkaConfig.Topic, "inline", inline)
+	return nil
+}
+
+// readInlineContent reads the (decompressed) file if it fits into limit bytes. The content is buffered
+// completely, so files above MaxInMemoryBytes are not inline either. The checksum of the content is calculated
+// in the same read, for larger files by reading the rest without buffering it.
+func (fh *FileHandler) readInlineContent(srcPath string, limit int) ([]byte, bool, string, error) {
+	srcFile, err := fh.openSource(srcPath)
+	if err != nil {
+		return nil, false, "", fmt.Errorf("error opening source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	hash := sha256.New()
+	content, err := fh.bufferContent(io.LimitReader(io.TeeReader(srcFile, hash), int64(limit)+1))
+	inline := err == nil && len(content) <= limit
+	if errors.Is(err, ErrExceedsMemoryLimit) {
+		slog.Debug("File exceeds the in-memory limit - not published inline", "file", srcPath, "error", err)
+	} else if err != nil {
+		return nil, false, "", fmt.Errorf("error reading source file: %w", err)
+	}
+	if _, err := io.Copy(hash, srcFile); err != nil {
+		return nil, false, "", fmt.Errorf("error calculating checksum: %w", err)
+	}
+
+	checksum := fmt.Sprintf("%x", hash.Sum(nil))
+	if !inline {
+		return nil, false, checksum, nil
+	}
+	return content, true, checksum, nil
+}
+
+// deleteFromKafka publishes a tombstone (null value) for the key as compensation,
+// published messages cannot be withdrawn but compacted topics drop the key
+func (fh *FileHandler) deleteFromKafka(relPath string, target config.OutputTarget) error {
+	return fh.publishKafka(context.Background(), target, kafka.Message{Key: []byte(filepath.ToSlash(relPath))})
+}
+
+// publishKafka writes a message within kafkaPublishTimeout, cancelled earlier once parent is done
+func (fh *FileHandler) publishKafka(parent context.Context, target config.OutputTarget, message kafka.Message) error {
+	ctx, cancel := context.WithTimeout(parent, kafkaPublishTimeout)
+	defer cancel()
+
+	if err := fh.kafkaProducer(target).WriteMessages(ctx, message); err != nil {
+		return fmt.Errorf("error publishing to Kafka: %w", err)
+	}
+	return nil
+}
+
+// kafkaProducer returns the producer of a target, producers are shared by all workers
+func (fh *FileHandler) kafkaProducer(target config.OutputTarget) kafkaProducer {
+	fh.kafkaMutex.Lock()
+	defer fh.kafkaMutex.Unlock()
+
+	if fh.kafkaProducers == nil {
+		fh.kafkaProducers = make(map[string]kafkaProducer)
+	}
+	producer, ok := fh.kafkaProducers[target.Path]
+	if !ok {
+		producer = newKafkaProducer(target.GetKafkaConfig(), fh.TLSConfig)
+		fh.kafkaProducers[target.Path] = producer
+	}
+	return producer
+}
+
+// CloseKafkaProducers flushes and closes all Kafka producers
+func (fh *FileHandler) CloseKafkaProducers() {
+	fh.kafkaMutex.Lock()
+	defer fh.kafkaMutex.Unlock()
+
+	for path, producer := range fh.kafkaProducers {
+		if err := producer.Close(); err != nil {
+			slog.Error("Error closing Kafka producer", "target", path, "error", err)
+		}
+	}
+	fh.kafkaProducers = nil
+}
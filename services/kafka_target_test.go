@@ -0,0 +1,249 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"file-shifter/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// mockKafkaProducer records published messages instead of sending them to brokers
+type mockKafkaProducer struct {
+	mu       sync.Mutex
+	messages []kafka.Message
+	closed   bool
+}
+
+func (p *mockKafkaProducer) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, msgs...)
+	return nil
+}
+
+func (p *mockKafkaProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+func stubKafkaProducer(t *testing.T) *mockKafkaProducer {
+	t.Helper()
+	producer := &mockKafkaProducer{}
+	original := newKafkaProducer
+	newKafkaProducer = func(config.KafkaConfig, *tls.Config) kafkaProducer { return producer }
+	t.Cleanup(func() { newKafkaProducer = original })
+	return producer
+}
+
+func kafkaHeader(message kafka.Message, key string) string {
+	for _, header := range message.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func writeKafkaSource(t *testing.T, content string) (inputDir, filePath string) {
+	t.Helper()
+	inputDir = t.TempDir()
+	filePath = filepath.Join(inputDir, "orders", "order-1.json")
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return inputDir, filePath
+}
+
+func TestFileHandler_KafkaTargetPublishesContent(t *testing.T) {
+	producer := stubKafkaProducer(t)
+	inputDir, filePath := writeKafkaSource(t, `{"order":1}`)
+
+	fh := NewFileHandler([]config.OutputTarget{{Type: "kafka", Path: "kafka://broker:9092/files"}}, nil)
+	if err := fh.ProcessFile(filePath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	if len(producer.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(producer.messages))
+	}
+	message := producer.messages[0]
+	if string(message.Key) != "orders/order-1.json" {
+		t.Errorf("key = %q, want orders/order-1.json", message.Key)
+	}
+	if string(message.Value) != `{"order":1}` {
+		t.Errorf("value = %q, want the file content", message.Value)
+	}
+	if got := kafkaHeader(message, kafkaMessageTypeHeader); got != kafkaMessageContent {
+		t.Errorf("type header = %q, want %q", got, kafkaMessageContent)
+	}
+	if kafkaHeader(message, checksumMetadataKey) == "" {
+		t.Error("expected a checksum header")
+	}
+}
+
+func TestFileHandler_KafkaTargetPublishesReference(t *testing.T) {
+	producer := stubKafkaProducer(t)
+	inputDir, filePath := writeKafkaSource(t, "a file larger than the inline limit")
+
+	target := config.OutputTarget{
+		Type:           "kafka",
+		Path:           "kafka://broker:9092/files",
+		MaxInlineBytes: 8,
+		ReferenceURL:   "s3://archive/incoming/",
+	}
+	fh := NewFileHandler([]config.OutputTarget{target}, nil)
+	if err := fh.ProcessFile(filePath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	if len(producer.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(producer.messages))
+	}
+	message := producer.messages[0]
+	if string(message.Key) != "orders/order-1.json" {
+		t.Errorf("key = %q, want orders/order-1.json", message.Key)
+	}
+	if got := kafkaHeader(message, kafkaMessageTypeHeader); got != kafkaMessageReference {
+		t.Errorf("type header = %q, want %q", got, kafkaMessageReference)
+	}
+	var reference kafkaReference
+	if err := json.Unmarshal(message.Value, &reference); err != nil {
+		t.Fatalf("value is not a reference document: %v", err)
+	}
+	if reference.URL != "s3://archive/incoming/orders/order-1.json" {
+		t.Errorf("url = %q", reference.URL)
+	}
+	if reference.Size != int64(len("a file larger than the inline limit")) || reference.Checksum != fmt.Sprintf("%x", sha256.Sum256([]byte("a file larger than the inline limit"))) {
+		t.Errorf("reference = %+v, want size and checksum", reference)
+	}
+}
+
+func TestFileHandler_KafkaTargetChecksumOfDecompressedContent(t *testing.T) {
+	producer := stubKafkaProducer(t)
+	inputDir := t.TempDir()
+	filePath := filepath.Join(inputDir, "order-1.json.gz")
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write([]byte(`{"order":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, compressed.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler([]config.OutputTarget{{Type: "kafka", Path: "kafka://broker:9092/files"}}, nil)
+	fh.Decompress = true
+	if err := fh.ProcessFile(filePath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	if len(producer.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(producer.messages))
+	}
+	message := producer.messages[0]
+	if string(message.Value) != `{"order":1}` {
+		t.Errorf("value = %q, want the decompressed content", message.Value)
+	}
+	if got := kafkaHeader(message, checksumMetadataKey); got != fmt.Sprintf("%x", sha256.Sum256([]byte(`{"order":1}`))) {
+		t.Errorf("checksum header = %q, want the checksum of the published content", got)
+	}
+}
+
+func TestKafkaBatchBytes(t *testing.T) {
+	if got := kafkaBatchBytes(config.DefaultKafkaMaxInlineBytes); got != kafkaMinBatchBytes {
+		t.Errorf("kafkaBatchBytes(default) = %d, want %d", got, kafkaMinBatchBytes)
+	}
+	if got := kafkaBatchBytes(4 * 1024 * 1024); got != 4*1024*1024+kafkaMessageOverhead {
+		t.Errorf("kafkaBatchBytes(4 MiB) = %d, want the limit plus %d bytes overhead", got, kafkaMessageOverhead)
+	}
+
+	writer, ok := newKafkaProducer(config.KafkaConfig{Brokers: []string{"broker:9092"}, Topic: "files", MaxInlineBytes: 4 * 1024 * 1024}, nil).(*kafka.Writer)
+	if !ok {
+		t.Fatal("expected a *kafka.Writer")
+	}
+	defer writer.Close()
+	if writer.BatchBytes != kafkaBatchBytes(4*1024*1024) || writer.BatchTimeout != kafkaBatchTimeout {
+		t.Errorf("writer BatchBytes = %d, BatchTimeout = %v", writer.BatchBytes, writer.BatchTimeout)
+	}
+}
+
+func TestFileHandler_KafkaTargetInlineWithinMemoryLimit(t *testing.T) {
+	producer := stubKafkaProducer(t)
+	inputDir, filePath := writeKafkaSource(t, "a file larger than the in-memory limit")
+
+	target := config.OutputTarget{
+		Type:           "kafka",
+		Path:           "kafka://broker:9092/files",
+		MaxInlineBytes: 1024,
+		ReferenceURL:   "s3://archive/incoming/",
+	}
+	fh := NewFileHandler([]config.OutputTarget{target}, nil)
+	fh.MaxInMemoryBytes = 8
+	if err := fh.ProcessFile(filePath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	if len(producer.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(producer.messages))
+	}
+	if got := kafkaHeader(producer.messages[0], kafkaMessageTypeHeader); got != kafkaMessageReference {
+		t.Errorf("type header = %q, want %q for a file above MaxInMemoryBytes", got, kafkaMessageReference)
+	}
+}
+
+func TestFileHandler_KafkaTargetTooLargeWithoutReference(t *testing.T) {
+	producer := stubKafkaProducer(t)
+	inputDir, filePath := writeKafkaSource(t, "a file larger than the inline limit")
+
+	fh := NewFileHandler([]config.OutputTarget{{Type: "kafka", Path: "kafka://broker:9092/files", MaxInlineBytes: 8}}, nil)
+	if err := fh.ProcessFile(filePath, inputDir); !errors.Is(err, ErrKafkaMessageTooLarge) {
+		t.Fatalf("ProcessFile() error = %v, want %v", err, ErrKafkaMessageTooLarge)
+	}
+	if len(producer.messages) != 0 {
+		t.Errorf("expected no message, got %d", len(producer.messages))
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		t.Errorf("source file should be kept: %v", err)
+	}
+}
+
+func TestFileHandler_KafkaCleanupPublishesTombstone(t *testing.T) {
+	producer := stubKafkaProducer(t)
+
+	fh := NewFileHandler([]config.OutputTarget{{Type: "kafka", Path: "kafka://broker:9092/files"}}, nil)
+	if err := fh.cleanupTargetFiles(fh.OutputTargets(), filepath.Join("orders", "order-1.json")); err != nil {
+		t.Fatalf("cleanupTargetFiles() error = %v", err)
+	}
+
+	if len(producer.messages) != 1 {
+		t.Fatalf("expected 1 tombstone, got %d messages", len(producer.messages))
+	}
+	if message := producer.messages[0]; string(message.Key) != "orders/order-1.json" || message.Value != nil {
+		t.Errorf("tombstone = key %q value %q, want key orders/order-1.json and nil value", message.Key, message.Value)
+	}
+
+	fh.CloseKafkaProducers()
+	if !producer.closed {
+		t.Error("producer should be closed")
+	}
+}
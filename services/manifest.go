@@ -0,0 +1,77 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	deliveryResultSuccess = "success"
+	deliveryResultFailed  = "failed"
+)
+
+// ManifestEntry describes a single delivery in the manifest
+type ManifestEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	RelPath   string    `json:"rel_path"`
+	Checksum  string    `json:"checksum"`
+	Size      int64     `json:"size"`
+	Targets   []string  `json:"targets"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// DeliveryManifest is an append-only JSONL audit trail of all deliveries.
+// Every entry is synced to disk before Record returns.
+type DeliveryManifest struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewDeliveryManifest opens (or creates) the manifest file in append mode
+func NewDeliveryManifest(path string) (*DeliveryManifest, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening manifest %s: %w", path, err)
+	}
+	return &DeliveryManifest{file: file}, nil
+}
+
+// Record appends an entry and flushes it to stable storage
+func (m *DeliveryManifest) Record(entry ManifestEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding manifest entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		return fmt.Errorf("manifest is closed")
+	}
+	if _, err := m.file.Write(line); err != nil {
+		return fmt.Errorf("error writing manifest entry: %w", err)
+	}
+	if err := m.file.Sync(); err != nil {
+		return fmt.Errorf("error syncing manifest: %w", err)
+	}
+	return nil
+}
+
+// Close closes the manifest file
+func (m *DeliveryManifest) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		return nil
+	}
+	err := m.file.Close()
+	m.file = nil
+	return err
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"file-shifter/config"
+)
+
+func TestFileHandler_ManifestRecordsDeliveries(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "manifest.jsonl")
+
+	manifest, err := NewDeliveryManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("NewDeliveryManifest() error = %v", err)
+	}
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	fh.Manifest = manifest
+
+	const fileCount = 8
+	expected := make(map[string]ManifestEntry)
+	for i := 0; i < fileCount; i++ {
+		relPath := fmt.Sprintf("file-%d.txt", i)
+		content := []byte(fmt.Sprintf("content of file %d", i))
+		filePath := filepath.Join(inputDir, relPath)
+		if err := os.WriteFile(filePath, content, 0644); err != nil {
+			t.Fatalf("failed to create input file: %v", err)
+		}
+		checksum, err := fh.calculateFileChecksum(filePath)
+		if err != nil {
+			t.Fatalf("failed to calculate checksum: %v", err)
+		}
+		expected[relPath] = ManifestEntry{RelPath: relPath, Checksum: checksum, Size: int64(len(content))}
+	}
+
+	// Process concurrently like the worker pool does
+	var wg sync.WaitGroup
+	for relPath := range expected {
+		wg.Add(1)
+		go func(relPath string) {
+			defer wg.Done()
+			if err := fh.ProcessFile(filepath.Join(inputDir, relPath), inputDir); err != nil {
+				t.Errorf("ProcessFile(%s) error = %v", relPath, err)
+			}
+		}(relPath)
+	}
+	wg.Wait()
+
+	if err := manifest.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	file, err := os.Open(manifestPath)
+	if err != nil {
+		t.Fatalf("failed to open manifest: %v", err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+		var entry ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid manifest line %q: %v", scanner.Text(), err)
+		}
+		want, ok := expected[entry.RelPath]
+		if !ok {
+			t.Errorf("unexpected manifest entry for %s", entry.RelPath)
+			continue
+		}
+		if entry.Checksum != want.Checksum {
+			t.Errorf("%s: checksum = %s, want %s", entry.RelPath, entry.Checksum, want.Checksum)
+		}
+		if entry.Size != want.Size {
+			t.Errorf("%s: size = %d, want %d", entry.RelPath, entry.Size, want.Size)
+		}
+		if entry.Result != deliveryResultSuccess {
+			t.Errorf("%s: result = %s, want %s", entry.RelPath, entry.Result, deliveryResultSuccess)
+		}
+		if len(entry.Targets) != 1 || entry.Targets[0] != outputDir {
+			t.Errorf("%s: targets = %v, want [%s]", entry.RelPath, entry.Targets, outputDir)
+		}
+		if entry.Timestamp.IsZero() {
+			t.Errorf("%s: expected timestamp to be set", entry.RelPath)
+		}
+	}
+	if lines != fileCount {
+		t.Fatalf("expected %d manifest lines, got %d", fileCount, lines)
+	}
+}
+
+func TestFileHandler_ManifestRecordsFailedDelivery(t *testing.T) {
+	inputDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "manifest.jsonl")
+	manifest, err := NewDeliveryManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("NewDeliveryManifest() error = %v", err)
+	}
+	defer manifest.Close()
+
+	filePath := filepath.Join(inputDir, "fail.txt")
+	if err := os.WriteFile(filePath, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: "/unused", Type: "unknown"}}, nil)
+	fh.Manifest = manifest
+	if err := fh.ProcessFile(filePath, inputDir); err == nil {
+		t.Fatal("expected ProcessFile to fail for unknown target type")
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var entry ManifestEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("invalid manifest content %q: %v", data, err)
+	}
+	if entry.Result != deliveryResultFailed || entry.Error == "" {
+		t.Errorf("expected failed entry with error, got %+v", entry)
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"file-shifter/config"
+)
+
+// metadataSidecarSuffix is appended to the relative path for sidecar documents
+const metadataSidecarSuffix = ".meta.json"
+
+// metadataHTTPTimeout limits a single metadata POST
+const metadataHTTPTimeout = 30 * time.Second
+
+// FileMetadata describes a delivered file without its contents
+type FileMetadata struct {
+	Name     string    `json:"name"`
+	RelPath  string    `json:"rel_path"`
+	Size     int64     `json:"size"`
+	Checksum string    `json:"checksum"`
+	ModTime  time.Time `json:"mtime"`
+}
+
+// isHTTPMetadataTarget reports whether metadata is posted to a URL instead of written as a sidecar
+func isHTTPMetadataTarget(target config.OutputTarget) bool {
+	return strings.HasPrefix(target.Path, "http://") || strings.HasPrefix(target.Path, "https://")
+}
+
+// copyToMetadata delivers a JSON metadata document for the file to an HTTP endpoint or a sidecar directory
+func (fh *FileHandler) copyToMetadata(srcPath, relPath string, target config.OutputTarget, fileInfo os.FileInfo) error {
+	checksum, err := fh.calculateFileChecksum(srcPath)
+	if err != nil {
+		return err
+	}
+
+	document, err := json.Marshal(FileMetadata{
+		Name:     fileInfo.Name(),
+		RelPath:  filepath.ToSlash(relPath),
+		Size:     fileInfo.Size(),
+		Checksum: checksum,
+		ModTime:  fileInfo.ModTime().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding metadata: %w", err)
+	}
+
+	if isHTTPMetadataTarget(target) {
+		return fh.postMetadata(fh.fileContext(srcPath), target.Path, document)
+	}
+	return writeMetadataSidecar(filepath.Join(target.Path, relPath+metadataSidecarSuffix), document)
+}
+
+// postMetadata sends the document to url, the request is cancelled once ctx is done (see file_timeout.go)
+func (fh *FileHandler) postMetadata(ctx context.Context, url string, document []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(document))
+	if err != nil {
+		return fmt.Errorf("error creating the metadata request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentTypeJSON)
+	resp, err := fh.metadataClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("metadata endpoint returned %s", resp.Status)
+	}
+
+	slog.Info("Metadata successfully posted", "url", url)
+	return nil
+}
+
+// metadataClient returns the HTTP client shared by all metadata targets, created with the TLS configuration
+// on first use so keep-alive connections are reused between files
+func (fh *FileHandler) metadataClient() *http.Client {
+	fh.metadataMutex.Lock()
+	defer fh.metadataMutex.Unlock()
+
+	if fh.metadataHTTPClient == nil {
+		fh.metadataHTTPClient = &http.Client{
+			Timeout:   metadataHTTPTimeout,
+			Transport: newHTTPTransport(fh.TLSConfig),
+		}
+	}
+	return fh.metadataHTTPClient
+}
+
+// CloseMetadataClient closes the idle connections of the metadata HTTP client
+func (fh *FileHandler) CloseMetadataClient() {
+	fh.metadataMutex.Lock()
+	defer fh.metadataMutex.Unlock()
+
+	if fh.metadataHTTPClient != nil {
+		fh.metadataHTTPClient.CloseIdleConnections()
+		fh.metadataHTTPClient = nil
+	}
+}
+
+func writeMetadataSidecar(sidecarPath string, document []byte) error {
+	if err := os.MkdirAll(filepath.Dir(sidecarPath), 0755); err != nil {
+		return fmt.Errorf("error creating the metadata directory: %w", err)
+	}
+	if err := os.WriteFile(sidecarPath, document, 0644); err != nil {
+		return fmt.Errorf("error writing metadata sidecar: %w", err)
+	}
+
+	slog.Info("Metadata sidecar written", "target", sidecarPath)
+	return nil
+}
+
+// deleteFromMetadata removes a sidecar document, posted documents cannot be withdrawn
+func (fh *FileHandler) deleteFromMetadata(relPath string, target config.OutputTarget) error {
+	if isHTTPMetadataTarget(target) {
+		slog.Warn("Metadata already posted cannot be withdrawn", "url", target.Path, "file", relPath)
+		return nil
+	}
+
+	sidecarPath := filepath.Join(target.Path, relPath+metadataSidecarSuffix)
+	if err := os.Remove(sidecarPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
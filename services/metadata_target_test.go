@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"file-shifter/config"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func writeMetadataTestFile(t *testing.T) (inputDir, filePath, checksum string) {
+	t.Helper()
+	inputDir = t.TempDir()
+	filePath = filepath.Join(inputDir, "sub", "report.csv")
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte("a,b,c\n1,2,3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler(nil, NewS3ClientManager())
+	checksum, err := fh.calculateFileChecksum(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inputDir, filePath, checksum
+}
+
+func assertMetadataDocument(t *testing.T, metadata FileMetadata, checksum string) {
+	t.Helper()
+	if metadata.Checksum != checksum {
+		t.Errorf("checksum = %q, want %q", metadata.Checksum, checksum)
+	}
+	if metadata.Size != 12 {
+		t.Errorf("size = %d, want 12", metadata.Size)
+	}
+	if metadata.RelPath != "sub/report.csv" || metadata.Name != "report.csv" {
+		t.Errorf("unexpected name/path: %+v", metadata)
+	}
+	if metadata.ModTime.IsZero() {
+		t.Error("mtime must be set")
+	}
+}
+
+func TestFileHandler_MetadataTargetSidecar(t *testing.T) {
+	inputDir, filePath, checksum := writeMetadataTestFile(t)
+	metadataDir := t.TempDir()
+	fh := NewFileHandler([]config.OutputTarget{{Path: metadataDir, Type: "metadata"}}, NewS3ClientManager())
+
+	if err := fh.ProcessFile(filePath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(metadataDir, "sub", "report.csv.meta.json"))
+	if err != nil {
+		t.Fatalf("sidecar not written: %v", err)
+	}
+	var metadata FileMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	assertMetadataDocument(t, metadata, checksum)
+
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Error("source file should be removed after a successful metadata delivery")
+	}
+
+	if err := fh.cleanupTargetFiles(fh.OutputTargets(), filepath.Join("sub", "report.csv")); err != nil {
+		t.Fatalf("cleanupTargetFiles() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(metadataDir, "sub", "report.csv.meta.json")); !os.IsNotExist(err) {
+		t.Error("cleanup should remove the sidecar")
+	}
+}
+
+func TestFileHandler_MetadataTargetHTTP(t *testing.T) {
+	inputDir, filePath, checksum := writeMetadataTestFile(t)
+
+	var received FileMetadata
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get(contentTypeHeader) != contentTypeJSON {
+			t.Errorf("unexpected request %s with content type %q", r.Method, r.Header.Get(contentTypeHeader))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("invalid metadata document: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: server.URL + "/catalog", Type: "metadata"}}, NewS3ClientManager())
+	if err := fh.ProcessFile(filePath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	assertMetadataDocument(t, received, checksum)
+}
+
+func TestFileHandler_MetadataTargetHTTPFailureKeepsSource(t *testing.T) {
+	inputDir, filePath, _ := writeMetadataTestFile(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: server.URL, Type: "metadata"}}, NewS3ClientManager())
+	if err := fh.ProcessFile(filePath, inputDir); err == nil {
+		t.Fatal("expected an error for a failing metadata endpoint")
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		t.Errorf("source file must be kept after a failed delivery: %v", err)
+	}
+}
+
+func TestFileHandler_MetadataTargetHTTPReusesConnections(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	fh := NewFileHandler(nil, nil)
+	defer fh.CloseMetadataClient()
+	for i := 0; i < 3; i++ {
+		if err := fh.postMetadata(context.Background(), server.URL, []byte(`{}`)); err != nil {
+			t.Fatalf("postMetadata() error = %v", err)
+		}
+	}
+
+	if got := connections.Load(); got != 1 {
+		t.Errorf("opened %d connections for 3 posts, want 1 kept alive", got)
+	}
+}
@@ -2,11 +2,17 @@ package services
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"io"
 	"log/slog"
+	"maps"
+	"net/http"
 	"path/filepath"
 	"strings"
 
+	"file-shifter/config"
+
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
@@ -20,10 +26,39 @@ type MinIO struct {
 }
 
 func NewMinIOConnection(endpoint, accessKey, secretKey string, useSSL bool) (*MinIO, error) {
-	minioClient, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+	return newMinIOConnection(endpoint, staticCredentials(config.S3SignatureV4, accessKey, secretKey), useSSL, minioTransportOptions{})
+}
+
+// staticCredentials returns the credentials signing requests with the given signature version ("v2" or "v4")
+func staticCredentials(signatureVersion, accessKey, secretKey string) *credentials.Credentials {
+	if signatureVersion == config.S3SignatureV2 {
+		return credentials.NewStaticV2(accessKey, secretKey, "")
+	}
+	return credentials.NewStaticV4(accessKey, secretKey, "")
+}
+
+// minioTransportOptions configures the HTTP transport of a client, zero values keep the minio-go defaults
+type minioTransportOptions struct {
+	tlsConfig           *tls.Config // nil = system defaults
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+}
+
+// newMinIOConnection creates a client whose transport uses transportOptions
+func newMinIOConnection(endpoint string, creds *credentials.Credentials, useSSL bool, transportOptions minioTransportOptions) (*MinIO, error) {
+	options := &minio.Options{
+		Creds:  creds,
 		Secure: useSSL,
-	})
+	}
+	if transportOptions != (minioTransportOptions{}) {
+		transport, err := newMinIOTransport(useSSL, transportOptions)
+		if err != nil {
+			return nil, err
+		}
+		options.Transport = transport
+	}
+
+	minioClient, err := minio.New(endpoint, options)
 	if err != nil {
 		return nil, err
 	}
@@ -32,59 +67,212 @@ func NewMinIOConnection(endpoint, accessKey, secretKey string, useSSL bool) (*Mi
 	return &MinIO{MinIOClient: minioClient}, nil
 }
 
+// newMinIOTransport returns the minio-go default transport adjusted by options
+func newMinIOTransport(useSSL bool, options minioTransportOptions) (*http.Transport, error) {
+	transport, err := minio.DefaultTransport(useSSL)
+	if err != nil {
+		return nil, err
+	}
+	if options.tlsConfig != nil {
+		transport.TLSClientConfig = options.tlsConfig.Clone()
+	}
+	if options.maxIdleConns > 0 {
+		transport.MaxIdleConns = options.maxIdleConns
+	}
+	if options.maxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = options.maxIdleConnsPerHost
+	}
+	return transport, nil
+}
+
 func (m *MinIO) EnsureBucket(bucketName string) error {
+	exists, err := m.BucketExists(bucketName)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return m.CreateBucket(bucketName)
+	}
+
+	return nil
+}
+
+func (m *MinIO) BucketExists(bucketName string) (bool, error) {
+	if m.MinIOClient == nil {
+		return false, errors.New(ErrMinIOClientNotInitialized)
+	}
+	return m.MinIOClient.BucketExists(context.Background(), bucketName)
+}
+
+func (m *MinIO) CreateBucket(bucketName string) error {
 	if m.MinIOClient == nil {
 		return errors.New(ErrMinIOClientNotInitialized)
 	}
 
+	if err := m.MinIOClient.MakeBucket(context.Background(), bucketName, minio.MakeBucketOptions{}); err != nil {
+		return err
+	}
+	slog.Info("Bucket erfolgreich erstellt", "bucket", bucketName)
+	return nil
+}
+
+func (m *MinIO) UploadFile(filePath, bucketName, fileName string) (string, error) {
+	return m.UploadFileWithOptions(filePath, bucketName, fileName, UploadOptions{})
+}
+
+// UploadOptions contains optional settings for a single upload
+type UploadOptions struct {
+	UserMetadata map[string]string // Stored as x-amz-meta-* headers
+	ACL          string            // Canned ACL sent as x-amz-acl, empty keeps the bucket default
+	// DisableContentTypeDetection always uploads as application/octet-stream
+	DisableContentTypeDetection bool
+	limiters                    []*rateLimiter  // Bandwidth limits, the upload is streamed through them if set
+	decompress                  bool            // Upload the gzip-decompressed content of the file
+	contentHash                 io.Writer       // Receives the streamed content, e.g. to checksum decompressed content
+	ctx                         context.Context // Cancels the upload, e.g. on the file timeout (nil = not cancelled)
+}
+
+func (m *MinIO) UploadFileWithOptions(filePath, bucketName, fileName string, opts UploadOptions) (string, error) {
+	if m.MinIOClient == nil {
+		return "", errors.New(ErrMinIOClientNotInitialized)
+	}
+
 	ctx := context.Background()
+	if opts.ctx != nil {
+		ctx = opts.ctx
+	}
 
-	exists, err := m.MinIOClient.BucketExists(ctx, bucketName)
+	var info minio.UploadInfo
+	var err error
+	if len(opts.limiters) > 0 || opts.decompress || opts.contentHash != nil {
+		info, err = m.putStream(ctx, filePath, bucketName, fileName, opts)
+	} else {
+		info, err = m.MinIOClient.FPutObject(ctx, bucketName, fileName, filePath, putObjectOptions(fileName, opts))
+	}
 	if err != nil {
-		return err
+		slog.Warn("Error uploading file", "file", fileName, "err", err)
+		return "", err
 	}
 
-	if !exists {
-		err = m.MinIOClient.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
-		if err != nil {
-			return err
-		}
-		slog.Info("Bucket erfolgreich erstellt", "bucket", bucketName)
+	slog.Info("File uploaded successfully", "file", fileName, "size", info.Size)
+	return fileName, nil
+}
+
+// putStream streams the file through the decompressor and bandwidth limiters of the upload options
+func (m *MinIO) putStream(ctx context.Context, filePath, bucketName, fileName string, opts UploadOptions) (minio.UploadInfo, error) {
+	reader, size, err := openSourceReader(filePath, opts.decompress)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	defer reader.Close()
+
+	if opts.contentHash != nil {
+		return m.uploadReader(ctx, io.TeeReader(reader, opts.contentHash), size, bucketName, fileName, opts)
+	}
+	return m.uploadReader(ctx, reader, size, bucketName, fileName, opts)
+}
+
+// ReplaceUserMetadata rewrites the metadata of an uploaded object with a server-side copy onto itself, e.g. to
+// add a checksum that is only known once the content has been uploaded. Content type and ACL are set again.
+func (m *MinIO) ReplaceUserMetadata(ctx context.Context, bucketName, fileName string, opts UploadOptions) error {
+	if m.MinIOClient == nil {
+		return errors.New(ErrMinIOClientNotInitialized)
 	}
 
+	putOpts := putObjectOptions(fileName, opts)
+	// The multipart copy of ComposeObject only sends the metadata map, minio-go sends standard headers in it as is
+	metadata := make(map[string]string, len(putOpts.UserMetadata)+1)
+	maps.Copy(metadata, putOpts.UserMetadata)
+	metadata["Content-Type"] = putOpts.ContentType
+	dst := minio.CopyDestOptions{
+		Bucket:          bucketName,
+		Object:          fileName,
+		ReplaceMetadata: true,
+		UserMetadata:    metadata,
+	}
+	// ComposeObject also copies objects above 5 GiB, CopyObject would reject them
+	if _, err := m.MinIOClient.ComposeObject(ctx, dst, minio.CopySrcOptions{Bucket: bucketName, Object: fileName}); err != nil {
+		slog.Warn("Error replacing object metadata", "file", fileName, "err", err)
+		return err
+	}
 	return nil
 }
 
-func (m *MinIO) UploadFile(filePath, bucketName, fileName string) (string, error) {
+// UploadReader uploads a stream, e.g. transformed content, without staging it in a local file.
+// size is the exact length of the stream or -1 if unknown. A stream of unknown size is uploaded as multipart
+// with parts of unknownSizePartSize (16 MiB), which bounds the memory of the upload to one part.
+func (m *MinIO) UploadReader(ctx context.Context, reader io.Reader, size int64, bucketName, fileName string, opts UploadOptions) (string, error) {
 	if m.MinIOClient == nil {
 		return "", errors.New(ErrMinIOClientNotInitialized)
 	}
 
-	ctx := context.Background()
+	info, err := m.uploadReader(ctx, reader, size, bucketName, fileName, opts)
+	if err != nil {
+		slog.Warn("Error uploading stream", "file", fileName, "err", err)
+		return "", err
+	}
+
+	slog.Info("Stream uploaded successfully", "file", fileName, "size", info.Size)
+	return fileName, nil
+}
+
+func (m *MinIO) uploadReader(ctx context.Context, reader io.Reader, size int64, bucketName, fileName string, opts UploadOptions) (minio.UploadInfo, error) {
+	return m.MinIOClient.PutObject(ctx, bucketName, fileName, throttleReader(reader, opts.limiters), size, streamPutObjectOptions(fileName, size, opts))
+}
+
+// unknownSizePartSize is the part size of streams without a known length. minio-go otherwise sizes the parts for
+// the largest possible object and buffers about 537 MiB per upload.
+const unknownSizePartSize = 16 * 1024 * 1024
+
+// streamPutObjectOptions builds the put options of a stream, with a fixed part size if its length is unknown
+func streamPutObjectOptions(fileName string, size int64, opts UploadOptions) minio.PutObjectOptions {
+	putOpts := putObjectOptions(fileName, opts)
+	if size < 0 {
+		putOpts.PartSize = unknownSizePartSize
+	}
+	return putOpts
+}
+
+// defaultContentType is used for unknown extensions and if the detection is disabled
+const defaultContentType = "application/octet-stream"
 
-	// Determine content type based on file extension
-	contentType := "application/octet-stream"
-	ext := filepath.Ext(fileName)
-	switch ext {
+// detectContentType determines the content type based on the file extension
+func detectContentType(fileName string) string {
+	switch filepath.Ext(fileName) {
 	case ".txt":
-		contentType = "text/plain"
+		return "text/plain"
 	case ".json":
-		contentType = "application/json"
+		return "application/json"
 	case ".pdf":
-		contentType = "application/pdf"
+		return "application/pdf"
 	default:
-		contentType = "application/octet-stream"
+		return defaultContentType
 	}
+}
 
-	info, err := m.MinIOClient.FPutObject(ctx, bucketName, fileName, filePath,
-		minio.PutObjectOptions{ContentType: contentType})
-	if err != nil {
-		slog.Warn("Error uploading file", "file", fileName, "err", err)
-		return "", err
+// s3ACLHeader carries the canned ACL of an uploaded object
+const s3ACLHeader = "x-amz-acl"
+
+// putObjectOptions builds the MinIO put options for an object
+func putObjectOptions(fileName string, opts UploadOptions) minio.PutObjectOptions {
+	contentType := defaultContentType
+	if !opts.DisableContentTypeDetection {
+		contentType = detectContentType(fileName)
 	}
 
-	slog.Info("File uploaded successfully", "file", fileName, "size", info.Size)
-	return fileName, nil
+	userMetadata := opts.UserMetadata
+	if opts.ACL != "" {
+		// minio-go sends x-amz-acl from the user metadata as a plain header
+		userMetadata = make(map[string]string, len(opts.UserMetadata)+1)
+		maps.Copy(userMetadata, opts.UserMetadata)
+		userMetadata[s3ACLHeader] = opts.ACL
+	}
+
+	return minio.PutObjectOptions{
+		ContentType:  contentType,
+		UserMetadata: userMetadata,
+	}
 }
 
 func (m *MinIO) ObjectExists(bucket, key string) (bool, error) {
@@ -65,6 +65,24 @@ func TestNewMinIOConnection(t *testing.T) { // NOSONAR - matrixartiger Konfigura
 	}
 }
 
+func TestNewMinIOTransport(t *testing.T) {
+	transport, err := newMinIOTransport(false, minioTransportOptions{maxIdleConns: 512, maxIdleConnsPerHost: 64})
+	if err != nil {
+		t.Fatalf("newMinIOTransport() error = %v", err)
+	}
+	if transport.MaxIdleConns != 512 || transport.MaxIdleConnsPerHost != 64 {
+		t.Errorf("pool = %d/%d, want 512/64", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+
+	defaults, err := newMinIOTransport(false, minioTransportOptions{})
+	if err != nil {
+		t.Fatalf("newMinIOTransport() error = %v", err)
+	}
+	if defaults.MaxIdleConns != 256 || defaults.MaxIdleConnsPerHost != 16 {
+		t.Errorf("default pool = %d/%d, want the minio-go defaults 256/16", defaults.MaxIdleConns, defaults.MaxIdleConnsPerHost)
+	}
+}
+
 func TestMinIO_SanitizeBucketName(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -271,3 +289,63 @@ func TestMinIO_EnsureBucket(t *testing.T) { // NOSONAR - umfangreiche Fehler-/Er
 		})
 	}
 }
+
+func TestPutObjectOptions(t *testing.T) {
+	metadata := map[string]string{"source-system": "erp"}
+
+	opts := putObjectOptions("folder/data.json", UploadOptions{UserMetadata: metadata})
+
+	if opts.ContentType != "application/json" {
+		t.Errorf("ContentType = %q, want application/json", opts.ContentType)
+	}
+	if opts.UserMetadata["source-system"] != "erp" {
+		t.Errorf("UserMetadata = %v, want the configured metadata", opts.UserMetadata)
+	}
+	if opts := putObjectOptions("blob.bin", UploadOptions{}); opts.UserMetadata != nil {
+		t.Errorf("UserMetadata = %v, want nil without configuration", opts.UserMetadata)
+	}
+}
+
+func TestPutObjectOptions_DisableContentTypeDetection(t *testing.T) {
+	for _, fileName := range []string{"report.pdf", "data.json", "notes.txt", "blob.bin"} {
+		opts := putObjectOptions(fileName, UploadOptions{DisableContentTypeDetection: true})
+		if opts.ContentType != "application/octet-stream" {
+			t.Errorf("ContentType of %s = %q, want application/octet-stream", fileName, opts.ContentType)
+		}
+	}
+
+	if opts := putObjectOptions("report.pdf", UploadOptions{}); opts.ContentType != "application/pdf" {
+		t.Errorf("ContentType = %q, want application/pdf with detection enabled", opts.ContentType)
+	}
+}
+
+func TestStreamPutObjectOptions_PartSize(t *testing.T) {
+	if got := streamPutObjectOptions("data.json", -1, UploadOptions{}).PartSize; got != unknownSizePartSize {
+		t.Errorf("PartSize = %d, want %d for a stream of unknown size", got, unknownSizePartSize)
+	}
+	if got := streamPutObjectOptions("data.json", 1024, UploadOptions{}).PartSize; got != 0 {
+		t.Errorf("PartSize = %d, want 0 (minio-go default) for a stream of known size", got)
+	}
+	if got := streamPutObjectOptions("data.json", -1, UploadOptions{}).ContentType; got != "application/json" {
+		t.Errorf("ContentType = %q, want application/json", got)
+	}
+}
+
+func TestPutObjectOptions_ACL(t *testing.T) {
+	metadata := map[string]string{"source-system": "erp"}
+
+	header := putObjectOptions("report.pdf", UploadOptions{UserMetadata: metadata, ACL: "public-read"}).Header()
+
+	if got := header.Get("X-Amz-Acl"); got != "public-read" {
+		t.Errorf("x-amz-acl = %q, want public-read", got)
+	}
+	if got := header.Get("X-Amz-Meta-Source-System"); got != "erp" {
+		t.Errorf("x-amz-meta-source-system = %q, want erp", got)
+	}
+	if _, ok := metadata[s3ACLHeader]; ok {
+		t.Error("the ACL must not be added to the target's metadata map")
+	}
+	if got := putObjectOptions("report.pdf", UploadOptions{}).Header().Get("X-Amz-Acl"); got != "" {
+		t.Errorf("x-amz-acl = %q, want none without configuration", got)
+	}
+}
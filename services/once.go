@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrEmptyInput fails a single run with RequireNonEmpty if the input directory holds no file to process
+var ErrEmptyInput = errors.New("no files found in the input directory")
+
+// ErrDeliveryFailed fails a single run in which at least one file could not be delivered
+var ErrDeliveryFailed = errors.New("file delivery failed")
+
+// RunOnce processes the files present in the input directory without watching it. It returns the number of
+// files detected once all of them have been processed, or early if the watcher is stopped.
+func (fw *FileWatcher) RunOnce() int64 {
+	if !fw.addProducer() {
+		return 0
+	}
+	slog.Info("Processing the files present in the input directory once", "directory", fw.inputDir)
+
+	fw.startWorkers()
+	scanDone := make(chan struct{})
+	fw.startInitialScanWorkers(scanDone)
+
+	fw.scanning.Store(true)
+	fw.initialScan.Store(true)
+	fw.processExistingFiles()
+	fw.producersWG.Done()
+	fw.initialScan.Store(false)
+	fw.scanning.Store(false)
+	close(scanDone)
+
+	if !fw.waitUntilDrained() || !fw.flushBatchesOnce() {
+		return fw.detectedFiles.Load()
+	}
+	if fw.successMarker != "" {
+		fw.completeBatch()
+	}
+	return fw.detectedFiles.Load()
+}
+
+// flushBatchesOnce uploads all batches of a single run and counts the files of failed uploads as failed
+// deliveries. Files that changed while batched are processed again. It returns false if the watcher is stopped.
+func (fw *FileWatcher) flushBatchesOnce() bool {
+	if fw.fileHandler == nil {
+		return true
+	}
+	for {
+		changed, failed := fw.fileHandler.FlushBatches(true)
+		if len(changed) == 0 {
+			// Files of failed uploads stay batched and are retried with changed files, only the last result counts
+			fw.failedDeliveries.Add(int64(failed))
+			return true
+		}
+		for _, filePath := range changed {
+			fw.processFile(filePath)
+		}
+		if !fw.waitUntilDrained() {
+			return false
+		}
+	}
+}
+
+// waitUntilDrained waits until no file is queued or being processed. It returns false if the watcher is
+// stopped in the meantime.
+func (fw *FileWatcher) waitUntilDrained() bool {
+	ticker := time.NewTicker(successMarkerPollInterval)
+	defer ticker.Stop()
+	for fw.inFlightCount() > 0 {
+		select {
+		case <-fw.stopChan:
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// runOnce processes the files present in the input directory and stops the worker afterwards
+func (w *Worker) runOnce() {
+	detected := w.FileWatcher.RunOnce()
+	slog.Info("Single run finished", "files", detected)
+	failed := w.FileWatcher.failedDeliveries.Load()
+	switch {
+	case failed > 0:
+		w.onceErr = fmt.Errorf("%w: %d of %d files", ErrDeliveryFailed, failed, detected)
+	case detected == 0 && w.RequireNonEmpty && !w.FileWatcher.stopping.Load():
+		w.onceErr = fmt.Errorf("%w: %s", ErrEmptyInput, w.InputDir)
+	}
+	w.Stop()
+}
+
+// OnceError returns why a single run (Once) failed, nil if it succeeded or the worker watches the input.
+// It is only valid after Start has returned.
+func (w *Worker) OnceError() error {
+	return w.onceErr
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWorker_RunOnce(t *testing.T) {
+	tests := []struct {
+		name            string
+		files           []string
+		requireNonEmpty bool
+		wantErr         error
+	}{
+		{name: "empty input required", requireNonEmpty: true, wantErr: ErrEmptyInput},
+		{name: "only hidden files required", files: []string{".partial"}, requireNonEmpty: true, wantErr: ErrEmptyInput},
+		{name: "non-empty input required", files: []string{"a.txt", "sub/b.txt"}, requireNonEmpty: true},
+		{name: "empty input allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputDir, targetDir := t.TempDir(), t.TempDir()
+			for _, name := range tt.files {
+				path := filepath.Join(inputDir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg := createDefaultConfig()
+			cfg.Once = true
+			cfg.InputOptions.RequireNonEmpty = tt.requireNonEmpty
+			cfg.FileStability.CheckInterval = 5
+			cfg.FileStability.StabilityPeriod = 10
+			worker, err := NewWorker(inputDir, createFilesystemTargets(targetDir), cfg)
+			if err != nil {
+				t.Fatalf("NewWorker failed: %v", err)
+			}
+			worker.FileWatcher.lsofAvailable = false
+
+			done := make(chan struct{})
+			go func() {
+				worker.Start()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("a single run should stop the worker on its own")
+			}
+
+			if err := worker.OnceError(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OnceError() = %v, want %v", err, tt.wantErr)
+			}
+			for _, name := range tt.files {
+				if name[0] == '.' {
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(targetDir, filepath.FromSlash(name))); err != nil {
+					t.Errorf("%s not delivered by the single run: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWorker_RunOnceDeliveryFailed(t *testing.T) {
+	inputDir, targetDir := t.TempDir(), t.TempDir()
+	srcFile := filepath.Join(inputDir, "a.txt")
+	if err := os.WriteFile(srcFile, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := createDefaultConfig()
+	cfg.Once = true
+	cfg.FileStability.CheckInterval = 5
+	cfg.FileStability.StabilityPeriod = 10
+	worker, err := NewWorker(inputDir, createFilesystemTargets(targetDir), cfg)
+	if err != nil {
+		t.Fatalf("NewWorker failed: %v", err)
+	}
+	worker.FileWatcher.lsofAvailable = false
+
+	// The target directory is replaced by a file, so every delivery fails
+	if err := os.Remove(targetDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(targetDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		worker.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("a single run should stop the worker on its own")
+	}
+
+	if err := worker.OnceError(); !errors.Is(err, ErrDeliveryFailed) {
+		t.Fatalf("OnceError() = %v, want %v", err, ErrDeliveryFailed)
+	}
+	if _, err := os.Stat(srcFile); err != nil {
+		t.Errorf("the undelivered file should stay in the input directory: %v", err)
+	}
+}
+
+func TestFileWatcher_RunOnceBatchUploadFailed(t *testing.T) {
+	fh, _, ts := newBatchTestHandler(t)
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "a.csv")
+	if err := os.WriteFile(srcFile, []byte("alpha"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fw, err := NewFileWatcher(inputDir, fh, 5, 5*time.Millisecond, 10*time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	fw.lsofAvailable = false
+	defer fw.Stop()
+
+	// Files are only batched while processing, the upload at the end of the run fails
+	ts.Close()
+	if detected := fw.RunOnce(); detected != 1 {
+		t.Fatalf("RunOnce() = %d, want 1", detected)
+	}
+
+	if failed := fw.failedDeliveries.Load(); failed != 1 {
+		t.Errorf("failed deliveries = %d, want 1 for the file of the failed batch upload", failed)
+	}
+	if _, err := os.Stat(srcFile); err != nil {
+		t.Errorf("the file of the failed batch should stay in the input directory: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package services
+
+import "log/slog"
+
+// openFileHolder is a process holding a file open
+type openFileHolder struct {
+	pid         string
+	processName string
+}
+
+// isFileOpenInProc is the lsof fallback on Linux: it checks /proc/<pid>/fd for relevant processes
+// holding the file open. Own, harmless and configured processes are treated like in the lsof check.
+func (fw *FileWatcher) isFileOpenInProc(filePath string) bool {
+	holders, err := procOpenFileHolders(filePath)
+	if err != nil {
+		slog.Debug("Error scanning /proc for open files", "file", filePath, "error", err)
+		return false
+	}
+
+	for _, holder := range holders {
+		if fw.isRelevantHolder(filePath, holder.processName, holder.pid) {
+			return true
+		}
+	}
+	return false
+}
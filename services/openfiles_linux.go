@@ -0,0 +1,65 @@
+//go:build linux
+
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// procRoot is the procfs mount point
+const procRoot = "/proc"
+
+// procFDAvailable reports whether open files can be listed via /proc/<pid>/fd
+func procFDAvailable() bool {
+	_, err := os.ReadDir(filepath.Join(procRoot, "self", "fd"))
+	return err == nil
+}
+
+// procOpenFileHolders scans /proc/<pid>/fd of all processes for descriptors referring to filePath.
+// Processes whose descriptors cannot be read (other users without privileges) are skipped.
+func procOpenFileHolders(filePath string) ([]openFileHolder, error) {
+	target, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		target = resolved
+	}
+
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var holders []openFileHolder
+	for _, entry := range entries {
+		pid := entry.Name()
+		if !entry.IsDir() || strings.Trim(pid, "0123456789") != "" {
+			continue
+		}
+
+		fdDir := filepath.Join(procRoot, pid, "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if link, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && link == target {
+				holders = append(holders, openFileHolder{pid: pid, processName: procProcessName(pid)})
+				break
+			}
+		}
+	}
+	return holders, nil
+}
+
+// procProcessName returns the command name of a process, empty if it cannot be read
+func procProcessName(pid string) string {
+	comm, err := os.ReadFile(filepath.Join(procRoot, pid, "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
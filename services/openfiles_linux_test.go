@@ -0,0 +1,79 @@
+//go:build linux
+
+package services
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestProcOpenFileHolders_OwnProcess(t *testing.T) {
+	if !procFDAvailable() {
+		t.Skip("/proc is not available")
+	}
+
+	filePath := filepath.Join(t.TempDir(), "held.txt")
+	file, err := os.Create(filePath)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	holders, err := procOpenFileHolders(filePath)
+	if err != nil {
+		t.Fatalf("procOpenFileHolders() error = %v", err)
+	}
+	ownPID := strconv.Itoa(os.Getpid())
+	if !slices.ContainsFunc(holders, func(h openFileHolder) bool { return h.pid == ownPID }) {
+		t.Fatalf("expected own process %s among holders, got %+v", ownPID, holders)
+	}
+
+	file.Close()
+	holders, err = procOpenFileHolders(filePath)
+	if err != nil {
+		t.Fatalf("procOpenFileHolders() error = %v", err)
+	}
+	if slices.ContainsFunc(holders, func(h openFileHolder) bool { return h.pid == ownPID }) {
+		t.Fatalf("expected no holder after closing the file, got %+v", holders)
+	}
+}
+
+func TestFileWatcher_IsFileOpenInProc(t *testing.T) {
+	if !procFDAvailable() {
+		t.Skip("/proc is not available")
+	}
+	sleepPath, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not available")
+	}
+
+	filePath := filepath.Join(t.TempDir(), "held.txt")
+	file, err := os.Create(filePath)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	defer file.Close()
+
+	fw := &FileWatcher{procAvailable: true}
+	if fw.isFileOpenInProc(filePath) {
+		t.Fatal("a file only held by the own process must not count as open")
+	}
+
+	// Another process holding the file open as its stdin
+	cmd := exec.Command(sleepPath, "30")
+	cmd.Stdin = file
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start holder process: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	if !fw.isFileOpenInProc(filePath) {
+		t.Fatal("expected the file held by another process to be detected via /proc")
+	}
+}
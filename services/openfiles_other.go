@@ -0,0 +1,13 @@
+//go:build !linux
+
+package services
+
+import "errors"
+
+func procFDAvailable() bool {
+	return false
+}
+
+func procOpenFileHolders(string) ([]openFileHolder, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// Limits of common Unix filesystems. Go adds the \\?\ prefix for long paths on Windows itself.
+const (
+	maxPathComponentLength = 255  // NAME_MAX, bytes per file or directory name
+	maxPathLength          = 4096 // PATH_MAX, bytes of the whole path
+)
+
+// errPathTooLong marks transfers that failed because the target path exceeds a length limit
+var errPathTooLong = errors.New("target path too long")
+
+// checkPathLength rejects paths exceeding the common limits. SFTP servers only report a generic
+// failure for them, so remote paths are checked before connecting.
+func checkPathLength(path string) error {
+	if len(path) > maxPathLength {
+		return fmt.Errorf("%w: %d bytes exceed the limit of %d bytes - shorten the directory structure "+
+			"or remove leading directories with strip_prefix: %s", errPathTooLong, len(path), maxPathLength, path)
+	}
+	for _, name := range strings.Split(path, "/") {
+		if len(name) > maxPathComponentLength {
+			return fmt.Errorf("%w: the name %q has %d bytes, the limit is %d bytes - rename the file or directory",
+				errPathTooLong, name, len(name), maxPathComponentLength)
+		}
+	}
+	return nil
+}
+
+// pathLengthError replaces the cryptic ENAMETOOLONG of the operating system by an actionable error
+func pathLengthError(path string, err error) error {
+	if !errors.Is(err, syscall.ENAMETOOLONG) {
+		return err
+	}
+	if checkErr := checkPathLength(path); checkErr != nil {
+		return fmt.Errorf("%w (%w)", checkErr, err)
+	}
+	// Some filesystems (e.g. eCryptfs or SMB mounts) have lower limits
+	return fmt.Errorf("%w for the target filesystem - shorten the directory structure or file name: %s (%w)",
+		errPathTooLong, path, err)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"file-shifter/config"
+)
+
+func TestFileHandler_CopyToFilesystemPathTooLong(t *testing.T) {
+	srcFile := filepath.Join(t.TempDir(), "data.txt")
+	if err := os.WriteFile(srcFile, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatalf("failed to stat source file: %v", err)
+	}
+
+	// 20 directories of 250 bytes each exceed PATH_MAX, a single 300 byte name exceeds NAME_MAX
+	var nested []string
+	for range 20 {
+		nested = append(nested, strings.Repeat("d", 250))
+	}
+	tests := []struct {
+		name    string
+		relPath string
+		want    string
+	}{
+		{name: "deeply nested", relPath: filepath.Join(append(nested, "data.txt")...), want: "bytes exceed the limit"},
+		{name: "long file name", relPath: strings.Repeat("f", 300) + ".txt", want: "rename the file or directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fh := NewFileHandler(nil, nil)
+			target := config.OutputTarget{Path: t.TempDir(), Type: "filesystem"}
+
+			err := fh.copyToTarget(srcFile, tt.relPath, target, fileInfo)
+			if err == nil {
+				t.Fatal("copyToTarget() should fail for a path exceeding the limits")
+			}
+			if !errors.Is(err, errPathTooLong) || !errors.Is(err, syscall.ENAMETOOLONG) {
+				t.Errorf("error should be errPathTooLong wrapping ENAMETOOLONG: %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPathLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "short path", path: "/upload/2025/report.csv"},
+		{name: "name at the limit", path: "/upload/" + strings.Repeat("n", maxPathComponentLength)},
+		{name: "name over the limit", path: "/upload/" + strings.Repeat("n", maxPathComponentLength+1), wantErr: true},
+		{name: "path over the limit", path: strings.Repeat("/"+strings.Repeat("d", 200), 21), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPathLength(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkPathLength() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errPathTooLong) {
+				t.Errorf("error should wrap errPathTooLong: %v", err)
+			}
+		})
+	}
+}
+
+func TestPathLengthError_OtherErrorsUnchanged(t *testing.T) {
+	err := os.ErrPermission
+	if got := pathLengthError("/target/file", err); got != err {
+		t.Errorf("pathLengthError() = %v, want the original error", got)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pauseLockPollInterval is how often paused workers check whether the pause lock file has been removed
+var pauseLockPollInterval = time.Second
+
+// isPauseLock reports whether path is the pause lock file in the input root, it is never transferred
+func (fw *FileWatcher) isPauseLock(path string) bool {
+	return fw.pauseLockFile != "" && path == filepath.Join(fw.inputDir, fw.pauseLockFile)
+}
+
+func (fw *FileWatcher) pauseLockPresent() bool {
+	if fw.pauseLockFile == "" {
+		return false
+	}
+	_, err := os.Lstat(filepath.Join(fw.inputDir, fw.pauseLockFile))
+	return err == nil
+}
+
+// Paused reports whether processing is paused by the lock file
+func (fw *FileWatcher) Paused() bool {
+	return fw.paused.Load()
+}
+
+// waitWhilePaused holds a worker before it processes its next file as long as the pause lock file exists.
+// It returns false if the watcher is stopped in the meantime.
+func (fw *FileWatcher) waitWhilePaused() bool {
+	if !fw.pauseLockPresent() {
+		fw.resume()
+		return true
+	}
+	if fw.paused.CompareAndSwap(false, true) {
+		slog.Warn("Pause lock file present - processing paused until it is removed",
+			"lock", filepath.Join(fw.inputDir, fw.pauseLockFile))
+	}
+
+	ticker := time.NewTicker(pauseLockPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fw.stopChan:
+			return false
+		case <-ticker.C:
+		}
+		if !fw.pauseLockPresent() {
+			fw.resume()
+			return true
+		}
+	}
+}
+
+func (fw *FileWatcher) resume() {
+	if fw.paused.CompareAndSwap(true, false) {
+		slog.Info("Pause lock file removed - processing resumed")
+	}
+}
+
+// processUnlessPaused processes a dequeued file once the pause is over. A file taken from the queue
+// just before the lock file appeared waits as well; if the watcher stops meanwhile it is left in place.
+func (fw *FileWatcher) processUnlessPaused(filePath string) {
+	if !fw.waitWhilePaused() {
+		fw.untrackQueued(filePath)
+		fw.releaseQueueBytes(filePath)
+		fw.unmarkFileForProcessing(filePath)
+		return
+	}
+	fw.processQueuedFile(filePath)
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileWatcher_PauseLockFile(t *testing.T) {
+	original := pauseLockPollInterval
+	pauseLockPollInterval = 10 * time.Millisecond
+	defer func() { pauseLockPollInterval = original }()
+
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	fw, err := NewFileWatcher(inputDir, fileHandler, 5, 10*time.Millisecond, 20*time.Millisecond, 2, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	fw.lsofAvailable = false
+	fw.pauseLockFile = "PAUSE"
+
+	go func() {
+		if err := fw.Start(); err != nil {
+			t.Logf("FileWatcher stopped with error: %v", err)
+		}
+	}()
+	defer fw.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	delivered := func(name string) bool {
+		_, err := os.Stat(filepath.Join(outputDir, name))
+		return err == nil
+	}
+
+	if err := os.WriteFile(filepath.Join(inputDir, "before.txt"), []byte("before"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+	waitFor("the delivery before the pause", func() bool { return delivered("before.txt") })
+
+	lockFile := filepath.Join(inputDir, "PAUSE")
+	if err := os.WriteFile(lockFile, nil, 0644); err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "during.txt"), []byte("during"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if delivered("during.txt") {
+		t.Fatal("no file may be processed while the lock file exists")
+	}
+	if !fw.Paused() {
+		t.Error("Paused() = false while the lock file exists")
+	}
+	if delivered("PAUSE") {
+		t.Error("the lock file must not be transferred")
+	}
+
+	if err := os.Remove(lockFile); err != nil {
+		t.Fatalf("failed to remove lock file: %v", err)
+	}
+	waitFor("the delivery after the pause", func() bool { return delivered("during.txt") })
+	if fw.Paused() {
+		t.Error("Paused() = true after the lock file was removed")
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"file-shifter/config"
+
+	"github.com/pkg/sftp"
+)
+
+// PrecreateTargetDirs creates the PrecreateDirs of every target at startup, so consumers find the
+// directory trees before the first file is delivered
+func (fh *FileHandler) PrecreateTargetDirs() error {
+	for _, target := range fh.OutputTargets() {
+		if len(target.PrecreateDirs) == 0 {
+			continue
+		}
+		if fh.DryRun {
+			slog.Info("Dry run - directories would be pre-created", "target", target.Path, "dirs", target.PrecreateDirs)
+			continue
+		}
+
+		if err := fh.precreateDirs(target); err != nil {
+			return fmt.Errorf("error pre-creating directories on target %s: %w", target.Path, err)
+		}
+		slog.Info("Directories pre-created", "target", target.Path, "dirs", target.PrecreateDirs)
+	}
+	return nil
+}
+
+func (fh *FileHandler) precreateDirs(target config.OutputTarget) error {
+	switch target.Type {
+	case "filesystem":
+		for _, dir := range target.PrecreateDirs {
+			if err := fh.prepareTargetDir(filepath.Join(target.Path, filepath.FromSlash(dir))); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "sftp":
+		return fh.precreateSFTPDirs(target)
+	case "ftp":
+		return fh.precreateFTPDirs(target)
+	case "s3":
+		return fh.precreateS3Prefixes(target)
+	default:
+		// Rejected by the configuration validation, the target has no directories
+		return fmt.Errorf("precreate-dirs is not supported for %s targets", target.Type)
+	}
+}
+
+func (fh *FileHandler) precreateSFTPDirs(target config.OutputTarget) error {
+	host, _, err := parseRemotePath(target.Path, "", "22")
+	if err != nil {
+		return fmt.Errorf("error parsing the SFTP path: %w", err)
+	}
+	address, err := fh.checkAllowedHost(host)
+	if err != nil {
+		return err
+	}
+
+	conn, err := dialSSH(context.Background(), address, host, createSSHConfig(target.GetFTPConfig()))
+	if err != nil {
+		return fmt.Errorf("SSH connection failed: %w", err)
+	}
+	defer conn.Close()
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return fmt.Errorf("SFTP client creation failed: %w", err)
+	}
+	defer client.Close()
+
+	for _, dir := range target.PrecreateDirs {
+		_, remoteDir, _ := parseRemotePath(target.Path, filepath.FromSlash(dir), "22")
+		if err := client.MkdirAll(remoteDir); err != nil {
+			return fmt.Errorf("error creating the remote directory %s: %w", remoteDir, err)
+		}
+	}
+	return nil
+}
+
+func (fh *FileHandler) precreateFTPDirs(target config.OutputTarget) error {
+	host, _, err := parseRemotePath(target.Path, "", "21")
+	if err != nil {
+		return fmt.Errorf("error parsing the FTP path: %w", err)
+	}
+	address, err := fh.checkAllowedHost(host)
+	if err != nil {
+		return err
+	}
+
+	ftpConfig := target.GetFTPConfig()
+	client, err := connectAndLoginFTP(context.Background(), address, ftpConfig)
+	if err != nil {
+		return err
+	}
+	defer client.Quit()
+
+	for _, dir := range target.PrecreateDirs {
+		_, remoteDir, _ := parseRemotePath(target.Path, filepath.FromSlash(dir), "21")
+		makeFTPDirs(client, remoteDir, ftpConfig.PathSeparator)
+	}
+	return nil
+}
+
+// precreateS3Prefixes uploads an empty "<prefix>/" object per directory, which S3 browsers show as a folder
+func (fh *FileHandler) precreateS3Prefixes(target config.OutputTarget) error {
+	if fh.S3ClientManager == nil {
+		return fmt.Errorf("s3ClientManager not initialised")
+	}
+	minioClient, err := fh.S3ClientManager.GetOrCreateClient(target.GetS3Config())
+	if err != nil {
+		return fmt.Errorf("error getting the S3 client: %w", err)
+	}
+
+	for _, dir := range target.PrecreateDirs {
+		s3Path, err := parseS3Path(target.Path, filepath.FromSlash(dir))
+		if err != nil {
+			return fmt.Errorf("error parsing the S3 path: %w", err)
+		}
+		bucketName := minioClient.SanitizeBucketName(s3Path.bucketName)
+		if err := fh.ensureS3Bucket(minioClient, bucketName, target); err != nil {
+			return err
+		}
+		if _, err := minioClient.UploadReader(context.Background(), bytes.NewReader(nil), 0, bucketName, s3Path.objectKey+"/", UploadOptions{}); err != nil {
+			return fmt.Errorf("error creating the prefix marker %s: %w", s3Path.objectKey+"/", err)
+		}
+	}
+	return nil
+}
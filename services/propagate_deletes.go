@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// noteSourceRemoval marks a delivered source file before file-shifter removes it itself.
+// Its remove event is then not mirrored, the file has just been delivered to the targets.
+func (fh *FileHandler) noteSourceRemoval(filePath string) {
+	if fh.PropagateDeletes {
+		fh.removedSources.Store(filePath, struct{}{})
+	}
+}
+
+// forgetSourceRemoval drops the mark of a source file that could not be removed
+func (fh *FileHandler) forgetSourceRemoval(filePath string) {
+	fh.removedSources.Delete(filePath)
+}
+
+// PropagateDelete removes a file deleted from the input directory from all targets.
+// Files removed by file-shifter after their delivery are skipped.
+func (fh *FileHandler) PropagateDelete(filePath, inputDir string) error {
+	if _, removedByUs := fh.removedSources.LoadAndDelete(filePath); removedByUs {
+		return nil
+	}
+
+	relPath, err := filepath.Rel(inputDir, filePath)
+	if err != nil {
+		return fmt.Errorf("error determining relative path: %w", err)
+	}
+	relPath = fh.deliveryPath(relPath)
+
+	// A file waiting for a scheduled target is not delivered anymore
+	fh.completeDeferredDelivery(filePath)
+
+	if fh.DryRun {
+		slog.Info("Dry run - deletion not propagated", "file", relPath)
+		return nil
+	}
+	slog.Info("File removed from the input directory - deleting it from the targets", "file", relPath)
+	return fh.cleanupTargetFiles(fh.OutputTargets(), relPath)
+}
+
+// propagatesDelete reports whether the removal of path is mirrored to the targets. Only files the
+// watcher would transfer are mirrored; removed directories are skipped, their files have events of their own.
+func (fw *FileWatcher) propagatesDelete(path string) bool {
+	if fw.fileHandler == nil || !fw.fileHandler.PropagateDeletes || fw.stopping.Load() {
+		return false
+	}
+	if slices.Contains(fw.watcher.WatchList(), path) || fw.isPauseLock(path) {
+		return false
+	}
+
+	fileName := filepath.Base(path)
+	if strings.HasPrefix(fileName, ".") || strings.HasPrefix(fileName, "~") || fw.isCompletionMarker(path) {
+		return false
+	}
+	return fw.watchPatterns.matchesDir(fw.relativePath(filepath.Dir(path)))
+}
+
+func (fw *FileWatcher) propagateDelete(path string) {
+	if err := fw.fileHandler.PropagateDelete(path, fw.inputDir); err != nil {
+		slog.Error("Error propagating the deletion to the targets", "file", path, "error", err)
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileWatcher_PropagateDeletes(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	// Without its marker a file stays in the input directory, so it can be deleted by the user
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	fileHandler.PropagateDeletes = true
+	fileHandler.CompletionMarkerSuffix = ".done"
+	fw, err := NewFileWatcher(inputDir, fileHandler, 5, 10*time.Millisecond, 20*time.Millisecond, 2, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	fw.lsofAvailable = false
+	fw.completionMarkerSuffix = ".done"
+
+	go func() {
+		if err := fw.Start(); err != nil {
+			t.Logf("FileWatcher stopped with error: %v", err)
+		}
+	}()
+	defer fw.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	// Delivered files are removed by file-shifter itself and must stay on the target
+	delivered := filepath.Join(inputDir, "delivered.csv")
+	if err := os.WriteFile(delivered, []byte("delivered"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+	if err := os.WriteFile(delivered+".done", nil, 0644); err != nil {
+		t.Fatalf("failed to create marker file: %v", err)
+	}
+	waitFor("the delivery", func() bool { return exists(filepath.Join(outputDir, "delivered.csv")) && !exists(delivered) })
+
+	// A file deleted by the user is deleted from the target as well
+	if err := os.WriteFile(filepath.Join(outputDir, "removed.csv"), []byte("old copy"), 0644); err != nil {
+		t.Fatalf("failed to create target file: %v", err)
+	}
+	removed := filepath.Join(inputDir, "removed.csv")
+	if err := os.WriteFile(removed, []byte("pending"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := os.Remove(removed); err != nil {
+		t.Fatalf("failed to remove input file: %v", err)
+	}
+	waitFor("the propagated deletion", func() bool { return !exists(filepath.Join(outputDir, "removed.csv")) })
+
+	time.Sleep(100 * time.Millisecond)
+	if !exists(filepath.Join(outputDir, "delivered.csv")) {
+		t.Error("a file removed after its delivery must not be deleted from the target")
+	}
+}
+
+func TestFileHandler_PropagateDeleteSkipsDeliveredSource(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	fh.PropagateDeletes = true
+	srcFile := filepath.Join(inputDir, "data.csv")
+	if err := os.WriteFile(srcFile, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	// The remove event of the delivered source
+	if err := fh.PropagateDelete(srcFile, inputDir); err != nil {
+		t.Fatalf("PropagateDelete() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "data.csv")); err != nil {
+		t.Fatalf("delivered file must stay on the target: %v", err)
+	}
+
+	// A later deletion of the same path is mirrored again
+	if err := fh.PropagateDelete(srcFile, inputDir); err != nil {
+		t.Fatalf("PropagateDelete() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "data.csv")); !os.IsNotExist(err) {
+		t.Errorf("file should have been deleted from the target, stat error = %v", err)
+	}
+}
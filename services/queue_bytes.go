@@ -0,0 +1,87 @@
+package services
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// queueByteLimit bounds the total size of the files between enqueueing and the end of their processing.
+// A count-based queue does not bound memory when features buffer file content (see buffer.go).
+type queueByteLimit struct {
+	max int64
+
+	mu    sync.Mutex
+	bytes int64
+	sizes map[string]int64
+	// changed is closed and replaced whenever bytes are released
+	changed chan struct{}
+}
+
+// newQueueByteLimit returns nil if maxBytes is not positive, i.e. the queue is only bounded by count
+func newQueueByteLimit(maxBytes int64) *queueByteLimit {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &queueByteLimit{max: maxBytes, sizes: make(map[string]int64), changed: make(chan struct{})}
+}
+
+// acquire blocks until the file fits below the limit or stop is closed. A file larger than the
+// limit is admitted once nothing else is in flight, otherwise it would never be processed.
+func (l *queueByteLimit) acquire(filePath string, size int64, stop <-chan bool) bool {
+	logged := false
+	for {
+		l.mu.Lock()
+		if l.bytes == 0 || l.bytes+size <= l.max {
+			l.bytes += size
+			l.sizes[filePath] += size
+			l.mu.Unlock()
+			return true
+		}
+		changed, inFlight := l.changed, l.bytes
+		l.mu.Unlock()
+
+		if !logged {
+			slog.Debug("Queue byte limit reached - waiting for workers", "file", filePath,
+				"size", size, "in_flight_bytes", inFlight, "max_bytes", l.max)
+			logged = true
+		}
+		select {
+		case <-stop:
+			return false
+		case <-changed:
+		}
+	}
+}
+
+// release returns the bytes of a processed file; files that were never acquired are ignored
+func (l *queueByteLimit) release(filePath string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	size, ok := l.sizes[filePath]
+	if !ok {
+		return
+	}
+	delete(l.sizes, filePath)
+	l.bytes -= size
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// acquireQueueBytes applies the backpressure of the byte limit before a file is enqueued
+func (fw *FileWatcher) acquireQueueBytes(filePath string) bool {
+	if fw.queueBytes == nil {
+		return true
+	}
+	var size int64
+	if info, err := os.Stat(filePath); err == nil {
+		size = info.Size()
+	}
+	return fw.queueBytes.acquire(filePath, size, fw.stopChan)
+}
+
+func (fw *FileWatcher) releaseQueueBytes(filePath string) {
+	if fw.queueBytes != nil {
+		fw.queueBytes.release(filePath)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileWatcher_QueueByteLimitBlocksEnqueue(t *testing.T) {
+	inputDir := t.TempDir()
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}, nil)
+	fw, err := NewFileWatcher(inputDir, fileHandler, 5, 10*time.Millisecond, 20*time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	defer fw.Stop()
+	fw.queueBytes = newQueueByteLimit(100)
+
+	var files []string
+	for _, name := range []string{"first.bin", "second.bin"} {
+		path := filepath.Join(inputDir, name)
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", 60)), 0644); err != nil {
+			t.Fatalf("failed to create input file: %v", err)
+		}
+		files = append(files, path)
+	}
+
+	fw.enqueueFileWithMonitoring(files[0])
+
+	// 60 + 60 bytes exceed the limit of 100 bytes although the queue has room for 10 files
+	enqueued := make(chan struct{})
+	go func() {
+		fw.enqueueFileWithMonitoring(files[1])
+		close(enqueued)
+	}()
+	select {
+	case <-enqueued:
+		t.Fatal("second file should wait until the first one has been processed")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if got := fw.QueueSize(); got != 1 {
+		t.Fatalf("queue size = %d, want 1", got)
+	}
+
+	// A worker processes the first file and releases its bytes
+	fw.processQueuedFile(<-fw.fileQueue)
+	select {
+	case <-enqueued:
+	case <-time.After(2 * time.Second):
+		t.Fatal("second file should be enqueued once the workers drained below the limit")
+	}
+	if got := <-fw.fileQueue; got != files[1] {
+		t.Errorf("queued file = %s, want %s", got, files[1])
+	}
+}
+
+func TestQueueByteLimit_AdmitsOversizedFileWhenIdle(t *testing.T) {
+	limit := newQueueByteLimit(100)
+	stop := make(chan bool)
+
+	if !limit.acquire("huge.bin", 500, stop) {
+		t.Fatal("a file larger than the limit must be admitted when nothing is in flight")
+	}
+
+	blocked := make(chan bool)
+	go func() { blocked <- limit.acquire("small.bin", 1, stop) }()
+	select {
+	case <-blocked:
+		t.Fatal("no further file fits while the oversized file is in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(stop)
+	if <-blocked {
+		t.Error("acquire should give up when the watcher stops")
+	}
+	limit.release("huge.bin")
+	if limit.bytes != 0 {
+		t.Errorf("bytes in flight = %d, want 0", limit.bytes)
+	}
+
+	if newQueueByteLimit(0) != nil {
+		t.Error("a limit of 0 should disable the byte limit")
+	}
+}
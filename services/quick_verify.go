@@ -0,0 +1,32 @@
+package services
+
+import (
+	"log/slog"
+	"os"
+)
+
+// finalChecksum returns the checksum of the file after its transfer. With QuickVerify the size and
+// modification time are compared with fileInfo (taken before the initial checksum) first:
+// a different size proves a change without reading the file, unchanged values prove the file unchanged
+// and the initial checksum is returned. Only a changed mtime of an equally sized file needs a full read.
+func (fh *FileHandler) finalChecksum(filePath, initialChecksum string, fileInfo os.FileInfo) (string, error) {
+	if !fh.QuickVerify || fileInfo == nil {
+		return fh.calculateFileChecksum(filePath)
+	}
+
+	current, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case current.Size() != fileInfo.Size():
+		slog.Debug("File size changed during processing - final checksum skipped",
+			"file", filePath, "initial_size", fileInfo.Size(), "size", current.Size())
+		return "", nil
+	case current.ModTime().Equal(fileInfo.ModTime()):
+		slog.Debug("File unchanged (size and mtime) - final checksum skipped", "file", filePath)
+		return initialChecksum, nil
+	default:
+		return fh.calculateFileChecksum(filePath)
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileHandler_QuickVerifySkipsFinalRead(t *testing.T) {
+	tests := []struct {
+		name        string
+		quickVerify bool
+		wantReads   int32
+	}{
+		{name: "disabled", quickVerify: false, wantReads: 3},
+		{name: "unchanged file", quickVerify: true, wantReads: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputDir := t.TempDir()
+			srcFile := filepath.Join(inputDir, "append.log")
+			if err := os.WriteFile(srcFile, []byte("line 1\nline 2\n"), 0644); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+			outputDir := t.TempDir()
+
+			fh := NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: outputDir}}, nil)
+			fh.QuickVerify = tt.quickVerify
+			reads := countSourceOpens(t)
+
+			if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+				t.Fatalf("ProcessFile() error = %v", err)
+			}
+
+			if got := reads.Load(); got != tt.wantReads {
+				t.Errorf("source reads = %d, want %d", got, tt.wantReads)
+			}
+			if _, err := os.Stat(filepath.Join(outputDir, "append.log")); err != nil {
+				t.Errorf("expected delivered file: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileHandler_QuickVerifyFinalChecksum(t *testing.T) {
+	tests := []struct {
+		name         string
+		change       func(t *testing.T, path string)
+		wantReads    int32
+		wantMismatch bool
+	}{
+		{name: "unchanged", change: func(*testing.T, string) {}, wantReads: 0},
+		{name: "grown", wantReads: 0, wantMismatch: true, change: func(t *testing.T, path string) {
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			if _, err := file.WriteString("line 3\n"); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{name: "rewritten with same size", wantReads: 1, wantMismatch: true, change: func(t *testing.T, path string) {
+			if err := os.WriteFile(path, []byte("line X\nline Y\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			later := time.Now().Add(time.Minute)
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcFile := filepath.Join(t.TempDir(), "append.log")
+			if err := os.WriteFile(srcFile, []byte("line 1\nline 2\n"), 0644); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+			fileInfo, err := os.Stat(srcFile)
+			if err != nil {
+				t.Fatalf("failed to stat source file: %v", err)
+			}
+
+			fh := NewFileHandler(nil, nil)
+			fh.QuickVerify = true
+			initialChecksum, err := fh.calculateFileChecksum(srcFile)
+			if err != nil {
+				t.Fatalf("calculateFileChecksum() error = %v", err)
+			}
+
+			tt.change(t, srcFile)
+			reads := countSourceOpens(t)
+
+			finalChecksum, err := fh.finalChecksum(srcFile, initialChecksum, fileInfo)
+			if err != nil {
+				t.Fatalf("finalChecksum() error = %v", err)
+			}
+			if got := reads.Load(); got != tt.wantReads {
+				t.Errorf("source reads = %d, want %d", got, tt.wantReads)
+			}
+			if mismatch := finalChecksum != initialChecksum; mismatch != tt.wantMismatch {
+				t.Errorf("checksum mismatch = %v, want %v", mismatch, tt.wantMismatch)
+			}
+		})
+	}
+}
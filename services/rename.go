@@ -0,0 +1,47 @@
+package services
+
+import (
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"file-shifter/config"
+)
+
+// renamePatterns caches the compiled RenameRegex of the targets by pattern
+var renamePatterns sync.Map
+
+// renameRelPath applies the target's RenameRegex/RenameReplace to the slash separated relative path.
+// A result that is empty or leaves the target directory is not used, the path stays unchanged.
+func renameRelPath(target config.OutputTarget, relPath string) string {
+	if target.RenameRegex == "" {
+		return relPath
+	}
+
+	pattern, err := compileRenamePattern(target.RenameRegex)
+	if err != nil {
+		slog.Warn("Invalid rename-regex, file keeps its name", "pattern", target.RenameRegex, "error", err)
+		return relPath
+	}
+
+	renamed := pattern.ReplaceAllString(filepath.ToSlash(relPath), target.RenameReplace)
+	cleaned := filepath.Clean(filepath.FromSlash(renamed))
+	if cleaned == "." || !filepath.IsLocal(cleaned) {
+		slog.Warn("Renamed path is outside the target, file keeps its name", "file", relPath, "renamed", renamed)
+		return relPath
+	}
+	return cleaned
+}
+
+func compileRenamePattern(expr string) (*regexp.Regexp, error) {
+	if cached, ok := renamePatterns.Load(expr); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	renamePatterns.Store(expr, pattern)
+	return pattern, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"file-shifter/config"
+)
+
+func TestRenameRelPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		regex   string
+		replace string
+		relPath string
+		want    string
+	}{
+		{"no rename configured", "", "", "data-20240101.csv", "data-20240101.csv"},
+		{"strip timestamp", `-\d{8}(\.csv)$`, "$1", "data-20240101.csv", "data.csv"},
+		{"nested path", `-\d{8}(\.csv)$`, "$1", "2024/data-20240101.csv", "2024/data.csv"},
+		{"change extension", `\.txt$`, ".csv", "in/report.txt", "in/report.csv"},
+		{"no match", `-\d{8}(\.csv)$`, "$1", "data.json", "data.json"},
+		{"move into directory", `^(\d{4})-(.*)$`, "$1/$2", "2024-data.csv", "2024/data.csv"},
+		{"empty result keeps name", `.*`, "", "data.csv", "data.csv"},
+		{"escaping result keeps name", `^`, "../", "data.csv", "data.csv"},
+		{"invalid regex keeps name", `data-(\d+`, "$1", "data-1.csv", "data-1.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := config.OutputTarget{Type: "filesystem", Path: "/out", RenameRegex: tt.regex, RenameReplace: tt.replace}
+			if got := renameRelPath(target, filepath.FromSlash(tt.relPath)); got != filepath.FromSlash(tt.want) {
+				t.Errorf("renameRelPath() = %q, want %q", got, filepath.FromSlash(tt.want))
+			}
+		})
+	}
+}
+
+func TestTargetRelPath_StripPrefixThenRename(t *testing.T) {
+	// The regex sees the path without the prefix
+	target := config.OutputTarget{
+		Type:          "filesystem",
+		Path:          "/out",
+		StripPrefix:   "staging",
+		RenameRegex:   `^(\d{4})/data-\d{8}`,
+		RenameReplace: "$1/data",
+	}
+	got := targetRelPath(target, filepath.FromSlash("staging/2024/data-20240101.csv"))
+	if want := filepath.FromSlash("2024/data.csv"); got != want {
+		t.Errorf("targetRelPath() = %q, want %q", got, want)
+	}
+}
+
+func TestFileHandler_Rename(t *testing.T) {
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "data-20240101.csv")
+	if err := os.WriteFile(srcFile, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := config.OutputTarget{Type: "filesystem", Path: t.TempDir(), RenameRegex: `-\d{8}(\.csv)$`, RenameReplace: "$1"}
+	mirrored := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+	fh := NewFileHandler([]config.OutputTarget{renamed, mirrored}, nil)
+
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	renamedFile := filepath.Join(renamed.Path, "data.csv")
+	mirroredFile := filepath.Join(mirrored.Path, "data-20240101.csv")
+	for _, path := range []string{renamedFile, mirroredFile} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected delivered file %s: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(renamed.Path, "data-20240101.csv")); !os.IsNotExist(err) {
+		t.Error("the renamed target should not receive the original name")
+	}
+
+	// Cleanup has to address the renamed destination
+	if err := fh.cleanupTargetFiles(fh.OutputTargets(), "data-20240101.csv"); err != nil {
+		t.Fatalf("cleanupTargetFiles() error = %v", err)
+	}
+	for _, path := range []string{renamedFile, mirroredFile} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed by cleanup", path)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+// permanentError stops retryWithBackoff without further attempts
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent marks an error of a retried operation as not worth retrying
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// retryWithBackoff runs op until it succeeds, returns a permanent error or attempts are used up. The delay before
+// the second attempt is backoff, doubled for every further attempt. onRetry is called with the failed attempt
+// (starting at 1) before waiting.
+func retryWithBackoff(attempts int, backoff time.Duration, op func(attempt int) error,
+	onRetry func(attempt int, retryIn time.Duration, err error)) error {
+	for attempt := 1; ; attempt++ {
+		err := op(attempt)
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if err == nil || attempt >= attempts {
+			return err
+		}
+
+		onRetry(attempt, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryWithBackoff(t *testing.T) {
+	transient := errors.New("transient")
+	fatal := errors.New("fatal")
+
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "succeeds at once", failures: 0, wantCalls: 1},
+		{name: "succeeds after retries", failures: 2, err: transient, wantCalls: 3},
+		{name: "attempts used up", failures: 100, err: transient, wantErr: transient, wantCalls: 3},
+		{name: "permanent error", failures: 100, err: permanent(fatal), wantErr: fatal, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			var delays []time.Duration
+			err := retryWithBackoff(3, time.Millisecond, func(attempt int) error {
+				calls++
+				if attempt != calls {
+					t.Errorf("attempt = %d, want %d", attempt, calls)
+				}
+				if calls <= tt.failures {
+					return tt.err
+				}
+				return nil
+			}, func(_ int, retryIn time.Duration, _ error) {
+				delays = append(delays, retryIn)
+			})
+
+			if err != tt.wantErr {
+				t.Errorf("retryWithBackoff() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("operation called %d times, want %d", calls, tt.wantCalls)
+			}
+			for i := 1; i < len(delays); i++ {
+				if delays[i] != 2*delays[i-1] {
+					t.Errorf("backoff should double, got %v", delays)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"file-shifter/config"
+)
+
+// Indirections for batched S3 targets, replaceable in tests
+var batchCheckInterval = time.Second // How often pending batches are checked for their interval and file limit
+
+// batchedFile is a delivered source file that waits for the archive upload of one or more batched targets
+type batchedFile struct {
+	relPath  string
+	checksum string
+	size     int64
+	modTime  time.Time
+	pending  int // Batches that have not uploaded the file yet
+}
+
+// s3Batch collects the source files for the next archive of a batched S3 target
+type s3Batch struct {
+	target  config.OutputTarget
+	started time.Time
+	files   []string
+}
+
+// BatchManifest is uploaded as <archive>.manifest.json next to every batch archive
+type BatchManifest struct {
+	Archive string               `json:"archive"`
+	Created time.Time            `json:"created"`
+	Files   []BatchManifestEntry `json:"files"`
+}
+
+// BatchManifestEntry describes a file in a batch archive, Path is the name in the archive
+type BatchManifestEntry struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"sha256"`
+}
+
+// batchesTarget reports whether a target receives its files as batch archives
+func batchesTarget(target config.OutputTarget) bool {
+	return target.Type == "s3" && target.BatchInterval > 0
+}
+
+// splitBatched separates the targets that receive each file on its own from those that batch them
+func splitBatched(targets []config.OutputTarget) (direct, batched []config.OutputTarget) {
+	for _, target := range targets {
+		if batchesTarget(target) {
+			batched = append(batched, target)
+		} else {
+			direct = append(direct, target)
+		}
+	}
+	return direct, batched
+}
+
+// addToBatches adds a verified source file to the next archive of the batched targets. The source is kept
+// until all of them have uploaded it.
+func (fh *FileHandler) addToBatches(filePath, relPath, checksum string, fileInfo os.FileInfo, targets []config.OutputTarget) {
+	fh.batchMutex.Lock()
+	defer fh.batchMutex.Unlock()
+
+	if fh.batches == nil {
+		fh.batches = make(map[string]*s3Batch)
+		fh.batchedFiles = make(map[string]*batchedFile)
+	}
+	file, ok := fh.batchedFiles[filePath]
+	if !ok {
+		file = &batchedFile{relPath: relPath, checksum: checksum, size: fileInfo.Size(), modTime: fileInfo.ModTime()}
+		fh.batchedFiles[filePath] = file
+	}
+
+	for _, target := range targets {
+		batch, ok := fh.batches[target.Path]
+		if !ok {
+			batch = &s3Batch{target: target}
+			fh.batches[target.Path] = batch
+		}
+		if len(batch.files) == 0 {
+			batch.started = time.Now()
+		}
+		batch.files = append(batch.files, filePath)
+		file.pending++
+	}
+	slog.Info("File added to the batch of the targets - original file kept until the upload", "file", relPath)
+}
+
+// isBatched reports whether a source file waits for a batch upload
+func (fh *FileHandler) isBatched(filePath string) bool {
+	fh.batchMutex.Lock()
+	defer fh.batchMutex.Unlock()
+	_, ok := fh.batchedFiles[filePath]
+	return ok
+}
+
+// dueBatches returns the targets whose batch has reached its interval or file limit, all non-empty ones if force is set
+func (fh *FileHandler) dueBatches(force bool) []string {
+	fh.batchMutex.Lock()
+	defer fh.batchMutex.Unlock()
+
+	var due []string
+	for targetPath, batch := range fh.batches {
+		if len(batch.files) == 0 {
+			continue
+		}
+		interval := time.Duration(batch.target.BatchInterval) * time.Millisecond
+		full := batch.target.BatchMaxFiles > 0 && len(batch.files) >= batch.target.BatchMaxFiles
+		if force || full || time.Since(batch.started) >= interval {
+			due = append(due, targetPath)
+		}
+	}
+	sort.Strings(due)
+	return due
+}
+
+// takeBatch removes the collected files from a batch for the upload
+func (fh *FileHandler) takeBatch(targetPath string) (config.OutputTarget, []string) {
+	fh.batchMutex.Lock()
+	defer fh.batchMutex.Unlock()
+
+	batch := fh.batches[targetPath]
+	files := batch.files
+	batch.files = nil
+	return batch.target, files
+}
+
+// returnBatch puts the files of a failed upload back, they are uploaded with the next attempt
+func (fh *FileHandler) returnBatch(targetPath string, files []string) {
+	fh.batchMutex.Lock()
+	defer fh.batchMutex.Unlock()
+
+	batch := fh.batches[targetPath]
+	if len(batch.files) == 0 {
+		batch.started = time.Now()
+	}
+	batch.files = append(files, batch.files...)
+}
+
+// releaseBatchedFile forgets a file for one batch and reports whether no other batch needs it anymore
+func (fh *FileHandler) releaseBatchedFile(filePath string) (*batchedFile, bool) {
+	fh.batchMutex.Lock()
+	defer fh.batchMutex.Unlock()
+
+	file, ok := fh.batchedFiles[filePath]
+	if !ok {
+		return nil, false
+	}
+	file.pending--
+	if file.pending > 0 {
+		return file, false
+	}
+	delete(fh.batchedFiles, filePath)
+	return file, true
+}
+
+// FlushBatches uploads the batches that are due (all batches if force is set). Files that changed while
+// waiting are dropped from their batch and returned, they have to be processed again. failed is the number of
+// files whose batch upload failed, they stay in their batch.
+func (fh *FileHandler) FlushBatches(force bool) (changed []string, failed int) {
+	for _, targetPath := range fh.dueBatches(force) {
+		target, files := fh.takeBatch(targetPath)
+		uploaded, dropped, err := fh.uploadBatch(target, files)
+		if err != nil {
+			slog.Error("Batch upload failed - files stay in the batch", "target", target.Path, "files", len(uploaded), "error", err)
+			fh.returnBatch(targetPath, uploaded)
+			failed += len(uploaded)
+		} else {
+			for _, filePath := range uploaded {
+				if fh.completeBatchedFile(filePath) {
+					changed = append(changed, filePath)
+				}
+			}
+		}
+		for _, filePath := range dropped {
+			if _, last := fh.releaseBatchedFile(filePath); last {
+				changed = append(changed, filePath)
+			}
+		}
+	}
+	return changed, failed
+}
+
+// completeBatchedFile removes the source of an uploaded file once no other batch or schedule needs it.
+// It reports whether the file has changed since it was archived, it is then a new file.
+func (fh *FileHandler) completeBatchedFile(filePath string) bool {
+	file, last := fh.releaseBatchedFile(filePath)
+	if !last || fh.hasDeferredDelivery(filePath) {
+		// Removed by the last batch or once the scheduled targets have received it (see schedule.go)
+		return false
+	}
+
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return false
+	}
+	if err != nil || info.Size() != file.size || !info.ModTime().Equal(file.modTime) {
+		slog.Warn("Original file changed after the batch upload - kept", "file", filePath)
+		return true
+	}
+
+	if fh.Mode == config.ModeCopy {
+		fh.recordCopiedSource(filePath, info, file.checksum)
+		slog.Info("Batched file successfully uploaded, original file kept (copy mode)", "file", file.relPath)
+		return false
+	}
+
+	if fh.DeleteDelay > 0 {
+		if err := fh.scheduleSourceRemoval(filePath); err != nil {
+			slog.Error("Error scheduling the removal of the original file", "file", filePath, "error", err)
+		}
+		return false
+	}
+	if err := fh.removeSourceFile(filePath); err != nil {
+		slog.Error("Error deleting the original file", "file", filePath, "error", err)
+		return false
+	}
+	slog.Info("Batched file successfully uploaded and removed", "file", file.relPath)
+	return false
+}
+
+// uploadBatch writes the files into a .tar.gz archive and uploads it together with its manifest. Files that
+// changed or vanished since they were added are dropped, the others are returned as uploaded.
+func (fh *FileHandler) uploadBatch(target config.OutputTarget, files []string) (uploaded, dropped []string, err error) {
+	archive, err := os.CreateTemp("", "file-shifter-batch-*.tar.gz")
+	if err != nil {
+		return files, nil, fmt.Errorf("error creating the batch archive: %w", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	created := time.Now().UTC()
+	name := fmt.Sprintf("batch-%s-%d", created.Format("20060102T150405Z"), fh.batchSeq.Add(1))
+	manifest := BatchManifest{Archive: name + ".tar.gz", Created: created}
+
+	gzipWriter := gzip.NewWriter(archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, filePath := range files {
+		entry, err := fh.writeBatchEntry(tarWriter, target, filePath)
+		if errors.Is(err, errBatchFileChanged) {
+			slog.Warn("File changed while waiting for the batch - processed again", "file", filePath)
+			dropped = append(dropped, filePath)
+			continue
+		}
+		if err != nil {
+			return files, nil, err
+		}
+		manifest.Files = append(manifest.Files, entry)
+		uploaded = append(uploaded, filePath)
+	}
+	if err := tarWriter.Close(); err != nil {
+		return files, nil, fmt.Errorf("error writing the batch archive: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return files, nil, fmt.Errorf("error writing the batch archive: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return files, nil, fmt.Errorf("error writing the batch archive: %w", err)
+	}
+	if len(uploaded) == 0 {
+		return nil, dropped, nil
+	}
+
+	if err := fh.uploadBatchObjects(target, archive.Name(), name, manifest); err != nil {
+		return uploaded, dropped, err
+	}
+	slog.Info("Batch uploaded", "target", target.Path, "archive", manifest.Archive, "files", len(uploaded))
+	return uploaded, dropped, nil
+}
+
+var errBatchFileChanged = errors.New("file changed since it was added to the batch")
+
+// writeBatchEntry adds a source file to the archive under its target path and returns its manifest entry
+func (fh *FileHandler) writeBatchEntry(tarWriter *tar.Writer, target config.OutputTarget, filePath string) (BatchManifestEntry, error) {
+	fh.batchMutex.Lock()
+	file := fh.batchedFiles[filePath]
+	fh.batchMutex.Unlock()
+
+	source, err := openSourceFile(filePath)
+	if err != nil {
+		return BatchManifestEntry{}, errBatchFileChanged
+	}
+	defer source.Close()
+	info, err := source.Stat()
+	if err != nil || info.Size() != file.size || !info.ModTime().Equal(file.modTime) {
+		return BatchManifestEntry{}, errBatchFileChanged
+	}
+
+	entryPath := filepath.ToSlash(targetRelPath(target, file.relPath))
+	header := &tar.Header{
+		Name:    entryPath,
+		Mode:    int64(info.Mode().Perm()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return BatchManifestEntry{}, fmt.Errorf("error writing the batch archive: %w", err)
+	}
+	hash := sha256.New()
+	if _, err := io.CopyN(tarWriter, io.TeeReader(source, hash), info.Size()); err != nil {
+		return BatchManifestEntry{}, fmt.Errorf("error writing %s to the batch archive: %w", filePath, err)
+	}
+	return BatchManifestEntry{Path: entryPath, Size: info.Size(), Checksum: fmt.Sprintf("%x", hash.Sum(nil))}, nil
+}
+
+// uploadBatchObjects uploads the archive and then its manifest below the target path
+func (fh *FileHandler) uploadBatchObjects(target config.OutputTarget, archivePath, name string, manifest BatchManifest) error {
+	if fh.S3ClientManager == nil {
+		return fmt.Errorf("s3ClientManager not initialised")
+	}
+	minioClient, err := fh.S3ClientManager.GetOrCreateClient(target.GetS3Config())
+	if err != nil {
+		return fmt.Errorf("error getting the S3 client: %w", err)
+	}
+
+	archivePathInfo, err := parseS3Path(target.Path, manifest.Archive)
+	if err != nil {
+		return fmt.Errorf("error parsing the S3 path: %w", err)
+	}
+	manifestPathInfo, err := parseS3Path(target.Path, name+".manifest.json")
+	if err != nil {
+		return fmt.Errorf("error parsing the S3 path: %w", err)
+	}
+	bucketName := minioClient.SanitizeBucketName(archivePathInfo.bucketName)
+	if err := fh.ensureS3Bucket(minioClient, bucketName, target); err != nil {
+		return fmt.Errorf("error ensuring the bucket: %w", err)
+	}
+
+	uploadOptions := UploadOptions{
+		ACL:      target.ACL,
+		limiters: fh.transferLimiters(target.Type, target.Path),
+	}
+	if _, err := minioClient.UploadFileWithOptions(archivePath, bucketName, archivePathInfo.objectKey, uploadOptions); err != nil {
+		return fmt.Errorf("error uploading the batch archive: %w", err)
+	}
+
+	// The manifest is written last, its presence marks a complete batch
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding the batch manifest: %w", err)
+	}
+	if _, err := minioClient.UploadReader(context.Background(), bytes.NewReader(data), int64(len(data)), bucketName,
+		manifestPathInfo.objectKey, UploadOptions{ACL: target.ACL}); err != nil {
+		return fmt.Errorf("error uploading the batch manifest: %w", err)
+	}
+	return nil
+}
+
+// runBatchUploads uploads batches once their interval has passed or they are full, and queues files
+// that changed while waiting again
+func (fw *FileWatcher) runBatchUploads() {
+	ticker := time.NewTicker(batchCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fw.stopChan:
+			return
+		case <-ticker.C:
+			changed, _ := fw.fileHandler.FlushBatches(false)
+			for _, filePath := range changed {
+				fw.processFile(filePath)
+			}
+		}
+	}
+}
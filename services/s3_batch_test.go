@@ -0,0 +1,228 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"file-shifter/config"
+)
+
+// newBatchTestHandler returns a file handler with a batched S3 target on a fake server
+func newBatchTestHandler(t *testing.T) (*FileHandler, *fakeS3Server, *httptest.Server) {
+	t.Helper()
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+
+	target := config.OutputTarget{
+		Type:          "s3",
+		Path:          "s3://batches/daily",
+		Endpoint:      strings.TrimPrefix(ts.URL, "http://"),
+		AccessKey:     "key",
+		SecretKey:     "secret",
+		SSL:           boolPtr(false),
+		Region:        "us-east-1",
+		BatchInterval: 3600000,
+	}
+	manager := NewS3ClientManager()
+	t.Cleanup(manager.Close)
+	return NewFileHandler([]config.OutputTarget{target}, manager), fake, ts
+}
+
+// batchObjects returns the archive and manifest objects uploaded to the fake server
+func batchObjects(t *testing.T, fake *fakeS3Server) (archive []byte, manifest BatchManifest) {
+	t.Helper()
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var manifests int
+	for key, data := range fake.buckets["batches"] {
+		switch {
+		case strings.HasSuffix(key, ".tar.gz"):
+			archive = data
+		case strings.HasSuffix(key, ".manifest.json"):
+			manifests++
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				t.Fatalf("invalid manifest %s: %v", key, err)
+			}
+			if !strings.HasPrefix(key, "daily/batch-") {
+				t.Errorf("manifest key %s should be below the target prefix", key)
+			}
+		}
+	}
+	if archive == nil || manifests != 1 {
+		t.Fatalf("expected one archive and one manifest, got objects %v", fake.buckets["batches"])
+	}
+	return archive, manifest
+}
+
+// readTarGz returns the contents of a .tar.gz archive by entry name
+func readTarGz(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("archive is not gzip compressed: %v", err)
+	}
+	contents := make(map[string][]byte)
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return contents
+		}
+		if err != nil {
+			t.Fatalf("invalid tar archive: %v", err)
+		}
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			t.Fatalf("error reading %s from the archive: %v", header.Name, err)
+		}
+		contents[header.Name] = content
+	}
+}
+
+func TestFileHandler_S3Batch(t *testing.T) {
+	fh, fake, _ := newBatchTestHandler(t)
+	inputDir := t.TempDir()
+
+	files := map[string]string{
+		"a.csv":        "alpha",
+		"b.csv":        "bravo",
+		"sub/c.json":   `{"c":true}`,
+		"sub/deep/d.x": "delta",
+	}
+	for relPath, content := range files {
+		path := filepath.Join(inputDir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := fh.ProcessFile(path, inputDir); err != nil {
+			t.Fatalf("ProcessFile(%s) error = %v", relPath, err)
+		}
+		// The source stays until the batch is uploaded
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("source %s should be kept until the batch upload: %v", relPath, err)
+		}
+	}
+
+	if due := fh.dueBatches(false); len(due) != 0 {
+		t.Fatalf("batch should not be due before its interval, got %v", due)
+	}
+	if changed, _ := fh.FlushBatches(true); len(changed) != 0 {
+		t.Fatalf("FlushBatches() changed = %v, want none", changed)
+	}
+
+	archive, manifest := batchObjects(t, fake)
+	contents := readTarGz(t, archive)
+	if len(contents) != len(files) || len(manifest.Files) != len(files) {
+		t.Fatalf("archive has %d files, manifest %d, want %d", len(contents), len(manifest.Files), len(files))
+	}
+	for relPath, content := range files {
+		if got := string(contents[relPath]); got != content {
+			t.Errorf("archive entry %s = %q, want %q", relPath, got, content)
+		}
+	}
+	for _, entry := range manifest.Files {
+		checksum := fmt.Sprintf("%x", sha256.Sum256(contents[entry.Path]))
+		if entry.Checksum != checksum || entry.Size != int64(len(contents[entry.Path])) {
+			t.Errorf("manifest entry %+v does not match the archived content (sha256 %s)", entry, checksum)
+		}
+	}
+	if !strings.HasSuffix(manifest.Archive, ".tar.gz") {
+		t.Errorf("manifest archive = %q", manifest.Archive)
+	}
+
+	for relPath := range files {
+		if _, err := os.Stat(filepath.Join(inputDir, filepath.FromSlash(relPath))); !os.IsNotExist(err) {
+			t.Errorf("source %s should be removed after the batch upload", relPath)
+		}
+	}
+}
+
+func TestFileHandler_S3BatchUploadFailureKeepsSources(t *testing.T) {
+	fh, fake, ts := newBatchTestHandler(t)
+	inputDir := t.TempDir()
+	path := filepath.Join(inputDir, "a.csv")
+	if err := os.WriteFile(path, []byte("alpha"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fh.ProcessFile(path, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	ts.Close()
+	fh.FlushBatches(true)
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("source should be kept after a failed batch upload: %v", err)
+	}
+	if !fh.isBatched(path) {
+		t.Fatal("file should stay in the batch for the next attempt")
+	}
+
+	// A batched file is not delivered again while it waits
+	if err := fh.ProcessFile(path, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	if due := fh.dueBatches(true); len(due) != 1 {
+		t.Fatalf("due batches = %v, want the one target", due)
+	}
+	fh.batchMutex.Lock()
+	files := len(fh.batches["s3://batches/daily"].files)
+	fh.batchMutex.Unlock()
+	if files != 1 {
+		t.Errorf("batch holds %d files, want 1", files)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.buckets["batches"]) != 0 {
+		t.Errorf("nothing should be uploaded, got %v", fake.buckets["batches"])
+	}
+}
+
+func TestFileHandler_S3BatchDropsChangedFiles(t *testing.T) {
+	fh, fake, _ := newBatchTestHandler(t)
+	inputDir := t.TempDir()
+	kept := filepath.Join(inputDir, "kept.csv")
+	changed := filepath.Join(inputDir, "changed.csv")
+	for _, path := range []string{kept, changed} {
+		if err := os.WriteFile(path, []byte("v1"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := fh.ProcessFile(path, inputDir); err != nil {
+			t.Fatalf("ProcessFile() error = %v", err)
+		}
+	}
+	if err := os.WriteFile(changed, []byte("version 2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	requeue, _ := fh.FlushBatches(true)
+	if len(requeue) != 1 || requeue[0] != changed {
+		t.Fatalf("FlushBatches() changed = %v, want [%s]", requeue, changed)
+	}
+	if fh.isBatched(changed) {
+		t.Error("changed file should be dropped from the batch")
+	}
+	if _, err := os.Stat(changed); err != nil {
+		t.Errorf("changed file should be kept: %v", err)
+	}
+
+	archive, manifest := batchObjects(t, fake)
+	contents := readTarGz(t, archive)
+	if len(contents) != 1 || len(manifest.Files) != 1 || manifest.Files[0].Path != "kept.csv" {
+		t.Errorf("archive should only contain kept.csv, got %v / %+v", contents, manifest.Files)
+	}
+}
@@ -1,17 +1,35 @@
 package services
 
 import (
-	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
 	"file-shifter/config"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"sync"
+	"time"
+)
+
+// Indirections for the health check of new clients, replaceable in tests
+var (
+	minioHealthCheck     = (*MinIO).HealthCheck
+	s3HealthCheckBackoff = 500 * time.Millisecond // Delay before the first retry, doubled for every further retry
 )
 
 // S3ClientManager manages multiple MinIO clients for different S3 configurations
 type S3ClientManager struct {
 	clients map[string]*MinIO
 	mutex   sync.RWMutex
+	// TLSConfig is used for all new clients (nil = system defaults)
+	TLSConfig *tls.Config
+	// MaxIdleConns and MaxIdleConnsPerHost size the connection pool of new clients (0 = minio-go defaults)
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// HealthCheckRetries retries a failed health check of a new client with exponential backoff (0 = no retry)
+	HealthCheckRetries int
+	// SignatureVersion signs the requests of new clients, "v2" for legacy stores (default "v4")
+	SignatureVersion string
 }
 
 // NewS3ClientManager creates a new S3ClientManager
@@ -23,14 +41,18 @@ func NewS3ClientManager() *S3ClientManager {
 
 // getClientKey creates a unique key for an S3 configuration
 func (scm *S3ClientManager) getClientKey(s3Config config.S3Config) string {
-	// Create a hash from the configuration
-	data := fmt.Sprintf("%s:%s:%s:%t:%s",
+	// Length-prefix every field so values containing separators cannot collide
+	hash := sha256.New()
+	for _, field := range []string{
 		s3Config.Endpoint,
 		s3Config.AccessKey,
 		s3Config.SecretKey,
-		s3Config.SSL,
-		s3Config.Region)
-	return fmt.Sprintf("%x", md5.Sum([]byte(data)))
+		strconv.FormatBool(s3Config.SSL),
+		s3Config.Region,
+	} {
+		_, _ = fmt.Fprintf(hash, "%d:%s", len(field), field)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
 }
 
 // GetOrCreateClient returns a MinIO client for the given S3 configuration
@@ -54,18 +76,22 @@ func (scm *S3ClientManager) GetOrCreateClient(s3Config config.S3Config) (*MinIO,
 		return client, nil
 	}
 
-	minioClient, err := NewMinIOConnection(
+	minioClient, err := newMinIOConnection(
 		s3Config.Endpoint,
-		s3Config.AccessKey,
-		s3Config.SecretKey,
+		staticCredentials(scm.SignatureVersion, s3Config.AccessKey, s3Config.SecretKey),
 		s3Config.SSL,
+		minioTransportOptions{
+			tlsConfig:           scm.TLSConfig,
+			maxIdleConns:        scm.MaxIdleConns,
+			maxIdleConnsPerHost: scm.MaxIdleConnsPerHost,
+		},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error creating MinIO client: %w", err)
 	}
 
 	// Perform health check
-	if err := minioClient.HealthCheck(); err != nil {
+	if err := scm.healthCheck(minioClient, s3Config.Endpoint); err != nil {
 		return nil, fmt.Errorf("minIO-HealthCheck fehlgeschlagen: %w", err)
 	}
 
@@ -79,6 +105,22 @@ func (scm *S3ClientManager) GetOrCreateClient(s3Config config.S3Config) (*MinIO,
 	return minioClient, nil
 }
 
+// healthCheck checks a new client, retrying endpoints that are still warming up
+func (scm *S3ClientManager) healthCheck(client *MinIO, endpoint string) error {
+	backoff := s3HealthCheckBackoff
+	for attempt := 0; ; attempt++ {
+		err := minioHealthCheck(client)
+		if err == nil || attempt == scm.HealthCheckRetries {
+			return err
+		}
+
+		slog.Warn("MinIO health check failed - retrying",
+			"endpoint", endpoint, "attempt", attempt+1, "retry_in", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
 // Close closes all MinIO clients (for cleanup)
 func (scm *S3ClientManager) Close() {
 	scm.mutex.Lock()
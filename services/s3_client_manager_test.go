@@ -1,11 +1,18 @@
 package services
 
 import (
-	"crypto/md5"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"file-shifter/config"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
 func TestNewS3ClientManager(t *testing.T) {
@@ -127,6 +134,42 @@ func TestS3ClientManager_getClientKey(t *testing.T) {
 			},
 			shouldBeSame: false,
 		},
+		{
+			name: "separator in secret key should not collide with shifted fields",
+			config1: config.S3Config{
+				Endpoint:  "s3.amazonaws.com",
+				AccessKey: "key1",
+				SecretKey: "a:true:eu",
+				SSL:       false,
+				Region:    "x",
+			},
+			config2: config.S3Config{
+				Endpoint:  "s3.amazonaws.com",
+				AccessKey: "key1",
+				SecretKey: "a",
+				SSL:       true,
+				Region:    "eu:false:x",
+			},
+			shouldBeSame: false,
+		},
+		{
+			name: "separator in access key should not collide with endpoint",
+			config1: config.S3Config{
+				Endpoint:  "a:b",
+				AccessKey: "c",
+				SecretKey: "secret1",
+				SSL:       true,
+				Region:    "us-east-1",
+			},
+			config2: config.S3Config{
+				Endpoint:  "a",
+				AccessKey: "b:c",
+				SecretKey: "secret1",
+				SSL:       true,
+				Region:    "us-east-1",
+			},
+			shouldBeSame: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -168,18 +211,13 @@ func TestS3ClientManager_getClientKey_Consistency(t *testing.T) {
 	}
 
 	// Verify key format (should be a hex string)
-	if len(key1) != 32 { // MD5 hash is 32 characters in hex
-		t.Errorf("Expected key length 32, got %d", len(key1))
+	if len(key1) != 64 { // SHA-256 hash is 64 characters in hex
+		t.Errorf("Expected key length 64, got %d", len(key1))
 	}
 
 	// Verify expected key value
-	expectedData := fmt.Sprintf("%s:%s:%s:%t:%s",
-		config.Endpoint,
-		config.AccessKey,
-		config.SecretKey,
-		config.SSL,
-		config.Region)
-	expectedKey := fmt.Sprintf("%x", md5.Sum([]byte(expectedData)))
+	expectedData := "17:test.endpoint.com7:testkey10:testsecret4:true11:test-region"
+	expectedKey := fmt.Sprintf("%x", sha256.Sum256([]byte(expectedData)))
 
 	if key1 != expectedKey {
 		t.Errorf("Key mismatch. Got %s, expected %s", key1, expectedKey)
@@ -389,3 +427,108 @@ func BenchmarkS3ClientManager_ConcurrentGetActiveClientCount(b *testing.B) {
 		}
 	})
 }
+
+func TestS3ClientManager_GetOrCreateClient_HealthCheckRetries(t *testing.T) {
+	originalCheck, originalBackoff := minioHealthCheck, s3HealthCheckBackoff
+	defer func() {
+		minioHealthCheck = originalCheck
+		s3HealthCheckBackoff = originalBackoff
+	}()
+	s3HealthCheckBackoff = time.Millisecond
+
+	s3Config := config.S3Config{Endpoint: "localhost:9000", AccessKey: "key", SecretKey: "secret", Region: "us-east-1"}
+
+	tests := []struct {
+		name      string
+		retries   int
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "passes on the third attempt", retries: 2, wantErr: false, wantCalls: 3},
+		{name: "too few retries", retries: 1, wantErr: true, wantCalls: 2},
+		{name: "no retry by default", retries: 0, wantErr: true, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The health check fails twice while the endpoint warms up, then passes
+			calls := 0
+			minioHealthCheck = func(*MinIO) error {
+				calls++
+				if calls <= 2 {
+					return errors.New("endpoint warming up")
+				}
+				return nil
+			}
+
+			manager := NewS3ClientManager()
+			manager.HealthCheckRetries = tt.retries
+			client, err := manager.GetOrCreateClient(s3Config)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetOrCreateClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("health checks = %d, want %d", calls, tt.wantCalls)
+			}
+			if !tt.wantErr && (client == nil || manager.GetActiveClientCount() != 1) {
+				t.Error("client should be created and cached after the health check passed")
+			}
+			if tt.wantErr && manager.GetActiveClientCount() != 0 {
+				t.Error("no client may be cached after the health check failed")
+			}
+		})
+	}
+}
+
+func TestS3ClientManager_GetOrCreateClient_SignatureVersion(t *testing.T) {
+	originalCheck := minioHealthCheck
+	defer func() { minioHealthCheck = originalCheck }()
+	minioHealthCheck = func(*MinIO) error { return nil }
+
+	tests := []struct {
+		version    string
+		wantSigner credentials.SignatureType
+		wantAuth   string
+	}{
+		{version: "", wantSigner: credentials.SignatureV4, wantAuth: "AWS4-HMAC-SHA256 "},
+		{version: config.S3SignatureV4, wantSigner: credentials.SignatureV4, wantAuth: "AWS4-HMAC-SHA256 "},
+		{version: config.S3SignatureV2, wantSigner: credentials.SignatureV2, wantAuth: "AWS key:"},
+	}
+
+	for _, tt := range tests {
+		t.Run("version "+tt.version, func(t *testing.T) {
+			value, err := staticCredentials(tt.version, "key", "secret").Get()
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if value.SignerType != tt.wantSigner {
+				t.Errorf("signer = %v, want %v", value.SignerType, tt.wantSigner)
+			}
+
+			// The client of the manager has to sign its requests accordingly
+			var authorization string
+			fake := newFakeS3Server()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorization = r.Header.Get("Authorization")
+				fake.ServeHTTP(w, r)
+			}))
+			defer server.Close()
+
+			manager := NewS3ClientManager()
+			manager.SignatureVersion = tt.version
+			client, err := manager.GetOrCreateClient(config.S3Config{
+				Endpoint: strings.TrimPrefix(server.URL, "http://"), AccessKey: "key", SecretKey: "secret", Region: "us-east-1",
+			})
+			if err != nil {
+				t.Fatalf("GetOrCreateClient() error = %v", err)
+			}
+			if _, err := client.BucketExists("bucket"); err != nil {
+				t.Fatalf("BucketExists() error = %v", err)
+			}
+			if !strings.HasPrefix(authorization, tt.wantAuth) {
+				t.Errorf("Authorization = %q, want prefix %q", authorization, tt.wantAuth)
+			}
+		})
+	}
+}
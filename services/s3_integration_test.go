@@ -1,6 +1,11 @@
 package services
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -9,9 +14,12 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"file-shifter/config"
 )
@@ -19,12 +27,27 @@ import (
 type fakeS3Server struct {
 	mu                   sync.Mutex
 	buckets              map[string]map[string][]byte
+	headers              map[string]http.Header // request headers of object uploads by "bucket/key"
+	uploads              map[string]*fakeMultipartUpload
+	partSizes            map[string][]int // part sizes of completed multipart uploads by "bucket/key"
+	bucketCreations      int
 	forceObjectHeadError bool
 	forceDeleteError     bool
 }
 
 func newFakeS3Server() *fakeS3Server {
-	return &fakeS3Server{buckets: make(map[string]map[string][]byte)}
+	return &fakeS3Server{
+		buckets:   make(map[string]map[string][]byte),
+		headers:   make(map[string]http.Header),
+		uploads:   make(map[string]*fakeMultipartUpload),
+		partSizes: make(map[string][]int),
+	}
+}
+
+// fakeMultipartUpload collects the parts of an upload with unknown size
+type fakeMultipartUpload struct {
+	header http.Header
+	parts  map[int][]byte
 }
 
 func (f *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -127,18 +150,38 @@ func (f *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			if _, ok := f.buckets[bucket]; !ok {
 				f.buckets[bucket] = make(map[string][]byte)
 			}
+			f.bucketCreations++
 			w.WriteHeader(http.StatusOK)
 			return
 		}
 		if _, ok := f.buckets[bucket]; !ok {
 			f.buckets[bucket] = make(map[string][]byte)
 		}
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			f.copyObject(w, r, source, bucket, key)
+			return
+		}
 		body, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			body = decodeAWSChunked(body)
+		}
+		if upload, ok := f.uploads[r.URL.Query().Get("uploadId")]; ok {
+			partNumber, _ := strconv.Atoi(r.URL.Query().Get("partNumber"))
+			upload.parts[partNumber] = body
+			w.Header().Set("ETag", fmt.Sprintf("\"part-%d\"", partNumber))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 		f.buckets[bucket][key] = body
+		f.headers[bucket+"/"+key] = r.Header.Clone()
 		w.Header().Set("ETag", "\"test-etag\"")
 		w.WriteHeader(http.StatusOK)
 		return
 
+	case http.MethodPost:
+		f.handleMultipart(w, r, bucket, key)
+		return
+
 	case http.MethodDelete:
 		if f.forceDeleteError {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -156,6 +199,90 @@ func (f *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// copyObject handles a server-side copy (copy object or upload part copy), the copy gets the metadata of the request
+func (f *fakeS3Server) copyObject(w http.ResponseWriter, r *http.Request, source, bucket, key string) {
+	source, _ = url.PathUnescape(strings.TrimPrefix(source, "/"))
+	srcBucket, srcKey, _ := strings.Cut(source, "/")
+	content, ok := f.buckets[srcBucket][srcKey]
+	if !ok {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>Not Found</Message></Error>"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	// Upload part copy of a multipart upload (ComposeObject), the whole object is copied into the part
+	if upload, ok := f.uploads[r.URL.Query().Get("uploadId")]; ok {
+		partNumber, _ := strconv.Atoi(r.URL.Query().Get("partNumber"))
+		upload.parts[partNumber] = content
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `<CopyPartResult><ETag>"part-%d"</ETag><LastModified>2006-01-02T15:04:05.000Z</LastModified></CopyPartResult>`, partNumber)
+		return
+	}
+	f.buckets[bucket][key] = content
+	f.headers[bucket+"/"+key] = r.Header.Clone()
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`<CopyObjectResult><ETag>"test-etag"</ETag><LastModified>2006-01-02T15:04:05.000Z</LastModified></CopyObjectResult>`))
+}
+
+// handleMultipart initiates (?uploads) and completes (?uploadId=) multipart uploads
+func (f *fakeS3Server) handleMultipart(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	query := r.URL.Query()
+	w.Header().Set("Content-Type", "application/xml")
+
+	if query.Has("uploads") {
+		uploadID := fmt.Sprintf("upload-%d", len(f.uploads)+1)
+		f.uploads[uploadID] = &fakeMultipartUpload{header: r.Header.Clone(), parts: make(map[int][]byte)}
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`,
+			bucket, key, uploadID)
+		return
+	}
+
+	upload, ok := f.uploads[query.Get("uploadId")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("<Error><Code>NoSuchUpload</Code><Message>Not Found</Message></Error>"))
+		return
+	}
+	delete(f.uploads, query.Get("uploadId"))
+
+	var content []byte
+	var partSizes []int
+	for partNumber := 1; partNumber <= len(upload.parts); partNumber++ {
+		content = append(content, upload.parts[partNumber]...)
+		partSizes = append(partSizes, len(upload.parts[partNumber]))
+	}
+	f.partSizes[bucket+"/"+key] = partSizes
+	if _, ok := f.buckets[bucket]; !ok {
+		f.buckets[bucket] = make(map[string][]byte)
+	}
+	f.buckets[bucket][key] = content
+	f.headers[bucket+"/"+key] = upload.header
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>"test-etag"</ETag></CompleteMultipartUploadResult>`,
+		bucket, key)
+}
+
+// decodeAWSChunked strips the chunk headers of a streaming signature V4 payload
+func decodeAWSChunked(body []byte) []byte {
+	var content []byte
+	for len(body) > 0 {
+		header, rest, found := bytes.Cut(body, []byte("\r\n"))
+		if !found {
+			break
+		}
+		sizeHex, _, _ := bytes.Cut(header, []byte(";"))
+		size, err := strconv.ParseInt(string(sizeHex), 16, 64)
+		if err != nil || size == 0 || int64(len(rest)) < size {
+			break
+		}
+		content = append(content, rest[:size]...)
+		body = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+	return content
+}
+
 func (f *fakeS3Server) writeListBuckets(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
@@ -218,6 +345,94 @@ func TestMinIO_WithFakeS3Server(t *testing.T) {
 	}
 }
 
+func TestMinIO_UploadReaderUnknownSizePartSize(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	minioConn, err := NewMinIOConnection(strings.TrimPrefix(ts.URL, "http://"), "key", "secret", false)
+	if err != nil {
+		t.Fatalf("failed to create minio connection: %v", err)
+	}
+	if err := minioConn.EnsureBucket("stream-bucket"); err != nil {
+		t.Fatalf("expected EnsureBucket success, got: %v", err)
+	}
+
+	content := bytes.Repeat([]byte("x"), unknownSizePartSize+1)
+	if _, err := minioConn.UploadReader(context.Background(), bytes.NewReader(content), -1, "stream-bucket", "stream/large.bin", UploadOptions{}); err != nil {
+		t.Fatalf("UploadReader() error = %v", err)
+	}
+
+	fake.mu.Lock()
+	partSizes := fake.partSizes["stream-bucket/stream/large.bin"]
+	stored := len(fake.buckets["stream-bucket"]["stream/large.bin"])
+	fake.mu.Unlock()
+
+	if want := []int{unknownSizePartSize, 1}; !slices.Equal(partSizes, want) {
+		t.Errorf("part sizes = %v, want %v", partSizes, want)
+	}
+	if stored != len(content) {
+		t.Errorf("stored %d bytes, want %d", stored, len(content))
+	}
+}
+
+func TestMinIO_UploadReaderWithFakeS3Server(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	minioConn, err := NewMinIOConnection(strings.TrimPrefix(ts.URL, "http://"), "key", "secret", false)
+	if err != nil {
+		t.Fatalf("failed to create minio connection: %v", err)
+	}
+	if err := minioConn.EnsureBucket("stream-bucket"); err != nil {
+		t.Fatalf("expected EnsureBucket success, got: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		size func(content []byte) int64
+	}{
+		{name: "known size", key: "stream/known.txt", size: func(content []byte) int64 { return int64(len(content)) }},
+		{name: "unknown size", key: "stream/unknown.txt", size: func([]byte) int64 { return -1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte("transformed " + tt.name)
+			opts := UploadOptions{UserMetadata: map[string]string{"origin": "stream"}}
+
+			key, err := minioConn.UploadReader(context.Background(), bytes.NewReader(content), tt.size(content), "stream-bucket", tt.key, opts)
+			if err != nil {
+				t.Fatalf("UploadReader() error = %v", err)
+			}
+			if key != tt.key {
+				t.Errorf("UploadReader() key = %q, want %q", key, tt.key)
+			}
+
+			fake.mu.Lock()
+			stored := fake.buckets["stream-bucket"][tt.key]
+			header := fake.headers["stream-bucket/"+tt.key]
+			fake.mu.Unlock()
+
+			if !bytes.Equal(stored, content) {
+				t.Errorf("stored content = %q, want %q", stored, content)
+			}
+			if got := header.Get("X-Amz-Meta-Origin"); got != "stream" {
+				t.Errorf("x-amz-meta-origin = %q, want %q", got, "stream")
+			}
+		})
+	}
+}
+
+func TestMinIO_UploadReaderWithoutClient(t *testing.T) {
+	m := &MinIO{}
+	if _, err := m.UploadReader(context.Background(), bytes.NewReader(nil), 0, "bucket", "key", UploadOptions{}); err == nil {
+		t.Fatal("expected error without initialized client")
+	}
+}
+
 func TestMinIO_ErrorBranchesWithFakeS3Server(t *testing.T) {
 	t.Run("object exists returns backend error", func(t *testing.T) {
 		fake := newFakeS3Server()
@@ -304,6 +519,62 @@ func TestFileHandler_S3SuccessWithFakeServer(t *testing.T) {
 	}
 }
 
+func TestFileHandler_S3MaxObjectSizeWithFakeServer(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	target := config.OutputTarget{
+		Type:          "s3",
+		Path:          "s3://bucket-a",
+		Endpoint:      strings.TrimPrefix(ts.URL, "http://"),
+		AccessKey:     "key",
+		SecretKey:     "secret",
+		SSL:           boolPtr(false),
+		Region:        "us-east-1",
+		MaxObjectSize: 10,
+	}
+
+	manager := NewS3ClientManager()
+	defer manager.Close()
+	fh := NewFileHandler([]config.OutputTarget{target}, manager)
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "below limit", content: "small", wantErr: false},
+		{name: "at limit", content: "0123456789", wantErr: false},
+		{name: "above limit", content: "this is too large", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := strings.ReplaceAll(tt.name, " ", "-") + ".txt"
+			tmp := filepath.Join(t.TempDir(), key)
+			if err := os.WriteFile(tmp, []byte(tt.content), 0o644); err != nil {
+				t.Fatalf("failed to write payload file: %v", err)
+			}
+
+			err := fh.copyToS3(tmp, key, target)
+			if tt.wantErr != errors.Is(err, ErrObjectTooLarge) {
+				t.Fatalf("copyToS3() error = %v, want ErrObjectTooLarge %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("copyToS3() error = %v", err)
+			}
+
+			fake.mu.Lock()
+			_, uploaded := fake.buckets["bucket-a"][key]
+			fake.mu.Unlock()
+			if uploaded == tt.wantErr {
+				t.Errorf("object uploaded = %v, want %v", uploaded, !tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestWorker_ValidateS3TargetSuccessWithFakeServer(t *testing.T) {
 	fake := newFakeS3Server()
 	ts := httptest.NewServer(fake)
@@ -327,3 +598,412 @@ func TestWorker_ValidateS3TargetSuccessWithFakeServer(t *testing.T) {
 		t.Fatalf("expected validateS3Target success, got: %v", err)
 	}
 }
+
+func TestFileHandler_S3AdditionalKeysWithFakeServer(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	originalNow := templateNow
+	defer func() { templateNow = originalNow }()
+	templateNow = func() time.Time { return time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC) }
+
+	host := strings.TrimPrefix(ts.URL, "http://")
+	target := config.OutputTarget{
+		Type:           "s3",
+		Path:           "s3://bucket-a/prefix",
+		Endpoint:       host,
+		AccessKey:      "key",
+		SecretKey:      "secret",
+		SSL:            boolPtr(false),
+		Region:         "us-east-1",
+		AdditionalKeys: []string{"latest/", "archive/{yyyy}/{mm}/"},
+	}
+
+	manager := NewS3ClientManager()
+	defer manager.Close()
+	fh := NewFileHandler([]config.OutputTarget{target}, manager)
+
+	tmp := filepath.Join(t.TempDir(), "payload.txt")
+	if err := os.WriteFile(tmp, []byte("payload"), 0o644); err != nil {
+		t.Fatalf("failed to write payload file: %v", err)
+	}
+
+	if err := fh.copyToS3(tmp, "sub/file.txt", target); err != nil {
+		t.Fatalf("expected copyToS3 success, got: %v", err)
+	}
+
+	expectedKeys := []string{"prefix/sub/file.txt", "latest/sub/file.txt", "archive/2024/05/sub/file.txt"}
+	fake.mu.Lock()
+	for _, key := range expectedKeys {
+		// The body may be aws-chunked encoded, so only check that the payload is contained
+		if !strings.Contains(string(fake.buckets["bucket-a"][key]), "payload") {
+			t.Errorf("expected object %s with payload, got %q", key, fake.buckets["bucket-a"][key])
+		}
+	}
+	fake.mu.Unlock()
+
+	if err := fh.deleteFromS3("sub/file.txt", target); err != nil {
+		t.Fatalf("expected deleteFromS3 success, got: %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if remaining := len(fake.buckets["bucket-a"]); remaining != 0 {
+		t.Errorf("expected all keys to be removed, %d objects remain", remaining)
+	}
+}
+
+func TestFileHandler_S3AdditionalKeysCleanupAfterDateChange(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	originalNow := templateNow
+	defer func() { templateNow = originalNow }()
+	templateNow = func() time.Time { return time.Date(2024, 5, 31, 23, 59, 59, 0, time.UTC) }
+
+	target := config.OutputTarget{
+		Type:           "s3",
+		Path:           "s3://bucket-a",
+		Endpoint:       strings.TrimPrefix(ts.URL, "http://"),
+		AccessKey:      "key",
+		SecretKey:      "secret",
+		SSL:            boolPtr(false),
+		Region:         "us-east-1",
+		AdditionalKeys: []string{"archive/{yyyy}/{mm}/"},
+	}
+
+	manager := NewS3ClientManager()
+	defer manager.Close()
+	fh := NewFileHandler([]config.OutputTarget{target}, manager)
+
+	tmp := filepath.Join(t.TempDir(), "payload.txt")
+	if err := os.WriteFile(tmp, []byte("payload"), 0o644); err != nil {
+		t.Fatalf("failed to write payload file: %v", err)
+	}
+	if err := fh.copyToS3(tmp, "file.txt", target); err != nil {
+		t.Fatalf("expected copyToS3 success, got: %v", err)
+	}
+
+	// The cleanup runs after midnight on the first day of the next month
+	templateNow = func() time.Time { return time.Date(2024, 6, 1, 0, 0, 1, 0, time.UTC) }
+	if err := fh.cleanupTargetFiles([]config.OutputTarget{target}, "file.txt"); err != nil {
+		t.Fatalf("cleanupTargetFiles() error = %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if remaining := fake.buckets["bucket-a"]; len(remaining) != 0 {
+		keys := make([]string, 0, len(remaining))
+		for key := range remaining {
+			keys = append(keys, key)
+		}
+		t.Errorf("the keys written on upload should be deleted, remaining: %v", keys)
+	}
+}
+
+func TestFileHandler_S3AdditionalKeysForgottenAfterDelivery(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	target := config.OutputTarget{
+		Type:           "s3",
+		Path:           "s3://bucket-a",
+		Endpoint:       strings.TrimPrefix(ts.URL, "http://"),
+		AccessKey:      "key",
+		SecretKey:      "secret",
+		SSL:            boolPtr(false),
+		Region:         "us-east-1",
+		AdditionalKeys: []string{"archive/{yyyy}/{mm}/"},
+	}
+
+	manager := NewS3ClientManager()
+	defer manager.Close()
+	fh := NewFileHandler([]config.OutputTarget{target}, manager)
+
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "file.txt")
+	if err := os.WriteFile(srcFile, []byte("payload"), 0o644); err != nil {
+		t.Fatalf("failed to write payload file: %v", err)
+	}
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	remembered := 0
+	fh.writtenS3Keys.Range(func(any, any) bool {
+		remembered++
+		return true
+	})
+	if remembered != 0 {
+		t.Errorf("%d written key sets remembered after the delivery, want none", remembered)
+	}
+}
+
+func TestFileHandler_S3UserMetadataWithFakeServer(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	originalNow := templateNow
+	defer func() { templateNow = originalNow }()
+	templateNow = func() time.Time { return time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC) }
+
+	target := config.OutputTarget{
+		Type:      "s3",
+		Path:      "s3://bucket-a",
+		Endpoint:  strings.TrimPrefix(ts.URL, "http://"),
+		AccessKey: "key",
+		SecretKey: "secret",
+		SSL:       boolPtr(false),
+		Region:    "us-east-1",
+		UserMetadata: map[string]string{
+			"source-system": "erp",
+			"ingest-batch":  "{yyyy}{mm}{dd}-{filename}",
+		},
+	}
+
+	manager := NewS3ClientManager()
+	defer manager.Close()
+	fh := NewFileHandler([]config.OutputTarget{target}, manager)
+
+	tmp := filepath.Join(t.TempDir(), "payload.txt")
+	if err := os.WriteFile(tmp, []byte("payload"), 0o644); err != nil {
+		t.Fatalf("failed to write payload file: %v", err)
+	}
+
+	if err := fh.copyToS3(tmp, "sub/file.txt", target); err != nil {
+		t.Fatalf("expected copyToS3 success, got: %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	headers := fake.headers["bucket-a/sub/file.txt"]
+	if got := headers.Get("X-Amz-Meta-Source-System"); got != "erp" {
+		t.Errorf("x-amz-meta-source-system = %q, want %q", got, "erp")
+	}
+	if got := headers.Get("X-Amz-Meta-Ingest-Batch"); got != "20240501-file.txt" {
+		t.Errorf("x-amz-meta-ingest-batch = %q, want %q", got, "20240501-file.txt")
+	}
+}
+
+func TestFileHandler_S3StoreChecksumMetadataWithFakeServer(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	target := config.OutputTarget{
+		Type:                  "s3",
+		Path:                  "s3://bucket-a",
+		Endpoint:              strings.TrimPrefix(ts.URL, "http://"),
+		AccessKey:             "key",
+		SecretKey:             "secret",
+		SSL:                   boolPtr(false),
+		Region:                "us-east-1",
+		UserMetadata:          map[string]string{"source-system": "erp"},
+		StoreChecksumMetadata: true,
+	}
+
+	manager := NewS3ClientManager()
+	defer manager.Close()
+	fh := NewFileHandler([]config.OutputTarget{target}, manager)
+
+	tmp := filepath.Join(t.TempDir(), "payload.txt")
+	if err := os.WriteFile(tmp, []byte("payload"), 0o644); err != nil {
+		t.Fatalf("failed to write payload file: %v", err)
+	}
+	checksum, err := fh.calculateFileChecksum(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fh.copyToS3(tmp, "file.txt", target); err != nil {
+		t.Fatalf("expected copyToS3 success, got: %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	headers := fake.headers["bucket-a/file.txt"]
+	if got := headers.Get("X-Amz-Meta-Sha256"); got != checksum {
+		t.Errorf("x-amz-meta-sha256 = %q, want %q", got, checksum)
+	}
+	if got := headers.Get("X-Amz-Meta-Source-System"); got != "erp" {
+		t.Errorf("configured user metadata must be kept, got %q", got)
+	}
+	if _, ok := target.UserMetadata[checksumMetadataKey]; ok {
+		t.Error("the target configuration must not be modified")
+	}
+}
+
+func TestFileHandler_S3StoreChecksumMetadataReusesInitialChecksum(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	target := config.OutputTarget{
+		Type:      "s3",
+		Path:      "s3://bucket-a",
+		Endpoint:  strings.TrimPrefix(ts.URL, "http://"),
+		AccessKey: "key",
+		SecretKey: "secret",
+		SSL:       boolPtr(false),
+		Region:    "us-east-1",
+	}
+	manager := NewS3ClientManager()
+	defer manager.Close()
+
+	sourceOpens := func(storeChecksum bool) int32 {
+		t.Helper()
+		inputDir := t.TempDir()
+		srcFile := filepath.Join(inputDir, "file.txt")
+		if err := os.WriteFile(srcFile, []byte("payload"), 0o644); err != nil {
+			t.Fatalf("failed to write payload file: %v", err)
+		}
+		target := target
+		target.StoreChecksumMetadata = storeChecksum
+		fh := NewFileHandler([]config.OutputTarget{target}, manager)
+		opens := countSourceOpens(t)
+		if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+			t.Fatalf("ProcessFile() error = %v", err)
+		}
+		return opens.Load()
+	}
+
+	without := sourceOpens(false)
+	if with := sourceOpens(true); with != without {
+		t.Errorf("source opened %d times with store-checksum-metadata, want %d as without", with, without)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	want := fmt.Sprintf("%x", sha256.Sum256([]byte("payload")))
+	if got := fake.headers["bucket-a/file.txt"].Get("X-Amz-Meta-Sha256"); got != want {
+		t.Errorf("x-amz-meta-sha256 = %q, want %q", got, want)
+	}
+}
+
+func TestFileHandler_S3StoreChecksumMetadataDecompressed(t *testing.T) {
+	fake := newFakeS3Server()
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	target := config.OutputTarget{
+		Type:                  "s3",
+		Path:                  "s3://bucket-a",
+		Endpoint:              strings.TrimPrefix(ts.URL, "http://"),
+		AccessKey:             "key",
+		SecretKey:             "secret",
+		SSL:                   boolPtr(false),
+		Region:                "us-east-1",
+		ACL:                   "public-read",
+		UserMetadata:          map[string]string{"source-system": "erp"},
+		AdditionalKeys:        []string{"latest/"},
+		StoreChecksumMetadata: true,
+	}
+	manager := NewS3ClientManager()
+	defer manager.Close()
+	fh := NewFileHandler([]config.OutputTarget{target}, manager)
+	fh.Decompress = true
+
+	content := []byte(`{"order":1}`)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "order.json.gz")
+	if err := os.WriteFile(srcFile, compressed.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	want := fmt.Sprintf("%x", sha256.Sum256(content))
+	for _, key := range []string{"order.json", "latest/order.json"} {
+		if stored := fake.buckets["bucket-a"][key]; !bytes.Equal(stored, content) {
+			t.Errorf("%s = %q, want the decompressed content", key, stored)
+		}
+		headers := fake.headers["bucket-a/"+key]
+		if got := headers.Get("X-Amz-Meta-Sha256"); got != want {
+			t.Errorf("%s x-amz-meta-sha256 = %q, want the checksum of the uploaded content %q", key, got, want)
+		}
+		if got := headers.Get("X-Amz-Meta-Source-System"); got != "erp" {
+			t.Errorf("%s x-amz-meta-source-system = %q, want erp", key, got)
+		}
+		if got := headers.Get("X-Amz-Acl"); got != "public-read" {
+			t.Errorf("%s x-amz-acl = %q, want public-read", key, got)
+		}
+		if got := headers.Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s content type = %q, want application/json", key, got)
+		}
+	}
+}
+
+func TestFileHandler_S3BucketCreation(t *testing.T) {
+	tests := []struct {
+		name        string
+		createIfNew *bool
+		dryRun      bool
+		wantErr     bool
+		wantCreated bool
+		wantObject  bool
+	}{
+		{name: "default creates bucket", wantCreated: true, wantObject: true},
+		{name: "creation disabled", createIfNew: boolPtr(false), wantErr: true},
+		{name: "dry run", dryRun: true},
+		{name: "dry run with creation disabled", createIfNew: boolPtr(false), dryRun: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeS3Server()
+			ts := httptest.NewServer(fake)
+			defer ts.Close()
+
+			target := config.OutputTarget{
+				Type:                  "s3",
+				Path:                  "s3://new-bucket",
+				Endpoint:              strings.TrimPrefix(ts.URL, "http://"),
+				AccessKey:             "key",
+				SecretKey:             "secret",
+				SSL:                   boolPtr(false),
+				Region:                "us-east-1",
+				CreateBucketIfMissing: tt.createIfNew,
+			}
+
+			manager := NewS3ClientManager()
+			defer manager.Close()
+			fh := NewFileHandler([]config.OutputTarget{target}, manager)
+			fh.DryRun = tt.dryRun
+
+			tmp := filepath.Join(t.TempDir(), "payload.txt")
+			if err := os.WriteFile(tmp, []byte("payload"), 0o644); err != nil {
+				t.Fatalf("failed to write payload file: %v", err)
+			}
+
+			err := fh.copyToS3(tmp, "file.txt", target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("copyToS3() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			if created := fake.bucketCreations > 0; created != tt.wantCreated {
+				t.Errorf("MakeBucket calls = %d, want bucket created = %v", fake.bucketCreations, tt.wantCreated)
+			}
+			if _, ok := fake.buckets["new-bucket"]["file.txt"]; ok != tt.wantObject {
+				t.Errorf("object uploaded = %v, want %v", ok, tt.wantObject)
+			}
+		})
+	}
+}
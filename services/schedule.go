@@ -0,0 +1,137 @@
+package services
+
+import (
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"file-shifter/config"
+)
+
+// Indirections for scheduled targets, replaceable in tests
+var (
+	scheduleNow           = time.Now
+	scheduleCheckInterval = 30 * time.Second // How often deferred files are checked for opened windows
+)
+
+// deferredDelivery is a source file that still has to be delivered to targets outside their schedule
+type deferredDelivery struct {
+	remaining []config.OutputTarget
+	size      int64
+	modTime   time.Time
+}
+
+// scheduleOpen reports whether a target accepts deliveries now
+func scheduleOpen(target config.OutputTarget, now time.Time) bool {
+	windows, err := config.ParseSchedule(target.Schedule)
+	if err != nil {
+		// Rejected by the configuration validation
+		return true
+	}
+	return config.ScheduleOpen(windows, now)
+}
+
+// splitBySchedule separates the targets whose window is open from those that have to wait
+func splitBySchedule(targets []config.OutputTarget, now time.Time) (due, deferred []config.OutputTarget) {
+	for _, target := range targets {
+		if scheduleOpen(target, now) {
+			due = append(due, target)
+		} else {
+			deferred = append(deferred, target)
+		}
+	}
+	return due, deferred
+}
+
+// remainingTargets returns the targets a deferred file still has to be delivered to.
+// A file changed since its first delivery is delivered to all targets again (ok = false).
+func (fh *FileHandler) remainingTargets(filePath string) ([]config.OutputTarget, bool) {
+	fh.deferredMutex.Lock()
+	defer fh.deferredMutex.Unlock()
+
+	deferred, ok := fh.deferredDeliveries[filePath]
+	if !ok {
+		return nil, false
+	}
+	info, err := os.Stat(filePath)
+	if err != nil || info.Size() != deferred.size || !info.ModTime().Equal(deferred.modTime) {
+		slog.Info("Deferred file changed - delivering to all targets again", "file", filePath)
+		delete(fh.deferredDeliveries, filePath)
+		return nil, false
+	}
+	return deferred.remaining, true
+}
+
+// deferDelivery keeps the source file until the remaining targets have received it
+func (fh *FileHandler) deferDelivery(filePath string, remaining []config.OutputTarget) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		slog.Error("Error reading file information of deferred file", "file", filePath, "error", err)
+		return
+	}
+
+	fh.deferredMutex.Lock()
+	defer fh.deferredMutex.Unlock()
+	if fh.deferredDeliveries == nil {
+		fh.deferredDeliveries = make(map[string]*deferredDelivery)
+	}
+	fh.deferredDeliveries[filePath] = &deferredDelivery{remaining: remaining, size: info.Size(), modTime: info.ModTime()}
+
+	paths := make([]string, 0, len(remaining))
+	for _, target := range remaining {
+		paths = append(paths, target.Path)
+	}
+	slog.Info("Delivery deferred until the schedule of the targets opens - original file kept",
+		"file", filePath, "targets", paths)
+}
+
+// hasDeferredDelivery reports whether a file still waits for targets outside their schedule
+func (fh *FileHandler) hasDeferredDelivery(filePath string) bool {
+	fh.deferredMutex.Lock()
+	defer fh.deferredMutex.Unlock()
+	_, ok := fh.deferredDeliveries[filePath]
+	return ok
+}
+
+// completeDeferredDelivery forgets a file once all targets have received it
+func (fh *FileHandler) completeDeferredDelivery(filePath string) {
+	fh.deferredMutex.Lock()
+	defer fh.deferredMutex.Unlock()
+	delete(fh.deferredDeliveries, filePath)
+}
+
+// DueDeferredFiles returns the deferred files of which at least one remaining target is open now
+func (fh *FileHandler) DueDeferredFiles() []string {
+	now := scheduleNow()
+
+	fh.deferredMutex.Lock()
+	defer fh.deferredMutex.Unlock()
+
+	var due []string
+	for filePath, deferred := range fh.deferredDeliveries {
+		if open, _ := splitBySchedule(deferred.remaining, now); len(open) > 0 {
+			due = append(due, filePath)
+		}
+	}
+	sort.Strings(due)
+	return due
+}
+
+// runScheduledDeliveries queues deferred files again once the window of one of their targets has opened
+func (fw *FileWatcher) runScheduledDeliveries() {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fw.stopChan:
+			return
+		case <-ticker.C:
+			for _, filePath := range fw.fileHandler.DueDeferredFiles() {
+				slog.Info("Schedule of a target opened - queueing deferred file", "file", filePath)
+				fw.processFile(filePath)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileHandler_ScheduledTargetDeferred(t *testing.T) {
+	originalNow := scheduleNow
+	defer func() { scheduleNow = originalNow }()
+	now := time.Date(2025, 3, 10, 21, 0, 0, 0, time.Local)
+	scheduleNow = func() time.Time { return now }
+
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "report.csv")
+	if err := os.WriteFile(srcFile, []byte("a,b\n1,2\n"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	alwaysOn := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+	scheduled := config.OutputTarget{Type: "filesystem", Path: t.TempDir(), Schedule: "22:00-23:00"}
+	fh := NewFileHandler([]config.OutputTarget{alwaysOn, scheduled}, nil)
+
+	// Outside the window only the always-on target receives the file
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(alwaysOn.Path, "report.csv")); err != nil {
+		t.Errorf("always-on target should have received the file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(scheduled.Path, "report.csv")); !os.IsNotExist(err) {
+		t.Error("scheduled target must not receive the file outside its window")
+	}
+	if _, err := os.Stat(srcFile); err != nil {
+		t.Fatalf("source must be kept until the scheduled target has the file: %v", err)
+	}
+	if due := fh.DueDeferredFiles(); len(due) != 0 {
+		t.Errorf("DueDeferredFiles() = %v, want none before the window", due)
+	}
+
+	// Processing again while the window is closed delivers nothing
+	if err := os.Remove(filepath.Join(alwaysOn.Path, "report.csv")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(alwaysOn.Path, "report.csv")); !os.IsNotExist(err) {
+		t.Error("the always-on target must not receive the file twice")
+	}
+
+	// Once the window opens the file is due and only the scheduled target receives it
+	now = now.Add(90 * time.Minute)
+	if due := fh.DueDeferredFiles(); !reflect.DeepEqual(due, []string{srcFile}) {
+		t.Fatalf("DueDeferredFiles() = %v, want [%s]", due, srcFile)
+	}
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(scheduled.Path, "report.csv")); err != nil {
+		t.Errorf("scheduled target should have received the file in its window: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(alwaysOn.Path, "report.csv")); !os.IsNotExist(err) {
+		t.Error("the always-on target must not receive the file twice")
+	}
+	if _, err := os.Stat(srcFile); !os.IsNotExist(err) {
+		t.Error("source should be removed once all targets have the file")
+	}
+	if due := fh.DueDeferredFiles(); len(due) != 0 {
+		t.Errorf("DueDeferredFiles() = %v, want none after the delivery", due)
+	}
+}
+
+func TestFileHandler_ScheduledTargetChangedFile(t *testing.T) {
+	originalNow := scheduleNow
+	defer func() { scheduleNow = originalNow }()
+	scheduleNow = func() time.Time { return time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local) }
+
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "report.csv")
+	if err := os.WriteFile(srcFile, []byte("v1"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	alwaysOn := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+	scheduled := config.OutputTarget{Type: "filesystem", Path: t.TempDir(), Schedule: "22:00-06:00"}
+	fh := NewFileHandler([]config.OutputTarget{alwaysOn, scheduled}, nil)
+
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	// A new version of the deferred file is delivered to the always-on target again
+	if err := os.WriteFile(srcFile, []byte("version 2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(alwaysOn.Path, "report.csv")); err != nil || string(got) != "version 2" {
+		t.Errorf("always-on target content = %q (error %v), want the new version", got, err)
+	}
+}
+
+func TestFileWatcher_ScheduledDeliveryWhenWindowOpens(t *testing.T) {
+	originalNow, originalInterval := scheduleNow, scheduleCheckInterval
+	defer func() {
+		scheduleNow = originalNow
+		scheduleCheckInterval = originalInterval
+	}()
+	var now atomic.Pointer[time.Time]
+	closed := time.Date(2025, 3, 10, 21, 0, 0, 0, time.Local)
+	now.Store(&closed)
+	scheduleNow = func() time.Time { return *now.Load() }
+	scheduleCheckInterval = 10 * time.Millisecond
+
+	inputDir := t.TempDir()
+	alwaysOn := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+	scheduled := config.OutputTarget{Type: "filesystem", Path: t.TempDir(), Schedule: "22:00-23:00"}
+	fw, err := NewFileWatcher(inputDir, NewFileHandler([]config.OutputTarget{alwaysOn, scheduled}, nil), 5, 5*time.Millisecond, 10*time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	fw.lsofAvailable = false
+
+	srcFile := filepath.Join(inputDir, "report.csv")
+	if err := os.WriteFile(srcFile, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	go func() {
+		if err := fw.Start(); err != nil {
+			t.Logf("FileWatcher stopped with error: %v", err)
+		}
+	}()
+	defer fw.Stop()
+
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	waitFor("the always-on delivery", func() bool { return exists(filepath.Join(alwaysOn.Path, "report.csv")) })
+	time.Sleep(50 * time.Millisecond)
+	if exists(filepath.Join(scheduled.Path, "report.csv")) || !exists(srcFile) {
+		t.Fatal("the file must wait in the input directory for the scheduled target")
+	}
+
+	open := closed.Add(90 * time.Minute)
+	now.Store(&open)
+	waitFor("the scheduled delivery", func() bool { return exists(filepath.Join(scheduled.Path, "report.csv")) && !exists(srcFile) })
+}
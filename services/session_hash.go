@@ -0,0 +1,74 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+)
+
+// SessionHash combines the checksums of all files delivered since the start into a single
+// Merkle root, so a batch can be audited against the targets with one value.
+// The root only depends on the set of delivered files: leaves are sha256(relPath NUL checksum)
+// sorted by relative path, pairs are hashed level by level and an odd last node is carried up.
+// A file delivered again under the same path replaces its previous checksum.
+type SessionHash struct {
+	mu        sync.Mutex
+	checksums map[string]string
+}
+
+// SessionHashStatus is the current root of a SessionHash
+type SessionHashStatus struct {
+	Root  string `json:"root"`
+	Files int    `json:"files"`
+}
+
+func NewSessionHash() *SessionHash {
+	return &SessionHash{checksums: make(map[string]string)}
+}
+
+// Add records the checksum of a delivered file
+func (s *SessionHash) Add(relPath, checksum string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checksums[relPath] = checksum
+}
+
+// Status returns the Merkle root of all delivered files (empty if nothing was delivered yet)
+func (s *SessionHash) Status() SessionHashStatus {
+	s.mu.Lock()
+	relPaths := make([]string, 0, len(s.checksums))
+	for relPath := range s.checksums {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+
+	level := make([][]byte, 0, len(relPaths))
+	for _, relPath := range relPaths {
+		leaf := sha256.Sum256([]byte(relPath + "\x00" + s.checksums[relPath]))
+		level = append(level, leaf[:])
+	}
+	s.mu.Unlock()
+
+	return SessionHashStatus{Root: merkleRoot(level), Files: len(relPaths)}
+}
+
+// merkleRoot hashes the nodes pairwise until a single node is left
+func merkleRoot(level [][]byte) string {
+	if len(level) == 0 {
+		return ""
+	}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			node := sha256.Sum256(append(append([]byte{}, level[i]...), level[i+1]...))
+			next = append(next, node[:])
+		}
+		level = next
+	}
+	return hex.EncodeToString(level[0])
+}
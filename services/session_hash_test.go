@@ -0,0 +1,91 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"file-shifter/config"
+)
+
+func TestSessionHash_MatchesIndependentComputation(t *testing.T) {
+	files := map[string]string{
+		"b/report.csv": "report",
+		"a.txt":        "alpha",
+		"c.bin":        "charlie",
+	}
+
+	inputDir := t.TempDir()
+	fh := NewFileHandler([]config.OutputTarget{{Path: t.TempDir(), Type: "filesystem"}}, nil)
+	fh.SessionHash = NewSessionHash()
+	for relPath, content := range files {
+		srcFile := filepath.Join(inputDir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(srcFile), 0755); err != nil {
+			t.Fatalf("failed to create input directory: %v", err)
+		}
+		if err := os.WriteFile(srcFile, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create source file: %v", err)
+		}
+		if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+			t.Fatalf("ProcessFile() error = %v", err)
+		}
+	}
+
+	leaf := func(relPath string) []byte {
+		checksum := sha256.Sum256([]byte(files[relPath]))
+		sum := sha256.Sum256([]byte(relPath + "\x00" + hex.EncodeToString(checksum[:])))
+		return sum[:]
+	}
+	pair := func(left, right []byte) []byte {
+		sum := sha256.Sum256(append(append([]byte{}, left...), right...))
+		return sum[:]
+	}
+	// Sorted: a.txt, b/report.csv, c.bin - the odd last leaf is carried up unchanged
+	want := hex.EncodeToString(pair(pair(leaf("a.txt"), leaf("b/report.csv")), leaf("c.bin")))
+
+	status := fh.SessionHash.Status()
+	if status.Root != want || status.Files != len(files) {
+		t.Errorf("Status() = %+v, want root %s for %d files", status, want, len(files))
+	}
+}
+
+func TestSessionHash_OrderIndependent(t *testing.T) {
+	first, second := NewSessionHash(), NewSessionHash()
+	for _, relPath := range []string{"a", "b", "c", "d", "e"} {
+		first.Add(relPath, "checksum-"+relPath)
+	}
+	for _, relPath := range []string{"e", "c", "a", "d", "b"} {
+		second.Add(relPath, "checksum-"+relPath)
+	}
+	if first.Status() != second.Status() {
+		t.Errorf("root depends on the delivery order: %+v != %+v", first.Status(), second.Status())
+	}
+
+	if status := NewSessionHash().Status(); status.Root != "" || status.Files != 0 {
+		t.Errorf("empty session Status() = %+v, want no root", status)
+	}
+}
+
+func TestHealthMonitor_SessionHash(t *testing.T) {
+	fh := NewFileHandler(nil, nil)
+	fh.SessionHash = NewSessionHash()
+	fh.SessionHash.Add("a.txt", "checksum")
+	fw := &FileWatcher{fileQueue: make(chan string, 10), queueCapacity: 10, workerCount: 1}
+	hm := NewHealthMonitor(&Worker{FileWatcher: fw, FileHandler: fh}, "0")
+
+	rec := httptest.NewRecorder()
+	hm.newMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var response HealthCheck
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode health response: %v", err)
+	}
+	if response.SessionHash == nil || *response.SessionHash != fh.SessionHash.Status() {
+		t.Errorf("session_hash = %+v, want %+v", response.SessionHash, fh.SessionHash.Status())
+	}
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+// defaultSourceRemoveRetries is used if no SourceRemoveRetries is configured
+const defaultSourceRemoveRetries = 3
+
+// Indirections for the source removal (also used for filesystem target cleanup), replaceable in tests
+var (
+	removeFile          = os.Remove
+	sourceRemoveBackoff = 100 * time.Millisecond // Delay before the first retry, doubled for every further retry
+)
+
+// removeSourceFile deletes a transferred source file and retries transient failures with exponential backoff
+func (fh *FileHandler) removeSourceFile(filePath string) error {
+	retries := fh.SourceRemoveRetries
+	if retries <= 0 {
+		retries = defaultSourceRemoveRetries
+	}
+
+	// The remove event of a delivered file must not delete it from the targets again
+	fh.noteSourceRemoval(filePath)
+
+	err := retryWithBackoff(retries+1, sourceRemoveBackoff, func(attempt int) error {
+		err := removeFile(filePath)
+		if os.IsNotExist(err) {
+			if attempt > 1 {
+				// A previous attempt may have succeeded despite reporting an error
+				return nil
+			}
+			return permanent(err)
+		}
+		return err
+	}, func(attempt int, retryIn time.Duration, err error) {
+		slog.Warn("Deleting the original file failed - retrying",
+			"file", filePath, "attempt", attempt, "retry_in", retryIn, "error", err)
+	})
+	if err != nil {
+		fh.forgetSourceRemoval(filePath)
+		return err
+	}
+
+	fh.removeCompletionMarker(filePath)
+	fh.HashCache.Forget(filePath)
+	fh.CopiedFiles.Forget(filePath)
+	return nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+// errSharingViolation simulates Windows' ERROR_SHARING_VIOLATION while a scanner holds the handle
+var errSharingViolation = errors.New("the process cannot access the file because it is being used by another process")
+
+func stubRemoveFile(t *testing.T, failures int) *int {
+	t.Helper()
+	originalRemove, originalBackoff := removeFile, sourceRemoveBackoff
+	t.Cleanup(func() {
+		removeFile = originalRemove
+		sourceRemoveBackoff = originalBackoff
+	})
+	sourceRemoveBackoff = time.Millisecond
+
+	calls := 0
+	removeFile = func(name string) error {
+		calls++
+		if calls <= failures {
+			return &os.PathError{Op: "remove", Path: name, Err: errSharingViolation}
+		}
+		return os.Remove(name)
+	}
+	return &calls
+}
+
+func TestFileHandler_RemoveSourceFileRetriesTransientFailure(t *testing.T) {
+	calls := stubRemoveFile(t, 2)
+
+	filePath := filepath.Join(t.TempDir(), "locked.txt")
+	if err := os.WriteFile(filePath, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler(nil, nil)
+	if err := fh.removeSourceFile(filePath); err != nil {
+		t.Fatalf("expected removal to succeed after retries, got: %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("expected 3 remove attempts, got %d", *calls)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Error("source file should be removed")
+	}
+}
+
+func TestFileHandler_RemoveSourceFileGivesUp(t *testing.T) {
+	calls := stubRemoveFile(t, 100)
+
+	filePath := filepath.Join(t.TempDir(), "locked.txt")
+	if err := os.WriteFile(filePath, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler(nil, nil)
+	fh.SourceRemoveRetries = 2
+	err := fh.removeSourceFile(filePath)
+	if !errors.Is(err, errSharingViolation) {
+		t.Fatalf("expected the last remove error, got: %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("expected 1 attempt plus 2 retries, got %d", *calls)
+	}
+}
+
+func TestFileHandler_ProcessFileRetriesSourceRemoval(t *testing.T) {
+	calls := stubRemoveFile(t, 1)
+
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	filePath := filepath.Join(inputDir, "file.txt")
+	if err := os.WriteFile(filePath, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fh := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	if err := fh.ProcessFile(filePath, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	if *calls != 2 {
+		t.Errorf("expected 2 remove attempts, got %d", *calls)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Error("source file should be removed")
+	}
+}
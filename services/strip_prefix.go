@@ -0,0 +1,30 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+
+	"file-shifter/config"
+)
+
+// targetRelPath returns the relative path under which a file is delivered to a target: the target's
+// StripPrefix is removed if the path starts with it (whole segments only), other paths stay unchanged.
+// The target's rename (see rename.go) is applied afterwards.
+// Transfers and cleanup both use it so they address the same destination.
+func targetRelPath(target config.OutputTarget, relPath string) string {
+	return renameRelPath(target, stripTargetPrefix(target, relPath))
+}
+
+// stripTargetPrefix removes the target's StripPrefix from relPath
+func stripTargetPrefix(target config.OutputTarget, relPath string) string {
+	prefix := strings.Trim(filepath.Clean(filepath.FromSlash(target.StripPrefix)), string(filepath.Separator))
+	if target.StripPrefix == "" || prefix == "." || prefix == "" {
+		return relPath
+	}
+
+	rest, found := strings.CutPrefix(relPath, prefix+string(filepath.Separator))
+	if !found || rest == "" {
+		return relPath
+	}
+	return rest
+}
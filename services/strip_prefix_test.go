@@ -0,0 +1,87 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"file-shifter/config"
+)
+
+func TestTargetRelPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		stripPrefix string
+		relPath     string
+		want        string
+	}{
+		{"no prefix configured", "", "staging/customer/a.csv", "staging/customer/a.csv"},
+		{"prefix present", "staging/customer", "staging/customer/a.csv", "a.csv"},
+		{"nested below prefix", "staging/customer", "staging/customer/2024/a.csv", "2024/a.csv"},
+		{"slashes around prefix", "/staging/customer/", "staging/customer/a.csv", "a.csv"},
+		{"prefix absent", "staging/customer", "other/a.csv", "other/a.csv"},
+		{"partial segment", "staging/cust", "staging/customer/a.csv", "staging/customer/a.csv"},
+		{"file named like prefix", "staging", "staging", "staging"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := config.OutputTarget{Type: "filesystem", Path: "/out", StripPrefix: tt.stripPrefix}
+			if got := targetRelPath(target, filepath.FromSlash(tt.relPath)); got != filepath.FromSlash(tt.want) {
+				t.Errorf("targetRelPath() = %q, want %q", got, filepath.FromSlash(tt.want))
+			}
+		})
+	}
+}
+
+func TestFileHandler_StripPrefix(t *testing.T) {
+	tests := []struct {
+		name         string
+		relPath      string
+		wantLocal    string // Path below the target stripping the prefix
+		wantMirrored string // Path below the target without prefix stripping
+	}{
+		{"prefix present", "staging/customer/report.csv", "report.csv", "staging/customer/report.csv"},
+		{"prefix absent", "other/report.csv", "other/report.csv", "other/report.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputDir := t.TempDir()
+			srcFile := filepath.Join(inputDir, filepath.FromSlash(tt.relPath))
+			if err := os.MkdirAll(filepath.Dir(srcFile), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(srcFile, []byte("data"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			stripped := config.OutputTarget{Type: "filesystem", Path: t.TempDir(), StripPrefix: "staging/customer"}
+			mirrored := config.OutputTarget{Type: "filesystem", Path: t.TempDir()}
+			fh := NewFileHandler([]config.OutputTarget{stripped, mirrored}, nil)
+
+			if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+				t.Fatalf("ProcessFile() error = %v", err)
+			}
+
+			strippedFile := filepath.Join(stripped.Path, filepath.FromSlash(tt.wantLocal))
+			mirroredFile := filepath.Join(mirrored.Path, filepath.FromSlash(tt.wantMirrored))
+			for _, path := range []string{strippedFile, mirroredFile} {
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("expected delivered file %s: %v", path, err)
+				}
+			}
+
+			// Cleanup has to address the same destinations
+			relPath := filepath.FromSlash(tt.relPath)
+			if err := fh.cleanupTargetFiles(fh.OutputTargets(), relPath); err != nil {
+				t.Fatalf("cleanupTargetFiles() error = %v", err)
+			}
+			for _, path := range []string{strippedFile, mirroredFile} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed by cleanup", path)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// subdirLimiter is a keyed semaphore capping the files processed at once per top-level subdirectory.
+// A file over the cap does not block its worker: it is deferred and handed to the worker that
+// releases the next slot of the same subdirectory, so other subdirectories keep being processed.
+type subdirLimiter struct {
+	limit    int
+	mu       sync.Mutex
+	active   map[string]int
+	deferred map[string][]string
+}
+
+// newSubdirLimiter returns nil (no limit) if limit is not positive
+func newSubdirLimiter(limit int) *subdirLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &subdirLimiter{limit: limit, active: make(map[string]int), deferred: make(map[string][]string)}
+}
+
+// acquire takes a slot of key and returns true, or defers filePath and returns false if all slots are taken
+func (l *subdirLimiter) acquire(key, filePath string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[key] < l.limit {
+		l.active[key]++
+		return true
+	}
+	l.deferred[key] = append(l.deferred[key], filePath)
+	return false
+}
+
+// next hands the slot of key to the oldest deferred file, or releases it if none is waiting
+func (l *subdirLimiter) next(key string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if waiting := l.deferred[key]; len(waiting) > 0 {
+		if len(waiting) == 1 {
+			delete(l.deferred, key)
+		} else {
+			l.deferred[key] = waiting[1:]
+		}
+		return waiting[0], true
+	}
+	if l.active[key]--; l.active[key] <= 0 {
+		delete(l.active, key)
+	}
+	return "", false
+}
+
+// topLevelDir returns the first path segment below the input directory, "." for files directly in it
+func (fw *FileWatcher) topLevelDir(filePath string) string {
+	rel := filepath.ToSlash(fw.relativePath(filePath))
+	dir, _, found := strings.Cut(rel, "/")
+	if !found {
+		return "."
+	}
+	return dir
+}
+
+// processWithSubdirLimit processes the file once its top-level subdirectory has a free slot,
+// followed by the files deferred for that subdirectory in the meantime
+func (fw *FileWatcher) processWithSubdirLimit(filePath string, process func(string)) {
+	if fw.subdirLimit == nil {
+		process(filePath)
+		return
+	}
+
+	key := fw.topLevelDir(filePath)
+	if !fw.subdirLimit.acquire(key, filePath) {
+		return
+	}
+	for ok := true; ok; filePath, ok = fw.subdirLimit.next(key) {
+		process(filePath)
+	}
+}
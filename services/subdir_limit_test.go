@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileWatcher_MaxPerSubdirInterleavesBursts(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	var files []string
+	for _, burst := range []struct {
+		dir   string
+		count int
+	}{{"a", 6}, {"b", 3}} {
+		if err := os.Mkdir(filepath.Join(inputDir, burst.dir), 0755); err != nil {
+			t.Fatalf("failed to create subdirectory: %v", err)
+		}
+		for i := 1; i <= burst.count; i++ {
+			path := filepath.Join(inputDir, burst.dir, fmt.Sprintf("file%d.txt", i))
+			if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+			files = append(files, path)
+		}
+	}
+
+	// Record the order in which transfers start and the concurrency per subdirectory
+	var mu sync.Mutex
+	var started []string
+	active := map[string]int{}
+	maxActive := map[string]int{}
+	original := openSourceFile
+	openSourceFile = func(name string) (*os.File, error) {
+		dir := filepath.Base(filepath.Dir(name))
+		mu.Lock()
+		started = append(started, dir)
+		active[dir]++
+		maxActive[dir] = max(maxActive[dir], active[dir])
+		mu.Unlock()
+
+		time.Sleep(30 * time.Millisecond)
+
+		mu.Lock()
+		active[dir]--
+		mu.Unlock()
+		return original(name)
+	}
+	defer func() { openSourceFile = original }()
+
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	fw, err := NewFileWatcher(inputDir, fileHandler, 1, time.Millisecond, time.Millisecond, 4, len(files))
+	if err != nil {
+		t.Fatalf("failed to create file watcher: %v", err)
+	}
+	defer fw.watcher.Close()
+	fw.subdirLimit = newSubdirLimiter(2)
+
+	// The whole burst of a/ is queued before b/
+	for _, path := range files {
+		fw.tryMarkFileForProcessing(path)
+		fw.enqueueFileWithMonitoring(path)
+	}
+	fw.startWorkers()
+	close(fw.fileQueue)
+	fw.workers.Wait()
+
+	for _, path := range files {
+		rel, _ := filepath.Rel(inputDir, path)
+		if _, err := os.Stat(filepath.Join(outputDir, rel)); err != nil {
+			t.Errorf("file %s not delivered: %v", rel, err)
+		}
+	}
+	for dir, count := range maxActive {
+		if count > 2 {
+			t.Errorf("%d files of %s/ processed at once, want at most 2", count, dir)
+		}
+	}
+	// Without the cap all workers would take a/ files first, b/ would only start at the end
+	lastStart := map[string]int{}
+	for i, dir := range started {
+		lastStart[dir] = i
+	}
+	if lastStart["b"] >= lastStart["a"] {
+		t.Errorf("b/ was not interleaved with the a/ burst, start order %v", started)
+	}
+}
+
+func TestSubdirLimiter(t *testing.T) {
+	limiter := newSubdirLimiter(1)
+
+	if !limiter.acquire("a", "a/1") {
+		t.Fatal("first file of a/ should get a slot")
+	}
+	if limiter.acquire("a", "a/2") {
+		t.Fatal("second file of a/ should be deferred")
+	}
+	if !limiter.acquire("b", "b/1") {
+		t.Fatal("b/ must not be limited by a/")
+	}
+
+	if next, ok := limiter.next("a"); !ok || next != "a/2" {
+		t.Fatalf("next(a) = %q, %v, want the deferred a/2", next, ok)
+	}
+	if _, ok := limiter.next("a"); ok {
+		t.Fatal("no file of a/ should be left")
+	}
+	if !limiter.acquire("a", "a/3") {
+		t.Fatal("slot of a/ should be free again")
+	}
+
+	if newSubdirLimiter(0) != nil {
+		t.Error("limit 0 should disable the limiter")
+	}
+}
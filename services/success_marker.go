@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// successMarkerPollInterval is how often the marker loop checks whether no file is in flight
+var successMarkerPollInterval = 100 * time.Millisecond
+
+// WriteSuccessMarker delivers an empty file with the given name to all targets, like Hadoop's _SUCCESS
+func (fh *FileHandler) WriteSuccessMarker(name string) error {
+	marker, err := os.CreateTemp("", "file-shifter-marker-*")
+	if err != nil {
+		return fmt.Errorf("error creating success marker: %w", err)
+	}
+	defer os.Remove(marker.Name())
+	if err := marker.Close(); err != nil {
+		return fmt.Errorf("error creating success marker: %w", err)
+	}
+
+	fileInfo, err := os.Stat(marker.Name())
+	if err != nil {
+		return fmt.Errorf("error reading success marker: %w", err)
+	}
+	return fh.copyToAllTargets(fh.OutputTargets(), marker.Name(), name, fileInfo)
+}
+
+// noteDeliveryResult records the outcome of a processed file for the success marker and a single run
+func (fw *FileWatcher) noteDeliveryResult(err error) {
+	if err != nil {
+		fw.failedDeliveries.Add(1)
+	}
+	if fw.successMarker == "" {
+		return
+	}
+	if err != nil {
+		fw.markerFailed.Store(true)
+	}
+	fw.lastDelivery.Store(time.Now().UnixNano())
+	fw.deliveredSinceMarker.Store(true)
+}
+
+// runSuccessMarker writes the success marker once the backlog of the initial scan has drained and,
+// with a quiet period, again whenever no file has been in flight for that long after new deliveries
+func (fw *FileWatcher) runSuccessMarker(scanDone <-chan struct{}) {
+	select {
+	case <-fw.stopChan:
+		return
+	case <-scanDone:
+	}
+
+	ticker := time.NewTicker(successMarkerPollInterval)
+	defer ticker.Stop()
+
+	initialBacklog := true
+	for {
+		select {
+		case <-fw.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		if fw.inFlightCount() > 0 {
+			continue
+		}
+		if initialBacklog {
+			initialBacklog = false
+			fw.completeBatch()
+			if fw.successMarkerQuiet <= 0 {
+				return
+			}
+			continue
+		}
+		if time.Since(time.Unix(0, fw.lastDelivery.Load())) >= fw.successMarkerQuiet {
+			fw.completeBatch()
+		}
+	}
+}
+
+// completeBatch writes the success marker if files have been delivered since the last one.
+// A batch with failed deliveries gets no marker.
+func (fw *FileWatcher) completeBatch() {
+	if !fw.deliveredSinceMarker.Swap(false) {
+		return
+	}
+	if fw.markerFailed.Swap(false) {
+		slog.Warn("Success marker not written - not all files of the batch were delivered", "marker", fw.successMarker)
+		return
+	}
+
+	if err := fw.fileHandler.WriteSuccessMarker(fw.successMarker); err != nil {
+		slog.Error("Error writing success marker", "marker", fw.successMarker, "error", err)
+		return
+	}
+	slog.Info("Success marker written", "marker", fw.successMarker)
+}
+
+// inFlightCount returns the number of files that are currently queued or being processed
+func (fw *FileWatcher) inFlightCount() int {
+	fw.processingMutex.Lock()
+	defer fw.processingMutex.Unlock()
+	return len(fw.processingFiles)
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileWatcher_SuccessMarkerAfterInitialScan(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	files := []string{"a.csv", "b.csv", "c.csv"}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("content of "+name), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, NewS3ClientManager())
+	fw, err := NewFileWatcher(inputDir, fileHandler, 5, 10*time.Millisecond, 20*time.Millisecond, 2, 10)
+	if err != nil {
+		t.Fatalf("failed to create file watcher: %v", err)
+	}
+	fw.lsofAvailable = false
+	fw.procAvailable = false
+	fw.successMarker = "_SUCCESS"
+
+	go func() {
+		if err := fw.Start(); err != nil {
+			t.Logf("FileWatcher stopped with error: %v", err)
+		}
+	}()
+	defer fw.Stop()
+
+	markerPath := filepath.Join(outputDir, "_SUCCESS")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(markerPath); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("success marker was not written after the initial scan")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// The marker may only appear once every file of the backlog has been delivered
+	if fw.QueueSize() != 0 || fw.inFlightCount() != 0 {
+		t.Errorf("marker written with queue size %d and %d files in flight", fw.QueueSize(), fw.inFlightCount())
+	}
+	for _, name := range files {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("file %s not delivered before the marker: %v", name, err)
+		}
+	}
+	if info, err := os.Stat(markerPath); err != nil || info.Size() != 0 {
+		t.Errorf("marker must be an empty file, got %v (error %v)", info, err)
+	}
+}
+
+func TestFileWatcher_CompleteBatch(t *testing.T) {
+	outputDir := t.TempDir()
+	fw := &FileWatcher{
+		fileHandler:   NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil),
+		successMarker: "_SUCCESS",
+	}
+	markerPath := filepath.Join(outputDir, "_SUCCESS")
+
+	fw.completeBatch()
+	if _, err := os.Stat(markerPath); !os.IsNotExist(err) {
+		t.Fatal("no marker expected without deliveries")
+	}
+
+	fw.noteDeliveryResult(os.ErrPermission)
+	fw.noteDeliveryResult(nil)
+	fw.completeBatch()
+	if _, err := os.Stat(markerPath); !os.IsNotExist(err) {
+		t.Fatal("no marker expected for a batch with failed deliveries")
+	}
+
+	fw.noteDeliveryResult(nil)
+	fw.completeBatch()
+	if _, err := os.Stat(markerPath); err != nil {
+		t.Fatalf("marker expected after a successful batch: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// tempPath returns the path a filesystem copy is written to before it is complete: a hidden file
+// ".<name>.<pid>-<random><suffix>" next to the target, so delivered files never look like temp files
+func (fh *FileHandler) tempPath(targetPath string) string {
+	if fh.TempSuffix == "" {
+		return targetPath
+	}
+	name := fmt.Sprintf(".%s.%d-%08x%s", filepath.Base(targetPath), os.Getpid(), rand.Uint32(), fh.TempSuffix)
+	return filepath.Join(filepath.Dir(targetPath), name)
+}
+
+// tempFilePattern matches the names created by tempPath with the given suffix
+func tempFilePattern(suffix string) *regexp.Regexp {
+	return regexp.MustCompile(`^\..+\.[0-9]+-[0-9a-f]{8}` + regexp.QuoteMeta(suffix) + `$`)
+}
+
+// CleanupStaleTempFiles removes temp files older than maxAge from all filesystem targets.
+// They are left behind when the process crashes during a copy. Only names created by tempPath are removed,
+// delivered files ending in the suffix are kept. It returns the number of removed files.
+func (fh *FileHandler) CleanupStaleTempFiles(maxAge time.Duration) int {
+	if fh.TempSuffix == "" || fh.DryRun {
+		return 0
+	}
+
+	pattern := tempFilePattern(fh.TempSuffix)
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, target := range fh.OutputTargets() {
+		if target.Type != "filesystem" {
+			continue
+		}
+
+		err := filepath.WalkDir(target.Path, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == target.Path {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if !entry.Type().IsRegular() || !pattern.MatchString(entry.Name()) {
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil || !info.ModTime().Before(cutoff) {
+				return nil
+			}
+			if err := os.Remove(path); err != nil {
+				slog.Warn("Error removing stale temp file", "file", path, "error", err)
+				return nil
+			}
+			slog.Info("Stale temp file removed", "file", path, "modified", info.ModTime())
+			removed++
+			return nil
+		})
+		if err != nil {
+			slog.Warn("Error cleaning up stale temp files", "target", target.Path, "error", err)
+		}
+	}
+	return removed
+}
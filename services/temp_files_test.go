@@ -0,0 +1,155 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestNewWorker_RemovesStaleTempFiles(t *testing.T) {
+	targetDir := t.TempDir()
+	nestedDir := filepath.Join(targetDir, "nested")
+	if err := os.MkdirAll(nestedDir, 0755); err != nil {
+		t.Fatalf("failed to create target subdirectory: %v", err)
+	}
+
+	stale := filepath.Join(nestedDir, ".old.csv.4711-0badcafe.partial")
+	recent := filepath.Join(targetDir, ".new.csv.4711-0badcafe.partial")
+	staleOther := filepath.Join(targetDir, "old.csv")
+	// Delivered files keep the source modification time and may end in the suffix themselves
+	delivered := filepath.Join(targetDir, "export.partial")
+	for _, path := range []string{stale, recent, staleOther, delivered} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", path, err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	for _, path := range []string{stale, staleOther, delivered} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("failed to age %s: %v", path, err)
+		}
+	}
+
+	cfg := createDefaultConfig()
+	cfg.Filesystem.TempSuffix = ".partial"
+	cfg.Filesystem.StaleTempAge = int(time.Hour / time.Millisecond)
+
+	if _, err := NewWorker(t.TempDir(), createFilesystemTargets(targetDir), cfg); err != nil {
+		t.Fatalf("NewWorker failed: %v", err)
+	}
+
+	// The sweep runs in the background
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(stale); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected stale temp file to be removed after startup")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("expected recent temp file to be kept: %v", err)
+	}
+	if _, err := os.Stat(staleOther); err != nil {
+		t.Errorf("expected old file without temp suffix to be kept: %v", err)
+	}
+	if _, err := os.Stat(delivered); err != nil {
+		t.Errorf("expected delivered file ending in the temp suffix to be kept: %v", err)
+	}
+}
+
+func TestFileHandler_CleanupStaleTempFilesKeepsDeliveredFiles(t *testing.T) {
+	srcFile := filepath.Join(t.TempDir(), "report.tmp")
+	if err := os.WriteFile(srcFile, []byte("a,b\n"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(srcFile, old, old); err != nil {
+		t.Fatalf("failed to age source file: %v", err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatalf("failed to stat source file: %v", err)
+	}
+
+	targetDir := t.TempDir()
+	fh := NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: targetDir}}, nil)
+	fh.TempSuffix = ".tmp"
+
+	if err := fh.copyToAllTargets(fh.OutputTargets(), srcFile, "report.tmp", fileInfo); err != nil {
+		t.Fatalf("copyToAllTargets() error = %v", err)
+	}
+
+	if removed := fh.CleanupStaleTempFiles(24 * time.Hour); removed != 0 {
+		t.Errorf("CleanupStaleTempFiles() removed %d files, want 0", removed)
+	}
+	if data, err := os.ReadFile(filepath.Join(targetDir, "report.tmp")); err != nil || string(data) != "a,b\n" {
+		t.Errorf("delivered report.tmp = %q, %v, want it kept", data, err)
+	}
+}
+
+func TestTempFilePattern(t *testing.T) {
+	fh := &FileHandler{TempSuffix: ".tmp"}
+	pattern := tempFilePattern(fh.TempSuffix)
+
+	if name := filepath.Base(fh.tempPath("/data/report.csv")); !pattern.MatchString(name) {
+		t.Errorf("temp file name %q does not match the cleanup pattern", name)
+	}
+	for _, name := range []string{"report.tmp", "report.csv.tmp", ".report.tmp", ".report.csv.12-abc.tmp"} {
+		if pattern.MatchString(name) {
+			t.Errorf("name %q matches the cleanup pattern, want it treated as a delivered file", name)
+		}
+	}
+}
+
+func TestFileHandler_CopyToFilesystemTempSuffix(t *testing.T) {
+	srcFile := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(srcFile, []byte("a,b\n"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	fileInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatalf("failed to stat source file: %v", err)
+	}
+
+	targetDirs := []string{t.TempDir(), t.TempDir()}
+	fh := NewFileHandler([]config.OutputTarget{
+		{Type: "filesystem", Path: targetDirs[0]},
+		{Type: "filesystem", Path: targetDirs[1]},
+	}, nil)
+	fh.TempSuffix = ".partial"
+
+	// The temp file is in place while the content is written
+	originalSync := syncFile
+	defer func() { syncFile = originalSync }()
+	var tempSeen int
+	syncFile = func(file *os.File) error {
+		if filepath.Ext(file.Name()) == ".partial" {
+			tempSeen++
+		}
+		return nil
+	}
+	fh.Fsync = true
+
+	if err := fh.copyToAllTargets(fh.OutputTargets(), srcFile, "report.csv", fileInfo); err != nil {
+		t.Fatalf("copyToAllTargets() error = %v", err)
+	}
+
+	if tempSeen != len(targetDirs) {
+		t.Errorf("content synced to %d temp files, want %d", tempSeen, len(targetDirs))
+	}
+	for _, dir := range targetDirs {
+		data, err := os.ReadFile(filepath.Join(dir, "report.csv"))
+		if err != nil || string(data) != "a,b\n" {
+			t.Errorf("target in %s = %q, %v", dir, data, err)
+		}
+		if leftovers, _ := filepath.Glob(filepath.Join(dir, ".report.csv.*")); len(leftovers) > 0 {
+			t.Errorf("expected no temp file left in %s, found %v", dir, leftovers)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"file-shifter/config"
+)
+
+// templateNow returns the time used to expand path templates, replaceable in tests
+var templateNow = time.Now
+
+// expandPathTemplate replaces the supported placeholders in a path template:
+// {relpath}, {filename}, {yyyy}, {mm} and {dd}
+func expandPathTemplate(tmpl, relPath string, now time.Time) string {
+	relPath = normalizeRemotePath(relPath)
+	replacer := strings.NewReplacer(
+		"{relpath}", relPath,
+		"{filename}", filepath.Base(relPath),
+		"{yyyy}", now.Format("2006"),
+		"{mm}", now.Format("01"),
+		"{dd}", now.Format("02"),
+	)
+	return replacer.Replace(tmpl)
+}
+
+// additionalS3Keys expands the additional key templates of a target. Templates without
+// {relpath} or {filename} are treated as prefixes to which the relative path is appended.
+func additionalS3Keys(templates []string, relPath string) []string {
+	now := templateNow()
+	keys := make([]string, 0, len(templates))
+	for _, tmpl := range templates {
+		key := expandPathTemplate(tmpl, relPath, now)
+		if !strings.Contains(tmpl, "{relpath}") && !strings.Contains(tmpl, "{filename}") {
+			key = path.Join(key, normalizeRemotePath(relPath))
+		}
+		keys = append(keys, strings.TrimPrefix(normalizeRemotePath(key), "/"))
+	}
+	return keys
+}
+
+// hasDatePlaceholder reports whether any template depends on the current date
+func hasDatePlaceholder(templates []string) bool {
+	for _, tmpl := range templates {
+		if strings.Contains(tmpl, "{yyyy}") || strings.Contains(tmpl, "{mm}") || strings.Contains(tmpl, "{dd}") {
+			return true
+		}
+	}
+	return false
+}
+
+// writtenS3KeysKey identifies the delivery of a file to an S3 target
+type writtenS3KeysKey struct {
+	endpoint, targetPath, relPath string
+}
+
+// writtenS3KeysEntry holds the additional keys of an upload and the source file they belong to
+type writtenS3KeysEntry struct {
+	srcPath string
+	keys    []string
+}
+
+// uploadS3Keys expands the additional keys of an upload. Keys depending on the date are remembered while the file
+// is processed, so a cleanup after midnight (checksum mismatch, file timeout) deletes exactly the keys that have
+// been written.
+func (fh *FileHandler) uploadS3Keys(srcPath string, target config.OutputTarget, relPath string) []string {
+	keys := additionalS3Keys(target.AdditionalKeys, relPath)
+	if hasDatePlaceholder(target.AdditionalKeys) {
+		fh.writtenS3Keys.Store(writtenS3KeysKey{target.Endpoint, target.Path, relPath}, writtenS3KeysEntry{srcPath, keys})
+	}
+	return keys
+}
+
+// deliveredS3Keys returns the additional keys written for a file, expanded with the current date if they are
+// unknown (e.g. a propagated delete after the delivery)
+func (fh *FileHandler) deliveredS3Keys(target config.OutputTarget, relPath string) []string {
+	if entry, ok := fh.writtenS3Keys.Load(writtenS3KeysKey{target.Endpoint, target.Path, relPath}); ok {
+		return entry.(writtenS3KeysEntry).keys
+	}
+	return additionalS3Keys(target.AdditionalKeys, relPath)
+}
+
+// forgetS3Keys drops the remembered keys of a file once they have been deleted
+func (fh *FileHandler) forgetS3Keys(target config.OutputTarget, relPath string) {
+	fh.writtenS3Keys.Delete(writtenS3KeysKey{target.Endpoint, target.Path, relPath})
+}
+
+// forgetS3KeysOf drops the remembered keys of all uploads of a source file once its processing has finished and
+// no cleanup of the attempt can follow
+func (fh *FileHandler) forgetS3KeysOf(srcPath string) {
+	fh.writtenS3Keys.Range(func(key, entry any) bool {
+		if entry.(writtenS3KeysEntry).srcPath == srcPath {
+			fh.writtenS3Keys.Delete(key)
+		}
+		return true
+	})
+}
+
+// s3UserMetadata expands the placeholders in the configured user metadata values
+func s3UserMetadata(metadata map[string]string, relPath string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	now := templateNow()
+	expanded := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		expanded[key] = expandPathTemplate(value, relPath, now)
+	}
+	return expanded
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAdditionalS3Keys(t *testing.T) {
+	originalNow := templateNow
+	defer func() { templateNow = originalNow }()
+	templateNow = func() time.Time { return time.Date(2024, 3, 7, 12, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name      string
+		templates []string
+		relPath   string
+		expected  []string
+	}{
+		{"no templates", nil, "file.txt", []string{}},
+		{"static prefix", []string{"latest/"}, "sub/file.txt", []string{"latest/sub/file.txt"}},
+		{"date prefix", []string{"archive/{yyyy}/{mm}/"}, "file.txt", []string{"archive/2024/03/file.txt"}},
+		{"explicit filename", []string{"by-day/{dd}/{filename}"}, "sub/file.txt", []string{"by-day/07/file.txt"}},
+		{"explicit relpath", []string{"/mirror/{relpath}"}, "sub\\file.txt", []string{"mirror/sub/file.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := additionalS3Keys(tt.templates, tt.relPath)
+			if len(keys) != len(tt.expected) {
+				t.Fatalf("additionalS3Keys() = %v, want %v", keys, tt.expected)
+			}
+			for i := range keys {
+				if keys[i] != tt.expected[i] {
+					t.Errorf("additionalS3Keys()[%d] = %q, want %q", i, keys[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestS3UserMetadata(t *testing.T) {
+	originalNow := templateNow
+	defer func() { templateNow = originalNow }()
+	templateNow = func() time.Time { return time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC) }
+
+	got := s3UserMetadata(map[string]string{
+		"static":  "erp",
+		"batch":   "{yyyy}-{mm}-{dd}",
+		"origin":  "{relpath}",
+		"product": "{filename}",
+	}, "sub/dir/report.csv")
+
+	want := map[string]string{
+		"static":  "erp",
+		"batch":   "2024-12-24",
+		"origin":  "sub/dir/report.csv",
+		"product": "report.csv",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("s3UserMetadata() = %v, want %v", got, want)
+	}
+
+	if got := s3UserMetadata(nil, "file.txt"); got != nil {
+		t.Errorf("s3UserMetadata(nil) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// maxThrottleChunk bounds a single read of a throttled transfer so the rate stays smooth
+const maxThrottleChunk = 32 * 1024
+
+// rateLimiter limits the combined rate (bytes or files per second) of everything sharing it
+type rateLimiter struct {
+	mu         sync.Mutex
+	ratePerSec int
+	next       time.Time // Point in time at which all reserved units have been "paid"
+}
+
+// newRateLimiter returns a limiter for ratePerSec units per second, nil if ratePerSec is not positive
+func newRateLimiter(ratePerSec int) *rateLimiter {
+	if ratePerSec <= 0 {
+		return nil
+	}
+	return &rateLimiter{ratePerSec: ratePerSec}
+}
+
+// reserve accounts n already consumed units and returns how long the caller has to wait
+func (l *rateLimiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(l.duration(n))
+	return l.next.Sub(now)
+}
+
+// reserveTurn reserves a single unit before it is consumed and returns how long the caller has to wait for its turn
+func (l *rateLimiter) reserveTurn() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	start := l.next
+	l.next = l.next.Add(l.duration(1))
+	return start.Sub(now)
+}
+
+func (l *rateLimiter) duration(n int) time.Duration {
+	return time.Duration(float64(n) / float64(l.ratePerSec) * float64(time.Second))
+}
+
+// throttledReader delays reads so the slowest of its limiters is respected
+type throttledReader struct {
+	reader   io.Reader
+	limiters []*rateLimiter
+	chunk    int
+}
+
+// throttleReader wraps r with the given limiters, r is returned unchanged without limiters
+func throttleReader(r io.Reader, limiters []*rateLimiter) io.Reader {
+	if len(limiters) == 0 {
+		return r
+	}
+	return &throttledReader{reader: r, limiters: limiters, chunk: throttleChunk(limiters)}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > t.chunk {
+		p = p[:t.chunk]
+	}
+	n, err := t.reader.Read(p)
+	time.Sleep(reserveAll(t.limiters, n))
+	return n, err
+}
+
+// throttledWriter delays writes so the slowest of its limiters is respected
+type throttledWriter struct {
+	writer   io.Writer
+	limiters []*rateLimiter
+	chunk    int
+}
+
+// throttleWriter wraps w with the given limiters, w is returned unchanged without limiters
+func throttleWriter(w io.Writer, limiters []*rateLimiter) io.Writer {
+	if len(limiters) == 0 {
+		return w
+	}
+	return &throttledWriter{writer: w, limiters: limiters, chunk: throttleChunk(limiters)}
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n, err := t.writer.Write(p[:min(len(p), t.chunk)])
+		written += n
+		time.Sleep(reserveAll(t.limiters, n))
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// throttleChunk returns the largest single read or write that keeps the rate of all limiters smooth
+func throttleChunk(limiters []*rateLimiter) int {
+	chunk := maxThrottleChunk
+	for _, limiter := range limiters {
+		chunk = min(chunk, max(limiter.ratePerSec/10, 1))
+	}
+	return chunk
+}
+
+// reserveAll accounts n consumed units on all limiters and returns the longest wait
+func reserveAll(limiters []*rateLimiter, n int) time.Duration {
+	var delay time.Duration
+	for _, limiter := range limiters {
+		delay = max(delay, limiter.reserve(n))
+	}
+	return delay
+}
+
+// transferLimiters returns the global limiter and the limiter of the given target, if configured.
+// Limiters are shared between all files, so concurrent transfers to one target share its bandwidth.
+func (fh *FileHandler) transferLimiters(targetType, targetPath string) []*rateLimiter {
+	var limiters []*rateLimiter
+	if limiter := fh.limiter("", fh.MaxBytesPerSec); limiter != nil {
+		limiters = append(limiters, limiter)
+	}
+	for _, target := range fh.OutputTargets() {
+		if target.Type == targetType && target.Path == targetPath {
+			if limiter := fh.limiter(targetType+"|"+targetPath, target.Transfer.MaxBytesPerSec); limiter != nil {
+				limiters = append(limiters, limiter)
+			}
+			break
+		}
+	}
+	return limiters
+}
+
+// limiter returns the limiter registered under key, nil if bytesPerSec is not positive
+func (fh *FileHandler) limiter(key string, bytesPerSec int) *rateLimiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+
+	fh.limitersMutex.Lock()
+	defer fh.limitersMutex.Unlock()
+
+	if fh.limiters == nil {
+		fh.limiters = make(map[string]*rateLimiter)
+	}
+	limiter, ok := fh.limiters[key]
+	if !ok {
+		limiter = newRateLimiter(bytesPerSec)
+		fh.limiters[key] = limiter
+	}
+	return limiter
+}
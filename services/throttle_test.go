@@ -0,0 +1,79 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileHandler_PerTargetBandwidthLimit(t *testing.T) {
+	srcPath := filepath.Join(t.TempDir(), "payload.bin")
+	if err := os.WriteFile(srcPath, make([]byte, 20*1024), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slow := config.OutputTarget{Path: t.TempDir(), Type: "filesystem"}
+	slow.Transfer.MaxBytesPerSec = 40 * 1024
+	fast := config.OutputTarget{Path: t.TempDir(), Type: "filesystem"}
+	fh := NewFileHandler([]config.OutputTarget{slow, fast}, nil)
+
+	timeCopy := func(target config.OutputTarget) time.Duration {
+		t.Helper()
+		start := time.Now()
+		if err := fh.copyToTarget(srcPath, "payload.bin", target, info); err != nil {
+			t.Fatalf("copyToTarget(%s) error = %v", target.Path, err)
+		}
+		return time.Since(start)
+	}
+
+	// 20 KiB at 40 KiB/s take about 500ms
+	if elapsed := timeCopy(slow); elapsed < 400*time.Millisecond {
+		t.Errorf("throttled target took %v, expected at least 400ms", elapsed)
+	}
+	if elapsed := timeCopy(fast); elapsed > 200*time.Millisecond {
+		t.Errorf("unthrottled target took %v, expected no throttling", elapsed)
+	}
+}
+
+func TestFileHandler_BandwidthLimitUsesMinimum(t *testing.T) {
+	srcPath := filepath.Join(t.TempDir(), "payload.bin")
+	if err := os.WriteFile(srcPath, make([]byte, 20*1024), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := config.OutputTarget{Path: t.TempDir(), Type: "filesystem"}
+	target.Transfer.MaxBytesPerSec = 10 * 1024 * 1024
+	fh := NewFileHandler([]config.OutputTarget{target}, nil)
+	fh.MaxBytesPerSec = 40 * 1024
+
+	start := time.Now()
+	if err := fh.copyToTarget(srcPath, "payload.bin", target, info); err != nil {
+		t.Fatalf("copyToTarget() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("global limit should apply to a faster target, took %v", elapsed)
+	}
+}
+
+func TestThrottleReaderWithoutLimiters(t *testing.T) {
+	file, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if got := throttleReader(file, nil); got != file {
+		t.Error("reader without limiters should be returned unchanged")
+	}
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"file-shifter/config"
+)
+
+// newTLSConfig builds the client TLS configuration for all targets. Without a CA file the system roots are
+// used; the minimum version is always set, config.DefaultTLSMinVersion if none is configured.
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	minVersion, err := cfg.MinTLSVersion()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{MinVersion: minVersion}
+
+	if cfg.CAFile != "" {
+		pool, err := loadCAFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.InsecureSkipVerify {
+		slog.Warn("!!! TLS CERTIFICATE VERIFICATION IS DISABLED (tls.insecure-skip-verify) - connections are open to " +
+			"man-in-the-middle attacks, never use this in production !!!")
+		tlsConfig.InsecureSkipVerify = true // NOSONAR - explicitly requested, reported as degraded health
+	}
+
+	return tlsConfig, nil
+}
+
+// loadCAFile returns the system roots extended by the certificates in caFile
+func loadCAFile(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA file: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid certificates found in CA file %s", caFile)
+	}
+	return pool, nil
+}
+
+// newHTTPTransport returns a default HTTP transport using the given TLS configuration
+func newHTTPTransport(tlsConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+	return transport
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/pem"
+	"file-shifter/config"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCAFile stores the certificate of a httptest TLS server as PEM CA file
+func writeCAFile(t *testing.T, ts *httptest.Server) string {
+	t.Helper()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return caFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Run("no CA file uses the system roots", func(t *testing.T) {
+		tlsConfig, err := newTLSConfig(config.TLSConfig{})
+		if err != nil || tlsConfig == nil {
+			t.Fatalf("newTLSConfig() = %v, %v, want a configuration", tlsConfig, err)
+		}
+		if tlsConfig.RootCAs != nil || tlsConfig.InsecureSkipVerify {
+			t.Errorf("newTLSConfig() = %+v, want system roots with verification", tlsConfig)
+		}
+	})
+
+	t.Run("missing CA file", func(t *testing.T) {
+		if _, err := newTLSConfig(config.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+			t.Fatal("expected error for a missing CA file")
+		}
+	})
+
+	t.Run("CA file without certificates", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "empty.pem")
+		if err := os.WriteFile(caFile, []byte("not a certificate"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newTLSConfig(config.TLSConfig{CAFile: caFile}); err == nil {
+			t.Fatal("expected error for a CA file without certificates")
+		}
+	})
+}
+
+func TestNewTLSConfig_MinVersionSetOnTransports(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.TLSConfig
+		want uint16
+	}{
+		{name: "all defaults", cfg: config.TLSConfig{}, want: tls.VersionTLS12},
+		{name: "default with insecure-skip-verify", cfg: config.TLSConfig{InsecureSkipVerify: true}, want: tls.VersionTLS12},
+		{name: "explicit 1.2", cfg: config.TLSConfig{MinVersion: config.TLSVersion12}, want: tls.VersionTLS12},
+		{name: "explicit 1.3", cfg: config.TLSConfig{MinVersion: config.TLSVersion13}, want: tls.VersionTLS13},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := newTLSConfig(tt.cfg)
+			if err != nil {
+				t.Fatalf("newTLSConfig() error = %v", err)
+			}
+
+			minioTransport, err := newMinIOTransport(true, minioTransportOptions{tlsConfig: tlsConfig})
+			if err != nil {
+				t.Fatalf("newMinIOTransport() error = %v", err)
+			}
+			if got := minioTransport.TLSClientConfig.MinVersion; got != tt.want {
+				t.Errorf("MinIO transport MinVersion = %#x, want %#x", got, tt.want)
+			}
+			if got := newHTTPTransport(tlsConfig).TLSClientConfig.MinVersion; got != tt.want {
+				t.Errorf("HTTP transport MinVersion = %#x, want %#x", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := newTLSConfig(config.TLSConfig{MinVersion: "1.0"}); err == nil {
+		t.Error("expected error for a TLS minimum version below 1.2")
+	}
+}
+
+func TestNewTLSConfig_CAFileTrustedByTransport(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tlsConfig, err := newTLSConfig(config.TLSConfig{CAFile: writeCAFile(t, ts)})
+	if err != nil {
+		t.Fatalf("newTLSConfig() error = %v", err)
+	}
+
+	if _, err := (&http.Client{Transport: newHTTPTransport(nil)}).Get(ts.URL); err == nil {
+		t.Fatal("request with system roots should fail for the private CA")
+	}
+
+	resp, err := (&http.Client{Transport: newHTTPTransport(tlsConfig)}).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("request with custom CA failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestS3ClientManager_UsesCustomCA(t *testing.T) {
+	ts := httptest.NewTLSServer(newFakeS3Server())
+	defer ts.Close()
+
+	s3Config := config.S3Config{
+		Endpoint:  strings.TrimPrefix(ts.URL, "https://"),
+		AccessKey: "key",
+		SecretKey: "secret",
+		SSL:       true,
+		Region:    "us-east-1",
+	}
+
+	if _, err := NewS3ClientManager().GetOrCreateClient(s3Config); err == nil {
+		t.Fatal("client without the custom CA should fail the TLS handshake")
+	}
+
+	tlsConfig, err := newTLSConfig(config.TLSConfig{CAFile: writeCAFile(t, ts)})
+	if err != nil {
+		t.Fatalf("newTLSConfig() error = %v", err)
+	}
+	manager := NewS3ClientManager()
+	manager.TLSConfig = tlsConfig
+
+	if _, err := manager.GetOrCreateClient(s3Config); err != nil {
+		t.Fatalf("client with the custom CA failed: %v", err)
+	}
+}
+
+func TestNewTLSConfig_InsecureSkipVerify(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	tlsConfig, err := newTLSConfig(config.TLSConfig{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("newTLSConfig() error = %v", err)
+	}
+
+	if !strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), "VERIFICATION IS DISABLED") {
+		t.Errorf("expected a prominent warning, got %q", logs.String())
+	}
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	resp, err := (&http.Client{Transport: newHTTPTransport(tlsConfig)}).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("insecure transport should accept the self-signed certificate: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestHealthMonitor_InsecureTLSDegraded(t *testing.T) {
+	fw := &FileWatcher{fileQueue: make(chan string, 10), queueCapacity: 10, workerCount: 1}
+	hm := NewHealthMonitor(&Worker{FileWatcher: fw, InsecureTLS: true}, "0")
+
+	health := hm.HealthStatus()
+	if health.Status != HealthStatusDegraded {
+		t.Errorf("status = %s, want degraded", health.Status)
+	}
+	if health.Components["tls"].Status != HealthStatusDegraded {
+		t.Errorf("tls component = %+v, want degraded", health.Components["tls"])
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"file-shifter/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation scope of the spans of file deliveries
+const tracerName = "file-shifter/services"
+
+// noopTracer is used while tracing is disabled, its spans are not recorded
+var noopTracer = noop.NewTracerProvider().Tracer(tracerName)
+
+// targetSpanNames names the span of a transfer by target type
+var targetSpanNames = map[string]string{
+	"filesystem": "copyToFilesystem",
+	"s3":         "copyToS3",
+	"ftp":        "copyToFTP",
+	"sftp":       "copyToSFTP",
+	"metadata":   "copyToMetadata",
+	"kafka":      "copyToKafka",
+}
+
+// NewTracerProvider creates a TracerProvider exporting spans to the OTLP/HTTP endpoint of cfg. tlsConfig is
+// used for HTTPS endpoints (nil = system defaults).
+func NewTracerProvider(cfg config.TracingConfig, tlsConfig *tls.Config) (*sdktrace.TracerProvider, error) {
+	var options []otlptracehttp.Option
+	if strings.Contains(cfg.Endpoint, "://") {
+		options = append(options, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	} else {
+		options = append(options, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		if cfg.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+	}
+	if tlsConfig != nil {
+		options = append(options, otlptracehttp.WithTLSClientConfig(tlsConfig))
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP trace exporter: %w", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = config.DefaultTracingServiceName
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	), nil
+}
+
+// tracer returns Tracer, the no-op tracer if tracing is disabled
+func (fh *FileHandler) tracer() trace.Tracer {
+	if fh.Tracer == nil {
+		return noopTracer
+	}
+	return fh.Tracer
+}
+
+// startFileSpan starts the span of a file delivery, the transfers to the targets become its children
+func (fh *FileHandler) startFileSpan(filePath string) (context.Context, trace.Span) {
+	return fh.tracer().Start(context.Background(), "ProcessFile",
+		trace.WithAttributes(attribute.String("file.path", filePath)))
+}
+
+// startTargetSpan starts the span of a transfer as a child of the span of the file being processed
+func (fh *FileHandler) startTargetSpan(filePath, relPath, targetType, targetPath string) trace.Span {
+	name, ok := targetSpanNames[targetType]
+	if !ok {
+		name = "copyToTarget"
+	}
+	_, span := fh.tracer().Start(fh.fileContext(filePath), name, trace.WithAttributes(
+		attribute.String("file.rel_path", relPath),
+		attribute.String("target.type", targetType),
+		attribute.String("target.path", targetPath),
+	))
+	return span
+}
+
+// endSpan ends a span, marking it as failed if err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
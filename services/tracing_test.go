@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"file-shifter/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestTracer returns a FileHandler tracer recording its spans in memory
+func newTestTracer(t *testing.T, fh *FileHandler) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	fh.Tracer = provider.Tracer(tracerName)
+	return exporter
+}
+
+// spanAttribute returns the value of an attribute of a span, "" if it is not set
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) string {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value.AsString()
+		}
+	}
+	return ""
+}
+
+// fileSpans returns the ProcessFile span and its children
+func fileSpans(t *testing.T, spans tracetest.SpanStubs) (tracetest.SpanStub, []tracetest.SpanStub) {
+	t.Helper()
+	parentIndex := slices.IndexFunc(spans, func(span tracetest.SpanStub) bool { return span.Name == "ProcessFile" })
+	if parentIndex < 0 {
+		t.Fatalf("no ProcessFile span in %d spans", len(spans))
+	}
+	parent := spans[parentIndex]
+
+	var children []tracetest.SpanStub
+	for _, span := range spans {
+		if span.Parent.SpanID() == parent.SpanContext.SpanID() {
+			if span.SpanContext.TraceID() != parent.SpanContext.TraceID() {
+				t.Errorf("span %s is not part of the trace of its parent", span.Name)
+			}
+			children = append(children, span)
+		}
+	}
+	return parent, children
+}
+
+func TestFileHandler_ProcessFileSpans(t *testing.T) {
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "report.csv")
+	if err := os.WriteFile(srcFile, []byte("a,b,c\n"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	targetDirs := []string{t.TempDir(), t.TempDir()}
+	fh := NewFileHandler([]config.OutputTarget{
+		{Type: "filesystem", Path: targetDirs[0]},
+		{Type: "filesystem", Path: targetDirs[1]},
+	}, nil)
+	exporter := newTestTracer(t, fh)
+
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+
+	parent, children := fileSpans(t, exporter.GetSpans())
+	if got := spanAttribute(parent, "file.path"); got != srcFile {
+		t.Errorf("file.path = %q, want %q", got, srcFile)
+	}
+	if parent.Status.Code == codes.Error {
+		t.Errorf("ProcessFile span failed: %s", parent.Status.Description)
+	}
+	if len(children) != len(targetDirs) {
+		t.Fatalf("got %d child spans, want one per target (%d)", len(children), len(targetDirs))
+	}
+	for i, child := range children {
+		if child.Name != "copyToFilesystem" {
+			t.Errorf("child span name = %q, want copyToFilesystem", child.Name)
+		}
+		if got := spanAttribute(child, "target.path"); got != targetDirs[i] {
+			t.Errorf("child %d target.path = %q, want %q", i, got, targetDirs[i])
+		}
+		if got := spanAttribute(child, "file.rel_path"); got != "report.csv" {
+			t.Errorf("child %d file.rel_path = %q, want report.csv", i, got)
+		}
+	}
+}
+
+func TestFileHandler_ProcessFileSpansFailedTarget(t *testing.T) {
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "report.csv")
+	if err := os.WriteFile(srcFile, []byte("a,b,c\n"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	// A regular file cannot be the base directory of a target
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatalf("failed to create blocking file: %v", err)
+	}
+
+	fh := NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: blocked}}, nil)
+	exporter := newTestTracer(t, fh)
+
+	if err := fh.ProcessFile(srcFile, inputDir); err == nil {
+		t.Fatal("ProcessFile() should fail for a blocked target")
+	}
+
+	parent, children := fileSpans(t, exporter.GetSpans())
+	if parent.Status.Code != codes.Error {
+		t.Errorf("ProcessFile span status = %v, want Error", parent.Status.Code)
+	}
+	if len(children) != 1 || children[0].Status.Code != codes.Error {
+		t.Fatalf("expected one failed child span, got %+v", children)
+	}
+	if len(children[0].Events) == 0 {
+		t.Error("the error of the failed transfer should be recorded on its span")
+	}
+}
+
+func TestFileHandler_ProcessFileWithoutTracer(t *testing.T) {
+	inputDir := t.TempDir()
+	srcFile := filepath.Join(inputDir, "report.csv")
+	if err := os.WriteFile(srcFile, []byte("a,b,c\n"), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	targetDir := t.TempDir()
+	fh := NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: targetDir}}, nil)
+
+	if err := fh.ProcessFile(srcFile, inputDir); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "report.csv")); err != nil {
+		t.Errorf("file not delivered without tracing: %v", err)
+	}
+	if _, ok := fh.fileContexts.Load(srcFile); ok {
+		t.Error("file context should be removed after processing")
+	}
+}
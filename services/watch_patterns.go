@@ -0,0 +1,106 @@
+package services
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// snapshotDirs are read-only snapshot directories of storage systems (NetApp, ZFS, btrfs/snapper),
+// they are never watched or scanned
+var snapshotDirs = []string{".snapshot", "~snapshot", ".snapshots", ".zfs"}
+
+// watchPatternMatcher decides which subdirectories of the input directory are watched and scanned.
+// An empty matcher accepts every directory except snapshot directories.
+type watchPatternMatcher struct {
+	patterns [][]string
+	// skipHiddenDirs excludes all directories whose name starts with a dot, e.g. .git
+	skipHiddenDirs bool
+}
+
+func newWatchPatternMatcher(patterns []string) watchPatternMatcher {
+	var m watchPatternMatcher
+	for _, pattern := range patterns {
+		m.patterns = append(m.patterns, splitPathSegments(pattern))
+	}
+	return m
+}
+
+// matchesDir reports whether files in the given directory (relative to the input directory) are processed
+func (m watchPatternMatcher) matchesDir(relDir string) bool {
+	segments := splitPathSegments(relDir)
+	if m.excludesDir(segments) {
+		return false
+	}
+	if len(m.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range m.patterns {
+		if matchSegments(pattern, segments, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldWatchDir reports whether a directory must be watched, either because it matches
+// or because a matching directory may still be created below it
+func (m watchPatternMatcher) shouldWatchDir(relDir string) bool {
+	segments := splitPathSegments(relDir)
+	if m.excludesDir(segments) {
+		return false
+	}
+	if len(m.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range m.patterns {
+		if matchSegments(pattern, segments, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludesDir reports whether a directory is below a snapshot or, if configured, a hidden directory
+func (m watchPatternMatcher) excludesDir(segments []string) bool {
+	for _, segment := range segments {
+		if slices.Contains(snapshotDirs, segment) || (m.skipHiddenDirs && strings.HasPrefix(segment, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitPathSegments(path string) []string {
+	path = strings.Trim(filepath.ToSlash(filepath.Clean(path)), "/")
+	if path == "" || path == "." {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// matchSegments matches path segments against pattern segments. With prefix set, a path that
+// is fully consumed before the pattern counts as a match (the directory is an ancestor of a match).
+func matchSegments(pattern, segments []string, prefix bool) bool {
+	if len(segments) == 0 {
+		if prefix {
+			return true
+		}
+		for _, p := range pattern {
+			if p != "**" {
+				return false
+			}
+		}
+		return true
+	}
+	if len(pattern) == 0 {
+		return false
+	}
+	if pattern[0] == "**" {
+		return matchSegments(pattern[1:], segments, prefix) || matchSegments(pattern, segments[1:], prefix)
+	}
+	if ok, err := filepath.Match(pattern[0], segments[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:], prefix)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestWatchPatternMatcher(t *testing.T) {
+	tests := []struct {
+		name          string
+		patterns      []string
+		relDir        string
+		expectMatch   bool
+		expectWatched bool
+	}{
+		{"no patterns accept root", nil, ".", true, true},
+		{"no patterns accept subdir", nil, "other/sub", true, true},
+		{"root is ancestor only", []string{"incoming/**"}, ".", false, true},
+		{"pattern base matches", []string{"incoming/**"}, "incoming", true, true},
+		{"nested dir matches", []string{"incoming/**"}, "incoming/a/b", true, true},
+		{"other dir rejected", []string{"incoming/**"}, "other", false, false},
+		{"single segment wildcard", []string{"data/*/in"}, "data/x/in", true, true},
+		{"single segment wildcard ancestor", []string{"data/*/in"}, "data/x", false, true},
+		{"single segment wildcard mismatch", []string{"data/*/in"}, "data/x/out", false, false},
+		{"exact pattern does not include children", []string{"incoming"}, "incoming/a", false, false},
+		{"multiple patterns", []string{"a/**", "b"}, "b", true, true},
+		{"snapshot dir rejected", nil, ".snapshot/hourly.0", false, false},
+		{"snapshot dir rejected despite pattern", []string{"**"}, "data/.zfs", false, false},
+		{"hidden dir accepted by default", nil, ".git", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newWatchPatternMatcher(tt.patterns)
+			if got := m.matchesDir(tt.relDir); got != tt.expectMatch {
+				t.Errorf("matchesDir(%q) = %v, want %v", tt.relDir, got, tt.expectMatch)
+			}
+			if got := m.shouldWatchDir(tt.relDir); got != tt.expectWatched {
+				t.Errorf("shouldWatchDir(%q) = %v, want %v", tt.relDir, got, tt.expectWatched)
+			}
+		})
+	}
+}
+
+func TestFileWatcher_WatchPatternsRestrictWatchesAndScan(t *testing.T) {
+	inputDir := t.TempDir()
+	for _, dir := range []string{"incoming/a", "other/b"} {
+		if err := os.MkdirAll(filepath.Join(inputDir, dir), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+	}
+	matching := filepath.Join(inputDir, "incoming", "a", "match.txt")
+	ignored := filepath.Join(inputDir, "other", "b", "ignored.txt")
+	for _, file := range []string{matching, ignored} {
+		if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	fileHandler := NewFileHandler([]config.OutputTarget{{Type: "filesystem", Path: t.TempDir()}}, nil)
+	fw, err := NewFileWatcher(inputDir, fileHandler, 1, time.Millisecond, time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("failed to create file watcher: %v", err)
+	}
+	defer fw.watcher.Close()
+	fw.lsofAvailable = false
+	fw.watchPatterns = newWatchPatternMatcher([]string{"incoming/**"})
+
+	if err := fw.addRecursiveWatcher(inputDir); err != nil {
+		t.Fatalf("addRecursiveWatcher failed: %v", err)
+	}
+
+	watched := fw.watcher.WatchList()
+	for _, dir := range []string{inputDir, filepath.Join(inputDir, "incoming"), filepath.Join(inputDir, "incoming", "a")} {
+		if !slices.Contains(watched, dir) {
+			t.Errorf("expected %s to be watched, watch list: %v", dir, watched)
+		}
+	}
+	for _, dir := range []string{filepath.Join(inputDir, "other"), filepath.Join(inputDir, "other", "b")} {
+		if slices.Contains(watched, dir) {
+			t.Errorf("expected %s not to be watched", dir)
+		}
+	}
+
+	fw.processExistingFiles()
+
+	if fw.QueueSize() != 1 {
+		t.Fatalf("expected exactly one queued file, got %d", fw.QueueSize())
+	}
+	if queued := <-fw.fileQueue; queued != matching {
+		t.Errorf("expected %s to be queued, got %s", matching, queued)
+	}
+}
+
+func TestFileWatcher_HiddenDirectories(t *testing.T) {
+	tests := []struct {
+		name           string
+		skipHiddenDirs bool
+		wantWatched    []string
+		wantSkipped    []string
+	}{
+		{
+			name:        "snapshot directories skipped by default",
+			wantWatched: []string{"data", ".git", ".git/objects"},
+			wantSkipped: []string{".snapshot", ".snapshot/hourly.0"},
+		},
+		{
+			name:           "all hidden directories skipped",
+			skipHiddenDirs: true,
+			wantWatched:    []string{"data"},
+			wantSkipped:    []string{".snapshot", ".snapshot/hourly.0", ".git", ".git/objects"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputDir := t.TempDir()
+			for _, dir := range []string{"data", ".git/objects", ".snapshot/hourly.0"} {
+				if err := os.MkdirAll(filepath.Join(inputDir, dir), 0755); err != nil {
+					t.Fatalf("failed to create directory: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(inputDir, dir, "file.txt"), []byte("content"), 0644); err != nil {
+					t.Fatalf("failed to create file: %v", err)
+				}
+			}
+
+			fw, err := NewFileWatcher(inputDir, nil, 1, time.Millisecond, time.Millisecond, 1, 10)
+			if err != nil {
+				t.Fatalf("failed to create file watcher: %v", err)
+			}
+			defer fw.watcher.Close()
+			fw.lsofAvailable = false
+			fw.procAvailable = false
+			fw.watchPatterns.skipHiddenDirs = tt.skipHiddenDirs
+
+			if err := fw.addRecursiveWatcher(inputDir); err != nil {
+				t.Fatalf("addRecursiveWatcher failed: %v", err)
+			}
+
+			watched := fw.watcher.WatchList()
+			for _, dir := range tt.wantWatched {
+				if !slices.Contains(watched, filepath.Join(inputDir, dir)) {
+					t.Errorf("expected %s to be watched, watch list: %v", dir, watched)
+				}
+			}
+			for _, dir := range tt.wantSkipped {
+				if slices.Contains(watched, filepath.Join(inputDir, dir)) {
+					t.Errorf("expected %s not to be watched", dir)
+				}
+			}
+
+			fw.processExistingFiles()
+
+			queued := map[string]bool{}
+			for fw.QueueSize() > 0 {
+				rel, _ := filepath.Rel(inputDir, filepath.Dir(<-fw.fileQueue))
+				queued[filepath.ToSlash(rel)] = true
+			}
+			for _, dir := range tt.wantSkipped {
+				if queued[dir] {
+					t.Errorf("file in skipped directory %s was queued", dir)
+				}
+			}
+			if !queued["data"] {
+				t.Error("file in data was not queued")
+			}
+		})
+	}
+}
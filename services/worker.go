@@ -1,11 +1,15 @@
 package services
 
 import (
+	"context"
 	"file-shifter/config"
 	"fmt"
 	"log/slog"
 	"os"
+	"sync"
 	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 type Worker struct {
@@ -15,6 +19,17 @@ type Worker struct {
 	S3ClientManager *S3ClientManager
 	FileHandler     *FileHandler
 	FileWatcher     *FileWatcher
+	// InsecureTLS is set if certificate verification is disabled, health reports degraded
+	InsecureTLS bool
+	startedAt   time.Time
+	// tracerProvider exports the spans of file deliveries if tracing is enabled
+	tracerProvider *sdktrace.TracerProvider
+	// Once processes the files present at startup and stops the worker instead of watching (see once.go)
+	Once bool
+	// RequireNonEmpty fails a single run that found no file, see OnceError
+	RequireNonEmpty bool
+	onceErr         error
+	stopOnce        sync.Once
 }
 
 func NewWorker(dir string, targets []config.OutputTarget, cfg *config.EnvConfig) (*Worker, error) {
@@ -24,6 +39,7 @@ func NewWorker(dir string, targets []config.OutputTarget, cfg *config.EnvConfig)
 		InputDir:        dir,
 		OutputTargets:   targets,
 		S3ClientManager: NewS3ClientManager(),
+		startedAt:       time.Now(),
 	}
 
 	if dir == "" {
@@ -31,16 +47,105 @@ func NewWorker(dir string, targets []config.OutputTarget, cfg *config.EnvConfig)
 	}
 
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		if err := os.MkdirAll(dir, 0755); err != nil {
+		dirMode, err := cfg.InputOptions.InputDirMode()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, dirMode); err != nil {
 			return nil, fmt.Errorf("error creating input directory %s: %w", dir, err)
 		}
+		// MkdirAll is subject to the umask, the configured mode has to apply exactly
+		if err := os.Chmod(dir, dirMode); err != nil {
+			return nil, fmt.Errorf("error setting mode of input directory %s: %w", dir, err)
+		}
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
 	}
+	w.S3ClientManager.TLSConfig = tlsConfig
+	w.S3ClientManager.MaxIdleConns, w.S3ClientManager.MaxIdleConnsPerHost = s3ConnectionPool(cfg.S3, cfg.WorkerPool.Workers)
+	w.S3ClientManager.HealthCheckRetries = cfg.S3.HealthCheckRetries
+	w.S3ClientManager.SignatureVersion = cfg.S3.SignatureVersion
+	w.InsecureTLS = cfg.TLS.InsecureSkipVerify
+	w.Once = cfg.Once
+	w.RequireNonEmpty = cfg.InputOptions.RequireNonEmpty
 
 	if err := w.validateTargets(targets); err != nil {
 		return nil, fmt.Errorf("target validation failed: %w", err)
 	}
 
 	w.FileHandler = NewFileHandler(targets, w.S3ClientManager)
+	w.FileHandler.RequireMetadataPreservation = cfg.Filesystem.RequireMetadataPreservation
+	w.FileHandler.Fsync = cfg.Filesystem.Fsync
+	w.FileHandler.FsyncDir = cfg.Filesystem.FsyncDir
+	w.FileHandler.MinFreeInodes = uint64(cfg.Filesystem.MinFreeInodes)
+	if w.FileHandler.ForceFileMode, err = cfg.FilesystemForceFileMode(); err != nil {
+		return nil, err
+	}
+	w.FileHandler.TempSuffix = cfg.Filesystem.TempSuffix
+	w.FileHandler.ChecksumDuringCopy = cfg.Filesystem.ChecksumDuringCopy
+	w.FileHandler.MaxInMemoryBytes = int64(cfg.Transfer.MaxInMemoryBytes)
+	w.FileHandler.SourceRemoveRetries = cfg.Transfer.SourceRemoveRetries
+	w.FileHandler.QuickVerify = cfg.Transfer.QuickVerify
+	w.FileHandler.MaxBytesPerSec = cfg.Transfer.MaxBytesPerSec
+	w.FileHandler.Decompress = cfg.InputOptions.Decompress
+	w.FileHandler.DryRun = cfg.DryRun
+	w.FileHandler.FileTimeout = time.Duration(cfg.Transfer.FileTimeout) * time.Millisecond
+	w.FileHandler.DeadLetterDir = cfg.Transfer.DeadLetterDir
+	w.FileHandler.DeleteDelay = time.Duration(cfg.InputOptions.DeleteDelay) * time.Millisecond
+	w.FileHandler.CompletionMarkerSuffix = cfg.InputOptions.CompletionMarkerSuffix
+	w.FileHandler.PropagateDeletes = cfg.Mirror.PropagateDeletes
+	w.FileHandler.Mode = cfg.Mode
+	// Copy mode keeps the originals, they must not be delivered again by every rescan
+	if cfg.Mode == config.ModeCopy {
+		copiedFiles, err := LoadCopiedFiles(cfg.CopyStateFile)
+		if err != nil {
+			return nil, err
+		}
+		w.FileHandler.CopiedFiles = copiedFiles
+	}
+	if cfg.InputOptions.HashCache || cfg.Mode == config.ModeCopy {
+		w.FileHandler.HashCache = NewHashCache()
+	}
+	if cfg.DryRun {
+		slog.Warn("Dry run mode - files are not transferred and stay in the input directory")
+	}
+	w.FileHandler.TLSConfig = tlsConfig
+	w.FileHandler.AllowedHosts = cfg.Targets.AllowedHosts
+
+	if cfg.Tracing.Enabled {
+		provider, err := NewTracerProvider(cfg.Tracing, tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tracing configuration: %w", err)
+		}
+		w.tracerProvider = provider
+		w.FileHandler.Tracer = provider.Tracer(tracerName)
+		slog.Info("Tracing enabled", "endpoint", cfg.Tracing.Endpoint)
+	}
+
+	// Temp files of copies interrupted by a crash, swept in the background so large target trees do not delay startup
+	go func(maxAge time.Duration) {
+		if removed := w.FileHandler.CleanupStaleTempFiles(maxAge); removed > 0 {
+			slog.Info("Stale temp files removed from filesystem targets", "count", removed)
+		}
+	}(cfg.FilesystemStaleTempAge())
+	if err := w.FileHandler.PrecreateTargetDirs(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Manifest.Path != "" {
+		manifest, err := NewDeliveryManifest(cfg.Manifest.Path)
+		if err != nil {
+			return nil, err
+		}
+		w.FileHandler.Manifest = manifest
+		slog.Info("Delivery manifest enabled", "path", cfg.Manifest.Path)
+	}
+	if cfg.Manifest.SessionHash {
+		w.FileHandler.SessionHash = NewSessionHash()
+	}
 
 	maxRetries := cfg.FileStability.MaxRetries
 	checkInterval := time.Duration(cfg.FileStability.CheckInterval) * time.Millisecond
@@ -50,33 +155,99 @@ func NewWorker(dir string, targets []config.OutputTarget, cfg *config.EnvConfig)
 	if err != nil {
 		return nil, fmt.Errorf("error initializing file watcher: %w", err)
 	}
+	fileWatcher.watchPatterns = newWatchPatternMatcher(cfg.InputOptions.WatchPatterns)
+	fileWatcher.watchPatterns.skipHiddenDirs = cfg.InputOptions.SkipHiddenDirs
+	fileWatcher.initialScanWorkers = cfg.WorkerPool.InitialScanWorkers
+	fileWatcher.subdirLimit = newSubdirLimiter(cfg.WorkerPool.MaxPerSubdir)
+	fileWatcher.queueBytes = newQueueByteLimit(int64(cfg.WorkerPool.MaxQueueBytes))
+	fileWatcher.filesLimiter = newRateLimiter(cfg.Transfer.MaxFilesPerSec)
+	fileWatcher.scanOrder = cfg.InputOptions.Order
+	fileWatcher.sizeOrder = cfg.InputOptions.SizeOrder
+	fileWatcher.renameComplete = cfg.InputOptions.RenameComplete
+	fileWatcher.renameCompletePatterns = cfg.InputOptions.RenameCompletePatterns
+	fileWatcher.completionMarkerSuffix = cfg.InputOptions.CompletionMarkerSuffix
+	fileWatcher.pauseLockFile = cfg.InputOptions.PauseLockFile
+	fileWatcher.typeChange = cfg.InputOptions.TypeChange
+	fileWatcher.allowContentTypes = cfg.InputOptions.AllowContentTypes
+	fileWatcher.successMarker = cfg.Transfer.SuccessMarker
+	fileWatcher.successMarkerQuiet = time.Duration(cfg.Transfer.SuccessMarkerQuiet) * time.Millisecond
+	fileWatcher.stabilityMode = cfg.FileStability.Mode
+	fileWatcher.relevantProcesses = cfg.FileStability.RelevantProcesses
+	if cfg.InputOptions.WatchRemoveGrace > 0 {
+		fileWatcher.watchRemoveGrace = time.Duration(cfg.InputOptions.WatchRemoveGrace) * time.Millisecond
+	}
 	w.FileWatcher = fileWatcher
 
 	return w, nil
 }
 
+// s3ConnectionPool returns the configured idle connection limits of the S3 clients. Unset limits follow the
+// minio-go defaults but grow with the worker count, so every worker can keep a connection per host.
+func s3ConnectionPool(s3 config.S3Defaults, workers int) (maxIdleConns, maxIdleConnsPerHost int) {
+	const defaultMaxIdleConns, defaultMaxIdleConnsPerHost = 256, 16
+
+	maxIdleConnsPerHost = s3.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = max(defaultMaxIdleConnsPerHost, workers)
+	}
+	maxIdleConns = s3.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = max(defaultMaxIdleConns, maxIdleConnsPerHost)
+	}
+	return maxIdleConns, maxIdleConnsPerHost
+}
+
 func (w *Worker) Start() {
-	slog.Info("Worker started - process incoming files")
+	if w.Once {
+		slog.Info("Worker started - process the files present in the input directory once")
+		go w.runOnce()
+	} else {
+		slog.Info("Worker started - process incoming files")
 
-	// Start file watcher in separate goroutine
-	go func() {
-		if err := w.FileWatcher.Start(); err != nil {
-			slog.Error("File-Watcher Fehler", "err", err)
-		}
-	}()
+		// Start file watcher in separate goroutine
+		go func() {
+			if err := w.FileWatcher.Start(); err != nil {
+				slog.Error("File-Watcher Fehler", "err", err)
+			}
+		}()
+	}
 
 	<-w.stopChan
 	slog.Info("Worker gestoppt")
 }
 
+// Stop shuts the worker down. It may be called more than once, e.g. by a finished single run and a signal.
 func (w *Worker) Stop() {
-	if w.FileWatcher != nil {
-		w.FileWatcher.Stop()
-	}
-	if w.S3ClientManager != nil {
-		w.S3ClientManager.Close()
-	}
-	w.stopChan <- true
+	w.stopOnce.Do(func() {
+		if w.FileWatcher != nil {
+			w.FileWatcher.Stop()
+		}
+		if w.FileHandler != nil {
+			// Batched files are uploaded now instead of waiting for the interval
+			w.FileHandler.FlushBatches(true)
+			w.FileHandler.FlushPendingDeletions()
+			w.FileHandler.CloseKafkaProducers()
+			w.FileHandler.CloseMetadataClient()
+		}
+		w.logSummary()
+		if w.tracerProvider != nil {
+			// Spans still buffered are exported before exiting
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := w.tracerProvider.Shutdown(ctx); err != nil {
+				slog.Error("Error exporting the remaining trace spans", "error", err)
+			}
+			cancel()
+		}
+		if w.S3ClientManager != nil {
+			w.S3ClientManager.Close()
+		}
+		if w.FileHandler != nil && w.FileHandler.Manifest != nil {
+			if err := w.FileHandler.Manifest.Close(); err != nil {
+				slog.Error("Error closing delivery manifest", "error", err)
+			}
+		}
+		w.stopChan <- true
+	})
 }
 
 // validateTargets validates the target configurations and creates S3 clients
@@ -103,14 +274,26 @@ func (w *Worker) validateSingleTarget(target config.OutputTarget) error {
 		return w.validateS3Target(target)
 	case "ftp", "sftp":
 		return w.validateFTPTarget(target)
-	case "filesystem":
+	case "filesystem", "metadata":
 		return w.validateFilesystemTarget(target)
+	case "kafka":
+		return w.validateKafkaTarget(target)
 	default:
 		slog.Error("Unknown output type in the environment file", "type", target.Type)
 		return fmt.Errorf("unknown output type: %s", target.Type)
 	}
 }
 
+// validateKafkaTarget validates the broker list and topic, brokers are only contacted on the first publish
+func (w *Worker) validateKafkaTarget(target config.OutputTarget) error {
+	kafkaConfig := target.GetKafkaConfig()
+	if len(kafkaConfig.Brokers) == 0 || kafkaConfig.Topic == "" {
+		slog.Error("Invalid Kafka configuration for target", "path", target.Path)
+		return fmt.Errorf("invalid Kafka configuration for target: %s", target.Path)
+	}
+	return nil
+}
+
 // validateS3Target validates S3-specific configuration
 func (w *Worker) validateS3Target(target config.OutputTarget) error {
 	s3Config := target.GetS3Config()
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// processFileRecovered runs ProcessFile and turns a panic into an error. Otherwise the worker
+// goroutine would die and the pool shrink silently until the queue is no longer served.
+func (fw *FileWatcher) processFileRecovered(filePath string) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			fw.fileHandler.counters.recordPanic()
+			slog.Error("Panic while processing file - worker continues with the next file",
+				"file", filePath, "panic", recovered, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic while processing file: %v", recovered)
+		}
+	}()
+	return fw.fileHandler.ProcessFile(filePath, fw.inputDir)
+}
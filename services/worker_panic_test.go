@@ -0,0 +1,77 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"file-shifter/config"
+)
+
+func TestFileWatcher_WorkerRecoversFromPanic(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	panicFile := filepath.Join(inputDir, "panic.txt")
+
+	original := openSourceFile
+	openSourceFile = func(name string) (*os.File, error) {
+		if name == panicFile {
+			panic("injected handler failure")
+		}
+		return original(name)
+	}
+	defer func() { openSourceFile = original }()
+
+	fileHandler := NewFileHandler([]config.OutputTarget{{Path: outputDir, Type: "filesystem"}}, nil)
+	// A single worker: the following files are only delivered if it survives the panic
+	fw, err := NewFileWatcher(inputDir, fileHandler, 5, 10*time.Millisecond, 20*time.Millisecond, 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FileWatcher: %v", err)
+	}
+	fw.lsofAvailable = false
+
+	go func() {
+		if err := fw.Start(); err != nil {
+			t.Logf("FileWatcher stopped with error: %v", err)
+		}
+	}()
+	defer fw.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(panicFile, []byte("panic"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	for _, name := range []string{"first.txt", "second.txt"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("failed to create input file: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		_, firstErr := os.Stat(filepath.Join(outputDir, "first.txt"))
+		_, secondErr := os.Stat(filepath.Join(outputDir, "second.txt"))
+		if firstErr == nil && secondErr == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("files after the panic were not delivered - the worker did not survive")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := fileHandler.counters.panics.Load(); got != 1 {
+		t.Errorf("panics = %d, want 1", got)
+	}
+	if got := fileHandler.counters.failed.Load(); got != 1 {
+		t.Errorf("failed = %d, want 1", got)
+	}
+	if _, err := os.Stat(panicFile); err != nil {
+		t.Errorf("the file that caused the panic must stay in the input directory: %v", err)
+	}
+	if got := fw.activeWorkers.Load(); got != 1 {
+		t.Errorf("active workers = %d, want 1", got)
+	}
+}
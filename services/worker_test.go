@@ -1,9 +1,15 @@
 package services
 
 import (
+	"bytes"
+	"errors"
 	"file-shifter/config"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -431,8 +437,8 @@ func TestWorker_ComponentInitialization(t *testing.T) {
 	if worker.FileHandler == nil {
 		t.Error("FileHandler should not be nil")
 	} else {
-		if len(worker.FileHandler.OutputTargets) != len(targets) {
-			t.Errorf("FileHandler should have %d targets, got %d", len(targets), len(worker.FileHandler.OutputTargets))
+		if len(worker.FileHandler.OutputTargets()) != len(targets) {
+			t.Errorf("FileHandler should have %d targets, got %d", len(targets), len(worker.FileHandler.OutputTargets()))
 		}
 	}
 
@@ -760,3 +766,159 @@ func TestWorker_validateSingleTarget(t *testing.T) {
 		})
 	}
 }
+
+func TestNewWorker_InputDirMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permission bits are not supported on Windows")
+	}
+
+	tests := []struct {
+		name     string
+		dirMode  string
+		expected os.FileMode
+	}{
+		{"default", "", 0755},
+		{"restricted", "0700", 0700},
+		{"group writable despite umask", "0775", 0775},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputDir := filepath.Join(t.TempDir(), "input")
+			cfg := createDefaultConfig()
+			cfg.InputOptions.DirMode = tt.dirMode
+
+			if _, err := NewWorker(inputDir, createFilesystemTargets(t.TempDir()), cfg); err != nil {
+				t.Fatalf("NewWorker failed: %v", err)
+			}
+
+			info, err := os.Stat(inputDir)
+			if err != nil {
+				t.Fatalf("input directory not created: %v", err)
+			}
+			if info.Mode().Perm() != tt.expected {
+				t.Errorf("input directory mode = %o, want %o", info.Mode().Perm(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewWorker_InvalidInputDirMode(t *testing.T) {
+	cfg := createDefaultConfig()
+	cfg.InputOptions.DirMode = "rwx"
+
+	if _, err := NewWorker(filepath.Join(t.TempDir(), "input"), createFilesystemTargets(t.TempDir()), cfg); err == nil {
+		t.Error("expected an error for an invalid dir mode")
+	}
+}
+
+func TestNewWorker_S3ConnectionPool(t *testing.T) {
+	tests := []struct {
+		name                string
+		workers             int
+		maxIdleConns        int
+		maxIdleConnsPerHost int
+		wantMaxIdle         int
+		wantPerHost         int
+	}{
+		{name: "defaults for few workers", workers: 4, wantMaxIdle: 256, wantPerHost: 16},
+		{name: "scaled with workers", workers: 64, wantMaxIdle: 256, wantPerHost: 64},
+		{name: "scaled beyond max idle", workers: 300, wantMaxIdle: 300, wantPerHost: 300},
+		{name: "configured", workers: 64, maxIdleConns: 50, maxIdleConnsPerHost: 10, wantMaxIdle: 50, wantPerHost: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := createDefaultConfig()
+			cfg.WorkerPool.Workers = tt.workers
+			cfg.S3.MaxIdleConns = tt.maxIdleConns
+			cfg.S3.MaxIdleConnsPerHost = tt.maxIdleConnsPerHost
+
+			w, err := NewWorker(t.TempDir(), createFilesystemTargets(t.TempDir()), cfg)
+			if err != nil {
+				t.Fatalf("NewWorker failed: %v", err)
+			}
+			if w.S3ClientManager.MaxIdleConns != tt.wantMaxIdle || w.S3ClientManager.MaxIdleConnsPerHost != tt.wantPerHost {
+				t.Errorf("pool = %d/%d, want %d/%d", w.S3ClientManager.MaxIdleConns, w.S3ClientManager.MaxIdleConnsPerHost,
+					tt.wantMaxIdle, tt.wantPerHost)
+			}
+		})
+	}
+}
+
+func TestNewWorker_PrecreateDirs(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	targets := createFilesystemTargets(outputDir)
+	targets[0].PrecreateDirs = []string{"incoming/customer", "archive"}
+
+	if _, err := NewWorker(inputDir, targets, createDefaultConfig()); err != nil {
+		t.Fatalf("NewWorker failed: %v", err)
+	}
+
+	for _, dir := range targets[0].PrecreateDirs {
+		info, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(dir)))
+		if err != nil || !info.IsDir() {
+			t.Errorf("expected directory %s to exist after startup (error %v)", dir, err)
+		}
+	}
+}
+
+func TestWorker_StopLogsSummary(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	contents := []string{"first", "second file", "third file content"}
+	var totalBytes int64
+	for i, content := range contents {
+		if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("file%d.txt", i)), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+		totalBytes += int64(len(content))
+	}
+
+	cfg := createDefaultConfig()
+	cfg.FileStability.CheckInterval = 10
+	cfg.FileStability.StabilityPeriod = 20
+	worker, err := NewWorker(inputDir, createFilesystemTargets(outputDir), cfg)
+	if err != nil {
+		t.Fatalf("NewWorker failed: %v", err)
+	}
+	worker.FileWatcher.lsofAvailable = false
+	worker.FileWatcher.procAvailable = false
+
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	stopped := make(chan struct{})
+	go func() {
+		worker.Start()
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for worker.Summary().Processed < int64(len(contents)) {
+		if time.Now().After(deadline) {
+			t.Fatalf("files not processed in time, summary %+v", worker.Summary())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	// A failed delivery is counted without its bytes
+	worker.FileHandler.recordDelivery(nil, "broken.txt", "", 100, errors.New("target unavailable"))
+
+	worker.Stop()
+	<-stopped
+
+	summary := worker.Summary()
+	if summary.Processed != 3 || summary.Failed != 1 || summary.Bytes != totalBytes {
+		t.Errorf("summary = %+v, want 3 processed, 1 failed, %d bytes", summary, totalBytes)
+	}
+	if summary.Uptime <= 0 {
+		t.Errorf("uptime = %v, want > 0", summary.Uptime)
+	}
+	want := fmt.Sprintf("processed=3 failed=1 bytes=%d", totalBytes)
+	if !strings.Contains(logs.String(), "Shutdown summary") || !strings.Contains(logs.String(), want) {
+		t.Errorf("shutdown summary with %q not logged:\n%s", want, logs.String())
+	}
+}